@@ -0,0 +1,71 @@
+package sabuhp
+
+import (
+	"sync"
+	"time"
+)
+
+var _ MessageBus = (*AliasBus)(nil)
+
+// AliasBus wraps a MessageBus, rewriting aliased topics to their new
+// names on Send and Listen, so producers and consumers of a legacy topic
+// keep working while the topic is renamed.
+//
+// A message sent to an alias reaches listeners of the new topic and
+// listeners of an alias receive messages sent to the new topic.
+type AliasBus struct {
+	bus MessageBus
+
+	mu      sync.RWMutex
+	aliases map[string]string
+}
+
+// NewAliasBus returns a new AliasBus with the aliases mapping old topic
+// names to new ones.
+func NewAliasBus(bus MessageBus, aliases map[string]string) *AliasBus {
+	var table = make(map[string]string, len(aliases))
+	for old, renamed := range aliases {
+		table[old] = renamed
+	}
+	return &AliasBus{bus: bus, aliases: table}
+}
+
+// Alias maps the old topic to the new topic, it only applies to later
+// calls of Send and Listen.
+func (a *AliasBus) Alias(old string, renamed string) {
+	a.mu.Lock()
+	a.aliases[old] = renamed
+	a.mu.Unlock()
+}
+
+// Resolve returns the topic the aliased topic maps to, other topics are
+// returned as is.
+func (a *AliasBus) Resolve(topic string) string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if renamed, ok := a.aliases[topic]; ok {
+		return renamed
+	}
+	return topic
+}
+
+func (a *AliasBus) resolveAll(data []Message) []Message {
+	var resolved = make([]Message, 0, len(data))
+	for _, msg := range data {
+		msg.Topic = NewTopic(a.Resolve(msg.Topic.T), msg.Topic.R)
+		resolved = append(resolved, msg)
+	}
+	return resolved
+}
+
+func (a *AliasBus) Send(data ...Message) {
+	a.bus.Send(a.resolveAll(data)...)
+}
+
+func (a *AliasBus) SendForReply(tm time.Duration, fromTopic Topic, replyGroup string, data ...Message) *ReplyFuture {
+	return a.bus.SendForReply(tm, fromTopic, replyGroup, a.resolveAll(data)...)
+}
+
+func (a *AliasBus) Listen(topic string, grp string, handler TransportResponse) Channel {
+	return a.bus.Listen(a.Resolve(topic), grp, handler)
+}
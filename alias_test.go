@@ -0,0 +1,55 @@
+package sabuhp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAliasBus(t *testing.T) {
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var logger GoLogImpl
+	var relay = NewPbRelay(controlCtx, logger)
+
+	var mb BusBuilder
+	mb.ListenFunc = func(topic string, grp string, handler TransportResponse) Channel {
+		return relay.Group(topic, grp).Listen(handler)
+	}
+	mb.SendFunc = func(data ...Message) {
+		for _, msg := range data {
+			_ = relay.Handle(controlCtx, msg, Transport{Bus: &mb})
+		}
+	}
+
+	var aliased = NewAliasBus(&mb, map[string]string{"orders": "shop.orders"})
+
+	var received = make(chan string, 4)
+	var listen = func(topic string, name string) Channel {
+		return aliased.Listen(topic, name, TransportResponseFunc(func(ctx context.Context, message Message, transport Transport) MessageErr {
+			received <- name + ":" + message.Topic.String()
+			return nil
+		}))
+	}
+
+	var legacy = listen("orders", "legacy")
+	require.NoError(t, legacy.Err())
+	defer legacy.Close()
+
+	var current = listen("shop.orders", "current")
+	require.NoError(t, current.Err())
+	defer current.Close()
+
+	// sent to the alias, received on the new topic.
+	aliased.Send(BasicMsg(T("orders"), "order", "me"))
+	require.ElementsMatch(t, []string{"legacy:shop.orders", "current:shop.orders"}, []string{<-received, <-received})
+
+	// sent to the new topic, received by the alias listener.
+	aliased.Send(BasicMsg(T("shop.orders"), "order", "me"))
+	require.ElementsMatch(t, []string{"legacy:shop.orders", "current:shop.orders"}, []string{<-received, <-received})
+
+	require.Equal(t, "shop.orders", aliased.Resolve("orders"))
+	require.Equal(t, "payments", aliased.Resolve("payments"))
+}
@@ -0,0 +1,72 @@
+package sabuhp
+
+import (
+	"context"
+	"sync"
+)
+
+// BridgedMetadata is the metadata key a Bridge marks forwarded messages
+// with, the Bridge never forwards a message carrying it again.
+const BridgedMetadata = "x-bridged"
+
+// Bridge forwards messages between a remote bus, e.g the redis bus, and
+// a local bus, e.g a MemoryBus, making topics of the local bus reachable
+// by remote processes and the other way round.
+//
+// Messages forwarded by a Bridge are marked with BridgedMetadata so a
+// topic bridged in both directions does not bounce back and forth.
+type Bridge struct {
+	remote MessageBus
+	local  MessageBus
+
+	mu       sync.Mutex
+	channels []Channel
+}
+
+// NewBridge returns a new Bridge between the remote and local bus.
+func NewBridge(remote MessageBus, local MessageBus) *Bridge {
+	return &Bridge{remote: remote, local: local}
+}
+
+// Inbound listens on the topic of the remote bus, delivering every
+// message it receives to the local bus.
+func (b *Bridge) Inbound(topic string, grp string) Channel {
+	return b.forward(b.remote, b.local, topic, grp)
+}
+
+// Outbound listens on the topic of the local bus, sending every message
+// it receives to the remote bus.
+func (b *Bridge) Outbound(topic string, grp string) Channel {
+	return b.forward(b.local, b.remote, topic, grp)
+}
+
+// Close closes all channels opened by the Bridge.
+func (b *Bridge) Close() {
+	b.mu.Lock()
+	var channels = b.channels
+	b.channels = nil
+	b.mu.Unlock()
+
+	for _, channel := range channels {
+		channel.Close()
+	}
+}
+
+func (b *Bridge) forward(from MessageBus, to MessageBus, topic string, grp string) Channel {
+	var channel = from.Listen(topic, grp, TransportResponseFunc(func(ctx context.Context, message Message, transport Transport) MessageErr {
+		if _, bridged := message.Metadata[BridgedMetadata]; bridged {
+			return nil
+		}
+
+		var forwarded = message.Copy()
+		forwarded.Future = nil
+		forwarded.Metadata[BridgedMetadata] = "true"
+		to.Send(forwarded)
+		return nil
+	}))
+
+	b.mu.Lock()
+	b.channels = append(b.channels, channel)
+	b.mu.Unlock()
+	return channel
+}
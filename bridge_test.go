@@ -0,0 +1,78 @@
+package sabuhp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func relayBus(ctx context.Context) *BusBuilder {
+	var logger GoLogImpl
+	var relay = NewPbRelay(ctx, logger)
+
+	var mb BusBuilder
+	mb.ListenFunc = func(topic string, grp string, handler TransportResponse) Channel {
+		return relay.Group(topic, grp).Listen(handler)
+	}
+	mb.SendFunc = func(data ...Message) {
+		for _, msg := range data {
+			_ = relay.Handle(ctx, msg, Transport{Bus: &mb})
+		}
+	}
+	return &mb
+}
+
+func TestBridge(t *testing.T) {
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var remote = relayBus(controlCtx)
+	var local = relayBus(controlCtx)
+
+	var bridge = NewBridge(remote, local)
+	defer bridge.Close()
+
+	require.NoError(t, bridge.Inbound("orders", "bridge").Err())
+	require.NoError(t, bridge.Outbound("orders", "bridge").Err())
+	require.NoError(t, bridge.Outbound("receipts", "bridge").Err())
+
+	var delivered = make(chan Message, 2)
+	local.Listen("orders", "mailbox", TransportResponseFunc(func(ctx context.Context, message Message, transport Transport) MessageErr {
+		delivered <- message
+		return nil
+	}))
+
+	var forwarded = make(chan Message, 2)
+	remote.Listen("receipts", "*", TransportResponseFunc(func(ctx context.Context, message Message, transport Transport) MessageErr {
+		forwarded <- message
+		return nil
+	}))
+
+	var remoteOrders = make(chan Message, 2)
+	remote.Listen("orders", "*", TransportResponseFunc(func(ctx context.Context, message Message, transport Transport) MessageErr {
+		remoteOrders <- message
+		return nil
+	}))
+
+	// a send on the remote bus is delivered to the local mailbox.
+	remote.Send(BasicMsg(T("orders"), "order-1", "me"))
+	var order = <-delivered
+	require.Equal(t, "order-1", string(order.Bytes))
+	require.Equal(t, "true", order.Metadata[BridgedMetadata])
+
+	// the bridged order was not forwarded back to the remote bus.
+	<-remoteOrders
+	select {
+	case <-remoteOrders:
+		require.Fail(t, "bridged message should not bounce back to the remote bus")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// local outputs reach the remote bus.
+	local.Send(BasicMsg(T("receipts"), "receipt-1", "mailbox"))
+	var receipt = <-forwarded
+	require.Equal(t, "receipt-1", string(receipt.Bytes))
+	require.Len(t, delivered, 0)
+}
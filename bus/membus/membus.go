@@ -0,0 +1,189 @@
+package membus
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/influx6/npkg/njson"
+	"github.com/influx6/npkg/nxid"
+
+	"github.com/ewe-studios/sabuhp"
+)
+
+var _ sabuhp.MessageBus = (*MemoryBus)(nil)
+
+var _ sabuhp.Lifecycle = (*MemoryBus)(nil)
+
+// MemoryBus implements sabuhp.MessageBus for listeners within a single
+// process.
+//
+// Messages are delivered as is, without passing through a sabuhp.Codec,
+// hence they keep their Future and Parts which codecs drop for transport
+// across processes.
+//
+// Delivery is synchronous, Send returns once all listeners of the topic
+// handled the message, so a handler must not Send to its own topic and
+// group directly.
+type MemoryBus struct {
+	ctx      context.Context
+	canceler context.CancelFunc
+	logger   sabuhp.Logger
+	relay    *sabuhp.PbRelay
+	stopper  sync.Once
+
+	delivered int64
+	dropped   int64
+	queued    int64
+}
+
+// Metrics is a snapshot of the delivery counters of a MemoryBus.
+type Metrics struct {
+	// Delivered is the number of messages delivered to the listeners of
+	// their topic, whether the listeners handled them successfully or not.
+	Delivered int64
+
+	// Dropped is the number of messages which could not be delivered,
+	// e.g. as their topic had no listeners.
+	Dropped int64
+
+	// Queued is the number of messages being delivered.
+	Queued int64
+
+	// Subscribers is the number of listeners across all topics.
+	Subscribers int
+}
+
+// Metrics returns a snapshot of the delivery counters of the bus.
+func (m *MemoryBus) Metrics() Metrics {
+	var subscribers int
+	for _, count := range m.relay.Subscribers() {
+		subscribers += count
+	}
+	return Metrics{
+		Delivered:   atomic.LoadInt64(&m.delivered),
+		Dropped:     atomic.LoadInt64(&m.dropped),
+		Queued:      atomic.LoadInt64(&m.queued),
+		Subscribers: subscribers,
+	}
+}
+
+func NewMemoryBus(ctx context.Context, logger sabuhp.Logger) *MemoryBus {
+	var newCtx, canceler = context.WithCancel(ctx)
+	return &MemoryBus{
+		ctx:      newCtx,
+		canceler: canceler,
+		logger:   logger,
+		relay:    sabuhp.NewPbRelay(newCtx, logger),
+	}
+}
+
+// Start does nothing as the bus delivers messages once created, it
+// returns sabuhp.ErrBusClosed once the bus was stopped.
+func (m *MemoryBus) Start() error {
+	if m.ctx.Err() != nil {
+		return sabuhp.ErrBusClosed
+	}
+	return nil
+}
+
+// Stop closes all topic groups of the bus, waiting till they are closed.
+// It returns sabuhp.ErrAlreadyStopped if the bus was already stopped.
+func (m *MemoryBus) Stop() error {
+	var stopErr = sabuhp.ErrAlreadyStopped
+	m.stopper.Do(func() {
+		stopErr = nil
+		m.canceler()
+		m.relay.Wait()
+	})
+	return stopErr
+}
+
+// Wait blocks till all topic groups of the bus are closed.
+func (m *MemoryBus) Wait() {
+	m.relay.Wait()
+}
+
+func (m *MemoryBus) Listen(topic string, grp string, handler sabuhp.TransportResponse) sabuhp.Channel {
+	return m.relay.Group(topic, grp).Listen(handler)
+}
+
+func (m *MemoryBus) Send(data ...sabuhp.Message) {
+	for _, msg := range data {
+		atomic.AddInt64(&m.queued, 1)
+		var handleErr = m.relay.Handle(m.ctx, msg, sabuhp.Transport{Bus: m})
+		atomic.AddInt64(&m.queued, -1)
+
+		if handleErr == nil {
+			atomic.AddInt64(&m.delivered, 1)
+			continue
+		}
+
+		atomic.AddInt64(&m.dropped, 1)
+		njson.Log(m.logger).New().
+			LError().
+			Message("failed to deliver message").
+			String("topic", msg.Topic.String()).
+			Error("error", handleErr).
+			End()
+
+		if msg.Future != nil {
+			msg.Future.WithError(handleErr)
+		}
+	}
+}
+
+// SendForReply sends the messages and resolves the returned future with
+// the first reply on the reply topic of fromTopic.
+//
+// Requests without a CorrelationId are sent with their Id as one, and
+// replies carrying the CorrelationId of another request are ignored, so
+// replies to concurrent requests on a topic never cross as long as
+// responders copy the CorrelationId of the request onto the reply, as
+// sabuhp.NewReply does.
+func (m *MemoryBus) SendForReply(tm time.Duration, fromTopic sabuhp.Topic, replyGroup string, data ...sabuhp.Message) *sabuhp.ReplyFuture {
+	var ft = sabuhp.NewReplyFuture()
+
+	var requests = make([]sabuhp.Message, 0, len(data))
+	var correlationIds = make(map[nxid.ID]struct{}, len(data))
+	for _, msg := range data {
+		if msg.CorrelationId.IsNil() {
+			msg.CorrelationId = msg.Id
+		}
+		correlationIds[msg.CorrelationId] = struct{}{}
+		requests = append(requests, msg)
+	}
+
+	var replyChannel = m.Listen(fromTopic.ReplyTopic().String(), replyGroup, sabuhp.TransportResponseFunc(func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+		if !message.CorrelationId.IsNil() {
+			if _, requested := correlationIds[message.CorrelationId]; !requested {
+				return nil
+			}
+		}
+
+		ft.WithReply(message)
+		return nil
+	}))
+	if listenErr := replyChannel.Err(); listenErr != nil {
+		ft.WithError(listenErr)
+		return ft
+	}
+
+	go func() {
+		defer replyChannel.Close()
+
+		select {
+		case <-ft.Done():
+		case <-time.After(tm):
+		case <-m.ctx.Done():
+			ft.WithError(sabuhp.ErrBusClosed)
+		}
+
+		// does nothing if a reply was received.
+		ft.WithError(sabuhp.ErrReplyTimeout)
+	}()
+
+	m.Send(requests...)
+	return ft
+}
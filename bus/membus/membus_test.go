@@ -0,0 +1,207 @@
+package membus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/influx6/npkg/nthen"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ewe-studios/sabuhp"
+	"github.com/ewe-studios/sabuhp/testingutils"
+)
+
+func TestMemoryBus_KeepsFuture(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var bus = NewMemoryBus(ctx, logger)
+
+	var received = make(chan sabuhp.Message, 1)
+	var channel = bus.Listen("hello", "*", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			received <- message
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	var msg = sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("world"))
+	msg.Future = nthen.NewFuture()
+	msg.Parts = []sabuhp.Message{sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("part"))}
+	bus.Send(msg)
+
+	var receivedMsg = <-received
+	require.Equal(t, msg.Id, receivedMsg.Id)
+	require.True(t, msg.Future == receivedMsg.Future)
+	require.Len(t, receivedMsg.Parts, 1)
+
+	msg.Future.Wait()
+	require.NoError(t, msg.Future.Err())
+}
+
+func TestMemoryBus_SendForReply(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var bus = NewMemoryBus(ctx, logger)
+
+	var channel = bus.Listen("hello", "*", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			var reply = message.ReplyWithTopic(message.Topic.ReplyTopic())
+			reply.WithPayload([]byte("yay!"))
+			transport.Bus.Send(reply)
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	var msg = sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("world"))
+	var reply, replyErr = bus.SendForReply(time.Second, msg.Topic, "*", msg).Get()
+	require.NoError(t, replyErr)
+	require.Equal(t, "yay!", string(reply.Bytes))
+}
+
+func TestMemoryBus_SendForReply_Timeout(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var bus = NewMemoryBus(ctx, logger)
+
+	// no responder listens on the topic, so no reply ever arrives.
+	var msg = sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("world"))
+	var _, replyErr = bus.SendForReply(50*time.Millisecond, msg.Topic, "*", msg).Get()
+	require.Equal(t, sabuhp.ErrReplyTimeout, replyErr)
+}
+
+func TestMemoryBus_SendForReply_ConcurrentRequests(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var bus = NewMemoryBus(ctx, logger)
+
+	var requests = make(chan sabuhp.Message, 2)
+	var channel = bus.Listen("hello", "*", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			requests <- message
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	var topic = sabuhp.T("hello")
+	var first = bus.SendForReply(time.Second, topic, "*", sabuhp.NewMessage(topic, "me", []byte("first")))
+	var second = bus.SendForReply(time.Second, topic, "*", sabuhp.NewMessage(topic, "me", []byte("second")))
+
+	var firstRequest, secondRequest = <-requests, <-requests
+	require.Equal(t, firstRequest.Id, firstRequest.CorrelationId)
+
+	// both requests listen on the reply topic, replied to in reverse order.
+	bus.Send(*sabuhp.NewReply(&secondRequest, secondRequest.Bytes))
+	bus.Send(*sabuhp.NewReply(&firstRequest, firstRequest.Bytes))
+
+	var firstReply, firstErr = first.Get()
+	require.NoError(t, firstErr)
+	require.Equal(t, "first", string(firstReply.Bytes))
+
+	var secondReply, secondErr = second.Get()
+	require.NoError(t, secondErr)
+	require.Equal(t, "second", string(secondReply.Bytes))
+}
+
+func TestMemoryBus_Lifecycle(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var bus = NewMemoryBus(context.Background(), logger)
+
+	require.NoError(t, bus.Start())
+	require.NoError(t, bus.Start())
+
+	var channel = bus.Listen("hello", "*", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+
+	require.NoError(t, bus.Stop())
+	require.Equal(t, sabuhp.ErrAlreadyStopped, bus.Stop())
+	require.Equal(t, sabuhp.ErrBusClosed, bus.Start())
+
+	bus.Wait()
+}
+
+func TestMemoryBus_Metrics(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var bus = NewMemoryBus(ctx, logger)
+	require.Equal(t, Metrics{}, bus.Metrics())
+
+	var release = make(chan struct{})
+	var channel = bus.Listen("hello", "*", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			if string(message.Bytes) == "wait" {
+				<-release
+			}
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	bus.Send(
+		sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("world")),
+		sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("world")),
+	)
+
+	// no listeners for the topic.
+	bus.Send(sabuhp.NewMessage(sabuhp.T("nobody"), "me", []byte("world")))
+
+	var sent = make(chan struct{})
+	go func() {
+		bus.Send(sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("wait")))
+		close(sent)
+	}()
+
+	require.Eventually(t, func() bool {
+		return bus.Metrics().Queued == 1
+	}, time.Second, 5*time.Millisecond)
+	require.Equal(t, Metrics{Delivered: 2, Dropped: 1, Queued: 1, Subscribers: 1}, bus.Metrics())
+
+	close(release)
+	<-sent
+	require.Equal(t, Metrics{Delivered: 3, Dropped: 1, Queued: 0, Subscribers: 1}, bus.Metrics())
+}
+
+func TestMemoryBus_ChannelIdentity(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var bus = NewMemoryBus(ctx, logger)
+
+	var handler = sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			return nil
+		})
+
+	var first = bus.Listen("orders", "billing", handler)
+	require.NoError(t, first.Err())
+	defer first.Close()
+
+	var second = bus.Listen("orders", "shipping", handler)
+	require.NoError(t, second.Err())
+	defer second.Close()
+
+	require.Equal(t, "orders", first.Topic())
+	require.Equal(t, "billing", first.Group())
+	require.Equal(t, "orders", second.Topic())
+	require.Equal(t, "shipping", second.Group())
+
+	require.False(t, first.ID().IsNil())
+	require.NotEqual(t, first.ID(), second.ID())
+}
@@ -0,0 +1,74 @@
+package redispub
+
+import (
+	"context"
+	"time"
+
+	"github.com/influx6/npkg/njson"
+)
+
+// ackBatch accumulates the ids of handled stream entries of a
+// subscription till they are acknowledged together, see
+// Config.AckBatchSize.
+type ackBatch struct {
+	ids       []string
+	lastFlush time.Time
+}
+
+func (b *ackBatch) add(ids ...string) {
+	if b.lastFlush.IsZero() {
+		b.lastFlush = time.Now()
+	}
+	b.ids = append(b.ids, ids...)
+}
+
+// due returns true once the batch holds size ids or interval passed
+// since its last flush.
+func (b *ackBatch) due(size int, interval time.Duration) bool {
+	if len(b.ids) >= size {
+		return true
+	}
+	return interval > 0 && len(b.ids) > 0 && time.Since(b.lastFlush) >= interval
+}
+
+// flush returns the batched ids and empties the batch.
+func (b *ackBatch) flush() []string {
+	var ids = b.ids
+	b.ids = nil
+	b.lastFlush = time.Now()
+	return ids
+}
+
+// ack acknowledges the entries of the group with a single XACK.
+func (r *RedisMessageBus) ack(pub *redisSubscription, streamName string, streamGroupName string, ackIds []string) {
+	if len(ackIds) == 0 {
+		return
+	}
+
+	// messages handled while the subscription closes are still
+	// acknowledged, rather than left to be reclaimed.
+	var ackCtx, canceler = context.WithTimeout(context.Background(), r.config.HealthCheckTimeout)
+	defer canceler()
+
+	var acked, ackErr = r.client.XAck(ackCtx, streamName, streamGroupName, ackIds...).Result()
+	if ackErr != nil {
+		njson.Log(pub.logger).New().
+			LError().
+			Message("failed to ack messages").
+			String("stream_name", streamName).
+			String("stream_group_name", streamGroupName).
+			Int("ack_ids", len(ackIds)).
+			Error("error", ackErr).
+			End()
+		return
+	}
+
+	njson.Log(pub.logger).New().
+		LInfo().
+		Message("sent acknowledgment for messages").
+		String("stream_name", streamName).
+		String("stream_group_name", streamGroupName).
+		Int("ack_ids", len(ackIds)).
+		Int64("acked", acked).
+		End()
+}
@@ -0,0 +1,43 @@
+package redispub
+
+import (
+	"time"
+
+	"github.com/ewe-studios/sabuhp"
+	"github.com/ewe-studios/sabuhp/codecs"
+)
+
+// SetCodec swaps the codec of the running bus.
+//
+// Messages being encoded or decoded complete with the old codec, later
+// messages are encoded with the new codec. For the transition window,
+// messages are decoded with either codec (see codecs.MultiCodec), as
+// messages encoded with the old codec may still be in redis or in
+// flight from producers yet to swap.
+func (r *RedisMessageBus) SetCodec(codec sabuhp.Codec, window time.Duration) {
+	r.codecMu.Lock()
+	defer r.codecMu.Unlock()
+
+	r.previousCodec = r.config.Codec
+	r.transitionEnd = time.Now().Add(window)
+	r.config.Codec = codec
+}
+
+// encodingCodec returns the codec messages are encoded with.
+func (r *RedisMessageBus) encodingCodec() sabuhp.Codec {
+	r.codecMu.RLock()
+	defer r.codecMu.RUnlock()
+	return r.config.Codec
+}
+
+// decodingCodec returns the codec messages are decoded with, decoding
+// with the previous codec as well during a transition window.
+func (r *RedisMessageBus) decodingCodec() sabuhp.Codec {
+	r.codecMu.RLock()
+	defer r.codecMu.RUnlock()
+
+	if r.previousCodec == nil || time.Now().After(r.transitionEnd) {
+		return r.config.Codec
+	}
+	return codecs.NewMultiCodec(r.config.Codec, r.previousCodec)
+}
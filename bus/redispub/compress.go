@@ -0,0 +1,89 @@
+package redispub
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+
+	"github.com/ewe-studios/sabuhp"
+	"github.com/influx6/npkg/nerror"
+)
+
+// gzipHeader is the magic header (with the deflate compression method)
+// starting every gzip stream.
+var gzipHeader = []byte{0x1f, 0x8b, 0x08}
+
+func isCompressed(data []byte) bool {
+	return bytes.HasPrefix(data, gzipHeader)
+}
+
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var writer = gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, nerror.WrapOnly(err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, nerror.WrapOnly(err)
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(data []byte) ([]byte, error) {
+	if !isCompressed(data) {
+		return data, nil
+	}
+
+	var reader, readerErr = gzip.NewReader(bytes.NewReader(data))
+	if readerErr != nil {
+		return nil, nerror.WrapOnly(readerErr)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	var decompressed, readErr = ioutil.ReadAll(reader)
+	if readErr != nil {
+		return nil, nerror.WrapOnly(readErr)
+	}
+	return decompressed, nil
+}
+
+// encode encodes the message with the configured codec, compressing the
+// result if Config.Compress is enabled.
+func (r *RedisMessageBus) encode(msg sabuhp.Message) ([]byte, error) {
+	var encodedData, encodeErr = r.encodingCodec().Encode(msg)
+	if encodeErr != nil {
+		return nil, nerror.WrapOnly(encodeErr)
+	}
+	if !r.config.Compress {
+		return encodedData, nil
+	}
+	return compress(encodedData)
+}
+
+// ErrMalformedMessage is returned by decode for data which decoded into
+// a message without a topic, e.g a truncated payload.
+var ErrMalformedMessage = nerror.New("decoded message has no topic")
+
+// decode decompresses the data if it's gzip compressed before decoding
+// with the configured codec, this allows consumers to read from both
+// compressing and non-compressing producers.
+//
+// A decoded message without a topic can not be routed and is rejected
+// with ErrMalformedMessage.
+func (r *RedisMessageBus) decode(data []byte) (sabuhp.Message, error) {
+	var decompressed, decompressErr = decompress(data)
+	if decompressErr != nil {
+		return sabuhp.Message{}, nerror.WrapOnly(decompressErr)
+	}
+
+	var msg, decodeErr = r.decodingCodec().Decode(decompressed)
+	if decodeErr != nil {
+		return msg, nerror.WrapOnly(decodeErr)
+	}
+	if msg.Topic.T == "" {
+		return msg, nerror.WrapOnly(ErrMalformedMessage)
+	}
+	return msg, nil
+}
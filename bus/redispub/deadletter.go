@@ -0,0 +1,255 @@
+package redispub
+
+import (
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/njson"
+	"github.com/influx6/npkg/nunsafe"
+
+	redis "github.com/go-redis/redis/v8"
+
+	"github.com/ewe-studios/sabuhp"
+)
+
+const (
+	deadLetterSuffix = ".dead-letter"
+	replayedSuffix   = ".replayed"
+)
+
+// DeadLetterFilter decides if a dead-lettered message with the reason it
+// was dead-lettered should be replayed.
+type DeadLetterFilter func(msg sabuhp.Message, reason string) bool
+
+// DeadLetterStream returns the name of the stream holding dead-lettered
+// messages for giving topic.
+func DeadLetterStream(topic string) string {
+	return topic + deadLetterSuffix
+}
+
+func replayedSet(topic string) string {
+	return DeadLetterStream(topic) + replayedSuffix
+}
+
+var _ sabuhp.DeadLetterStore = (*RedisDeadLetterStore)(nil)
+
+// RedisDeadLetterStore is the sabuhp.DeadLetterStore of a RedisMessageBus
+// without a Config.DeadLetterStore, it holds the dead letters of a topic in
+// the stream named by DeadLetterStream, encoded with the codec of the bus,
+// and the ids of the replayed ones in a set next to it.
+type RedisDeadLetterStore struct {
+	bus *RedisMessageBus
+}
+
+// NewRedisDeadLetterStore returns a store using the client and codec of
+// the bus.
+func NewRedisDeadLetterStore(bus *RedisMessageBus) *RedisDeadLetterStore {
+	return &RedisDeadLetterStore{bus: bus}
+}
+
+func (s *RedisDeadLetterStore) Store(msg sabuhp.Message, reason error) (string, error) {
+	var r = s.bus
+
+	// the reply future is not part of the dead-lettered message.
+	msg.Future = nil
+
+	var encodedData, encodeErr = r.encode(msg)
+	if encodeErr != nil {
+		return "", nerror.WrapOnly(encodeErr)
+	}
+
+	var reasonText string
+	if reason != nil {
+		reasonText = reason.Error()
+	}
+
+	var topic = msg.Topic.String()
+	var addCmd = r.client.XAdd(r.ctx, &redis.XAddArgs{
+		Stream: DeadLetterStream(topic),
+		ID:     "*",
+		Values: map[string]interface{}{
+			"data":   nunsafe.Bytes2String(encodedData),
+			"topic":  topic,
+			"reason": reasonText,
+		},
+	})
+	if addErr := addCmd.Err(); addErr != nil {
+		return "", nerror.WrapOnly(addErr)
+	}
+	return addCmd.Val(), nil
+}
+
+func (s *RedisDeadLetterStore) List(query sabuhp.DeadLetterQuery) ([]sabuhp.DeadLetter, error) {
+	var r = s.bus
+	var entries, rangeErr = r.client.XRange(r.ctx, DeadLetterStream(query.Topic), "-", "+").Result()
+	if rangeErr != nil {
+		return nil, nerror.WrapOnly(rangeErr)
+	}
+
+	var replayed, replayedErr = r.client.SMembers(r.ctx, replayedSet(query.Topic)).Result()
+	if replayedErr != nil {
+		return nil, nerror.WrapOnly(replayedErr)
+	}
+
+	var alreadyReplayed = make(map[string]bool, len(replayed))
+	for _, id := range replayed {
+		alreadyReplayed[id] = true
+	}
+
+	var letters []sabuhp.DeadLetter
+	for _, entry := range entries {
+		var data, _ = entry.Values["data"].(string)
+		var reason, _ = entry.Values["reason"].(string)
+
+		var msg, decodeErr = r.decode(nunsafe.String2Bytes(data))
+		if decodeErr != nil {
+			njson.Log(r.logger).New().
+				LError().
+				Message("failed to decode dead-lettered message").
+				String("topic", query.Topic).
+				String("dead_letter_id", entry.ID).
+				String("error", decodeErr.Error()).
+				End()
+			continue
+		}
+
+		var storedAt, _ = streamIdTime(entry.ID)
+		var letter = sabuhp.DeadLetter{
+			Id:       entry.ID,
+			Message:  msg,
+			Reason:   reason,
+			StoredAt: storedAt,
+			Replayed: alreadyReplayed[entry.ID],
+		}
+		if letter.Replayed && !query.IncludeReplayed {
+			continue
+		}
+		if query.Match != nil && !query.Match(letter) {
+			continue
+		}
+		letters = append(letters, letter)
+	}
+	return letters, nil
+}
+
+func (s *RedisDeadLetterStore) MarkReplayed(topic string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var members = make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		members = append(members, id)
+	}
+
+	var r = s.bus
+	if addErr := r.client.SAdd(r.ctx, replayedSet(topic), members...).Err(); addErr != nil {
+		return nerror.WrapOnly(addErr)
+	}
+	return nil
+}
+
+func (s *RedisDeadLetterStore) Delete(topic string, id string) error {
+	var r = s.bus
+	var deleted, delErr = r.client.XDel(r.ctx, DeadLetterStream(topic), id).Result()
+	if delErr != nil {
+		return nerror.WrapOnly(delErr)
+	}
+	if deleted == 0 {
+		return nerror.New("no dead letter %q for topic %q", id, topic)
+	}
+	if remErr := r.client.SRem(r.ctx, replayedSet(topic), id).Err(); remErr != nil {
+		return nerror.WrapOnly(remErr)
+	}
+	return nil
+}
+
+// DeadLetters returns the store holding the dead letters of the bus.
+func (r *RedisMessageBus) DeadLetters() sabuhp.DeadLetterStore {
+	return r.deadLetters
+}
+
+// DeadLetter quarantines the message with the reason for its failure into the
+// dead-letter store of the bus, from which it can later be re-published with
+// RedisMessageBus.ReplayDeadLetter.
+func (r *RedisMessageBus) DeadLetter(msg sabuhp.Message, reason error) error {
+	var id, storeErr = r.deadLetters.Store(msg, reason)
+	if storeErr != nil {
+		return nerror.WrapOnly(storeErr)
+	}
+
+	var reasonText string
+	if reason != nil {
+		reasonText = reason.Error()
+	}
+
+	njson.Log(r.logger).New().
+		LWarn().
+		Message("dead-lettered message").
+		String("topic", msg.Topic.String()).
+		String("reason", reasonText).
+		String("dead_letter_id", id).
+		End()
+	return nil
+}
+
+// ReplayDeadLetter re-publishes dead-lettered messages of giving topic back
+// onto their original topic, returning the total replayed.
+//
+// If filter is nil then all not yet replayed messages are re-published. Replayed
+// entries are marked and will not be replayed again.
+func (r *RedisMessageBus) ReplayDeadLetter(topic string, filter DeadLetterFilter) (int, error) {
+	var letters, listErr = r.deadLetters.List(sabuhp.DeadLetterQuery{Topic: topic})
+	if listErr != nil {
+		return 0, nerror.WrapOnly(listErr)
+	}
+
+	var pipelined = r.client.Pipeline()
+	var replayedIds = make([]string, 0, len(letters))
+	for _, letter := range letters {
+		var msg = letter.Message
+		if filter != nil && !filter(msg, letter.Reason) {
+			continue
+		}
+
+		var data, encodeErr = r.encode(msg)
+		if encodeErr != nil {
+			return 0, nerror.WrapOnly(encodeErr)
+		}
+
+		var originalTopic = msg.Topic.String()
+		if len(originalTopic) == 0 {
+			originalTopic = topic
+		}
+
+		var sendErr error
+		if r.channelFor(originalTopic) == RedisStreams {
+			_, sendErr = r.sendStream(originalTopic, data, pipelined)
+		} else {
+			_, sendErr = r.sendPubSub(r.attributeChannel(originalTopic, msg), data, pipelined)
+		}
+		if sendErr != nil {
+			return 0, nerror.WrapOnly(sendErr)
+		}
+
+		replayedIds = append(replayedIds, letter.Id)
+	}
+
+	if len(replayedIds) == 0 {
+		return 0, nil
+	}
+
+	if _, execErr := pipelined.Exec(r.ctx); execErr != nil {
+		return 0, nerror.WrapOnly(execErr)
+	}
+	if markErr := r.deadLetters.MarkReplayed(topic, replayedIds...); markErr != nil {
+		return 0, nerror.WrapOnly(markErr)
+	}
+
+	njson.Log(r.logger).New().
+		LInfo().
+		Message("replayed dead-lettered messages").
+		String("topic", topic).
+		Int("total", len(replayedIds)).
+		End()
+
+	return len(replayedIds), nil
+}
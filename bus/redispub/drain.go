@@ -0,0 +1,102 @@
+package redispub
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ewe-studios/sabuhp"
+	"github.com/ewe-studios/sabuhp/utils"
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/njson"
+)
+
+// DrainThenListen subscribes the handler to the stream topic with the
+// giving group like Listen, but first delivers the backlog of the group:
+// a new group reads the stream from its first entry rather than only
+// new entries, an existing group resumes after its last delivered entry.
+//
+// The onCaughtUp callback is called once the entries present when
+// listening started were handled, or once no entry is left to read when
+// other consumers of the group read the rest or they were trimmed, from
+// then on the handler receives live messages. It's called right away if
+// there is no backlog.
+//
+// Entries pending on other consumers of the group are not delivered by
+// the drain, they are claimed by reclaiming with Config.ReclaimMinIdle.
+//
+// Topics using pubsub have no backlog and are rejected.
+func (r *RedisMessageBus) DrainThenListen(topic string, grp string, handler sabuhp.TransportResponse, onCaughtUp func()) sabuhp.Channel {
+	if r.channelFor(topic) != RedisStreams {
+		return &utils.CloseErrorChannel{T: topic, G: grp, Error: nerror.New("pubsub topic %q has no backlog to drain", topic)}
+	}
+	if groupErr := r.validateGroup(topic, grp); groupErr != nil {
+		return &utils.CloseErrorChannel{T: topic, G: grp, Error: groupErr}
+	}
+	if onCaughtUp == nil {
+		onCaughtUp = func() {}
+	}
+	return r.listenStreamFrom(topic, grp, AckOnHandle, "0", handler, onCaughtUp, false)
+}
+
+// backlogEnd returns the id of the last entry of the stream not yet
+// delivered to the group, or an empty id if the group is caught up.
+func (r *RedisMessageBus) backlogEnd(stream string, grp string) (string, error) {
+	var last, lastErr = r.client.XRevRangeN(r.ctx, stream, "+", "-", 1).Result()
+	if lastErr != nil {
+		return "", nerror.WrapOnly(lastErr)
+	}
+	if len(last) == 0 {
+		return "", nil
+	}
+
+	var groups, groupsErr = r.client.XInfoGroups(r.ctx, stream).Result()
+	if groupsErr != nil {
+		return "", nerror.WrapOnly(groupsErr)
+	}
+	for _, group := range groups {
+		if group.Name == grp && compareStreamIds(group.LastDeliveredID, last[0].ID) >= 0 {
+			return "", nil
+		}
+	}
+	return last[0].ID, nil
+}
+
+// caughtUp calls the onCaughtUp callback of the subscription once.
+func (r *redisSubscription) caughtUp() {
+	r.caughtUpOnce.Do(func() {
+		njson.Log(r.logger).New().
+			LInfo().
+			Message("caught up with stream backlog").
+			String("backlog_end", r.backlogEnd).
+			End()
+		r.onCaughtUp()
+	})
+}
+
+// compareStreamIds compares two stream ids of the form millis-sequence,
+// returning -1, 0 or 1 as a is before, equal to or after b.
+func compareStreamIds(a string, b string) int {
+	var aMillis, aSeq = splitStreamId(a)
+	var bMillis, bSeq = splitStreamId(b)
+	switch {
+	case aMillis < bMillis:
+		return -1
+	case aMillis > bMillis:
+		return 1
+	case aSeq < bSeq:
+		return -1
+	case aSeq > bSeq:
+		return 1
+	}
+	return 0
+}
+
+func splitStreamId(id string) (uint64, uint64) {
+	var millis, seq = id, "0"
+	if index := strings.Index(id, "-"); index >= 0 {
+		millis, seq = id[:index], id[index+1:]
+	}
+	var millisValue, _ = strconv.ParseUint(millis, 10, 64)
+	var seqValue, _ = strconv.ParseUint(seq, 10, 64)
+	return millisValue, seqValue
+}
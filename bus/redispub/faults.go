@@ -0,0 +1,106 @@
+package redispub
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/influx6/npkg/nerror"
+)
+
+// ErrInjectedFault is returned by dials a FaultDialer was told to fail.
+var ErrInjectedFault = nerror.New("injected connection fault")
+
+// FaultDialer is a dialer for redis.Options.Dialer which fails
+// connections on demand, so the reconnect behaviour of a bus can be
+// tested deterministically without stopping redis.
+//
+//	var faults = NewFaultDialer()
+//	config.Redis.Dialer = faults.Dial
+//
+//	// later, mid-subscription
+//	faults.FailDials(1)
+//	faults.Drop()
+type FaultDialer struct {
+	dialer net.Dialer
+
+	mu        sync.Mutex
+	conns     map[net.Conn]struct{}
+	dials     int
+	failDials int
+}
+
+func NewFaultDialer() *FaultDialer {
+	return &FaultDialer{conns: map[net.Conn]struct{}{}}
+}
+
+// Dial dials the address unless a failure was requested with FailDials.
+func (f *FaultDialer) Dial(ctx context.Context, network string, addr string) (net.Conn, error) {
+	f.mu.Lock()
+	f.dials++
+	if f.failDials > 0 {
+		f.failDials--
+		f.mu.Unlock()
+		return nil, ErrInjectedFault
+	}
+	f.mu.Unlock()
+
+	var conn, dialErr = f.dialer.DialContext(ctx, network, addr)
+	if dialErr != nil {
+		return nil, nerror.WrapOnly(dialErr)
+	}
+
+	var tracked = &faultConn{Conn: conn, dialer: f}
+	f.mu.Lock()
+	f.conns[tracked] = struct{}{}
+	f.mu.Unlock()
+	return tracked, nil
+}
+
+// FailDials makes the next n dials fail with ErrInjectedFault.
+func (f *FaultDialer) FailDials(n int) {
+	f.mu.Lock()
+	f.failDials = n
+	f.mu.Unlock()
+}
+
+// Drop closes every open connection, like a network failure would,
+// returning the number of connections dropped.
+func (f *FaultDialer) Drop() int {
+	f.mu.Lock()
+	var conns = f.conns
+	f.conns = map[net.Conn]struct{}{}
+	f.mu.Unlock()
+
+	for conn := range conns {
+		_ = conn.(*faultConn).Conn.Close()
+	}
+	return len(conns)
+}
+
+// Dials returns the number of dials made, including failed ones.
+func (f *FaultDialer) Dials() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.dials
+}
+
+// Conns returns the number of open connections.
+func (f *FaultDialer) Conns() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.conns)
+}
+
+// faultConn untracks itself once closed by its user.
+type faultConn struct {
+	net.Conn
+	dialer *FaultDialer
+}
+
+func (c *faultConn) Close() error {
+	c.dialer.mu.Lock()
+	delete(c.dialer.conns, c)
+	c.dialer.mu.Unlock()
+	return c.Conn.Close()
+}
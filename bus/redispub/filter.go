@@ -0,0 +1,142 @@
+package redispub
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/influx6/npkg/nerror"
+
+	"github.com/ewe-studios/sabuhp"
+	"github.com/ewe-studios/sabuhp/utils"
+)
+
+const (
+	attributeSeparator = "|"
+	attributeAssign    = "="
+	anyAttribute       = "*"
+)
+
+// attributeChannel returns the pubsub channel a message of the topic is
+// published to, qualified by the values of the Config.FilterAttributes
+// of the message Metadata, e.g. "orders|region=eu".
+//
+// Values are query escaped, so they never hold glob characters of
+// subscription patterns nor the separators of the channel.
+func (r *RedisMessageBus) attributeChannel(topic string, msg sabuhp.Message) string {
+	if len(r.config.FilterAttributes) == 0 {
+		return topic
+	}
+
+	var channel strings.Builder
+	channel.WriteString(topic)
+	for _, attribute := range r.config.FilterAttributes {
+		channel.WriteString(attributeSeparator)
+		channel.WriteString(attribute)
+		channel.WriteString(attributeAssign)
+		channel.WriteString(url.QueryEscape(msg.Metadata[attribute]))
+	}
+	return channel.String()
+}
+
+// attributePattern returns the pubsub pattern of the topic matching
+// only messages with the attribute values of the filter, attributes
+// missing from the filter match any value.
+func (r *RedisMessageBus) attributePattern(topic string, filter sabuhp.Params) (string, error) {
+	if len(r.config.FilterAttributes) == 0 {
+		if len(filter) != 0 {
+			return "", nerror.New("bus has no filter attributes to filter topic %q by", topic)
+		}
+		return r.topicGlob(topic), nil
+	}
+
+	var matched int
+	var pattern strings.Builder
+	pattern.WriteString(r.topicGlob(topic))
+	for _, attribute := range r.config.FilterAttributes {
+		pattern.WriteString(attributeSeparator)
+		pattern.WriteString(attribute)
+		pattern.WriteString(attributeAssign)
+
+		var value, ok = filter[attribute]
+		if !ok {
+			pattern.WriteString(anyAttribute)
+			continue
+		}
+		matched++
+		pattern.WriteString(url.QueryEscape(value))
+	}
+
+	if matched != len(filter) {
+		return "", nerror.New(
+			"filter of topic %q uses attributes missing from Config.FilterAttributes %v",
+			topic,
+			r.config.FilterAttributes,
+		)
+	}
+	return pattern.String(), nil
+}
+
+// ListenWithFilter subscribes the handler to the messages of the pubsub
+// topic whose Metadata hold the values of the filter.
+//
+// Filtering is done by redis: messages are published to channels
+// qualified by their Config.FilterAttributes, which the subscription
+// only matches for the filtered values, so other messages never reach
+// the listener. Only attributes of Config.FilterAttributes can be used.
+//
+// Streams can not be filtered by redis, so stream topics are rejected.
+func (r *RedisMessageBus) ListenWithFilter(topic string, grp string, filter sabuhp.Params, handler sabuhp.TransportResponse) sabuhp.Channel {
+	if r.channelFor(topic) == RedisStreams {
+		return &utils.CloseErrorChannel{T: topic, G: grp, Error: nerror.New("stream topic %q can not be filtered", topic)}
+	}
+	if groupErr := r.validateGroup(topic, grp); groupErr != nil {
+		return &utils.CloseErrorChannel{T: topic, G: grp, Error: groupErr}
+	}
+
+	var pattern, patternErr = r.attributePattern(topic, filter)
+	if patternErr != nil {
+		return &utils.CloseErrorChannel{T: topic, G: grp, Error: patternErr}
+	}
+	return r.listenPubSub(topic, pattern, grp, handler, false)
+}
+
+// isTopicPattern returns true if a segment of the topic in the
+// Config.TopicHierarchy is a wildcard, see sabuhp.TopicHierarchy.Match.
+func (r *RedisMessageBus) isTopicPattern(topic string) bool {
+	var segments = r.config.TopicHierarchy.Segments(topic)
+	for index, segment := range segments {
+		if segment == sabuhp.AnySegment {
+			return true
+		}
+		if segment == sabuhp.AnySegments && index == len(segments)-1 {
+			return true
+		}
+	}
+	return false
+}
+
+// topicGlob returns the redis glob subscribing to the channels of the
+// topic. Globs match across separators, so listens of a topic pattern
+// are filtered with matchingTopics as well.
+func (r *RedisMessageBus) topicGlob(topic string) string {
+	var segments = r.config.TopicHierarchy.Segments(topic)
+	if segments[len(segments)-1] != sabuhp.AnySegments {
+		return topic
+	}
+
+	// "orders.#" matches "orders" as well, so the glob ends before the
+	// separator.
+	return r.config.TopicHierarchy.Join(segments[:len(segments)-1]...) + "*"
+}
+
+// matchingTopics returns a handler only passing the messages whose topics
+// match the topic pattern in the Config.TopicHierarchy to the handler.
+func (r *RedisMessageBus) matchingTopics(pattern string, handler sabuhp.TransportResponse) sabuhp.TransportResponse {
+	return sabuhp.TransportResponseFunc(func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+		if !r.config.TopicHierarchy.Match(pattern, message.Topic.String()) {
+			return nil
+		}
+		return handler.Handle(ctx, message, transport)
+	})
+}
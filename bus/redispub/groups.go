@@ -0,0 +1,107 @@
+package redispub
+
+import (
+	"github.com/influx6/npkg/nerror"
+
+	"github.com/ewe-studios/sabuhp"
+	"github.com/ewe-studios/sabuhp/utils"
+)
+
+// AckMode decides when a stream consumer acknowledges its messages.
+type AckMode int
+
+const (
+	// AckOnHandle acknowledges a message once its handler is done with it
+	// and asked for it, the mode used by Listen.
+	AckOnHandle AckMode = iota
+
+	// AckOnRead acknowledges messages as they are read, a message
+	// whose handler fails is not redelivered but dead-lettered.
+	AckOnRead
+)
+
+func (a AckMode) String() string {
+	switch a {
+	case AckOnHandle:
+		return "ack-on-handle"
+	case AckOnRead:
+		return "ack-on-read"
+	}
+	return "unknown"
+}
+
+// streamGroup is the registration of a consumer group of a stream
+// topic by the listeners of the bus.
+type streamGroup struct {
+	mode      AckMode
+	listeners int
+}
+
+// ListenWithAckMode subscribes the handler to the stream topic with the
+// giving group, acknowledging messages with the provided mode.
+//
+// Every distinct group of a topic gets its own consumer group which
+// receives all messages of the topic, while listeners of the same group
+// compete for its messages. All listeners of a group must use the same
+// mode, a listener of a group with a different mode gets a closed channel
+// with an error.
+//
+// Topics using pubsub have no acknowledgement, the mode is ignored.
+func (r *RedisMessageBus) ListenWithAckMode(topic string, grp string, mode AckMode, handler sabuhp.TransportResponse) sabuhp.Channel {
+	return r.listenWithAckMode(topic, grp, mode, handler, false)
+}
+
+// listenWithAckMode is ListenWithAckMode, heldSlot is as for
+// listenStreamFrom.
+func (r *RedisMessageBus) listenWithAckMode(topic string, grp string, mode AckMode, handler sabuhp.TransportResponse, heldSlot bool) sabuhp.Channel {
+	if groupErr := r.validateGroup(topic, grp); groupErr != nil {
+		return &utils.CloseErrorChannel{T: topic, G: grp, Error: groupErr}
+	}
+	if r.channelFor(topic) == RedisStreams {
+		return r.listenStreamFrom(topic, grp, mode, "$", handler, nil, heldSlot)
+	}
+	var pattern, _ = r.attributePattern(topic, nil)
+	return r.listenPubSub(topic, pattern, grp, handler, heldSlot)
+}
+
+// registerGroup records a listener of the group of a stream topic,
+// failing if the group is used with another mode.
+func (r *RedisMessageBus) registerGroup(topic string, grp string, mode AckMode) error {
+	r.groupsMu.Lock()
+	defer r.groupsMu.Unlock()
+
+	var key = topic + "/" + grp
+	var registered, ok = r.groups[key]
+	if !ok {
+		r.groups[key] = &streamGroup{mode: mode, listeners: 1}
+		return nil
+	}
+	if registered.mode != mode {
+		return nerror.New(
+			"group %q of topic %q is used with %s, can not listen with %s",
+			grp,
+			topic,
+			registered.mode,
+			mode,
+		)
+	}
+	registered.listeners++
+	return nil
+}
+
+// releaseGroup removes a listener of the group of a stream topic, the
+// group can be used with another mode once it has no listener.
+func (r *RedisMessageBus) releaseGroup(topic string, grp string) {
+	r.groupsMu.Lock()
+	defer r.groupsMu.Unlock()
+
+	var key = topic + "/" + grp
+	var registered, ok = r.groups[key]
+	if !ok {
+		return
+	}
+	registered.listeners--
+	if registered.listeners <= 0 {
+		delete(r.groups, key)
+	}
+}
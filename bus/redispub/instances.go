@@ -0,0 +1,223 @@
+package redispub
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/njson"
+	"github.com/influx6/npkg/nxid"
+
+	"github.com/ewe-studios/sabuhp"
+)
+
+var _ sabuhp.MessageBus = (*InstanceBus)(nil)
+
+var _ sabuhp.HealthReporter = (*InstanceBus)(nil)
+
+var _ sabuhp.Lifecycle = (*InstanceBus)(nil)
+
+var _ sabuhp.ReplyTopicResolver = (*InstanceBus)(nil)
+
+var _ sabuhp.ReplyGroupResolver = (*InstanceBus)(nil)
+
+// TopicRouter returns the index of the redis instance serving the topic
+// out of the given number of instances.
+type TopicRouter func(topic string, instances int) int
+
+// HashTopicRouter routes topics by their sabuhp.FNVHasher hash, it's the
+// default TopicRouter of an InstanceBus.
+func HashTopicRouter(topic string, instances int) int {
+	return int(sabuhp.FNVHasher(topic) % uint32(instances))
+}
+
+// MappedTopicRouter routes the topics of the mapping to their instance,
+// every other topic is routed by the fallback, HashTopicRouter if nil.
+func MappedTopicRouter(mapping map[string]int, fallback TopicRouter) TopicRouter {
+	if fallback == nil {
+		fallback = HashTopicRouter
+	}
+	return func(topic string, instances int) int {
+		if instance, ok := mapping[topic]; ok && instance >= 0 && instance < instances {
+			return instance
+		}
+		return fallback(topic, instances)
+	}
+}
+
+// InstanceBus spreads topics across multiple redis instances (or databases),
+// presenting them as one MessageBus. Every topic is served by the instance
+// picked by the TopicRouter, each instance being a RedisMessageBus with its
+// own connection.
+//
+// All producers and consumers of a topic must use the same instances and
+// router, else they will not meet on the same instance.
+type InstanceBus struct {
+	logger     sabuhp.Logger
+	router     TopicRouter
+	instances  []*RedisMessageBus
+	replyGroup string
+}
+
+// Instances returns a new InstanceBus with a bus for each of the redis
+// options using the channel, the remaining settings are taken from the
+// config. Like Stream and PubSub, every instance is pinged when creating
+// the bus.
+func Instances(config Config, options []redis.Options, channel MessageChannel, router TopicRouter) (*InstanceBus, error) {
+	if len(options) == 0 {
+		return nil, nerror.New("InstanceBus requires at least one redis instance")
+	}
+	if router == nil {
+		router = HashTopicRouter
+	}
+
+	var clients = make([]*redis.Client, 0, len(options))
+	var closeClients = func() {
+		for _, client := range clients {
+			_ = client.Close()
+		}
+	}
+
+	var instances = make([]*RedisMessageBus, 0, len(options))
+	for index := range options {
+		var instanceConfig = config
+		instanceConfig.Redis = options[index]
+
+		var client = redis.NewClient(&instanceConfig.Redis)
+		clients = append(clients, client)
+
+		if statusErr := client.Ping(config.Ctx).Err(); statusErr != nil {
+			closeClients()
+			return nil, nerror.Wrap(statusErr, "failed to ping redis instance %d", index)
+		}
+
+		instances = append(instances, ownClient(NewRedisMessageBus(instanceConfig, client, channel)))
+	}
+
+	return &InstanceBus{
+		logger:     config.Logger,
+		router:     router,
+		instances:  instances,
+		replyGroup: "replies-" + nxid.New().String(),
+	}, nil
+}
+
+// Instances returns the bus of each redis instance.
+func (s *InstanceBus) Instances() []*RedisMessageBus {
+	return s.instances
+}
+
+// InstanceFor returns the index of the instance serving the topic.
+func (s *InstanceBus) InstanceFor(topic string) int {
+	return s.router(topic, len(s.instances))
+}
+
+func (s *InstanceBus) instanceFor(topic string) *RedisMessageBus {
+	return s.instances[s.InstanceFor(topic)]
+}
+
+// Start starts the bus of every instance, returning the first error seen.
+func (s *InstanceBus) Start() error {
+	var startErr error
+	for _, instance := range s.instances {
+		if err := instance.Start(); err != nil && startErr == nil {
+			startErr = err
+		}
+	}
+	return startErr
+}
+
+// Stop stops the bus of every instance, returning the first error seen.
+func (s *InstanceBus) Stop() error {
+	var stopErr error
+	for _, instance := range s.instances {
+		if err := instance.Stop(); err != nil && stopErr == nil {
+			stopErr = err
+		}
+	}
+	return stopErr
+}
+
+func (s *InstanceBus) Wait() {
+	for _, instance := range s.instances {
+		instance.Wait()
+	}
+}
+
+// Healthy returns true if every instance is healthy.
+func (s *InstanceBus) Healthy() bool {
+	return len(s.UnhealthyInstances()) == 0
+}
+
+// UnhealthyInstances returns the indexes of the instances failing
+// their health check.
+func (s *InstanceBus) UnhealthyInstances() []int {
+	var unhealthy []int
+	for index, instance := range s.instances {
+		if !instance.Healthy() {
+			unhealthy = append(unhealthy, index)
+		}
+	}
+	return unhealthy
+}
+
+// Send sends every message to the instance of its topic, keeping the
+// order of messages of the same instance.
+func (s *InstanceBus) Send(data ...sabuhp.Message) {
+	var batches = make([][]sabuhp.Message, len(s.instances))
+	for _, msg := range data {
+		var index = s.InstanceFor(msg.Topic.String())
+		batches[index] = append(batches[index], msg)
+	}
+
+	for index, batch := range batches {
+		if len(batch) > 0 {
+			s.instances[index].Send(batch...)
+		}
+	}
+}
+
+// ReplyTopicOf returns the reply topic of the topic in the hierarchy of
+// the instances, which share their config. Instances never creates a bus
+// without an instance.
+func (s *InstanceBus) ReplyTopicOf(topic sabuhp.Topic) sabuhp.Topic {
+	return s.instances[0].ReplyTopicOf(topic)
+}
+
+// SendForReply sends the messages to the instances of their topics and
+// listens for replies on the instance of the reply topic of fromTopic.
+//
+// An empty replyGroup uses a group of its own for each request on a
+// stream reply topic, like RedisMessageBus.SendForReply, and no group on a
+// pubsub one.
+func (s *InstanceBus) SendForReply(tm time.Duration, fromTopic sabuhp.Topic, replyGroup string, data ...sabuhp.Message) *sabuhp.ReplyFuture {
+	var replyTopic = s.ReplyTopicOf(fromTopic).String()
+	return sendForReply(s.instanceFor(replyTopic), tm, replyTopic, replyGroup, s.replyGroup, s.Send, data)
+}
+
+// ReplyGroupFor returns the group a request listens with on the reply
+// topic, resolved by the instance of the reply topic like
+// RedisMessageBus.ReplyGroupFor.
+func (s *InstanceBus) ReplyGroupFor(replyTopic string, replyGroup string) (string, func(), error) {
+	return resolveReplyGroup(s.instanceFor(replyTopic), replyTopic, replyGroup, s.replyGroup)
+}
+
+// Listen subscribes the handler on the instance of the topic, the handler
+// receives the InstanceBus as its transport bus, so replies it sends are
+// routed to the instance of their own topic.
+func (s *InstanceBus) Listen(topic string, grp string, handler sabuhp.TransportResponse) sabuhp.Channel {
+	var index = s.InstanceFor(topic)
+
+	njson.Log(s.logger).New().
+		LInfo().
+		Message("listening on redis instance of topic").
+		String("topic", topic).
+		Int("instance", index).
+		End()
+
+	return s.instances[index].Listen(topic, grp, sabuhp.TransportResponseFunc(func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+		transport.Bus = s
+		return handler.Handle(ctx, message, transport)
+	}))
+}
@@ -0,0 +1,60 @@
+package redispub
+
+import (
+	"github.com/influx6/npkg/nerror"
+)
+
+// lagPageSize is the number of stream entries read per page when
+// counting the entries a group has not yet received.
+const lagPageSize = 500
+
+// Lag returns the number of messages of the stream topic which were not yet
+// delivered to the consumer group, i.e the entries after the group's last
+// delivered id.
+//
+// Entries are counted page by page, so computing a large lag reads the
+// whole backlog from redis.
+func (r *RedisMessageBus) Lag(topic string, group string) (int64, error) {
+	var groups, groupsErr = r.client.XInfoGroups(r.ctx, topic).Result()
+	if groupsErr != nil {
+		return 0, nerror.WrapOnly(groupsErr)
+	}
+
+	var lastDeliveredId string
+	var hasGroup bool
+	for _, info := range groups {
+		if info.Name == group {
+			lastDeliveredId = info.LastDeliveredID
+			hasGroup = true
+			break
+		}
+	}
+	if !hasGroup {
+		return 0, nerror.New("stream %q has no consumer group %q", topic, group)
+	}
+
+	var lag int64
+	var start = lastDeliveredId
+	for {
+		var entries, rangeErr = r.client.XRangeN(r.ctx, topic, start, "+", lagPageSize).Result()
+		if rangeErr != nil {
+			return 0, nerror.WrapOnly(rangeErr)
+		}
+
+		var read int
+		for _, entry := range entries {
+			// ranges are inclusive of the start id, which was already counted
+			// or delivered.
+			if entry.ID == start {
+				continue
+			}
+			lag++
+			read++
+		}
+
+		if read == 0 {
+			return lag, nil
+		}
+		start = entries[len(entries)-1].ID
+	}
+}
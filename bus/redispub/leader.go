@@ -0,0 +1,231 @@
+package redispub
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+	"github.com/influx6/npkg/njson"
+	"github.com/influx6/npkg/nxid"
+
+	"github.com/ewe-studios/sabuhp"
+)
+
+var DefaultLeaderTTL = 5 * time.Second
+
+// renewLeaderScript extends the lock expiry only if it's still
+// held by the caller.
+var renewLeaderScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseLeaderScript removes the lock only if it's still held by the caller.
+var releaseLeaderScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+type LeaderConfig struct {
+	Ctx    context.Context
+	Logger sabuhp.Logger
+
+	// Key is the redis key used as the leadership lock, all contenders
+	// for the same leadership must use the same key.
+	Key string
+
+	// TTL is the expiry of the lock, a leader which fails to renew
+	// its lock within this duration loses leadership.
+	TTL time.Duration
+
+	// RenewInterval is the interval at which the leader renews the lock and
+	// others attempt to acquire it, defaults to a third of the TTL.
+	RenewInterval time.Duration
+
+	// OnBecomeLeader is called when the instance acquires leadership.
+	OnBecomeLeader func()
+
+	// OnLoseLeader is called when the instance loses or releases leadership.
+	OnLoseLeader func()
+}
+
+func (l *LeaderConfig) ensure() {
+	if l.Ctx == nil {
+		panic("LeaderConfig.Ctx is required")
+	}
+	if l.Logger == nil {
+		panic("LeaderConfig.Logger is required")
+	}
+	if len(l.Key) == 0 {
+		panic("LeaderConfig.Key is required")
+	}
+	if l.TTL <= 0 {
+		l.TTL = DefaultLeaderTTL
+	}
+	if l.RenewInterval <= 0 {
+		l.RenewInterval = l.TTL / 3
+	}
+}
+
+// LeaderElection elects a single leader among instances contending for the
+// same key, using a redis lock (SET NX PX) which the leader keeps renewing.
+//
+// It's useful for consumers which must run as a singleton across instances,
+// where subscriptions are created in OnBecomeLeader and closed in OnLoseLeader.
+type LeaderElection struct {
+	config   LeaderConfig
+	client   *redis.Client
+	id       string
+	leader   uint32
+	ctx      context.Context
+	canceler context.CancelFunc
+	waiter   sync.WaitGroup
+	starter  sync.Once
+	stopper  sync.Once
+}
+
+func NewLeaderElection(config LeaderConfig, client *redis.Client) *LeaderElection {
+	config.ensure()
+	var ctx, canceler = context.WithCancel(config.Ctx)
+	return &LeaderElection{
+		config:   config,
+		client:   client,
+		id:       nxid.New().String(),
+		ctx:      ctx,
+		canceler: canceler,
+	}
+}
+
+// ID returns the id of the instance held in the lock while it's the leader.
+func (l *LeaderElection) ID() string {
+	return l.id
+}
+
+// AmLeader returns true if the instance is currently the leader.
+func (l *LeaderElection) AmLeader() bool {
+	return atomic.LoadUint32(&l.leader) == 1
+}
+
+// Start starts contending for leadership.
+func (l *LeaderElection) Start() {
+	l.starter.Do(func() {
+		l.waiter.Add(1)
+		go l.manage()
+	})
+}
+
+// Stop stops contending for leadership, releasing it if held.
+func (l *LeaderElection) Stop() {
+	l.stopper.Do(func() {
+		l.canceler()
+		l.waiter.Wait()
+	})
+}
+
+func (l *LeaderElection) manage() {
+	defer l.waiter.Done()
+
+	var ticker = time.NewTicker(l.config.RenewInterval)
+	defer ticker.Stop()
+
+	for {
+		l.contend()
+
+		select {
+		case <-l.ctx.Done():
+			l.release()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (l *LeaderElection) contend() {
+	if l.AmLeader() {
+		var renewCmd = renewLeaderScript.Run(l.ctx, l.client, []string{l.config.Key}, l.id, l.config.TTL.Milliseconds())
+		if renewed, renewErr := renewCmd.Int(); renewErr != nil || renewed == 0 {
+			njson.Log(l.config.Logger).New().
+				LWarn().
+				Message("failed to renew leadership").
+				String("key", l.config.Key).
+				String("id", l.id).
+				Error("error", renewErr).
+				End()
+			l.lose()
+		}
+		return
+	}
+
+	var acquireCmd = l.client.SetNX(l.ctx, l.config.Key, l.id, l.config.TTL)
+	if acquireErr := acquireCmd.Err(); acquireErr != nil {
+		njson.Log(l.config.Logger).New().
+			LError().
+			Message("failed to acquire leadership").
+			String("key", l.config.Key).
+			String("id", l.id).
+			Error("error", acquireErr).
+			End()
+		return
+	}
+
+	if acquireCmd.Val() {
+		l.become()
+	}
+}
+
+func (l *LeaderElection) release() {
+	if !l.AmLeader() {
+		return
+	}
+
+	// the election context is done, so release with a fresh one.
+	var ctx, canceler = context.WithTimeout(context.Background(), l.config.RenewInterval)
+	defer canceler()
+
+	if releaseErr := releaseLeaderScript.Run(ctx, l.client, []string{l.config.Key}, l.id).Err(); releaseErr != nil {
+		njson.Log(l.config.Logger).New().
+			LError().
+			Message("failed to release leadership").
+			String("key", l.config.Key).
+			String("id", l.id).
+			Error("error", releaseErr).
+			End()
+	}
+	l.lose()
+}
+
+func (l *LeaderElection) become() {
+	atomic.StoreUint32(&l.leader, 1)
+
+	njson.Log(l.config.Logger).New().
+		LInfo().
+		Message("became leader").
+		String("key", l.config.Key).
+		String("id", l.id).
+		End()
+
+	if l.config.OnBecomeLeader != nil {
+		l.config.OnBecomeLeader()
+	}
+}
+
+func (l *LeaderElection) lose() {
+	atomic.StoreUint32(&l.leader, 0)
+
+	njson.Log(l.config.Logger).New().
+		LInfo().
+		Message("lost leadership").
+		String("key", l.config.Key).
+		String("id", l.id).
+		End()
+
+	if l.config.OnLoseLeader != nil {
+		l.config.OnLoseLeader()
+	}
+}
@@ -0,0 +1,79 @@
+package redispub
+
+import (
+	"github.com/go-redis/redis/v8"
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/njson"
+)
+
+// migrateGroupScript creates the group ARGV[2] of the stream KEYS[1] at the
+// last delivered id of the group ARGV[1], deleting the latter if ARGV[3]
+// is "1". The groups are checked and changed in one step, so no consumer
+// reads or acknowledges entries in between, and the old group is only
+// deleted once the new one was created.
+var migrateGroupScript = redis.NewScript(`
+local old
+for _, group in ipairs(redis.call("XINFO", "GROUPS", KEYS[1])) do
+	local info = {}
+	for i = 1, #group, 2 do
+		info[group[i]] = group[i + 1]
+	end
+	if info["name"] == ARGV[2] then
+		return redis.error_reply("stream already has consumer group " .. ARGV[2])
+	end
+	if info["name"] == ARGV[1] then
+		old = info
+	end
+end
+if not old then
+	return redis.error_reply("stream has no consumer group " .. ARGV[1])
+end
+if ARGV[3] == "1" and old["pending"] > 0 then
+	return redis.error_reply("consumer group " .. ARGV[1] .. " has " .. old["pending"] .. " pending messages")
+end
+redis.call("XGROUP", "CREATE", KEYS[1], ARGV[2], old["last-delivered-id"])
+if ARGV[3] == "1" then
+	redis.call("XGROUP", "DESTROY", KEYS[1], ARGV[1])
+end
+return old["last-delivered-id"]
+`)
+
+// MigrateGroup creates the consumer group newGroup of the stream topic at
+// the last delivered id of oldGroup, so consumers of newGroup resume where
+// those of oldGroup left off rather than reading the stream again. With
+// deleteOld set oldGroup is deleted in the same step, once newGroup was
+// created.
+//
+// Pending entries of oldGroup are not moved to newGroup. Deleting a group
+// with pending entries would lose them, hence it fails instead: stop the
+// consumers of oldGroup and let its pending entries be acknowledged
+// before migrating with deleteOld.
+func (r *RedisMessageBus) MigrateGroup(topic string, oldGroup string, newGroup string, deleteOld bool) error {
+	var deleteFlag = "0"
+	if deleteOld {
+		deleteFlag = "1"
+	}
+
+	var lastDeliveredId, migrateErr = migrateGroupScript.Run(
+		r.ctx,
+		r.client,
+		[]string{topic},
+		oldGroup,
+		newGroup,
+		deleteFlag,
+	).Text()
+	if migrateErr != nil {
+		return nerror.Wrap(migrateErr, "failed to migrate consumer group %q of stream %q to %q", oldGroup, topic, newGroup)
+	}
+
+	njson.Log(r.logger).New().
+		LInfo().
+		Message("migrated stream consumer group").
+		String("topic", topic).
+		String("old_group", oldGroup).
+		String("new_group", newGroup).
+		String("last_delivered_id", lastDeliveredId).
+		Bool("deleted_old", deleteOld).
+		End()
+	return nil
+}
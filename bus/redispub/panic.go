@@ -0,0 +1,80 @@
+package redispub
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/influx6/npkg/njson"
+)
+
+// PanicReport describes a panic recovered by the bus.
+type PanicReport struct {
+	// Goroutine names where the panic occurred, e.g. "manage",
+	// "stream:orders:billing" or "handler:orders".
+	Goroutine string
+	Value     interface{}
+	Stack     []byte
+	At        time.Time
+}
+
+// LastPanic returns the last panic recovered in a goroutine or handler
+// of the bus, nil if none occurred.
+func (r *RedisMessageBus) LastPanic() *PanicReport {
+	r.panicMu.Lock()
+	defer r.panicMu.Unlock()
+	return r.lastPanic
+}
+
+// recordPanic records the panic recovered from the goroutine as the
+// last panic of the bus.
+func (r *RedisMessageBus) recordPanic(goroutine string, value interface{}) {
+	var report = &PanicReport{
+		Goroutine: goroutine,
+		Value:     value,
+		Stack:     debug.Stack(),
+		At:        time.Now(),
+	}
+
+	r.panicMu.Lock()
+	r.lastPanic = report
+	r.panicMu.Unlock()
+}
+
+// supervise runs fn till it returns, recovering its panics. A panicked
+// fn is run again if Config.RestartOnPanic is enabled and the bus is
+// still running.
+func (r *RedisMessageBus) supervise(goroutine string, fn func()) {
+	for r.runRecovered(goroutine, fn) {
+		if !r.config.RestartOnPanic || r.ctx.Err() != nil {
+			return
+		}
+
+		njson.Log(r.logger).New().
+			LWarn().
+			Message("restarting goroutine after panic").
+			String("goroutine", goroutine).
+			End()
+	}
+}
+
+// runRecovered runs fn, returning true if it panicked.
+func (r *RedisMessageBus) runRecovered(goroutine string, fn func()) (panicked bool) {
+	defer func() {
+		if panicInfo := recover(); panicInfo != nil {
+			panicked = true
+			r.recordPanic(goroutine, panicInfo)
+
+			njson.Log(r.logger).New().
+				LError().
+				Message("panic occurred").
+				String("goroutine", goroutine).
+				String("panic_data", fmt.Sprintf("%#v", panicInfo)).
+				String("stack", string(debug.Stack())).
+				End()
+		}
+	}()
+
+	fn()
+	return false
+}
@@ -0,0 +1,52 @@
+package redispub
+
+import (
+	"github.com/go-redis/redis/v8"
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/njson"
+)
+
+// PurgeTopic deletes every message retained by the stream topic and
+// resets its consumer groups, which are recreated empty at the start of
+// the stream, dropping their pending entries. Groups are kept so
+// subscriptions of the topic keep reading once it's purged.
+//
+// Pub/sub topics retain no messages, purging one does nothing.
+func (r *RedisMessageBus) PurgeTopic(topic string) error {
+	if r.channelFor(topic) != RedisStreams {
+		return nil
+	}
+
+	var exists, existsErr = r.client.Exists(r.ctx, topic).Result()
+	if existsErr != nil {
+		return nerror.WrapOnly(existsErr)
+	}
+	if exists == 0 {
+		return nil
+	}
+
+	var groups, groupsErr = r.client.XInfoGroups(r.ctx, topic).Result()
+	if groupsErr != nil {
+		return nerror.WrapOnly(groupsErr)
+	}
+
+	var _, purgeErr = r.client.TxPipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		pipe.XTrim(r.ctx, topic, 0)
+		for _, group := range groups {
+			pipe.XGroupDestroy(r.ctx, topic, group.Name)
+			pipe.XGroupCreate(r.ctx, topic, group.Name, "0")
+		}
+		return nil
+	})
+	if purgeErr != nil {
+		return nerror.WrapOnly(purgeErr)
+	}
+
+	njson.Log(r.logger).New().
+		LInfo().
+		Message("purged stream topic").
+		String("topic", topic).
+		Int("groups", len(groups)).
+		End()
+	return nil
+}
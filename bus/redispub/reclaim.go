@@ -0,0 +1,209 @@
+package redispub
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/njson"
+
+	"github.com/ewe-studios/sabuhp"
+)
+
+// reclaimBatchSize is the number of pending entries claimed per reclaim.
+const reclaimBatchSize = 100
+
+// ErrMaxDeliveries is the reason messages delivered Config.MaxDeliveries
+// times without being acknowledged are dead-lettered with.
+var ErrMaxDeliveries = nerror.New("message exceeded its maximum deliveries")
+
+// RebalanceEvent describes the pending entries of a consumer group a
+// consumer claimed from other consumers of the group, e.g from the
+// consumer of an instance which left or crashed.
+type RebalanceEvent struct {
+	Topic    string
+	Group    string
+	Consumer string
+
+	// From are the consumers the entries were claimed from.
+	From []string
+
+	// Claimed are the ids of the claimed entries.
+	Claimed []string
+}
+
+// RebalanceFunc is called with every rebalance of a consumer group seen
+// by a consumer of the bus.
+type RebalanceFunc func(event RebalanceEvent)
+
+// consumerName returns the name of the stream consumer of the subscription.
+func consumerName(pub *redisSubscription) string {
+	return fmt.Sprintf("%s_consumer_%s", pub.topic, pub.id.String())
+}
+
+// reclaim claims the entries of the group pending on other consumers for
+// longer than Config.ReclaimMinIdle and handles them like newly read ones,
+// so messages of a consumer which left without acknowledging them are not
+// stuck. Entries already delivered Config.MaxDeliveries times are claimed
+// and dead-lettered instead, so a message crashing its consumers is not
+// redelivered forever.
+//
+// Redis 6.2 offers XAUTOCLAIM for this, XPENDING with XCLAIM is used as
+// they are supported by the client and older servers alike. A claim only
+// succeeds for entries still idle, so consumers reclaiming concurrently
+// never claim the same entry twice.
+func (r *RedisMessageBus) reclaim(
+	ctx context.Context,
+	handler sabuhp.TransportResponse,
+	pub *redisSubscription,
+	streamName string,
+	streamGroupName string,
+) error {
+	var consumer = consumerName(pub)
+
+	// XPENDING lists entries of every consumer, idle or not, so pages are
+	// read from the last id seen till a batch of candidates is found.
+	var ids []string
+	var exhausted = map[string]struct{}{}
+	var owners = map[string]struct{}{}
+	var start = "-"
+	for len(ids) < reclaimBatchSize {
+		var pending, pendingErr = r.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: streamName,
+			Group:  streamGroupName,
+			Start:  start,
+			End:    "+",
+			Count:  reclaimBatchSize,
+		}).Result()
+		if pendingErr != nil {
+			return nerror.WrapOnly(pendingErr)
+		}
+
+		for _, entry := range pending {
+			if entry.Consumer == consumer || entry.Idle < r.config.ReclaimMinIdle {
+				continue
+			}
+			if r.config.MaxDeliveries > 0 && entry.RetryCount >= int64(r.config.MaxDeliveries) {
+				exhausted[entry.ID] = struct{}{}
+			}
+			ids = append(ids, entry.ID)
+			owners[entry.Consumer] = struct{}{}
+		}
+
+		if len(pending) < reclaimBatchSize {
+			break
+		}
+		start = nextStreamId(pending[len(pending)-1].ID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var claimed, claimErr = r.client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   streamName,
+		Group:    streamGroupName,
+		Consumer: consumer,
+		MinIdle:  r.config.ReclaimMinIdle,
+		Messages: ids,
+	}).Result()
+	if claimErr != nil {
+		return nerror.WrapOnly(claimErr)
+	}
+	if len(claimed) == 0 {
+		return nil
+	}
+
+	var event = RebalanceEvent{
+		Topic:    pub.topic,
+		Group:    streamGroupName,
+		Consumer: consumer,
+	}
+	for owner := range owners {
+		event.From = append(event.From, owner)
+	}
+	for _, message := range claimed {
+		event.Claimed = append(event.Claimed, message.ID)
+	}
+
+	njson.Log(pub.logger).New().
+		LWarn().
+		Message("reclaimed pending messages of other consumers").
+		String("stream_name", streamName).
+		String("stream_group_name", streamGroupName).
+		String("consumer", consumer).
+		Int("claimed", len(claimed)).
+		End()
+
+	if r.config.OnRebalance != nil {
+		r.config.OnRebalance(event)
+	}
+
+	var ackIds = make([]string, 0, len(claimed))
+	for _, message := range claimed {
+		if _, isExhausted := exhausted[message.ID]; isExhausted {
+			if r.deadLetterXMessage(pub.logger, streamName, message) {
+				ackIds = append(ackIds, message.ID)
+			}
+			continue
+		}
+		if r.handleXMessage(pub.logger, streamName, pub.ackMode, handler, message) {
+			ackIds = append(ackIds, message.ID)
+		}
+	}
+	r.ack(pub, streamName, streamGroupName, ackIds)
+	return nil
+}
+
+// deadLetterXMessage dead-letters the stream entry with ErrMaxDeliveries,
+// returning true if it can be acknowledged. An entry which fails to decode
+// is dead-lettered as is, under the topic of the stream.
+func (r *RedisMessageBus) deadLetterXMessage(logger sabuhp.Logger, topicName string, message redis.XMessage) bool {
+	var messageBytes []byte
+	switch data := message.Values["data"].(type) {
+	case string:
+		messageBytes = []byte(data)
+	case []byte:
+		messageBytes = data
+	}
+
+	var decodedMessage, decodedErr = r.decode(messageBytes)
+	if decodedErr != nil {
+		decodedMessage = sabuhp.Message{
+			Topic: sabuhp.T(topicName),
+			Bytes: messageBytes,
+		}
+	}
+
+	if deadLetterErr := r.DeadLetter(decodedMessage, ErrMaxDeliveries); deadLetterErr != nil {
+		njson.Log(logger).New().
+			LError().
+			Message("failed to dead-letter message").
+			String("stream_name", topicName).
+			String("message_id", message.ID).
+			String("error", deadLetterErr.Error()).
+			End()
+		return false
+	}
+	return true
+}
+
+// nextStreamId returns the stream id following id.
+func nextStreamId(id string) string {
+	var millis, seq = splitStreamId(id)
+	return fmt.Sprintf("%d-%d", millis, seq+1)
+}
+
+// reclaimDue returns true if the subscription should reclaim pending
+// entries, updating the time of its last reclaim.
+func (r *RedisMessageBus) reclaimDue(pub *redisSubscription) bool {
+	if r.config.ReclaimMinIdle <= 0 || pub.ackMode != AckOnHandle {
+		return false
+	}
+	if !pub.lastReclaim.IsZero() && time.Since(pub.lastReclaim) < r.config.ReclaimInterval {
+		return false
+	}
+	pub.lastReclaim = time.Now()
+	return true
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/gob"
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -30,13 +31,30 @@ import (
 const (
 	GroupExistErrorMsg        = "BUSYGROUP Consumer Group name already exists"
 	SubscriptionExistsAlready = "String is already subscribed to"
+
+	// AnyGroup is the group for listeners on a pubsub bus, where every
+	// listener receives every message (fan-out).
+	AnyGroup = "*"
 )
 
 var (
-	DefaultMessageBatchCount = 200
-	DefaultMessageBatchWait  = 700 * time.Millisecond
+	DefaultMessageBatchCount  = 200
+	DefaultMessageBatchWait   = 700 * time.Millisecond
+	DefaultHealthCheckTimeout = time.Second
+	DefaultScheduleInterval   = 50 * time.Millisecond
+	DefaultStreamBlockTimeout = 3 * time.Second
+	DefaultReclaimInterval    = time.Second
+	DefaultRetentionInterval  = time.Minute
 )
 
+// ErrRedisUnreachable is returned by Start when Config.VerifyConnectionOnStart
+// is set and redis does not respond to a ping.
+var ErrRedisUnreachable = nerror.New("redis is unreachable")
+
+// ErrRepliesDisabled is returned by SendForReply when Config.DisableReplies
+// is set.
+var ErrRepliesDisabled = nerror.New("reply support disabled")
+
 // Channel implements the sabuhp.Channel interface.
 type Channel struct {
 	id           nxid.ID
@@ -55,6 +73,14 @@ func (r *Channel) Close() {
 
 var _ sabuhp.MessageBus = (*RedisMessageBus)(nil)
 
+var _ sabuhp.HealthReporter = (*RedisMessageBus)(nil)
+
+var _ sabuhp.Lifecycle = (*RedisMessageBus)(nil)
+
+var _ sabuhp.ReplyTopicResolver = (*RedisMessageBus)(nil)
+
+var _ sabuhp.ReplyGroupResolver = (*RedisMessageBus)(nil)
+
 var _ sabuhp.Channel = (*redisSubscription)(nil)
 
 type redisSubscription struct {
@@ -67,7 +93,28 @@ type redisSubscription struct {
 	cancel     context.CancelFunc
 	initialMsg chan interface{}
 	stream     *redis.StatusCmd
+	logger     sabuhp.Logger
 	err        error
+	ackMode    AckMode
+	closer     sync.Once
+
+	// backlogEnd is the id of the last entry of the stream's backlog,
+	// onCaughtUp is called once an entry at or after it was handled.
+	backlogEnd   string
+	onCaughtUp   func()
+	caughtUpOnce sync.Once
+
+	// lastReclaim is the time the consumer last claimed pending entries
+	// of other consumers, only used by its reading goroutine.
+	lastReclaim time.Time
+
+	// acks are the handled entries awaiting acknowledgement when acks are
+	// batched, only used by its reading goroutine.
+	acks ackBatch
+}
+
+func (r *redisSubscription) ID() nxid.ID {
+	return r.id
 }
 
 func (r *redisSubscription) Topic() string {
@@ -79,36 +126,181 @@ func (r *redisSubscription) Group() string {
 }
 
 func (r *redisSubscription) Close() {
-	r.cancel()
+	r.closer.Do(func() {
+		r.cancel()
+		if r.stream != nil {
+			r.host.releaseGroup(r.topic, r.group)
+		}
+	})
 }
 
 func (r *redisSubscription) Err() error {
 	return r.err
 }
 
+// SlowHandlerFunc is called with the topic and processing duration of
+// a handler which exceeded the Config.SlowHandlerThreshold.
+type SlowHandlerFunc func(topic string, elapsed time.Duration)
+
 type MessageChannel int
 
 const (
 	RedisPubSub MessageChannel = iota
 	RedisStreams
+
+	// RedisHybrid selects streams or pubsub per topic with Config.DurableTopic.
+	RedisHybrid
 )
 
 type Config struct {
-	Logger                    sabuhp.Logger
-	Ctx                       context.Context
-	Codec                     sabuhp.Codec
+	Logger sabuhp.Logger
+	Ctx    context.Context
+	Codec  sabuhp.Codec
+
+	// Redis are the options of the redis client, for servers using ACL
+	// authentication (Redis 6+) set both Redis.Username and Redis.Password,
+	// with only a Password the client authenticates as the default user.
 	Redis                     redis.Options
 	MaxWaitForSubConfirmation time.Duration
 	StreamMessageInterval     time.Duration
 	MaxWaitForSubRetry        int
 	MaxMessageBatch           int
 	MaxMessageBatchWait       time.Duration
+	HealthCheckTimeout        time.Duration
+
+	// VerifyConnectionOnStart makes Start ping redis and fail with
+	// ErrRedisUnreachable if it does not respond within HealthCheckTimeout,
+	// so a misconfigured bus fails fast instead of on its first Send.
+	// Stream, PubSub and Hybrid already ping when creating the bus, the
+	// flag matters for buses created with NewRedisMessageBus or started
+	// long after their creation.
+	VerifyConnectionOnStart bool
+
+	// StreamBlockTimeout is how long a stream consumer blocks on redis
+	// waiting for new messages before reading again, a failed read is
+	// retried after StreamMessageInterval.
+	StreamBlockTimeout time.Duration
+
+	// Retention are the retention policies of stream topics, applied
+	// every RetentionInterval with XTRIM, or XDEL for age limits.
+	Retention         map[string]RetentionPolicy
+	RetentionInterval time.Duration
+
+	// DeadLetterStore is where messages are dead-lettered and replayed
+	// from, defaults to a RedisDeadLetterStore using the bus.
+	DeadLetterStore sabuhp.DeadLetterStore
+
+	// AckBatchSize when above one makes stream consumers acknowledge
+	// handled messages in batches of up to AckBatchSize ids per XACK,
+	// flushed once full, once AckFlushInterval passed since the last flush
+	// and whenever the stream is idle or the subscription closes.
+	//
+	// Delivery stays at-least-once: messages handled but not yet
+	// acknowledged when the process crashes are pending on the group and
+	// are handled again once reclaimed (see ReclaimMinIdle).
+	//
+	// Batched entries stay pending till flushed, so with ReclaimMinIdle
+	// set AckFlushInterval must be below it, else other consumers reclaim
+	// and handle again messages already handled. An AckFlushInterval not
+	// below ReclaimMinIdle is lowered to half of it.
+	AckBatchSize     int
+	AckFlushInterval time.Duration
+
+	// IncludeRawFrame makes the raw frame of every handled message, i.e its
+	// bytes as read from redis and the stream entry id, available to
+	// handlers through sabuhp.RawFrame of the handler context.
+	IncludeRawFrame bool
+
+	// ReclaimMinIdle when set makes stream consumers claim the entries of
+	// their group pending on another consumer for longer than it, e.g of
+	// an instance which left or crashed before acknowledging them, and
+	// handle them. Claims happen at most every ReclaimInterval, between
+	// reads of the stream, and are reported to OnRebalance.
+	//
+	// It must exceed the time handlers take, else slow handlers have
+	// their messages claimed and handled twice.
+	ReclaimMinIdle  time.Duration
+	ReclaimInterval time.Duration
+	OnRebalance     RebalanceFunc
+
+	// MaxDeliveries when set caps the deliveries of a stream message: a
+	// pending entry already delivered as many times is dead-lettered with
+	// ErrMaxDeliveries on reclaim rather than handled again.
+	MaxDeliveries int
+
+	// SlowHandlerThreshold when set is the duration after which a handler
+	// is reported as slow through a warning log and OnSlowHandler.
+	SlowHandlerThreshold time.Duration
+	OnSlowHandler        SlowHandlerFunc
+
+	// Compress enables gzip compression of every encoded message before
+	// it's sent to redis.
+	//
+	// Redis (RESP) has no protocol level compression which go-redis could
+	// enable on the connection, hence compression is done per value.
+	// Consumers will always decompress gzip payloads, so producers with
+	// and without compression can share a topic.
+	Compress bool
+
+	// ScheduleKey is the sorted set holding messages with a ScheduledFor
+	// time till they are due, defaults to DefaultScheduleKey.
+	ScheduleKey string
+
+	// ScheduleInterval is the interval at which due scheduled messages
+	// are published.
+	ScheduleInterval time.Duration
+
+	// DisableTraceIds stops the bus from adding a trace id (see
+	// sabuhp.WithTraceId) to messages sent without one.
+	DisableTraceIds bool
+
+	// DisableReplies turns off reply support for publish-only buses,
+	// SendForReply then fails with ErrRepliesDisabled without subscribing
+	// to a reply topic or tracking a future for the request.
+	DisableReplies bool
+
+	// FilterAttributes are the Metadata keys whose values qualify the
+	// pubsub channel of messages, allowing ListenWithFilter to only
+	// receive messages with the given values.
+	//
+	// All producers and consumers of a topic must use the same list.
+	FilterAttributes []string
+
+	// RestartOnPanic restarts a goroutine of the bus which panicked, e.g.
+	// a subscription reader, otherwise the goroutine ends. Panics are
+	// always logged and reported by LastPanic.
+	RestartOnPanic bool
+
+	// TopicHierarchy is the hierarchy of the topics of the bus, messages
+	// sent with topics of the default hierarchy are sent with its
+	// separator, so their reply topics use it too.
+	//
+	// Pubsub listens of topics with wildcard segments receive the
+	// messages of the topics matched by TopicHierarchy.Match, e.g.
+	// "orders/*/created" or "orders/#" with "/".
+	TopicHierarchy sabuhp.TopicHierarchy
+
+	// Goroutines caps the goroutines of the bus: the consumer of each
+	// subscription and the waiter of each SendForReply request hold a slot
+	// while running. A Listen without a free slot fails with
+	// sabuhp.ErrGoroutineLimit while SendForReply waits for two, one for
+	// its waiter and one for the subscription to its reply, and fails with
+	// sabuhp.ErrGoroutineLimit on a limiter of a single slot. Buses sharing
+	// a limiter are capped together, defaults to an unlimited limiter.
+	Goroutines *sabuhp.GoroutineLimiter
+
+	// DurableTopic decides for a RedisHybrid bus if a topic is durable
+	// and uses redis streams, otherwise the topic uses redis pubsub.
+	DurableTopic func(topic string) bool
 }
 
 func (b *Config) ensure() {
 	if b.Logger == nil {
 		panic("Config.Logger is required")
 	}
+	if b.Goroutines == nil {
+		b.Goroutines = sabuhp.NewGoroutineLimiter(0)
+	}
 	if b.Ctx == nil {
 		panic("Config.ctx is required")
 	}
@@ -118,6 +310,9 @@ func (b *Config) ensure() {
 	if b.StreamMessageInterval <= 0 {
 		b.StreamMessageInterval = time.Second * 1
 	}
+	if b.StreamBlockTimeout <= 0 {
+		b.StreamBlockTimeout = DefaultStreamBlockTimeout
+	}
 	if b.MaxWaitForSubConfirmation <= 0 {
 		b.MaxWaitForSubConfirmation = time.Second * 3
 	}
@@ -130,6 +325,26 @@ func (b *Config) ensure() {
 	if b.MaxMessageBatch <= 0 {
 		b.MaxMessageBatch = DefaultMessageBatchCount
 	}
+	if b.HealthCheckTimeout <= 0 {
+		b.HealthCheckTimeout = DefaultHealthCheckTimeout
+	}
+	if len(b.ScheduleKey) == 0 {
+		b.ScheduleKey = DefaultScheduleKey
+	}
+	if b.ScheduleInterval <= 0 {
+		b.ScheduleInterval = DefaultScheduleInterval
+	}
+	if b.ReclaimInterval <= 0 {
+		b.ReclaimInterval = DefaultReclaimInterval
+	}
+	if b.AckBatchSize > 1 && b.ReclaimMinIdle > 0 {
+		if b.AckFlushInterval <= 0 || b.AckFlushInterval >= b.ReclaimMinIdle {
+			b.AckFlushInterval = b.ReclaimMinIdle / 2
+		}
+	}
+	if b.RetentionInterval <= 0 {
+		b.RetentionInterval = DefaultRetentionInterval
+	}
 }
 
 type RedisMessageBus struct {
@@ -139,11 +354,36 @@ type RedisMessageBus struct {
 	ctx           context.Context
 	canceller     context.CancelFunc
 	waiter        sync.WaitGroup
-	starter       sync.Once
 	stopper       sync.Once
 	doAction      chan func()
 	channel       MessageChannel
 	subscriptions []sabuhp.Channel
+
+	startMu sync.Mutex
+	started bool
+	stopped bool
+	pending []func()
+
+	// done is closed once Stop tore the bus down, ownsClient is set
+	// when the client was created for the bus and is closed with it.
+	done       chan struct{}
+	ownsClient bool
+
+	deadLetters sabuhp.DeadLetterStore
+
+	replyMu    sync.Mutex
+	replies    map[*sabuhp.ReplyFuture]struct{}
+	replyGroup string
+
+	groupsMu sync.Mutex
+	groups   map[string]*streamGroup
+
+	codecMu       sync.RWMutex
+	previousCodec sabuhp.Codec
+	transitionEnd time.Time
+
+	panicMu   sync.Mutex
+	lastPanic *PanicReport
 }
 
 func Stream(config Config) (*RedisMessageBus, error) {
@@ -152,7 +392,7 @@ func Stream(config Config) (*RedisMessageBus, error) {
 	if statusErr := status.Err(); statusErr != nil {
 		return nil, nerror.WrapOnly(statusErr)
 	}
-	return NewRedisMessageBus(config, client, RedisStreams), nil
+	return ownClient(NewRedisMessageBus(config, client, RedisStreams)), nil
 }
 
 func PubSub(config Config) (*RedisMessageBus, error) {
@@ -161,9 +401,24 @@ func PubSub(config Config) (*RedisMessageBus, error) {
 	if statusErr := status.Err(); statusErr != nil {
 		return nil, nerror.WrapOnly(statusErr)
 	}
-	return NewRedisMessageBus(config, client, RedisPubSub), nil
+	return ownClient(NewRedisMessageBus(config, client, RedisPubSub)), nil
+}
+
+// Hybrid returns a bus using redis streams for the topics reported
+// durable by Config.DurableTopic and redis pubsub for all others.
+func Hybrid(config Config) (*RedisMessageBus, error) {
+	var client = redis.NewClient(&config.Redis)
+	var status = client.Ping(config.Ctx)
+	if statusErr := status.Err(); statusErr != nil {
+		return nil, nerror.WrapOnly(statusErr)
+	}
+	return ownClient(NewRedisMessageBus(config, client, RedisHybrid)), nil
 }
 
+// NewRedisMessageBus returns a new bus using the client, which stays owned by
+// the caller and is not closed when the bus stops.
+//
+// Cancelling Config.Ctx tears a started bus down like Stop.
 func NewRedisMessageBus(config Config, client *redis.Client, channel MessageChannel) *RedisMessageBus {
 	config.ensure()
 	var newCtx, canceler = context.WithCancel(config.Ctx)
@@ -175,36 +430,287 @@ func NewRedisMessageBus(config Config, client *redis.Client, channel MessageChan
 		canceller: canceler,
 		channel:   channel,
 		doAction:  make(chan func()),
+		done:      make(chan struct{}),
+		replies:   map[*sabuhp.ReplyFuture]struct{}{},
+		groups:    map[string]*streamGroup{},
+
+		replyGroup: "replies-" + nxid.New().String(),
+	}
+
+	pubsub.deadLetters = config.DeadLetterStore
+	if pubsub.deadLetters == nil {
+		pubsub.deadLetters = NewRedisDeadLetterStore(pubsub)
 	}
+
 	return pubsub
 }
 
+// ownClient marks the client of the bus as created for it, so it's
+// closed when the bus stops.
+func ownClient(bus *RedisMessageBus) *RedisMessageBus {
+	bus.ownsClient = true
+	return bus
+}
+
+// stopOnCancel stops the bus once its context is cancelled, so cancelling
+// Config.Ctx closes subscriptions and the client like Stop.
+func (r *RedisMessageBus) stopOnCancel() {
+	<-r.ctx.Done()
+	_ = r.Stop()
+}
+
+// Wait blocks till the bus was stopped, either by Stop or by cancelling
+// Config.Ctx, and all its goroutines, subscriptions and owned client
+// are closed. It returns right away for a bus which was neither started
+// nor stopped, as it launched nothing to wait for.
 func (r *RedisMessageBus) Wait() {
-	r.waiter.Wait()
+	r.startMu.Lock()
+	var started = r.started
+	r.startMu.Unlock()
+
+	if !started {
+		return
+	}
+	<-r.done
 }
 
-func (r *RedisMessageBus) Start() {
-	r.starter.Do(func() {
+// Start starts the bus, launching the consumers of all subscriptions
+// created with Listen before the bus was started.
+//
+// With Config.VerifyConnectionOnStart set, Start first pings redis and
+// returns ErrRedisUnreachable if it does not respond.
+//
+// Start is safe to call concurrently and more than once, only the first
+// call starts the bus. It returns sabuhp.ErrBusClosed once the bus
+// was stopped.
+func (r *RedisMessageBus) Start() error {
+	r.startMu.Lock()
+	defer r.startMu.Unlock()
+
+	if r.stopped {
+		return sabuhp.ErrBusClosed
+	}
+	if r.started {
+		return nil
+	}
+
+	// the bus stays unstarted, so Start can be retried.
+	if r.config.VerifyConnectionOnStart && !r.Healthy() {
+		return ErrRedisUnreachable
+	}
+
+	r.launchPending()
+
+	// not part of the waiter, as it stops the bus which waits on it.
+	go r.stopOnCancel()
+
+	r.waiter.Add(2)
+	go r.manage()
+	go r.manageSchedule()
+
+	if len(r.config.Retention) > 0 {
 		r.waiter.Add(1)
-		go r.manage()
-	})
+		go r.manageRetention()
+	}
+	return nil
 }
 
-func (r *RedisMessageBus) Stop() {
+// Stop stops the bus, cancelling pending replies and closing all
+// subscriptions and the client if it was created by Stream, PubSub or
+// Hybrid. It returns sabuhp.ErrAlreadyStopped if the bus was already
+// stopped.
+func (r *RedisMessageBus) Stop() error {
+	var stopErr = sabuhp.ErrAlreadyStopped
 	r.stopper.Do(func() {
+		stopErr = nil
 		r.canceller()
+		r.cancelReplies()
+
+		// launch pending consumers so they can observe the
+		// closed context and exit.
+		r.startMu.Lock()
+		r.stopped = true
+		r.launchPending()
+		r.startMu.Unlock()
+
 		r.waiter.Wait()
+
+		// subscriptions of a bus which never started are not closed
+		// by its managing goroutine.
+		r.startMu.Lock()
+		var subs = r.subscriptions
+		r.subscriptions = nil
+		r.startMu.Unlock()
+
+		for _, sub := range subs {
+			sub.Close()
+		}
+
+		if r.ownsClient {
+			if closeErr := r.client.Close(); closeErr != nil {
+				njson.Log(r.logger).New().
+					LWarn().
+					Message("failed to close redis client").
+					Error("error", closeErr).
+					End()
+			}
+		}
+
+		close(r.done)
 	})
+	return stopErr
+}
+
+// launchPending must be called with startMu held.
+func (r *RedisMessageBus) launchPending() {
+	r.started = true
+	for _, consumer := range r.pending {
+		go consumer()
+	}
+	r.pending = nil
+}
+
+// launch runs the consumer of a subscription, if the bus is not yet started
+// the consumer will be launched on Start.
+//
+// It must only be called from a listen action executed by doListen.
+func (r *RedisMessageBus) launch(consumer func()) {
+	if !r.started {
+		r.pending = append(r.pending, consumer)
+		return
+	}
+	go consumer()
+}
+
+// doListen executes a listen action.
+//
+// Before the bus is started, the action is executed immediately, so the
+// subscription is registered with redis and no message sent after Listen
+// returns is missed, but its consumer is only launched on Start. After Start,
+// actions are executed by the bus managing goroutine.
+func (r *RedisMessageBus) doListen(topic string, grp string, doFunc func(), result chan sabuhp.Channel) sabuhp.Channel {
+	r.startMu.Lock()
+	if !r.started {
+		doFunc()
+		r.startMu.Unlock()
+		return <-result
+	}
+	r.startMu.Unlock()
+
+	select {
+	case r.doAction <- doFunc:
+		return <-result
+	case <-r.ctx.Done():
+		r.waiter.Done()
+		return &utils.CloseErrorChannel{T: topic, G: grp, Error: nerror.WrapOnly(r.ctx.Err())}
+	}
 }
 
+// acquireSlot acquires a slot of Config.Goroutines for a consumer unless
+// its caller already holds one.
+func (r *RedisMessageBus) acquireSlot(heldSlot bool) error {
+	if heldSlot {
+		return nil
+	}
+	return r.config.Goroutines.TryAcquire()
+}
+
+// ActiveGoroutines returns the number of goroutines holding a slot of
+// Config.Goroutines, including those of other buses sharing it.
+func (r *RedisMessageBus) ActiveGoroutines() int {
+	return r.config.Goroutines.Active()
+}
+
+// Healthy returns true if redis responds to a ping within Config.HealthCheckTimeout.
+func (r *RedisMessageBus) Healthy() bool {
+	var ctx, canceler = context.WithTimeout(r.ctx, r.config.HealthCheckTimeout)
+	defer canceler()
+	return r.client.Ping(ctx).Err() == nil
+}
+
+// Listen subscribes the handler to the topic with the giving group.
+//
+// The group is validated against the bus mode: a stream bus requires a
+// non-empty consumer group where listeners of the same group compete for
+// messages, while a pubsub bus only fans out, so only AnyGroup or an empty
+// group is accepted.
+//
+// A hybrid bus applies the rules of the mode used by the topic.
+//
+// Stream messages are acknowledged with AckOnHandle, see ListenWithAckMode.
+//
+// The MessageErr returned by the handler decides the outcome of a failed
+// message: if MessageErr.ShouldAck is true, the failure is only logged and
+// the message is done. Otherwise a stream message is left unacknowledged
+// in the pending entries of the group, while a pubsub message, which can
+// not be redelivered, is dead-lettered (see DeadLetter). A nil return
+// acknowledges the message.
 func (r *RedisMessageBus) Listen(topic string, grp string, handler sabuhp.TransportResponse) sabuhp.Channel {
-	if r.channel == RedisStreams {
-		return r.ListenStream(topic, grp, handler)
+	return r.ListenWithAckMode(topic, grp, AckOnHandle, handler)
+}
+
+// channelFor returns the channel used by the bus for the topic.
+func (r *RedisMessageBus) channelFor(topic string) MessageChannel {
+	return r.resolveChannel(r.channel, topic)
+}
+
+// resolveChannel returns the channel used for the topic by a
+// RedisHybrid channel, other channels are returned as is.
+func (r *RedisMessageBus) resolveChannel(channel MessageChannel, topic string) MessageChannel {
+	if channel != RedisHybrid {
+		return channel
 	}
-	return r.ListenPubSub(topic, grp, handler)
+	if r.config.DurableTopic != nil && r.config.DurableTopic(topic) {
+		return RedisStreams
+	}
+	return RedisPubSub
+}
+
+func (r *RedisMessageBus) validateGroup(topic string, grp string) error {
+	switch r.channelFor(topic) {
+	case RedisStreams:
+		if len(grp) == 0 {
+			return nerror.New("stream bus requires a consumer group")
+		}
+	case RedisPubSub:
+		if len(grp) != 0 && grp != AnyGroup {
+			return nerror.New(
+				"pubsub bus can not honor consumer group %q, use %q or an empty group",
+				grp,
+				AnyGroup,
+			)
+		}
+	}
+	return nil
 }
 
 func (r *RedisMessageBus) ListenStream(streamTopic string, grp string, handler sabuhp.TransportResponse) sabuhp.Channel {
+	return r.listenStream(streamTopic, grp, AckOnHandle, handler)
+}
+
+func (r *RedisMessageBus) listenStream(streamTopic string, grp string, mode AckMode, handler sabuhp.TransportResponse) sabuhp.Channel {
+	return r.listenStreamFrom(streamTopic, grp, mode, "$", handler, nil, false)
+}
+
+// listenStreamFrom subscribes the handler to the stream topic, a new group
+// starts reading after the start id. With onCaughtUp set, it's called once
+// the backlog of the group was handled.
+//
+// With heldSlot set the caller already holds the slot of Config.Goroutines
+// for the consumer, which takes it over unless the subscription fails.
+func (r *RedisMessageBus) listenStreamFrom(
+	streamTopic string,
+	grp string,
+	mode AckMode,
+	start string,
+	handler sabuhp.TransportResponse,
+	onCaughtUp func(),
+	heldSlot bool,
+) sabuhp.Channel {
+	if registerErr := r.registerGroup(streamTopic, grp, mode); registerErr != nil {
+		return &utils.CloseErrorChannel{T: streamTopic, G: grp, Error: registerErr}
+	}
+
 	var result = make(chan sabuhp.Channel, 1)
 
 	r.waiter.Add(1)
@@ -213,7 +719,9 @@ func (r *RedisMessageBus) ListenStream(streamTopic string, grp string, handler s
 		rs.id = nxid.New()
 		rs.group = grp
 		rs.topic = streamTopic
+		rs.ackMode = mode
 		rs.host = r
+		rs.logger = sabuhp.WithFields(r.logger, sabuhp.LogFields{"topic": streamTopic, "group": grp})
 
 		r.logger.Log(njson.MJSON("Creating stream group for topic", func(encoder npkg.Encoder) {
 			encoder.String("topic", streamTopic)
@@ -222,14 +730,13 @@ func (r *RedisMessageBus) ListenStream(streamTopic string, grp string, handler s
 			encoder.String("stream_group_name", grp)
 		}))
 
-		var streamGroup = r.client.XGroupCreateMkStream(r.ctx, streamTopic, grp, "$")
+		var streamGroup = r.client.XGroupCreateMkStream(r.ctx, streamTopic, grp, start)
 		rs.stream = streamGroup
 
 		if streamResponseErr := streamGroup.Err(); streamResponseErr != nil {
 			if !strings.Contains(streamResponseErr.Error(), GroupExistErrorMsg) {
 				// close waiter
 				r.waiter.Done()
-				r.waiter.Done()
 
 				rs.err = streamResponseErr
 				result <- rs
@@ -237,6 +744,33 @@ func (r *RedisMessageBus) ListenStream(streamTopic string, grp string, handler s
 			}
 		}
 
+		if onCaughtUp != nil {
+			var backlogEnd, backlogErr = r.backlogEnd(streamTopic, grp)
+			if backlogErr != nil {
+				// close waiter
+				r.waiter.Done()
+
+				rs.err = backlogErr
+				result <- rs
+				return
+			}
+
+			rs.backlogEnd = backlogEnd
+			rs.onCaughtUp = onCaughtUp
+			if backlogEnd == "" {
+				rs.caughtUp()
+			}
+		}
+
+		if limitErr := r.acquireSlot(heldSlot); limitErr != nil {
+			// close waiter
+			r.waiter.Done()
+
+			rs.err = limitErr
+			result <- rs
+			return
+		}
+
 		var ctx, canceler = context.WithCancel(r.ctx)
 
 		rs.ctx = ctx
@@ -245,7 +779,10 @@ func (r *RedisMessageBus) ListenStream(streamTopic string, grp string, handler s
 		// register sub with subscriptions
 		r.subscriptions = append(r.subscriptions, rs)
 
-		go r.listenForStream(ctx, handler, rs, streamTopic, grp)
+		r.launch(func() {
+			defer r.config.Goroutines.Release()
+			r.listenForStream(ctx, handler, rs, streamTopic, grp)
+		})
 
 		r.logger.Log(njson.MJSON("Launched pubsub channel and stream readers", func(encoder npkg.Encoder) {
 			encoder.String("topic", streamTopic)
@@ -257,25 +794,37 @@ func (r *RedisMessageBus) ListenStream(streamTopic string, grp string, handler s
 		result <- rs
 	}
 
-	select {
-	case r.doAction <- doFunc:
-		return <-result
-	case <-r.ctx.Done():
-		return &utils.CloseErrorChannel{T: streamTopic, G: grp, Error: nerror.WrapOnly(r.ctx.Err())}
+	var channel = r.doListen(streamTopic, grp, doFunc, result)
+	if channel.Err() != nil {
+		r.releaseGroup(streamTopic, grp)
 	}
+	return channel
 }
 
 func (r *RedisMessageBus) ListenPubSub(topic string, grp string, handler sabuhp.TransportResponse) sabuhp.Channel {
+	var pattern, _ = r.attributePattern(topic, nil)
+	return r.listenPubSub(topic, pattern, grp, handler, false)
+}
+
+// listenPubSub subscribes the handler to the channels of the topic
+// matching the pattern, heldSlot is as for listenStreamFrom.
+func (r *RedisMessageBus) listenPubSub(topic string, pattern string, grp string, handler sabuhp.TransportResponse, heldSlot bool) sabuhp.Channel {
+	if r.isTopicPattern(topic) {
+		handler = r.matchingTopics(topic, handler)
+	}
+
 	var result = make(chan sabuhp.Channel, 1)
 
 	r.waiter.Add(1)
 	var doFunc = func() {
-		var pub = r.client.PSubscribe(r.ctx, topic)
+		var pub = r.client.PSubscribe(r.ctx, pattern)
 
 		var rs = new(redisSubscription)
 		rs.id = nxid.New()
+		rs.group = grp
 		rs.topic = topic
 		rs.host = r
+		rs.logger = sabuhp.WithFields(r.logger, sabuhp.LogFields{"topic": topic, "group": grp})
 
 		r.logger.Log(njson.MJSON("Creating stream group for topic", func(encoder npkg.Encoder) {
 			encoder.String("topic", topic)
@@ -303,10 +852,10 @@ func (r *RedisMessageBus) ListenPubSub(topic string, grp string, handler sabuhp.
 		}))
 
 		if firstErrMsg, ok := firstMessage.(redis.Error); ok {
+			_ = pub.Close()
 
 			// close waiter
 			r.waiter.Done()
-			r.waiter.Done()
 
 			r.logger.Log(njson.MJSON("Received pubsub error", func(encoder npkg.Encoder) {
 				encoder.String("topic", topic)
@@ -319,6 +868,17 @@ func (r *RedisMessageBus) ListenPubSub(topic string, grp string, handler sabuhp.
 			return
 		}
 
+		if limitErr := r.acquireSlot(heldSlot); limitErr != nil {
+			_ = pub.Close()
+
+			// close waiter
+			r.waiter.Done()
+
+			rs.err = limitErr
+			result <- rs
+			return
+		}
+
 		var initialMessage = make(chan interface{}, 1)
 		initialMessage <- firstMessage
 
@@ -334,7 +894,10 @@ func (r *RedisMessageBus) ListenPubSub(topic string, grp string, handler sabuhp.
 		r.subscriptions = append(r.subscriptions, rs)
 
 		var pubChan = pub.Channel()
-		go r.listenForChannel(ctx, handler, rs, pubChan)
+		r.launch(func() {
+			defer r.config.Goroutines.Release()
+			r.listenForChannel(ctx, handler, rs, pubChan)
+		})
 
 		r.logger.Log(njson.MJSON("Launched pubsub channel and stream readers", func(encoder npkg.Encoder) {
 			encoder.String("topic", topic)
@@ -344,12 +907,7 @@ func (r *RedisMessageBus) ListenPubSub(topic string, grp string, handler sabuhp.
 		result <- rs
 	}
 
-	select {
-	case r.doAction <- doFunc:
-		return <-result
-	case <-r.ctx.Done():
-		return &utils.CloseErrorChannel{T: topic, G: grp, Error: nerror.WrapOnly(r.ctx.Err())}
-	}
+	return r.doListen(topic, grp, doFunc, result)
 }
 
 func (r *RedisMessageBus) listenForStream(
@@ -359,55 +917,96 @@ func (r *RedisMessageBus) listenForStream(
 	streamName string,
 	streamGroupName string,
 ) {
-	defer func() {
-		r.waiter.Done()
+	defer r.waiter.Done()
 
-		if panicInfo := recover(); panicInfo != nil {
-			r.logger.Log(njson.MJSON("panic occurred", func(event npkg.Encoder) {
-				event.Int("_level", int(npkg.PANIC))
-				event.String("panic_data", fmt.Sprintf("%#v", panicInfo))
-				event.String("stream_name", streamName)
-				event.String("stream_group_name", streamGroupName)
-			}))
-		}
+	r.supervise("stream:"+streamName+":"+streamGroupName, func() {
+		r.readStream(ctx, handler, pub, streamName, streamGroupName)
+	})
+}
+
+func (r *RedisMessageBus) readStream(
+	ctx context.Context,
+	handler sabuhp.TransportResponse,
+	pub *redisSubscription,
+	streamName string,
+	streamGroupName string,
+) {
+	// acks still batched when the subscription closes are flushed.
+	defer func() {
+		r.ack(pub, streamName, streamGroupName, pub.acks.flush())
 	}()
 
-	var msgTicker = time.NewTicker(r.config.StreamMessageInterval)
-	defer msgTicker.Stop()
+	// the entries pending on the consumer, e.g handled but not acknowledged
+	// before the reader was restarted, are read again from the start of
+	// its pending entries list before reading new entries with ">".
+	var readId = "0"
 
 doLoop:
 	for {
-		select {
-		case <-ctx.Done():
+		if ctx.Err() != nil {
 			break doLoop
-		case <-msgTicker.C:
 		}
 
+		if r.reclaimDue(pub) {
+			if reclaimErr := r.reclaim(ctx, handler, pub, streamName, streamGroupName); reclaimErr != nil && ctx.Err() == nil {
+				njson.Log(pub.logger).New().
+					LError().
+					Message("failed to reclaim pending messages").
+					String("stream_name", streamName).
+					String("stream_group_name", streamGroupName).
+					Error("error", reclaimErr).
+					End()
+			}
+		}
+
+		// block on redis till a message arrives or the block timeout
+		// elapses, rather than polling the stream.
 		var stream = r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
 			Group:    streamGroupName,
-			Consumer: fmt.Sprintf("%s_consumer_%s", pub.topic, pub.id.String()),
-			Streams:  []string{streamName, ">"},
+			Consumer: consumerName(pub),
+			Streams:  []string{streamName, readId},
 			Count:    1,
-			Block:    time.Second * 3,
-			NoAck:    false,
+			Block:    r.config.StreamBlockTimeout,
+			NoAck:    pub.ackMode == AckOnRead,
 		})
 
-		if streamErr := stream.Err(); streamErr != nil && streamErr != redis.Nil {
-			r.logger.Log(njson.MJSON("stream err occurred", func(event npkg.Encoder) {
+		var streamErr = stream.Err()
+
+		// redis.Nil means no message arrived within the block timeout,
+		// the idle stream flushes batched acks. No entry being left for the
+		// consumer, the backlog was drained, whether by it or by other
+		// consumers of the group, or trimmed.
+		if streamErr == redis.Nil {
+			r.ack(pub, streamName, streamGroupName, pub.acks.flush())
+			if pub.onCaughtUp != nil {
+				pub.caughtUp()
+			}
+			continue doLoop
+		}
+
+		if streamErr != nil {
+			// the read was interrupted by the subscription closing.
+			if ctx.Err() != nil {
+				break doLoop
+			}
+
+			pub.logger.Log(njson.MJSON("stream err occurred", func(event npkg.Encoder) {
 				event.Int("_level", int(npkg.ERROR))
 				event.String("error", streamErr.Error())
 				event.String("stream_name", streamName)
 				event.String("stream_group_name", streamGroupName)
 			}))
-			continue doLoop
-		}
 
-		// re-loop as there was not a message pending.
-		if streamErr := stream.Err(); streamErr != nil && streamErr == redis.Nil {
+			// wait before retrying so a failing redis is not hammered.
+			select {
+			case <-ctx.Done():
+				break doLoop
+			case <-time.After(r.config.StreamMessageInterval):
+			}
 			continue doLoop
 		}
 
-		r.logger.Log(njson.MJSON("stream responded", func(event npkg.Encoder) {
+		pub.logger.Log(njson.MJSON("stream responded", func(event npkg.Encoder) {
 			event.String("value", fmt.Sprintf("%#v", stream.Val()))
 			event.String("id", stream.FullName())
 			event.String("stream_name", streamName)
@@ -415,62 +1014,93 @@ doLoop:
 			event.Int("_level", int(npkg.INFO))
 		}))
 
+		if readId != ">" {
+			var pendingLeft bool
+			for _, xstream := range stream.Val() {
+				if len(xstream.Messages) > 0 {
+					readId = xstream.Messages[len(xstream.Messages)-1].ID
+					pendingLeft = true
+				}
+			}
+
+			// the pending entries list is read, new entries are next.
+			if !pendingLeft {
+				readId = ">"
+				continue doLoop
+			}
+		}
+
 		for _, xstream := range stream.Val() {
 			var ackIdList = make([]string, 0, len(xstream.Messages))
 			for _, message := range xstream.Messages {
-				if shouldAck := r.handleXMessage(streamName, handler, message); shouldAck {
+				var shouldAck = r.handleXMessage(pub.logger, streamName, pub.ackMode, handler, message)
+				if shouldAck && pub.ackMode == AckOnHandle {
 					ackIdList = append(ackIdList, message.ID)
 				}
+				if pub.onCaughtUp != nil && compareStreamIds(message.ID, pub.backlogEnd) >= 0 {
+					pub.caughtUp()
+				}
 			}
 
-			if len(ackIdList) > 0 {
-				func(ackIds []string) {
-					var ackCmd = r.client.XAck(ctx, streamName, streamGroupName, ackIdList...)
-					if ackErr := ackCmd.Err(); nil != ackErr {
-						r.logger.Log(njson.MJSON("failed to ack messages", func(event npkg.Encoder) {
-							event.String("value", fmt.Sprintf("%#v", stream.Val()))
-							event.Int("_level", int(npkg.ERROR))
-							event.ListFor("ack_ids", func(idList npkg.ListEncoder) {
-								for _, id := range ackIds {
-									idList.AddString(id)
-								}
-							})
-							event.String("error", ackErr.Error())
-							event.String("stream_name", streamName)
-							event.String("stream_group_name", streamGroupName)
-							event.String("response_string", ackCmd.String())
-							event.Int64("response_code", ackCmd.Val())
-							event.String("response_name", ackCmd.Name())
-							event.String("response_full_name", ackCmd.FullName())
-						}))
-						return
-					}
-					r.logger.Log(njson.MJSON("sent acknowledgment for messages", func(event npkg.Encoder) {
-						event.String("value", fmt.Sprintf("%#v", stream.Val()))
-						event.String("stream_name", streamName)
-						event.String("response_string", ackCmd.String())
-						event.Int("_level", int(npkg.INFO))
-						event.Int64("response_code", ackCmd.Val())
-						event.String("response_name", ackCmd.Name())
-						event.String("response_full_name", ackCmd.FullName())
-						event.String("stream_group_name", streamGroupName)
-						event.ListFor("ack_ids", func(idList npkg.ListEncoder) {
-							for _, id := range ackIds {
-								idList.AddString(id)
-							}
-						})
-					}))
-				}(ackIdList)
+			if r.config.AckBatchSize > 1 {
+				pub.acks.add(ackIdList...)
+				if pub.acks.due(r.config.AckBatchSize, r.config.AckFlushInterval) {
+					r.ack(pub, streamName, streamGroupName, pub.acks.flush())
+				}
+				continue
 			}
+
+			r.ack(pub, streamName, streamGroupName, ackIdList)
 		}
 	}
 }
 
-func (r *RedisMessageBus) handleXMessage(topicName string, handler sabuhp.TransportResponse, message redis.XMessage) bool {
+// handle calls the handler with the message, reporting the handler as slow
+// if it exceeds the Config.SlowHandlerThreshold.
+func (r *RedisMessageBus) handle(topicName string, handler sabuhp.TransportResponse, msg sabuhp.Message, frame sabuhp.Frame) sabuhp.MessageErr {
+	njson.Log(r.logger).New().
+		LInfo().
+		Message("handling message").
+		String("topic", topicName).
+		String("message_id", msg.Id.String()).
+		String("trace_id", sabuhp.TraceId(msg)).
+		End()
+
+	var ctx = r.ctx
+	if r.config.IncludeRawFrame {
+		// the frame bytes may alias the payload string read from redis.
+		frame.Bytes = append([]byte(nil), frame.Bytes...)
+		ctx = sabuhp.WithFrame(ctx, frame)
+	}
+
+	var started = time.Now()
+	var handleErr = handler.Handle(ctx, msg, sabuhp.Transport{Bus: r})
+	var elapsed = time.Since(started)
+
+	if r.config.SlowHandlerThreshold > 0 && elapsed > r.config.SlowHandlerThreshold {
+		njson.Log(r.logger).New().
+			LWarn().
+			Message("slow message handler").
+			String("topic", topicName).
+			String("message_id", msg.Id.String()).
+			String("trace_id", sabuhp.TraceId(msg)).
+			Int64("duration_ms", elapsed.Milliseconds()).
+			Int64("threshold_ms", r.config.SlowHandlerThreshold.Milliseconds()).
+			End()
+
+		if r.config.OnSlowHandler != nil {
+			r.config.OnSlowHandler(topicName, elapsed)
+		}
+	}
+
+	return handleErr
+}
+
+func (r *RedisMessageBus) handleXMessage(logger sabuhp.Logger, topicName string, mode AckMode, handler sabuhp.TransportResponse, message redis.XMessage) bool {
 	defer func() {
 		if panicInfo := recover(); panicInfo != nil {
-			r.logger.Log(njson.MJSON("panic occurred processing message", func(event npkg.Encoder) {
-				event.String("topic", topicName)
+			r.recordPanic("handler:"+topicName, panicInfo)
+			logger.Log(njson.MJSON("panic occurred processing message", func(event npkg.Encoder) {
 				event.Int("_level", int(npkg.PANIC))
 				event.String("message_id", message.ID)
 				event.String("panic_data", fmt.Sprintf("%#v", panicInfo))
@@ -485,8 +1115,7 @@ func (r *RedisMessageBus) handleXMessage(topicName string, handler sabuhp.Transp
 
 	var messageData, hasMessageData = message.Values["data"]
 	if !hasMessageData {
-		r.logger.Log(njson.MJSON("failed to find 'data' key in message key-value map", func(event npkg.Encoder) {
-			event.String("topic", topicName)
+		logger.Log(njson.MJSON("failed to find 'data' key in message key-value map", func(event npkg.Encoder) {
 			event.String("message_id", message.ID)
 			event.Int("_level", int(npkg.WARN))
 			event.ObjectFor("message_values", func(valueEncoder npkg.ObjectEncoder) {
@@ -498,8 +1127,7 @@ func (r *RedisMessageBus) handleXMessage(topicName string, handler sabuhp.Transp
 		return false
 	}
 
-	r.logger.Log(njson.MJSON("received data from xmessage", func(event npkg.Encoder) {
-		event.String("topic", topicName)
+	logger.Log(njson.MJSON("received data from xmessage", func(event npkg.Encoder) {
 		event.Int("_level", int(npkg.INFO))
 		event.String("message_id", message.ID)
 		event.String("message_data", fmt.Sprintf("%s", messageData))
@@ -514,18 +1142,27 @@ func (r *RedisMessageBus) handleXMessage(topicName string, handler sabuhp.Transp
 		messageBytes = msg
 	}
 
-	r.logger.Log(njson.MJSON("decoded message type into bytes", func(event npkg.Encoder) {
-		event.String("topic", topicName)
+	logger.Log(njson.MJSON("decoded message type into bytes", func(event npkg.Encoder) {
 		event.Int("_level", int(npkg.INFO))
 		event.String("message_id", message.ID)
 		event.String("message_bytes", fmt.Sprintf("%+q", messageBytes))
 		event.String("message_data_type", fmt.Sprintf("%T", messageBytes))
 	}))
 
-	var decodedMessage, decodedErr = r.config.Codec.Decode(messageBytes)
+	var decodedMessage, decodedErr = r.decode(messageBytes)
 	if decodedErr != nil {
-		r.logger.Log(njson.MJSON("failed to decode message", func(event npkg.Encoder) {
-			event.String("topic", topicName)
+		// a message without a topic can never be routed, it is acknowledged
+		// so it is not redelivered. Other failures, e.g a codec not yet
+		// deployed on this consumer, leave the entry pending for another
+		// consumer to reclaim or for dead-lettering after
+		// Config.MaxDeliveries.
+		var malformed = nerror.IsAny(decodedErr, ErrMalformedMessage)
+
+		var reason = "failed to decode message, leaving it pending"
+		if malformed {
+			reason = "failed to decode message, skipping malformed message"
+		}
+		logger.Log(njson.MJSON(reason, func(event npkg.Encoder) {
 			event.Int("_level", int(npkg.ERROR))
 			event.String("message_id", message.ID)
 			event.String("error", fmt.Sprintf("%#v", decodedErr))
@@ -535,11 +1172,12 @@ func (r *RedisMessageBus) handleXMessage(topicName string, handler sabuhp.Transp
 				}
 			})
 		}))
+		return malformed
 	}
 
-	if handleErr := handler.Handle(r.ctx, decodedMessage, sabuhp.Transport{Bus: r}); handleErr != nil {
-		r.logger.Log(njson.MJSON("failed to handle message", func(event npkg.Encoder) {
-			event.String("topic", topicName)
+	var frame = sabuhp.Frame{Transport: "redis-stream", Id: message.ID, Channel: topicName, Bytes: messageBytes}
+	if handleErr := r.handle(topicName, handler, decodedMessage, frame); handleErr != nil {
+		logger.Log(njson.MJSON("failed to handle message", func(event npkg.Encoder) {
 			event.String("message_id", message.ID)
 			event.Int("_level", int(npkg.ERROR))
 			event.String("error", handleErr.Error())
@@ -549,11 +1187,36 @@ func (r *RedisMessageBus) handleXMessage(topicName string, handler sabuhp.Transp
 				}
 			})
 		}))
+
+		// messages already acknowledged can not be redelivered.
+		if mode == AckOnRead {
+			r.deadLetterFailure(decodedMessage, handleErr)
+		}
 		return handleErr.ShouldAck()
 	}
 	return true
 }
 
+// deadLetterFailure dead-letters a message whose handler failed when the
+// failure was not acknowledged, for messages which can not be redelivered.
+func (r *RedisMessageBus) deadLetterFailure(msg sabuhp.Message, handleErr sabuhp.MessageErr) {
+	if handleErr.ShouldAck() {
+		return
+	}
+
+	// the reply future is not part of the dead-lettered message.
+	msg.Future = nil
+	if deadLetterErr := r.DeadLetter(msg, handleErr); deadLetterErr != nil {
+		njson.Log(r.logger).New().
+			LError().
+			Message("failed to dead-letter message").
+			String("topic", msg.Topic.String()).
+			String("message_id", msg.Id.String()).
+			String("error", deadLetterErr.Error()).
+			End()
+	}
+}
+
 func (r *RedisMessageBus) listenForChannel(
 	ctx context.Context,
 	handler sabuhp.TransportResponse,
@@ -561,29 +1224,35 @@ func (r *RedisMessageBus) listenForChannel(
 	messages <-chan *redis.Message,
 ) {
 	defer func() {
-		r.waiter.Done()
-
-		if panicInfo := recover(); panicInfo != nil {
-			r.logger.Log(njson.MJSON("panic occurred", func(event npkg.Encoder) {
-				event.Int("_level", int(npkg.PANIC))
-				event.String("panic_data", fmt.Sprintf("%#v", panicInfo))
-			}))
-		}
+		defer r.waiter.Done()
 
 		if closeErr := pub.pub.Close(); closeErr != nil {
-			r.logger.Log(njson.MJSON("error out during subscription closing", func(event npkg.Encoder) {
+			pub.logger.Log(njson.MJSON("error out during subscription closing", func(event npkg.Encoder) {
 				event.Int("_level", int(npkg.ERROR))
 				event.String("error", nerror.WrapOnly(closeErr).Error())
 			}))
 		}
 
-		r.canceller()
+		// only this subscription ends, the bus and its other
+		// subscriptions keep running.
+		pub.cancel()
 
-		r.logger.Log(njson.MJSON("closed listener for channel", func(event npkg.Encoder) {
+		pub.logger.Log(njson.MJSON("closed listener for channel", func(event npkg.Encoder) {
 			event.Int("_level", int(npkg.INFO))
 		}))
 	}()
 
+	r.supervise("pubsub:"+pub.topic, func() {
+		r.readChannel(ctx, handler, pub, messages)
+	})
+}
+
+func (r *RedisMessageBus) readChannel(
+	ctx context.Context,
+	handler sabuhp.TransportResponse,
+	pub *redisSubscription,
+	messages <-chan *redis.Message,
+) {
 doLoop:
 	for {
 		select {
@@ -591,24 +1260,24 @@ doLoop:
 			break doLoop
 		case msg := <-pub.initialMsg:
 			if redisMsg, ok := msg.(*redis.Message); ok {
-				r.handleMessage(handler, redisMsg)
+				r.handleMessage(pub.logger, handler, redisMsg)
 			}
 		case msg := <-messages:
-			r.logger.Log(njson.MJSON("Received new msg", func(event npkg.Encoder) {
+			pub.logger.Log(njson.MJSON("Received new msg", func(event npkg.Encoder) {
 				event.Int("_level", int(npkg.INFO))
-				event.String("topic", pub.topic)
 				event.String("message", fmt.Sprintf("%#v", msg))
 			}))
-			r.handleMessage(handler, msg)
+			r.handleMessage(pub.logger, handler, msg)
 		}
 	}
 }
 
-func (r *RedisMessageBus) handleMessage(handler sabuhp.TransportResponse, message *redis.Message) {
+func (r *RedisMessageBus) handleMessage(logger sabuhp.Logger, handler sabuhp.TransportResponse, message *redis.Message) {
 	defer func() {
 		var panicErr = nerror.New("panic occurred in redis.handleMessage")
 		if panicInfo := recover(); panicInfo != nil {
-			r.logger.Log(njson.MJSON("panic occurred handling pubsub message", func(event npkg.Encoder) {
+			r.recordPanic("handler:"+message.Channel, panicInfo)
+			logger.Log(njson.MJSON("panic occurred handling pubsub message", func(event npkg.Encoder) {
 				event.Error("error", panicErr)
 				event.Int("_level", int(npkg.ERROR))
 				event.String("message", message.String())
@@ -618,36 +1287,41 @@ func (r *RedisMessageBus) handleMessage(handler sabuhp.TransportResponse, messag
 		}
 	}()
 
-	r.logger.Log(njson.MJSON("received message to decode", func(event npkg.Encoder) {
-		event.String("topic", message.Channel)
+	logger.Log(njson.MJSON("received message to decode", func(event npkg.Encoder) {
+		event.String("channel", message.Channel)
 		event.Int("_level", int(npkg.INFO))
 		event.String("pattern", message.Pattern)
 		event.String("payload", message.Payload)
 	}))
 
 	var payloadBytes = nunsafe.String2Bytes(message.Payload)
-	var decodedMessage, decodedErr = r.config.Codec.Decode(payloadBytes)
+	var decodedMessage, decodedErr = r.decode(payloadBytes)
 	if decodedErr != nil {
-		r.logger.Log(njson.MJSON("failed to decode message", func(event npkg.Encoder) {
-			event.String("topic", message.Channel)
+		logger.Log(njson.MJSON("failed to decode message, skipping malformed message", func(event npkg.Encoder) {
+			event.String("channel", message.Channel)
 			event.String("pattern", message.Pattern)
 			event.Int("_level", int(npkg.ERROR))
 			event.String("payload", message.Payload)
 			event.String("error", decodedErr.Error())
 		}))
+		return
 	}
 
 	decodedMessage.Future = nthen.NewFuture()
 
-	if handleErr := handler.Handle(r.ctx, decodedMessage, sabuhp.Transport{Bus: r}); handleErr != nil {
+	var frame = sabuhp.Frame{Transport: "redis-pubsub", Channel: message.Channel, Bytes: payloadBytes}
+	if handleErr := r.handle(decodedMessage.Topic.String(), handler, decodedMessage, frame); handleErr != nil {
 		decodedMessage.Future.WithError(handleErr)
-		r.logger.Log(njson.MJSON("failed to handle message", func(event npkg.Encoder) {
-			event.String("topic", message.Channel)
+		logger.Log(njson.MJSON("failed to handle message", func(event npkg.Encoder) {
+			event.String("channel", message.Channel)
 			event.String("pattern", message.Pattern)
 			event.Int("_level", int(npkg.ERROR))
 			event.String("payload", message.Payload)
 			event.String("error", handleErr.Error())
 		}))
+
+		// pubsub messages are never redelivered.
+		r.deadLetterFailure(decodedMessage, handleErr)
 		return
 	}
 
@@ -658,51 +1332,358 @@ func (r *RedisMessageBus) Send(data ...sabuhp.Message) {
 	r.sendChannelBatch(data, r.channel)
 }
 
-func (r *RedisMessageBus) SendForReply(tm time.Duration, fromTopic sabuhp.Topic, replyGroup string, data ...sabuhp.Message) *nthen.Future {
-	var ft = nthen.Fn(func(ft *nthen.Future) {
-		var replyChannel = r.Listen(fromTopic.ReplyTopic().String(), replyGroup, sabuhp.TransportResponseFunc(func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
-			// delete reply stream
-			var intCmd = r.client.Del(ctx, fromTopic.ReplyTopic().String())
-			if intCmd.Err() != nil {
-				r.logger.Log(njson.MJSON("received message to decode", func(event npkg.Encoder) {
-					event.String("topic", message.Topic.String())
-					event.Int("_level", int(npkg.INFO))
-					event.Error("error", intCmd.Err())
-				}))
+// PendingReplies returns the number of SendForReply requests still
+// awaiting a reply.
+func (r *RedisMessageBus) PendingReplies() int {
+	r.replyMu.Lock()
+	defer r.replyMu.Unlock()
+	return len(r.replies)
+}
+
+// addReply registers the future as pending, returning false if the
+// bus is already closed.
+func (r *RedisMessageBus) addReply(ft *sabuhp.ReplyFuture) bool {
+	r.replyMu.Lock()
+	defer r.replyMu.Unlock()
+	if r.replies == nil {
+		return false
+	}
+	r.replies[ft] = struct{}{}
+	return true
+}
+
+func (r *RedisMessageBus) removeReply(ft *sabuhp.ReplyFuture) {
+	r.replyMu.Lock()
+	defer r.replyMu.Unlock()
+	delete(r.replies, ft)
+}
+
+// cancelReplies resolves all pending reply futures with sabuhp.ErrBusClosed.
+func (r *RedisMessageBus) cancelReplies() {
+	r.replyMu.Lock()
+	var replies = r.replies
+	r.replies = nil
+	r.replyMu.Unlock()
+
+	for ft := range replies {
+		ft.WithError(sabuhp.ErrBusClosed)
+	}
+}
+
+// ReplyGroup returns the reply group unique to the bus, which prefixes the
+// group of each request SendForReply makes without a reply group.
+func (r *RedisMessageBus) ReplyGroup() string {
+	return r.replyGroup
+}
+
+// SendForReply sends the messages and listens on the reply topic of the
+// fromTopic for a reply with the replyGroup.
+//
+// An empty replyGroup uses a group of its own for each request on a
+// stream reply topic, prefixed by the ReplyGroup of the bus, so requests
+// sharing a reply topic each receive all replies rather than competing for
+// them in a shared stream group. The group is destroyed once the request
+// is done. Pubsub reply topics have no groups, every requester receives
+// all replies. Replies carrying the CorrelationId of another request are
+// then ignored, which keeps replies from crossing between requests as
+// long as responders copy the CorrelationId of the request onto the reply.
+//
+// A given replyGroup is shared by all requests using it, which compete for
+// its replies, and is never destroyed: it suits a single requester only.
+//
+// It blocks while Config.Goroutines has no two free slots for the request.
+func (r *RedisMessageBus) SendForReply(tm time.Duration, fromTopic sabuhp.Topic, replyGroup string, data ...sabuhp.Message) *sabuhp.ReplyFuture {
+	return sendForReply(r, tm, r.ReplyTopicOf(fromTopic).String(), replyGroup, r.replyGroup, func(requests ...sabuhp.Message) {
+		r.sendChannelBatch(requests, r.channel)
+	}, data)
+}
+
+// sendForReply listens on replyTopic of the bus for replies to the
+// messages then sends them with send, the requests and replies of an
+// InstanceBus go through different instances. An empty replyGroup is
+// resolved with replyGroupFor from uniqueGroup and released once the
+// request is done.
+func sendForReply(
+	bus *RedisMessageBus,
+	tm time.Duration,
+	replyTopic string,
+	replyGroup string,
+	uniqueGroup string,
+	send func(requests ...sabuhp.Message),
+	data []sabuhp.Message,
+) *sabuhp.ReplyFuture {
+	var ft = sabuhp.NewReplyFuture()
+	if bus.config.DisableReplies {
+		ft.WithError(ErrRepliesDisabled)
+		return ft
+	}
+	if !bus.addReply(ft) {
+		ft.WithError(sabuhp.ErrBusClosed)
+		return ft
+	}
+
+	var ownGroup = replyGroup == ""
+	if ownGroup {
+		replyGroup = bus.replyGroupFor(replyTopic, uniqueGroup)
+	}
+
+	// the waiter of the request and the consumer of its reply subscription
+	// each need a slot, acquired together so requests waiting for slots
+	// never hold one another's.
+	if limitErr := bus.config.Goroutines.AcquireN(bus.ctx, 2); limitErr != nil {
+		bus.removeReply(ft)
+		if nerror.IsAny(limitErr, sabuhp.ErrGoroutineLimit) {
+			ft.WithError(limitErr)
+			return ft
+		}
+		ft.WithError(sabuhp.ErrBusClosed)
+		return ft
+	}
+
+	var requests = make([]sabuhp.Message, 0, len(data))
+	var correlationIds = make(map[nxid.ID]struct{}, len(data))
+	for _, msg := range data {
+		if msg.CorrelationId.IsNil() {
+			msg.CorrelationId = msg.Id
+		}
+		correlationIds[msg.CorrelationId] = struct{}{}
+		requests = append(requests, msg)
+	}
+
+	go func() {
+		defer bus.config.Goroutines.Release()
+		defer bus.removeReply(ft)
+
+		var replyChannel = bus.listenWithAckMode(replyTopic, replyGroup, AckOnHandle, sabuhp.TransportResponseFunc(func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			if !message.CorrelationId.IsNil() {
+				if _, requested := correlationIds[message.CorrelationId]; !requested {
+					return nil
+				}
 			}
 
-			ft.WithValue(message)
+			ft.WithReply(message)
 			return nil
-		}))
+		}), true)
+
+		// a failed subscription did not take over the slot acquired for it.
+		if listenErr := replyChannel.Err(); listenErr != nil {
+			bus.config.Goroutines.Release()
+			ft.WithError(listenErr)
+			return
+		}
 
 		// send message after listening for reply
-		r.sendChannelBatch(data, r.channel)
+		send(requests...)
 
-		<-time.After(tm)
-		replyChannel.Close()
+		select {
+		case <-ft.Done():
+		case <-time.After(tm):
+		case <-bus.ctx.Done():
+			ft.WithError(sabuhp.ErrBusClosed)
+		}
 
-		// delete reply stream
-		var intCmd = r.client.Del(r.ctx, fromTopic.ReplyTopic().String())
-		if intCmd.Err() != nil {
-			r.logger.Log(njson.MJSON("received message to decode", func(event npkg.Encoder) {
-				event.String("topic", fromTopic.String())
-				event.Int("_level", int(npkg.INFO))
-				event.Error("error", intCmd.Err())
-			}))
+		replyChannel.Close()
+		if ownGroup {
+			bus.releaseReplyTopic(replyTopic, replyGroup)
 		}
 
-		ft.WithError(nerror.New("timed out waiting for reply"))
-	})
+		// does nothing if a reply was received.
+		ft.WithError(sabuhp.ErrReplyTimeout)
+	}()
 	return ft
 }
 
+// ReplyTopicOf returns the reply topic of the topic in the hierarchy of
+// the bus, which SendForReply listens on.
+func (r *RedisMessageBus) ReplyTopicOf(topic sabuhp.Topic) sabuhp.Topic {
+	return r.topicOf(topic).ReplyTopic()
+}
+
+// ReplyGroupFor returns the group a request listens with on the reply
+// topic, for requests made with sabuhp.SendForReplyContext: a new group
+// like the ones of SendForReply if replyGroup is empty. It fails with
+// ErrRepliesDisabled if Config.DisableReplies is set.
+func (r *RedisMessageBus) ReplyGroupFor(replyTopic string, replyGroup string) (string, func(), error) {
+	return resolveReplyGroup(r, replyTopic, replyGroup, r.replyGroup)
+}
+
+// resolveReplyGroup implements sabuhp.ReplyGroupResolver for the bus of
+// the reply topic, with uniqueGroup prefixing new groups.
+func resolveReplyGroup(bus *RedisMessageBus, replyTopic string, replyGroup string, uniqueGroup string) (string, func(), error) {
+	if bus.config.DisableReplies {
+		return "", nil, nerror.WrapOnly(ErrRepliesDisabled)
+	}
+	if replyGroup != "" {
+		return replyGroup, nil, nil
+	}
+
+	var group = bus.replyGroupFor(replyTopic, uniqueGroup)
+	return group, func() {
+		bus.releaseReplyTopic(replyTopic, group)
+	}, nil
+}
+
+// replyGroupFor returns the group a request listens with on the reply
+// topic when given none: a new group prefixed by the unique group of the
+// requester on a stream, as consumers of a group compete for messages even
+// within one requester, and no group on pubsub which has none.
+func (r *RedisMessageBus) replyGroupFor(replyTopic string, uniqueGroup string) string {
+	if r.channelFor(replyTopic) == RedisStreams {
+		return uniqueGroup + "-" + nxid.New().String()
+	}
+	return ""
+}
+
+// topicOf returns the topic in the hierarchy of the bus, unless the topic
+// has its own separator.
+func (r *RedisMessageBus) topicOf(topic sabuhp.Topic) sabuhp.Topic {
+	if topic.S != "" {
+		return topic
+	}
+	return r.config.TopicHierarchy.Apply(topic)
+}
+
+// releaseReplyTopic destroys the group of a request once it is done and
+// deletes the reply topic, a stream shared with other requests is kept
+// till their groups are gone.
+func (r *RedisMessageBus) releaseReplyTopic(replyTopic string, replyGroup string) {
+	if r.channelFor(replyTopic) == RedisStreams {
+		var destroyErr = r.client.XGroupDestroy(r.ctx, replyTopic, replyGroup).Err()
+		var groups, groupsErr = r.client.XInfoGroups(r.ctx, replyTopic).Result()
+		if destroyErr != nil || groupsErr != nil || len(groups) > 0 {
+			return
+		}
+	}
+
+	if delErr := r.client.Del(r.ctx, replyTopic).Err(); delErr != nil {
+		njson.Log(r.logger).New().
+			LInfo().
+			Message("failed to delete reply topic").
+			String("topic", replyTopic).
+			Error("error", delErr).
+			End()
+	}
+}
+
+// PublishAck is the receipt of a message redis accepted, it's the value
+// of the future returned by SendWithAck and of the Future of any message
+// sent with one.
+//
+// Producers can store it to later reconcile the messages they sent with
+// the stream, it tells nothing of the message being consumed.
+type PublishAck struct {
+	Topic     string
+	MessageId string
+
+	// StreamId is the id redis assigned to the message in stream mode.
+	StreamId string
+
+	// AcceptedAt is the time redis accepted the message, in stream mode
+	// it's the time part of the StreamId.
+	AcceptedAt time.Time
+
+	// Receivers is the number of subscribers which received the
+	// message in pubsub mode.
+	Receivers int64
+}
+
+// streamIdTime returns the time of a stream id, redis stream ids are
+// the unix time in milliseconds and a sequence number: "<ms>-<seq>".
+func streamIdTime(streamId string) (time.Time, bool) {
+	var index = strings.Index(streamId, "-")
+	if index <= 0 {
+		return time.Time{}, false
+	}
+
+	var millis, parseErr = strconv.ParseInt(streamId[:index], 10, 64)
+	if parseErr != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, millis*int64(time.Millisecond)).UTC(), true
+}
+
+// SendWithReceipt sends the message and blocks till redis accepted it,
+// returning its receipt.
+func (r *RedisMessageBus) SendWithReceipt(msg sabuhp.Message) (PublishAck, error) {
+	var ft = r.SendWithAck(msg)
+	if ackErr := ft.Err(); ackErr != nil {
+		return PublishAck{}, nerror.WrapOnly(ackErr)
+	}
+	return ft.Value().(PublishAck), nil
+}
+
+// SendWithAck sends the message, returning a future resolved with a
+// PublishAck once redis confirmed the publish.
+//
+// Unlike SendForReply it does not wait on any consumer. Scheduled messages
+// are acknowledged once added to the schedule.
+func (r *RedisMessageBus) SendWithAck(msg sabuhp.Message) *nthen.Future {
+	msg.Future = nthen.NewFuture()
+	r.sendChannelBatch([]sabuhp.Message{msg}, r.channel)
+	return msg.Future
+}
+
+// SendTo publishes the message to each of the topics in a single
+// pipeline, returning the error of each topic it failed to be published
+// to, an empty map if published to all.
+//
+// Every copy keeps the id of the message, the Future of the message is
+// resolved with the first error, if any, once all were published.
+func (r *RedisMessageBus) SendTo(topics []string, msg sabuhp.Message) map[string]error {
+	var ft = msg.Future
+
+	var batch = make([]sabuhp.Message, 0, len(topics))
+	for _, topic := range topics {
+		var copied = msg
+		copied.Topic.T = topic
+		copied.Future = nthen.NewFuture()
+		batch = append(batch, copied)
+	}
+
+	r.sendChannelBatch(batch, r.channel)
+
+	var failed = map[string]error{}
+	for index, copied := range batch {
+		if sendErr := copied.Future.Err(); sendErr != nil {
+			failed[topics[index]] = sendErr
+		}
+	}
+
+	if ft != nil {
+		for _, topic := range topics {
+			if sendErr, ok := failed[topic]; ok {
+				ft.WithError(sendErr)
+				return failed
+			}
+		}
+		ft.WithValue(nil)
+	}
+	return failed
+}
+
 func (r *RedisMessageBus) sendChannelBatch(batch []sabuhp.Message, channel MessageChannel) {
+	// prepared into a new slice, the batch may be the callers own.
+	var prepared = make([]sabuhp.Message, len(batch))
+	for index, msg := range batch {
+		if !r.config.DisableTraceIds {
+			msg = sabuhp.WithTraceId(msg)
+		}
+		msg.Topic = r.topicOf(msg.Topic)
+		prepared[index] = msg
+	}
+	batch = prepared
+
 	var pipelining = r.client.Pipeline()
 
-	for _, msg := range batch {
+	// commands of each message of the batch, nil for messages which
+	// failed before reaching the pipeline.
+	var commands = make([]redis.Cmder, len(batch))
+	var scheduled = make([]bool, len(batch))
+
+	for index, msg := range batch {
 		var ft = msg.Future
 
-		var encodedData, encodeErr = r.config.Codec.Encode(msg)
+		var encodedData, encodeErr = r.encode(msg)
 		if encodeErr != nil {
 			if ft != nil {
 				ft.WithError(encodeErr)
@@ -718,26 +1699,22 @@ func (r *RedisMessageBus) sendChannelBatch(batch []sabuhp.Message, channel Messa
 			continue
 		}
 
-		// publish to streams
-		if channel == RedisStreams {
-			if addErr := r.sendStream(msg.Topic.String(), encodedData, pipelining); addErr != nil {
-				if ft != nil {
-					ft.WithError(addErr)
-				}
-
-				r.logger.Log(njson.MJSON("failed to add to pipelined", func(event npkg.Encoder) {
-					event.String("topic", msg.Topic.String())
-					event.String("from_addr", msg.FromAddr)
-					event.Int("_level", int(npkg.ERROR))
-					event.String("payload", fmt.Sprintf("%#v", msg.Bytes))
-					event.String("error", addErr.Error())
-				}))
-			}
+		// hold scheduled messages till they are due
+		if isScheduled(msg) {
+			commands[index] = r.schedule(msg, encodedData, pipelining)
+			scheduled[index] = true
 			continue
 		}
 
-		// publish to pubsub
-		if addErr := r.sendPubSub(msg.Topic.String(), encodedData, pipelining); addErr != nil {
+		var command redis.Cmder
+		var addErr error
+		if r.resolveChannel(channel, msg.Topic.String()) == RedisStreams {
+			command, addErr = r.sendStream(msg.Topic.String(), encodedData, pipelining)
+		} else {
+			command, addErr = r.sendPubSub(r.attributeChannel(msg.Topic.String(), msg), encodedData, pipelining)
+		}
+
+		if addErr != nil {
 			if ft != nil {
 				ft.WithError(addErr)
 			}
@@ -749,31 +1726,30 @@ func (r *RedisMessageBus) sendChannelBatch(batch []sabuhp.Message, channel Messa
 				event.String("payload", fmt.Sprintf("%#v", msg.Bytes))
 				event.String("error", addErr.Error())
 			}))
+			continue
 		}
-	}
 
-	var execResults, execErr = pipelining.Exec(r.ctx)
-	if execErr != nil {
-		for _, msg := range batch {
-			if msg.Future == nil {
-				continue
-			}
-			msg.Future.WithError(execErr)
-		}
+		commands[index] = command
+	}
 
+	// a failed command or connection fails the pipeline, every command
+	// holds its own error, so futures are resolved per command below.
+	if _, execErr := pipelining.Exec(r.ctx); execErr != nil {
 		r.logger.Log(njson.MJSON("failed to execute pipeline", func(event npkg.Encoder) {
 			event.String("error", execErr.Error())
 			event.Int("_level", int(npkg.ERROR))
 		}))
-
-		return
 	}
 
-	for index, execResult := range execResults {
+	for index, command := range commands {
+		if command == nil {
+			continue
+		}
+
 		var msg = batch[index]
 		var ft = msg.Future
 
-		if execErr := execResult.Err(); execErr != nil {
+		if execErr := command.Err(); execErr != nil {
 			if ft != nil {
 				ft.WithError(execErr)
 			}
@@ -788,7 +1764,29 @@ func (r *RedisMessageBus) sendChannelBatch(batch []sabuhp.Message, channel Messa
 			continue
 		}
 
+		if ft != nil {
+			var ack = PublishAck{
+				Topic:      msg.Topic.String(),
+				MessageId:  msg.Id.String(),
+				AcceptedAt: time.Now().UTC(),
+			}
+			switch cmd := command.(type) {
+			case *redis.StringCmd:
+				ack.StreamId = cmd.Val()
+				if acceptedAt, ok := streamIdTime(ack.StreamId); ok {
+					ack.AcceptedAt = acceptedAt
+				}
+			case *redis.IntCmd:
+				if !scheduled[index] {
+					ack.Receivers = cmd.Val()
+				}
+			}
+			ft.WithValue(ack)
+		}
+
 		r.logger.Log(njson.MJSON("published message to pubsub", func(event npkg.Encoder) {
+			event.String("topic", msg.Topic.String())
+			event.String("trace_id", sabuhp.TraceId(msg))
 			event.String("from_addr", msg.FromAddr)
 			event.Int("_level", int(npkg.INFO))
 			event.String("payload", fmt.Sprintf("%#v", msg.Bytes))
@@ -796,7 +1794,7 @@ func (r *RedisMessageBus) sendChannelBatch(batch []sabuhp.Message, channel Messa
 	}
 }
 
-func (r *RedisMessageBus) sendStream(streamName string, encodedData []byte, pipelined redis.Pipeliner) error {
+func (r *RedisMessageBus) sendStream(streamName string, encodedData []byte, pipelined redis.Pipeliner) (*redis.StringCmd, error) {
 	var xmessage = redis.XAddArgs{
 		Stream:       streamName,
 		MaxLen:       0,
@@ -815,7 +1813,7 @@ func (r *RedisMessageBus) sendStream(streamName string, encodedData []byte, pipe
 			event.Int("_level", int(npkg.ERROR))
 			event.String("payload", fmt.Sprintf("%#v", encodedData))
 		}))
-		return nerror.WrapOnly(resErr)
+		return nil, nerror.WrapOnly(resErr)
 	}
 
 	r.logger.Log(njson.MJSON("sent new consumer group message", func(event npkg.Encoder) {
@@ -825,10 +1823,10 @@ func (r *RedisMessageBus) sendStream(streamName string, encodedData []byte, pipe
 		event.String("redis_command_name", responseCmd.Name())
 		event.String("redis_command_full_name", responseCmd.FullName())
 	}))
-	return nil
+	return responseCmd, nil
 }
 
-func (r *RedisMessageBus) sendPubSub(topic string, encodedData []byte, pipelined redis.Pipeliner) error {
+func (r *RedisMessageBus) sendPubSub(topic string, encodedData []byte, pipelined redis.Pipeliner) (*redis.IntCmd, error) {
 	var responseCmd = pipelined.Publish(r.ctx, topic, encodedData)
 	if resErr := responseCmd.Err(); resErr != nil {
 		r.logger.Log(njson.MJSON("failed to encode message", func(event npkg.Encoder) {
@@ -837,7 +1835,7 @@ func (r *RedisMessageBus) sendPubSub(topic string, encodedData []byte, pipelined
 			event.Int("_level", int(npkg.ERROR))
 			event.String("payload", fmt.Sprintf("%#v", encodedData))
 		}))
-		return nerror.WrapOnly(resErr)
+		return nil, nerror.WrapOnly(resErr)
 	}
 
 	r.logger.Log(njson.MJSON("sent new pubsub message", func(event npkg.Encoder) {
@@ -847,7 +1845,7 @@ func (r *RedisMessageBus) sendPubSub(topic string, encodedData []byte, pipelined
 		event.String("redis_command_name", responseCmd.Name())
 		event.String("redis_command_full_name", responseCmd.FullName())
 	}))
-	return nil
+	return responseCmd, nil
 }
 
 func (r *RedisMessageBus) manage() {
@@ -863,6 +1861,10 @@ func (r *RedisMessageBus) manage() {
 		r.waiter.Done()
 	}()
 
+	r.supervise("manage", r.runActions)
+}
+
+func (r *RedisMessageBus) runActions() {
 runLoop:
 	for {
 		select {
@@ -3,6 +3,8 @@ package redispub
 import (
 	"context"
 	"fmt"
+	"runtime"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -10,6 +12,10 @@ import (
 	"github.com/ewe-studios/sabuhp"
 	"github.com/ewe-studios/sabuhp/codecs"
 	redis "github.com/go-redis/redis/v8"
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/njson"
+	"github.com/influx6/npkg/nthen"
+	"github.com/influx6/npkg/nxid"
 
 	"github.com/stretchr/testify/require"
 
@@ -44,6 +50,121 @@ func TestRedis__Start_Stop_WithCancel(t *testing.T) {
 	pb.Wait()
 }
 
+func TestRedis_CancelTearsDownBus(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Codec = codec
+	config.Logger = logger
+	config.StreamBlockTimeout = 100 * time.Millisecond
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	requireRedis(t, &config.Redis)
+
+	var goroutines = runtime.NumGoroutine()
+
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+	config.Ctx = ctx
+
+	var pb, err = Hybrid(config)
+	require.NoError(t, err)
+
+	var handler = sabuhp.TransportResponseFunc(func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+		return nil
+	})
+
+	// one subscription before and one after start.
+	var streamChannel = pb.ListenStream("teardown-stream", "workers", handler)
+	require.NoError(t, streamChannel.Err())
+
+	require.NoError(t, pb.Start())
+
+	var pubsubChannel = pb.ListenPubSub("teardown-pubsub", AnyGroup, handler)
+	require.NoError(t, pubsubChannel.Err())
+
+	canceler()
+
+	var waited = make(chan struct{})
+	go func() {
+		pb.Wait()
+		close(waited)
+	}()
+
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "Wait should return once the context is cancelled")
+	}
+
+	for _, channel := range []sabuhp.Channel{streamChannel, pubsubChannel} {
+		require.Error(t, channel.(*redisSubscription).ctx.Err())
+	}
+	require.Equal(t, redis.ErrClosed, pb.client.Ping(context.Background()).Err())
+	require.Equal(t, sabuhp.ErrAlreadyStopped, pb.Stop())
+
+	require.Eventually(t, func() bool {
+		return runtime.NumGoroutine() <= goroutines
+	}, 5*time.Second, 10*time.Millisecond, "goroutines leaked after shutdown")
+}
+
+func TestRedis_PubSub_ClosingOneSubscriptionKeepsOthers(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	requireRedis(t, &config.Redis)
+
+	var pb, err = PubSub(config)
+	require.NoError(t, err)
+
+	// a bus which was never started has nothing to wait for.
+	pb.Wait()
+
+	require.NoError(t, pb.Start())
+	defer pb.Stop()
+
+	var handler = sabuhp.TransportResponseFunc(func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+		return nil
+	})
+
+	var received = make(chan sabuhp.Message, 1)
+	var kept = pb.Listen("kept-topic", "", sabuhp.TransportResponseFunc(func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+		received <- message
+		return nil
+	}))
+	require.NoError(t, kept.Err())
+	defer kept.Close()
+
+	var closed = pb.Listen("closed-topic", "", handler)
+	require.NoError(t, closed.Err())
+	closed.Close()
+
+	require.Eventually(t, func() bool {
+		return closed.(*redisSubscription).ctx.Err() != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, pb.ctx.Err())
+	require.NoError(t, pb.client.Ping(ctx).Err())
+
+	pb.Send(sabuhp.NewMessage(sabuhp.T("kept-topic"), "me", []byte("still here")))
+	select {
+	case message := <-received:
+		require.Equal(t, "still here", string(message.Bytes))
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "other subscriptions should keep receiving")
+	}
+}
+
 func TestRedis_Start_Stop(t *testing.T) {
 	var ctx, canceler = context.WithCancel(context.Background())
 	defer canceler()
@@ -53,6 +174,9 @@ func TestRedis_Start_Stop(t *testing.T) {
 	config.Ctx = ctx
 	config.Codec = codec
 	config.Logger = logger
+	// an empty Addr defaults to localhost:6379. Stream already pings redis
+	// when creating the bus, Config.VerifyConnectionOnStart additionally
+	// pings on Start.
 	config.Redis = redis.Options{
 		Network: "tcp",
 	}
@@ -71,6 +195,35 @@ func TestRedis_Start_Stop(t *testing.T) {
 	pb.Wait()
 }
 
+func TestRedis_Start_VerifyConnection(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.VerifyConnectionOnStart = true
+	config.HealthCheckTimeout = 200 * time.Millisecond
+	config.Redis = redis.Options{
+		Network:     "tcp",
+		Addr:        "127.0.0.1:1",
+		MaxRetries:  -1,
+		DialTimeout: 100 * time.Millisecond,
+	}
+
+	var client = redis.NewClient(&config.Redis)
+	defer client.Close()
+
+	var pb = NewRedisMessageBus(config, client, RedisStreams)
+	require.Equal(t, ErrRedisUnreachable, pb.Start())
+	require.False(t, pb.Healthy())
+
+	// a failed start leaves the bus stoppable.
+	require.NoError(t, pb.Stop())
+}
+
 func TestRedis_Stream(t *testing.T) {
 	var ctx, canceler = context.WithCancel(context.Background())
 	defer canceler()
@@ -187,6 +340,73 @@ func TestRedis_PubSub(t *testing.T) {
 	pb.Wait()
 }
 
+func TestRedis_ACLAuthentication(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var admin = redis.Options{Network: "tcp"}
+	requireRedis(t, &admin)
+
+	var adminClient = redis.NewClient(&admin)
+	defer adminClient.Close()
+
+	var username = "sabuhp-acl-test"
+	var password = "sabuhp-acl-secret"
+
+	// allchannels is only known from Redis 6.2, older versions allow all
+	// channels by default.
+	var setupErr = adminClient.Do(ctx, "ACL", "SETUSER", username, "reset", "on", ">"+password, "allkeys", "allchannels", "+@all").Err()
+	if setupErr != nil {
+		setupErr = adminClient.Do(ctx, "ACL", "SETUSER", username, "reset", "on", ">"+password, "allkeys", "+@all").Err()
+	}
+	if setupErr != nil {
+		t.Skipf("redis does not support ACL users: %s", setupErr)
+	}
+	defer adminClient.Do(context.Background(), "ACL", "DELUSER", username)
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.Redis = redis.Options{
+		Network:  "tcp",
+		Username: username,
+		Password: "wrong-secret",
+	}
+
+	var _, authErr = PubSub(config)
+	require.Error(t, authErr)
+
+	config.Redis.Password = password
+
+	var pb, err = PubSub(config)
+	require.NoError(t, err)
+
+	pb.Start()
+
+	var received = make(chan sabuhp.Message, 1)
+	var channel = pb.Listen("acl", "*", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			received <- message
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	pb.Send(sabuhp.NewMessage(sabuhp.T("acl"), "me", []byte("\"yes\"")))
+
+	select {
+	case msg := <-received:
+		require.Equal(t, "acl", msg.Topic.String())
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "message should be delivered over the authenticated connection")
+	}
+
+	canceler()
+	pb.Wait()
+}
+
 func TestRedis_PubSub_WithReply(t *testing.T) {
 	var ctx, canceler = context.WithCancel(context.Background())
 	defer canceler()
@@ -236,14 +456,2757 @@ func TestRedis_PubSub_WithReply(t *testing.T) {
 	var replyFT = pb.SendForReply(time.Minute, whyMessage.Topic, "*", whyMessage)
 	var replyMsg, replyErr = replyFT.Get()
 	require.NoError(t, replyErr)
-	require.NotNil(t, replyMsg)
+	require.Equal(t, "Yo!", string(replyMsg.Bytes))
+
+	delivered.Wait()
 
-	var rm = replyMsg.(sabuhp.Message)
+	canceler()
+	pb.Wait()
+}
 
-	require.Equal(t, "Yo!", string(rm.Bytes))
+func TestRedis_SendForReply_UniqueReplyGroups(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
 
-	delivered.Wait()
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.StreamBlockTimeout = 100 * time.Millisecond
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	requireRedis(t, &config.Redis)
+
+	var responder, responderErr = Stream(config)
+	require.NoError(t, responderErr)
+	var first, firstErr = Stream(config)
+	require.NoError(t, firstErr)
+	var second, secondErr = Stream(config)
+	require.NoError(t, secondErr)
+
+	require.NotEqual(t, first.ReplyGroup(), second.ReplyGroup())
+
+	responder.Start()
+	first.Start()
+	second.Start()
+
+	// both requesters share the topic and so the reply topic.
+	var askTopic = sabuhp.T("ask")
+
+	var channel = responder.Listen(askTopic.String(), "responders", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			var reply = sabuhp.NewMessage(message.Topic.ReplyTopic(), "responder", message.Bytes)
+			reply.CorrelationId = message.CorrelationId
+			transport.Bus.Send(reply)
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	var ask = func(bus *RedisMessageBus, payload string, replies chan<- string) {
+		var reply, replyErr = bus.SendForReply(10*time.Second, askTopic, "", sabuhp.NewMessage(askTopic, "me", []byte(payload))).Get()
+		if replyErr != nil {
+			replies <- replyErr.Error()
+			return
+		}
+		replies <- string(reply.Bytes)
+	}
+
+	var firstReplies = make(chan string, 1)
+	var secondReplies = make(chan string, 1)
+	go ask(first, "from-first", firstReplies)
+	go ask(second, "from-second", secondReplies)
+
+	require.Equal(t, "from-first", <-firstReplies)
+	require.Equal(t, "from-second", <-secondReplies)
+
+	canceler()
+	responder.Wait()
+	first.Wait()
+	second.Wait()
+}
+
+// requireConcurrentReplies sends concurrent requests on one topic of the
+// bus with ask, each must receive its own reply.
+func requireConcurrentReplies(t *testing.T, bus sabuhp.MessageBus, askTopic sabuhp.Topic, ask func(request sabuhp.Message) *sabuhp.ReplyFuture) {
+	var channel = bus.Listen(askTopic.String(), "responders", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			transport.Bus.Send(*sabuhp.NewReply(&message, message.Bytes))
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	const requests = 10
+
+	var replies = make(chan [2]string, requests)
+	for i := 0; i < requests; i++ {
+		go func(payload string) {
+			var reply, replyErr = ask(sabuhp.NewMessage(askTopic, "me", []byte(payload))).Get()
+			if replyErr != nil {
+				replies <- [2]string{payload, replyErr.Error()}
+				return
+			}
+			replies <- [2]string{payload, string(reply.Bytes)}
+		}(fmt.Sprintf("request-%d", i))
+	}
+
+	for i := 0; i < requests; i++ {
+		var reply = <-replies
+		require.Equal(t, reply[0], reply[1])
+	}
+}
+
+func TestRedis_SendForReply_ConcurrentRequests(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.StreamBlockTimeout = 100 * time.Millisecond
+	requireRedis(t, &config.Redis)
+
+	var askTopic = sabuhp.T("ask-concurrently")
+
+	var pb, err = Stream(config)
+	require.NoError(t, err)
+	require.NoError(t, pb.client.Del(ctx, askTopic.String(), pb.ReplyTopicOf(askTopic).String()).Err())
+	pb.Start()
+
+	var replyStreamReleased = func() bool {
+		return pb.client.Exists(ctx, pb.ReplyTopicOf(askTopic).String()).Val() == 0
+	}
+
+	requireConcurrentReplies(t, pb, askTopic, func(request sabuhp.Message) *sabuhp.ReplyFuture {
+		return pb.SendForReply(10*time.Second, askTopic, "", request)
+	})
+
+	// the group of each request was destroyed, and with the last the
+	// reply stream.
+	require.Eventually(t, replyStreamReleased, 5*time.Second, 50*time.Millisecond)
+
+	requireConcurrentReplies(t, pb, askTopic, func(request sabuhp.Message) *sabuhp.ReplyFuture {
+		var requestCtx, requestCanceler = context.WithTimeout(ctx, 10*time.Second)
+		var ft = sabuhp.SendForReplyContext(requestCtx, pb, askTopic, "", request)
+		go func() {
+			<-ft.Done()
+			requestCanceler()
+		}()
+		return ft
+	})
+	require.Eventually(t, replyStreamReleased, 5*time.Second, 50*time.Millisecond)
+
+	canceler()
+	pb.Wait()
+}
+
+func TestInstanceBus_SendForReply_ConcurrentRequests(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.StreamBlockTimeout = 100 * time.Millisecond
+
+	var options = []redis.Options{
+		{Network: "tcp", DB: 0},
+		{Network: "tcp", DB: 1},
+	}
+	requireRedis(t, &options[0])
+
+	// requests and replies go through different instances.
+	var askTopic = sabuhp.T("instance-ask-concurrently")
+	var replyTopic = askTopic.ReplyTopic().String()
+	var router = MappedTopicRouter(map[string]int{askTopic.String(): 0, replyTopic: 1}, nil)
+
+	var pb, err = Instances(config, options, RedisStreams, router)
+	require.NoError(t, err)
+	require.NoError(t, pb.Start())
+
+	requireConcurrentReplies(t, pb, askTopic, func(request sabuhp.Message) *sabuhp.ReplyFuture {
+		return pb.SendForReply(10*time.Second, askTopic, "", request)
+	})
+
+	require.NoError(t, pb.Stop())
+	pb.Wait()
+}
+
+func TestRedis_SendForReply_TopicHierarchy(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.StreamBlockTimeout = 100 * time.Millisecond
+	config.TopicHierarchy = sabuhp.TopicHierarchy{Separator: "/"}
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	requireRedis(t, &config.Redis)
+
+	var pb, err = Stream(config)
+	require.NoError(t, err)
+	pb.Start()
+
+	var askTopic = sabuhp.NewTopic("orders/ask", "requester")
+
+	var replyTopics = make(chan string, 1)
+	var channel = pb.Listen(askTopic.String(), "responders", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			var replyTopic = message.Topic.ReplyTopic()
+			replyTopics <- replyTopic.String()
+
+			var reply = sabuhp.NewMessage(replyTopic, "responder", message.Bytes)
+			reply.CorrelationId = message.CorrelationId
+			transport.Bus.Send(reply)
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	var reply, replyErr = pb.SendForReply(10*time.Second, askTopic, "", sabuhp.NewMessage(askTopic, "me", []byte("yes"))).Get()
+	require.NoError(t, replyErr)
+	require.Equal(t, "yes", string(reply.Bytes))
+	require.Equal(t, "orders/ask/reply/requester", <-replyTopics)
 
 	canceler()
 	pb.Wait()
 }
+
+func TestRedis_SendForReplyContext_TopicHierarchy(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.TopicHierarchy = sabuhp.TopicHierarchy{Separator: "/"}
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	requireRedis(t, &config.Redis)
+
+	var pb, err = PubSub(config)
+	require.NoError(t, err)
+	require.NoError(t, pb.Start())
+	defer pb.Stop()
+
+	var askTopic = sabuhp.NewTopic("orders/ask", "requester")
+	require.Equal(t, "orders/ask/reply/requester", pb.ReplyTopicOf(askTopic).String())
+
+	var channel = pb.Listen(askTopic.String(), "", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			transport.Bus.Send(*sabuhp.NewReply(&message, message.Bytes))
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	var requestCtx, requestCanceler = context.WithTimeout(ctx, 10*time.Second)
+	defer requestCanceler()
+
+	var reply, replyErr = sabuhp.SendForReplyContext(requestCtx, pb, askTopic, "", sabuhp.NewMessage(askTopic, "me", []byte("yes"))).Get()
+	require.NoError(t, replyErr)
+	require.Equal(t, "yes", string(reply.Bytes))
+}
+
+func TestRedis_GoroutineLimit(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.StreamBlockTimeout = 100 * time.Millisecond
+	config.Goroutines = sabuhp.NewGoroutineLimiter(6)
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	requireRedis(t, &config.Redis)
+
+	var pb, err = Stream(config)
+	require.NoError(t, err)
+	pb.Start()
+
+	var channel = pb.Listen("flood", "responders", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			var reply = sabuhp.NewMessage(message.Topic.ReplyTopic(), "responder", message.Bytes)
+			reply.CorrelationId = message.CorrelationId
+			transport.Bus.Send(reply)
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+	require.Equal(t, 1, pb.ActiveGoroutines())
+
+	var sampled = make(chan struct{})
+	var maxActive int
+	go func() {
+		defer close(sampled)
+		for ctx.Err() == nil {
+			if active := pb.ActiveGoroutines(); active > maxActive {
+				maxActive = active
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	var results = make(chan error, 30)
+	for i := 0; i < 30; i++ {
+		go func(index int) {
+			var topic = sabuhp.T("flood")
+			var _, replyErr = pb.SendForReply(10*time.Second, topic, "", sabuhp.NewMessage(topic, "me", []byte(fmt.Sprintf("request %d", index)))).Get()
+			results <- replyErr
+		}(i)
+	}
+
+	// requests wait for the slots of their waiter and reply subscription
+	// together, so every one of them is answered.
+	for i := 0; i < 30; i++ {
+		require.NoError(t, <-results)
+	}
+
+	// subscriptions beyond the cap fail right away.
+	require.Eventually(t, func() bool {
+		return pb.ActiveGoroutines() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, pb.Listen(fmt.Sprintf("idle-%d", i), "idlers", sabuhp.TransportResponseFunc(
+			func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+				return nil
+			})).Err())
+	}
+	var limited = pb.Listen("idle-5", "idlers", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			return nil
+		}))
+	require.True(t, nerror.IsAny(limited.Err(), sabuhp.ErrGoroutineLimit))
+
+	canceler()
+	pb.Wait()
+	<-sampled
+	require.True(t, maxActive <= 6)
+	require.Equal(t, 0, pb.ActiveGoroutines())
+}
+
+func TestRedis_PubSub_ResubscribesAfterDrop(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var faults = NewFaultDialer()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.Redis = redis.Options{
+		Network: "tcp",
+		Dialer:  faults.Dial,
+	}
+
+	requireRedis(t, &config.Redis)
+
+	var pb, err = PubSub(config)
+	require.NoError(t, err)
+	pb.Start()
+
+	var received = make(chan sabuhp.Message, 10)
+	var channel = pb.Listen("faulty", "", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			received <- message
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	pb.Send(sabuhp.NewMessage(sabuhp.T("faulty"), "me", []byte("before")))
+	select {
+	case msg := <-received:
+		require.Equal(t, "before", string(msg.Bytes))
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "message should be received before the drop")
+	}
+
+	// drop every connection mid-subscription, failing the first redial.
+	var dials = faults.Dials()
+	faults.FailDials(1)
+	require.True(t, faults.Drop() > 0)
+
+	// messages published while resubscribing are lost, keep publishing
+	// till one arrives.
+	var resubscribed = false
+	for i := 0; i < 50 && !resubscribed; i++ {
+		pb.Send(sabuhp.NewMessage(sabuhp.T("faulty"), "me", []byte("after")))
+		select {
+		case msg := <-received:
+			require.Equal(t, "after", string(msg.Bytes))
+			resubscribed = true
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	require.True(t, resubscribed, "subscription should be restored after the drop")
+	require.True(t, faults.Dials() >= dials+2)
+
+	canceler()
+	pb.Wait()
+}
+
+func TestRedis_SendTo(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.StreamBlockTimeout = 100 * time.Millisecond
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	requireRedis(t, &config.Redis)
+
+	var pb, err = Stream(config)
+	require.NoError(t, err)
+
+	var topics = []string{"fanout-orders", "fanout-billing", "fanout-audit"}
+	require.NoError(t, pb.client.Del(ctx, topics...).Err())
+
+	var received = make(chan sabuhp.Message, len(topics))
+	for _, topic := range topics {
+		var channel = pb.Listen(topic, "fanout", sabuhp.TransportResponseFunc(
+			func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+				received <- message
+				return nil
+			}))
+		require.NoError(t, channel.Err())
+		defer channel.Close()
+	}
+
+	pb.Start()
+
+	var msg = sabuhp.NewMessage(sabuhp.T("fanout"), "me", []byte("shipped"))
+	msg.Future = nthen.NewFuture()
+	require.Empty(t, pb.SendTo(topics, msg))
+	require.NoError(t, msg.Future.Err())
+
+	var receivedTopics []string
+	for range topics {
+		select {
+		case message := <-received:
+			require.Equal(t, "shipped", string(message.Bytes))
+			require.Equal(t, msg.Id, message.Id)
+			receivedTopics = append(receivedTopics, message.Topic.String())
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "every topic should receive the message")
+		}
+	}
+	require.ElementsMatch(t, topics, receivedTopics)
+
+	canceler()
+	pb.Wait()
+}
+
+func TestRedis_ListenPubSub_TopicPatterns(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.TopicHierarchy = sabuhp.TopicHierarchy{Separator: "/"}
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	requireRedis(t, &config.Redis)
+
+	var pb, err = PubSub(config)
+	require.NoError(t, err)
+	pb.Start()
+
+	var listen = func(pattern string) chan string {
+		var topics = make(chan string, 10)
+		var channel = pb.ListenPubSub(pattern, AnyGroup, sabuhp.TransportResponseFunc(
+			func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+				topics <- message.Topic.String()
+				return nil
+			}))
+		require.NoError(t, channel.Err())
+		t.Cleanup(channel.Close)
+		return topics
+	}
+
+	var created = listen("orders/*/created")
+	var orders = listen("orders/#")
+
+	for _, topic := range []string{"orders", "orders/eu/created", "orders/eu/paid/created", "ordersx/eu/created"} {
+		pb.Send(sabuhp.NewMessage(sabuhp.NewTopic(topic, ""), "me", []byte(topic)))
+	}
+
+	var received = func(topics chan string, count int) []string {
+		var got []string
+		for i := 0; i < count; i++ {
+			select {
+			case topic := <-topics:
+				got = append(got, topic)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("received %v, expected %d topics", got, count)
+			}
+		}
+		return got
+	}
+
+	require.Equal(t, []string{"orders/eu/created"}, received(created, 1))
+	require.ElementsMatch(t, []string{"orders", "orders/eu/created", "orders/eu/paid/created"}, received(orders, 3))
+
+	// no other topic was delivered to either pattern.
+	time.Sleep(100 * time.Millisecond)
+	require.Empty(t, created)
+	require.Empty(t, orders)
+
+	canceler()
+	pb.Wait()
+}
+
+func TestRedis_ReplayDeadLetter(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	var pb, err = Stream(config)
+	require.NoError(t, err)
+	require.NotNil(t, pb)
+
+	pb.Start()
+
+	var topic = sabuhp.T("dead-letter-replay")
+	var firstMessage = sabuhp.NewMessage(topic, "me", []byte("first"))
+	var secondMessage = sabuhp.NewMessage(topic, "me", []byte("second"))
+
+	defer pb.client.Del(ctx, topic.String(), DeadLetterStream(topic.String()), replayedSet(topic.String()))
+
+	var received = make(chan sabuhp.Message, 2)
+	var channel = pb.Listen(topic.String(), "replay", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			received <- message
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	require.NoError(t, pb.DeadLetter(firstMessage, fmt.Errorf("bad first")))
+	require.NoError(t, pb.DeadLetter(secondMessage, fmt.Errorf("bad second")))
+
+	var replayed, replayErr = pb.ReplayDeadLetter(topic.String(), func(msg sabuhp.Message, reason string) bool {
+		return msg.Id == secondMessage.Id
+	})
+	require.NoError(t, replayErr)
+	require.Equal(t, 1, replayed)
+
+	select {
+	case msg := <-received:
+		require.Equal(t, secondMessage.Id, msg.Id)
+	case <-time.After(time.Second * 5):
+		require.Fail(t, "should have received replayed message")
+	}
+
+	select {
+	case msg := <-received:
+		require.Fail(t, "should not have received another message", msg.String())
+	case <-time.After(time.Second * 2):
+	}
+
+	replayed, replayErr = pb.ReplayDeadLetter(topic.String(), nil)
+	require.NoError(t, replayErr)
+	require.Equal(t, 1, replayed)
+
+	canceler()
+	pb.Wait()
+}
+
+func TestRedisDeadLetterStore(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	requireRedis(t, &config.Redis)
+
+	var client = redis.NewClient(&config.Redis)
+	defer client.Close()
+
+	var topic = "dead-letter-store"
+	require.NoError(t, client.Del(ctx, DeadLetterStream(topic), replayedSet(topic)).Err())
+	defer client.Del(ctx, DeadLetterStream(topic), replayedSet(topic))
+
+	var pb = NewRedisMessageBus(config, client, RedisStreams)
+	var store = pb.DeadLetters()
+
+	var firstId, firstErr = store.Store(sabuhp.NewMessage(sabuhp.T(topic), "me", []byte("first")), fmt.Errorf("bad first"))
+	require.NoError(t, firstErr)
+	var secondId, secondErr = store.Store(sabuhp.NewMessage(sabuhp.T(topic), "me", []byte("second")), fmt.Errorf("bad second"))
+	require.NoError(t, secondErr)
+
+	var letters, listErr = store.List(sabuhp.DeadLetterQuery{Topic: topic})
+	require.NoError(t, listErr)
+	require.Len(t, letters, 2)
+	require.Equal(t, firstId, letters[0].Id)
+	require.Equal(t, "first", string(letters[0].Message.Bytes))
+	require.Equal(t, "bad first", letters[0].Reason)
+	require.False(t, letters[0].StoredAt.IsZero())
+
+	require.NoError(t, store.MarkReplayed(topic, firstId))
+	letters, listErr = store.List(sabuhp.DeadLetterQuery{Topic: topic})
+	require.NoError(t, listErr)
+	require.Len(t, letters, 1)
+	require.Equal(t, secondId, letters[0].Id)
+
+	require.NoError(t, store.Delete(topic, secondId))
+	require.Error(t, store.Delete(topic, secondId))
+
+	letters, listErr = store.List(sabuhp.DeadLetterQuery{Topic: topic, IncludeReplayed: true})
+	require.NoError(t, listErr)
+	require.Len(t, letters, 1)
+	require.True(t, letters[0].Replayed)
+
+	// a bus dead-letters into and replays from the configured store.
+	var memory = sabuhp.NewMemoryDeadLetterStore()
+	config.DeadLetterStore = memory
+	var memoryBus = NewRedisMessageBus(config, client, RedisStreams)
+	require.NoError(t, memoryBus.DeadLetter(sabuhp.NewMessage(sabuhp.T(topic), "me", []byte("kept")), fmt.Errorf("bad")))
+
+	letters, listErr = memory.List(sabuhp.DeadLetterQuery{Topic: topic})
+	require.NoError(t, listErr)
+	require.Len(t, letters, 1)
+	require.Equal(t, "kept", string(letters[0].Message.Bytes))
+}
+
+func TestCompressDecompress(t *testing.T) {
+	var content = []byte(strings.Repeat("sabuhp-compression-", 1024))
+
+	var compressed, compressErr = compress(content)
+	require.NoError(t, compressErr)
+	require.True(t, isCompressed(compressed))
+	require.Less(t, len(compressed), len(content))
+
+	var decompressed, decompressErr = decompress(compressed)
+	require.NoError(t, decompressErr)
+	require.Equal(t, content, decompressed)
+
+	var passthrough, passthroughErr = decompress(content)
+	require.NoError(t, passthroughErr)
+	require.Equal(t, content, passthrough)
+}
+
+func TestRedis_Stream_WithCompression(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.Compress = true
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	var pb, err = Stream(config)
+	require.NoError(t, err)
+	require.NotNil(t, pb)
+
+	pb.Start()
+
+	var content = []byte(strings.Repeat("large-payload-", 10*1024))
+	var largeMessage = sabuhp.NewMessage(sabuhp.T("compressed"), "me", content)
+
+	var received = make(chan sabuhp.Message, 1)
+	var channel = pb.Listen("compressed", "*", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			received <- message
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	pb.Send(largeMessage)
+
+	select {
+	case msg := <-received:
+		require.Equal(t, content, msg.Bytes)
+	case <-time.After(time.Second * 10):
+		require.Fail(t, "should have received compressed message")
+	}
+
+	canceler()
+	pb.Wait()
+}
+
+func TestRedis_ListenGroupValidation(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+
+	var client = redis.NewClient(&config.Redis)
+	var streamBus = NewRedisMessageBus(config, client, RedisStreams)
+	var pubsubBus = NewRedisMessageBus(config, client, RedisPubSub)
+
+	var specs = []struct {
+		Name  string
+		Bus   *RedisMessageBus
+		Group string
+		Valid bool
+	}{
+		{Name: "stream with group", Bus: streamBus, Group: "workers", Valid: true},
+		{Name: "stream with any group", Bus: streamBus, Group: AnyGroup, Valid: true},
+		{Name: "stream without group", Bus: streamBus, Group: "", Valid: false},
+		{Name: "pubsub with any group", Bus: pubsubBus, Group: AnyGroup, Valid: true},
+		{Name: "pubsub without group", Bus: pubsubBus, Group: "", Valid: true},
+		{Name: "pubsub with group", Bus: pubsubBus, Group: "workers", Valid: false},
+	}
+
+	for _, spec := range specs {
+		t.Run(spec.Name, func(t *testing.T) {
+			var groupErr = spec.Bus.validateGroup("what", spec.Group)
+			if spec.Valid {
+				require.NoError(t, groupErr)
+				return
+			}
+
+			require.Error(t, groupErr)
+
+			var channel = spec.Bus.Listen("what", spec.Group, sabuhp.TransportResponseFunc(
+				func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+					return nil
+				}))
+			require.Error(t, channel.Err())
+			require.Equal(t, "what", channel.Topic())
+			require.Equal(t, spec.Group, channel.Group())
+		})
+	}
+}
+
+func TestRedis_ListenBeforeStart(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	var pb, err = Stream(config)
+	require.NoError(t, err)
+	require.NotNil(t, pb)
+
+	var publisher, publisherErr = Stream(config)
+	require.NoError(t, publisherErr)
+	require.NotNil(t, publisher)
+
+	publisher.Start()
+
+	var received = make(chan sabuhp.Message, 1)
+	var channel = pb.Listen("before-start", "*", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			received <- message
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	pb.Start()
+
+	var msg = sabuhp.NewMessage(sabuhp.T("before-start"), "me", []byte("yes"))
+	publisher.Send(msg)
+
+	select {
+	case receivedMsg := <-received:
+		require.Equal(t, msg.Id, receivedMsg.Id)
+	case <-time.After(time.Second * 5):
+		require.Fail(t, "should have received message")
+	}
+
+	canceler()
+	pb.Wait()
+	publisher.Wait()
+}
+
+func TestRedis_SlowHandler(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var slowTopic = make(chan string, 1)
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.SlowHandlerThreshold = 50 * time.Millisecond
+	config.OnSlowHandler = func(topic string, elapsed time.Duration) {
+		require.True(t, elapsed > 50*time.Millisecond)
+		slowTopic <- topic
+	}
+
+	var pb = NewRedisMessageBus(config, redis.NewClient(&config.Redis), RedisPubSub)
+
+	var encoded, encodeErr = codec.Encode(sabuhp.NewMessage(sabuhp.T("slow"), "me", []byte("yes")))
+	require.NoError(t, encodeErr)
+
+	pb.handleMessage(logger, sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			time.Sleep(100 * time.Millisecond)
+			return nil
+		}), &redis.Message{Channel: "slow", Payload: string(encoded)})
+
+	select {
+	case topic := <-slowTopic:
+		require.Equal(t, "slow", topic)
+	default:
+		require.Fail(t, "slow handler should have been reported")
+	}
+
+	pb.handleMessage(logger, sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			return nil
+		}), &redis.Message{Channel: "slow", Payload: string(encoded)})
+
+	require.Len(t, slowTopic, 0)
+}
+
+func TestRedis_StopCancelsPendingReplies(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	var pb, err = PubSub(config)
+	require.NoError(t, err)
+	require.NotNil(t, pb)
+
+	pb.Start()
+
+	var futures []*sabuhp.ReplyFuture
+	for i := 0; i < 5; i++ {
+		var msg = sabuhp.NewMessage(sabuhp.T(fmt.Sprintf("unanswered_%d", i)), "me", []byte("yes"))
+		futures = append(futures, pb.SendForReply(time.Minute, msg.Topic, "*", msg))
+	}
+
+	require.Equal(t, 5, pb.PendingReplies())
+
+	var stopped = time.Now()
+	pb.Stop()
+
+	for _, ft := range futures {
+		select {
+		case <-ft.Done():
+		case <-time.After(time.Second):
+			require.Fail(t, "reply future should have resolved on Stop")
+		}
+
+		var _, replyErr = ft.Get()
+		require.Equal(t, sabuhp.ErrBusClosed, replyErr)
+	}
+
+	require.True(t, time.Since(stopped) < time.Minute)
+	require.Equal(t, 0, pb.PendingReplies())
+
+	var _, closedErr = pb.SendForReply(time.Minute, sabuhp.T("closed"), "*").Get()
+	require.Equal(t, sabuhp.ErrBusClosed, closedErr)
+}
+
+type captureLogger struct {
+	sync.Mutex
+	lines []string
+}
+
+func (c *captureLogger) Log(cb *njson.JSON) {
+	c.Lock()
+	c.lines = append(c.lines, cb.Message())
+	c.Unlock()
+}
+
+func TestRedis_HandlerErrorLogHasTopic(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &captureLogger{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+
+	var pb = NewRedisMessageBus(config, redis.NewClient(&config.Redis), RedisPubSub)
+
+	var encoded, encodeErr = codec.Encode(sabuhp.NewMessage(sabuhp.T("orders"), "me", []byte("yes")))
+	require.NoError(t, encodeErr)
+
+	var subLogger = sabuhp.WithFields(logger, sabuhp.LogFields{"topic": "orders", "group": "*"})
+	pb.handleMessage(subLogger, sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			return sabuhp.WrapErr(nerror.New("bad message"), false)
+		}), &redis.Message{Channel: "orders", Payload: string(encoded)})
+
+	var failureLine string
+	for _, line := range logger.lines {
+		if strings.Contains(line, "failed to handle message") {
+			failureLine = line
+		}
+	}
+
+	require.NotEmpty(t, failureLine)
+	require.Contains(t, failureLine, `"topic":"orders"`)
+	require.Contains(t, failureLine, `"group":"*"`)
+}
+
+func TestRedis_SkipsMalformedMessages(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &captureLogger{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+
+	var pb = NewRedisMessageBus(config, redis.NewClient(&config.Redis), RedisPubSub)
+
+	var encoded, encodeErr = codec.Encode(sabuhp.NewMessage(sabuhp.T("orders"), "me", []byte("yes")))
+	require.NoError(t, encodeErr)
+
+	var handled int
+	var handler = sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			handled++
+			return nil
+		})
+
+	var payloads = []struct {
+		data string
+		ack  bool
+	}{
+		// truncated payload which fails to decode, left pending for
+		// reclaim or dead-lettering.
+		{data: string(encoded[:len(encoded)/2]), ack: false},
+		// partial payload which decodes without a topic, never routable.
+		{data: `{"FromAddr": "me", "Bytes": "eWVz"}`, ack: true},
+	}
+
+	for _, payload := range payloads {
+		pb.handleMessage(logger, handler, &redis.Message{Channel: "orders", Payload: payload.data})
+		require.Equal(t, payload.ack, pb.handleXMessage(logger, "orders", AckOnHandle, handler, redis.XMessage{
+			ID:     "1-0",
+			Values: map[string]interface{}{"data": payload.data},
+		}))
+	}
+
+	require.Equal(t, 0, handled)
+
+	var skipped, pending int
+	for _, line := range logger.lines {
+		if strings.Contains(line, "skipping malformed message") {
+			skipped++
+		}
+		if strings.Contains(line, "leaving it pending") {
+			pending++
+		}
+	}
+	require.Equal(t, 3, skipped)
+	require.Equal(t, 1, pending)
+
+	var _, malformedErr = pb.decode([]byte(payloads[1].data))
+	require.Error(t, malformedErr)
+	require.Contains(t, malformedErr.Error(), ErrMalformedMessage.Error())
+}
+
+func TestRedis_ScheduledDelivery(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.ScheduleKey = "sabuhp.scheduled.test"
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	var pb, err = PubSub(config)
+	require.NoError(t, err)
+	require.NotNil(t, pb)
+
+	pb.Start()
+
+	var received = make(chan time.Time, 1)
+	var channel = pb.Listen("scheduled", "*", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			received <- time.Now()
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	var sentAt = time.Now()
+	var msg = sabuhp.NewMessage(sabuhp.T("scheduled"), "me", []byte("later"))
+	msg.ScheduledFor = sentAt.Add(200 * time.Millisecond)
+	pb.Send(msg)
+
+	select {
+	case arrivedAt := <-received:
+		var elapsed = arrivedAt.Sub(sentAt)
+		require.True(t, elapsed >= 200*time.Millisecond, "arrived after %s", elapsed)
+		require.True(t, elapsed < 2*time.Second, "arrived after %s", elapsed)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "scheduled message was never delivered")
+	}
+
+	canceler()
+	pb.Wait()
+}
+
+func TestRedis_PublishDueRemovesOnlyPublishedMessages(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.ScheduleKey = "sabuhp.scheduled.publish-due.test"
+	requireRedis(t, &config.Redis)
+
+	var client = redis.NewClient(&config.Redis)
+	var pb = NewRedisMessageBus(config, client, RedisPubSub)
+	require.NoError(t, client.Del(ctx, config.ScheduleKey).Err())
+
+	var now = time.Now()
+	var dueScore = float64(now.Add(-time.Second).UnixNano() / int64(time.Millisecond))
+
+	var encode = func(body string) string {
+		var encoded, encodeErr = pb.encode(sabuhp.NewMessage(sabuhp.T("scheduled"), "me", []byte(body)))
+		require.NoError(t, encodeErr)
+		return string(encoded)
+	}
+
+	var due, claimed = encode("due"), encode("claimed")
+	require.NoError(t, client.ZAdd(ctx, config.ScheduleKey,
+		&redis.Z{Score: dueScore, Member: due},
+		&redis.Z{Score: dueScore, Member: claimed},
+		&redis.Z{Score: dueScore, Member: "not a message"},
+	).Err())
+
+	// another bus claimed a message, it is left to that bus.
+	var leaseEnd = now.Add(scheduleClaimLease).UnixNano() / int64(time.Millisecond)
+	require.NoError(t, claimDueScript.Run(ctx, client, []string{config.ScheduleKey},
+		claimed, now.UnixNano()/int64(time.Millisecond), leaseEnd).Err())
+
+	var published, publishErr = pb.publishDue(now)
+	require.NoError(t, publishErr)
+	require.Equal(t, 1, published)
+
+	// the published and the undecodable messages were removed, the claimed
+	// one stays till the other bus published it or its lease elapsed.
+	var remaining, remainingErr = client.ZRange(ctx, config.ScheduleKey, 0, -1).Result()
+	require.NoError(t, remainingErr)
+	require.Equal(t, []string{claimed}, remaining)
+
+	published, publishErr = pb.publishDue(now.Add(scheduleClaimLease + time.Second))
+	require.NoError(t, publishErr)
+	require.Equal(t, 1, published)
+
+	var count, countErr = client.ZCard(ctx, config.ScheduleKey).Result()
+	require.NoError(t, countErr)
+	require.Zero(t, count)
+}
+
+func TestLeaderElection(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var client = redis.NewClient(&redis.Options{Network: "tcp"})
+	require.NoError(t, client.Del(ctx, "sabuhp.leader.test").Err())
+
+	var became = make(chan string, 2)
+	var createContender = func() *LeaderElection {
+		var election *LeaderElection
+		election = NewLeaderElection(LeaderConfig{
+			Ctx:           ctx,
+			Logger:        logger,
+			Key:           "sabuhp.leader.test",
+			TTL:           time.Second,
+			RenewInterval: 50 * time.Millisecond,
+			OnBecomeLeader: func() {
+				became <- election.ID()
+			},
+		}, client)
+		return election
+	}
+
+	var first = createContender()
+	var second = createContender()
+	first.Start()
+	second.Start()
+	defer second.Stop()
+
+	var leaderId = <-became
+	var leader, follower = first, second
+	if leaderId == second.ID() {
+		leader, follower = second, first
+	}
+
+	// contenders keep trying, only one must lead.
+	time.Sleep(200 * time.Millisecond)
+	require.True(t, leader.AmLeader())
+	require.False(t, follower.AmLeader())
+	require.Len(t, became, 0)
+
+	leader.Stop()
+	require.False(t, leader.AmLeader())
+
+	select {
+	case newLeaderId := <-became:
+		require.Equal(t, follower.ID(), newLeaderId)
+		require.True(t, follower.AmLeader())
+	case <-time.After(2 * time.Second):
+		require.Fail(t, "follower should have taken over leadership")
+	}
+
+	follower.Stop()
+}
+
+func TestRedis_Hybrid(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+	config.DurableTopic = func(topic string) bool {
+		return topic == "hybrid-orders"
+	}
+
+	var pb, err = Hybrid(config)
+	require.NoError(t, err)
+	require.NotNil(t, pb)
+
+	require.NoError(t, pb.client.Del(ctx, "hybrid-orders", "hybrid-events").Err())
+
+	require.Error(t, pb.validateGroup("hybrid-orders", ""))
+	require.Error(t, pb.validateGroup("hybrid-events", "workers"))
+
+	var orders = make(chan sabuhp.Message, 1)
+	var ordersChannel = pb.Listen("hybrid-orders", "workers", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			orders <- message
+			return nil
+		}))
+	require.NoError(t, ordersChannel.Err())
+	defer ordersChannel.Close()
+
+	var events = make(chan sabuhp.Message, 1)
+	var eventsChannel = pb.Listen("hybrid-events", AnyGroup, sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			events <- message
+			return nil
+		}))
+	require.NoError(t, eventsChannel.Err())
+	defer eventsChannel.Close()
+
+	pb.Start()
+
+	var order = sabuhp.NewMessage(sabuhp.T("hybrid-orders"), "me", []byte("order"))
+	var event = sabuhp.NewMessage(sabuhp.T("hybrid-events"), "me", []byte("event"))
+	pb.Send(order, event)
+
+	select {
+	case receivedMsg := <-orders:
+		require.Equal(t, order.Id, receivedMsg.Id)
+	case <-time.After(time.Second * 5):
+		require.Fail(t, "should have received durable message")
+	}
+
+	select {
+	case receivedMsg := <-events:
+		require.Equal(t, event.Id, receivedMsg.Id)
+	case <-time.After(time.Second * 5):
+		require.Fail(t, "should have received ephemeral message")
+	}
+
+	// durable messages are kept in a stream, ephemeral ones are not stored.
+	var streamLength, lengthErr = pb.client.XLen(ctx, "hybrid-orders").Result()
+	require.NoError(t, lengthErr)
+	require.Equal(t, int64(1), streamLength)
+
+	var exists, existsErr = pb.client.Exists(ctx, "hybrid-events").Result()
+	require.NoError(t, existsErr)
+	require.Equal(t, int64(0), exists)
+
+	canceler()
+	pb.Wait()
+}
+
+func TestRedis_StartIsIdempotent(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+
+	var pb = NewRedisMessageBus(config, redis.NewClient(&config.Redis), RedisPubSub)
+
+	require.NoError(t, pb.Start())
+	<-time.After(time.Millisecond * 100)
+	var goroutines = runtime.NumGoroutine()
+
+	var starters sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		starters.Add(1)
+		go func() {
+			defer starters.Done()
+			require.NoError(t, pb.Start())
+		}()
+	}
+	starters.Wait()
+
+	<-time.After(time.Millisecond * 100)
+	require.Equal(t, goroutines, runtime.NumGoroutine())
+
+	pb.Stop()
+	require.Equal(t, sabuhp.ErrBusClosed, pb.Start())
+}
+
+func TestRedis_SendWithAck(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	var streamBus, streamErr = Stream(config)
+	require.NoError(t, streamErr)
+
+	var pubsubBus, pubsubErr = PubSub(config)
+	require.NoError(t, pubsubErr)
+
+	streamBus.Start()
+	pubsubBus.Start()
+
+	var streamAck = streamBus.SendWithAck(sabuhp.NewMessage(sabuhp.T("acked-stream"), "me", []byte("yes")))
+	streamAck.Wait()
+	require.NoError(t, streamAck.Err())
+
+	var ack = streamAck.Value().(PublishAck)
+	require.Equal(t, "acked-stream", ack.Topic)
+	require.NotEmpty(t, ack.StreamId)
+
+	var entries, rangeErr = streamBus.client.XRange(ctx, "acked-stream", ack.StreamId, ack.StreamId).Result()
+	require.NoError(t, rangeErr)
+	require.Len(t, entries, 1)
+
+	var received = make(chan sabuhp.Message, 1)
+	var channel = pubsubBus.Listen("acked-pubsub", AnyGroup, sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			received <- message
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	var pubsubAck = pubsubBus.SendWithAck(sabuhp.NewMessage(sabuhp.T("acked-pubsub"), "me", []byte("yes")))
+	pubsubAck.Wait()
+	require.NoError(t, pubsubAck.Err())
+	require.Equal(t, int64(1), pubsubAck.Value().(PublishAck).Receivers)
+
+	<-received
+
+	canceler()
+	streamBus.Wait()
+	pubsubBus.Wait()
+}
+
+// requireRedis skips the test when no redis server is reachable.
+func requireRedis(t *testing.T, options *redis.Options) {
+	var ctx, canceler = context.WithTimeout(context.Background(), time.Second)
+	defer canceler()
+
+	var client = redis.NewClient(options)
+	defer client.Close()
+
+	if pingErr := client.Ping(ctx).Err(); pingErr != nil {
+		t.Skipf("redis is not available: %s", pingErr)
+	}
+}
+
+// commandCounter is a redis.Hook counting the commands processed by name.
+type commandCounter struct {
+	sync.Mutex
+	counts map[string]int
+}
+
+func (c *commandCounter) Count(name string) int {
+	c.Lock()
+	defer c.Unlock()
+	return c.counts[name]
+}
+
+func (c *commandCounter) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	c.Lock()
+	c.counts[cmd.Name()]++
+	c.Unlock()
+	return ctx, nil
+}
+
+func (c *commandCounter) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (c *commandCounter) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (c *commandCounter) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+func TestRedis_IdleStream(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &captureLogger{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.StreamBlockTimeout = 100 * time.Millisecond
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	requireRedis(t, &config.Redis)
+
+	var counter = &commandCounter{counts: map[string]int{}}
+	var client = redis.NewClient(&config.Redis)
+	client.AddHook(counter)
+
+	var pb = NewRedisMessageBus(config, client, RedisStreams)
+	require.NoError(t, client.Del(ctx, "idle-stream").Err())
+
+	var channel = pb.Listen("idle-stream", "idlers", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			require.Fail(t, "idle stream should deliver no message")
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+
+	pb.Start()
+
+	<-time.After(time.Second)
+
+	// a blocking read returns at most every block timeout, a busy loop
+	// would issue far more reads.
+	var reads = counter.Count("xreadgroup")
+	require.True(t, reads > 0)
+	require.True(t, reads <= 15, "expected at most 15 reads but got %d", reads)
+
+	channel.Close()
+	canceler()
+	pb.Wait()
+
+	logger.Lock()
+	defer logger.Unlock()
+	for _, line := range logger.lines {
+		require.NotContains(t, line, "stream err occurred")
+	}
+}
+
+func TestRedis_BatchedAcks(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.StreamBlockTimeout = 100 * time.Millisecond
+	config.AckBatchSize = 10
+	config.AckFlushInterval = time.Minute
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	requireRedis(t, &config.Redis)
+
+	var counter = &commandCounter{counts: map[string]int{}}
+	var client = redis.NewClient(&config.Redis)
+	client.AddHook(counter)
+
+	var pb = NewRedisMessageBus(config, client, RedisStreams)
+	require.NoError(t, client.Del(ctx, "batched-acks").Err())
+
+	var handled = make(chan sabuhp.Message, 25)
+	var channel = pb.Listen("batched-acks", "ackers", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			handled <- message
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+
+	// the backlog is read without the stream going idle in between.
+	var messages = make([]sabuhp.Message, 25)
+	for index := range messages {
+		messages[index] = sabuhp.NewMessage(sabuhp.T("batched-acks"), "me", []byte("yes"))
+	}
+	pb.Send(messages...)
+
+	pb.Start()
+
+	for range messages {
+		select {
+		case <-handled:
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "all messages should be handled")
+		}
+	}
+
+	// two full batches, then the rest once the stream is idle.
+	require.Eventually(t, func() bool {
+		var pending, pendingErr = client.XPending(ctx, "batched-acks", "ackers").Result()
+		return pendingErr == nil && pending.Count == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 3, counter.Count("xack"))
+
+	channel.Close()
+	canceler()
+	pb.Wait()
+}
+
+func TestConfig_AckFlushIntervalBelowReclaimMinIdle(t *testing.T) {
+	var config Config
+	config.Ctx = context.Background()
+	config.Logger = &testingutils.LoggerPub{}
+	config.AckBatchSize = 10
+	config.AckFlushInterval = time.Minute
+	config.ReclaimMinIdle = 200 * time.Millisecond
+	config.ensure()
+	require.Equal(t, 100*time.Millisecond, config.AckFlushInterval)
+
+	// batched acks without a flush interval are flushed before reclaimed.
+	config.AckFlushInterval = 0
+	config.ensure()
+	require.Equal(t, 100*time.Millisecond, config.AckFlushInterval)
+
+	config.AckFlushInterval = 50 * time.Millisecond
+	config.ensure()
+	require.Equal(t, 50*time.Millisecond, config.AckFlushInterval)
+
+	// without reclaims entries are never handled again while batched.
+	config.ReclaimMinIdle = 0
+	config.AckFlushInterval = time.Minute
+	config.ensure()
+	require.Equal(t, time.Minute, config.AckFlushInterval)
+}
+
+func TestRedis_DrainThenListen(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.StreamBlockTimeout = 100 * time.Millisecond
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	requireRedis(t, &config.Redis)
+
+	var client = redis.NewClient(&config.Redis)
+	var pb = NewRedisMessageBus(config, client, RedisStreams)
+	require.NoError(t, client.Del(ctx, "drain-stream").Err())
+
+	pb.Start()
+
+	for _, payload := range []string{"backlog-1", "backlog-2", "backlog-3"} {
+		var _, sendErr = pb.SendWithReceipt(sabuhp.NewMessage(sabuhp.T("drain-stream"), "me", []byte(payload)))
+		require.NoError(t, sendErr)
+	}
+
+	var receivedMu sync.Mutex
+	var received []string
+	var caughtUpAt = make(chan int, 1)
+
+	var channel = pb.DrainThenListen("drain-stream", "drainers", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			receivedMu.Lock()
+			received = append(received, string(message.Bytes))
+			receivedMu.Unlock()
+			return nil
+		}), func() {
+		receivedMu.Lock()
+		caughtUpAt <- len(received)
+		receivedMu.Unlock()
+	})
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	select {
+	case count := <-caughtUpAt:
+		require.Equal(t, 3, count)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "consumer should catch up with the backlog")
+	}
+
+	var _, sendErr = pb.SendWithReceipt(sabuhp.NewMessage(sabuhp.T("drain-stream"), "me", []byte("live-1")))
+	require.NoError(t, sendErr)
+
+	require.Eventually(t, func() bool {
+		receivedMu.Lock()
+		defer receivedMu.Unlock()
+		return len(received) == 4
+	}, 5*time.Second, 10*time.Millisecond)
+
+	receivedMu.Lock()
+	require.Equal(t, []string{"backlog-1", "backlog-2", "backlog-3", "live-1"}, received)
+	receivedMu.Unlock()
+
+	var pubsub = NewRedisMessageBus(config, client, RedisPubSub)
+	require.Error(t, pubsub.DrainThenListen("drain-stream", "drainers", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			return nil
+		}), nil).Err())
+
+	canceler()
+	pb.Wait()
+}
+
+func TestRedis_DrainRereadsPendingEntriesAndCatchesUpWhenIdle(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.StreamBlockTimeout = 100 * time.Millisecond
+	requireRedis(t, &config.Redis)
+
+	var client = redis.NewClient(&config.Redis)
+	var pb = NewRedisMessageBus(config, client, RedisStreams)
+	require.NoError(t, client.Del(ctx, "drain-pending").Err())
+	require.NoError(t, client.XGroupCreateMkStream(ctx, "drain-pending", "drainers", "$").Err())
+
+	var caughtUp = make(chan struct{})
+	var pub = &redisSubscription{
+		id:      nxid.New(),
+		topic:   "drain-pending",
+		group:   "drainers",
+		ackMode: AckOnHandle,
+		host:    pb,
+		logger:  logger,
+
+		// the end of the backlog was read by another consumer of the group
+		// or trimmed, this consumer never reads it.
+		backlogEnd: "99999999999999-0",
+		onCaughtUp: func() { close(caughtUp) },
+	}
+
+	// an entry read but not acknowledged by the consumer before its reader
+	// restarted.
+	var encoded, encodeErr = pb.encode(sabuhp.NewMessage(sabuhp.T("drain-pending"), "me", []byte("pending")))
+	require.NoError(t, encodeErr)
+	require.NoError(t, client.XAdd(ctx, &redis.XAddArgs{Stream: "drain-pending", ID: "*", Values: map[string]interface{}{"data": string(encoded)}}).Err())
+	require.NoError(t, client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    "drainers",
+		Consumer: consumerName(pub),
+		Streams:  []string{"drain-pending", ">"},
+		Count:    1,
+	}).Err())
+
+	var received = make(chan string, 1)
+	var readCtx, readCanceler = context.WithCancel(ctx)
+	var done = make(chan struct{})
+	go func() {
+		defer close(done)
+		pb.readStream(readCtx, sabuhp.TransportResponseFunc(
+			func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+				received <- string(message.Bytes)
+				return nil
+			}), pub, "drain-pending", "drainers")
+	}()
+
+	select {
+	case payload := <-received:
+		require.Equal(t, "pending", payload)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "the pending entry should be read again")
+	}
+
+	select {
+	case <-caughtUp:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "an idle read should catch the consumer up")
+	}
+
+	readCanceler()
+	<-done
+
+	var pending, pendingErr = client.XPending(ctx, "drain-pending", "drainers").Result()
+	require.NoError(t, pendingErr)
+	require.Zero(t, pending.Count)
+}
+func TestCompareStreamIds(t *testing.T) {
+	require.Equal(t, 0, compareStreamIds("1-1", "1-1"))
+	require.Equal(t, -1, compareStreamIds("1-1", "1-2"))
+	require.Equal(t, 1, compareStreamIds("2-0", "1-9"))
+	require.Equal(t, -1, compareStreamIds("9-0", "10-0"))
+}
+
+func TestRedis_DoubleStop(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = context.Background()
+	config.Codec = codec
+	config.Logger = logger
+
+	var pb = NewRedisMessageBus(config, redis.NewClient(&config.Redis), RedisPubSub)
+	require.NoError(t, pb.Start())
+
+	require.NoError(t, pb.Stop())
+	require.Equal(t, sabuhp.ErrAlreadyStopped, pb.Stop())
+	require.Equal(t, sabuhp.ErrBusClosed, pb.Start())
+}
+
+func TestRedis_Lag(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	requireRedis(t, &config.Redis)
+
+	var pb = NewRedisMessageBus(config, redis.NewClient(&config.Redis), RedisStreams)
+	require.NoError(t, pb.client.Del(ctx, "lagging").Err())
+	require.NoError(t, pb.client.XGroupCreateMkStream(ctx, "lagging", "laggards", "$").Err())
+
+	var lag, lagErr = pb.Lag("lagging", "laggards")
+	require.NoError(t, lagErr)
+	require.Equal(t, int64(0), lag)
+
+	for i := 0; i < 5; i++ {
+		var ack = pb.SendWithAck(sabuhp.NewMessage(sabuhp.T("lagging"), "me", []byte("yes")))
+		ack.Wait()
+		require.NoError(t, ack.Err())
+	}
+
+	lag, lagErr = pb.Lag("lagging", "laggards")
+	require.NoError(t, lagErr)
+	require.Equal(t, int64(5), lag)
+
+	// reading messages as the group reduces its lag.
+	var streams, readErr = pb.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    "laggards",
+		Consumer: "laggard",
+		Streams:  []string{"lagging", ">"},
+		Count:    2,
+	}).Result()
+	require.NoError(t, readErr)
+	require.Len(t, streams[0].Messages, 2)
+
+	lag, lagErr = pb.Lag("lagging", "laggards")
+	require.NoError(t, lagErr)
+	require.Equal(t, int64(3), lag)
+
+	var _, missingErr = pb.Lag("lagging", "missing")
+	require.Error(t, missingErr)
+}
+
+func TestRedis_PurgeTopic(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	requireRedis(t, &config.Redis)
+
+	var pb = NewRedisMessageBus(config, redis.NewClient(&config.Redis), RedisStreams)
+	require.NoError(t, pb.client.Del(ctx, "purging").Err())
+	require.NoError(t, pb.client.XGroupCreateMkStream(ctx, "purging", "purgers", "$").Err())
+
+	for i := 0; i < 5; i++ {
+		var ack = pb.SendWithAck(sabuhp.NewMessage(sabuhp.T("purging"), "me", []byte("yes")))
+		ack.Wait()
+		require.NoError(t, ack.Err())
+	}
+
+	// leave entries pending on the group.
+	var _, readErr = pb.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    "purgers",
+		Consumer: "purger",
+		Streams:  []string{"purging", ">"},
+		Count:    2,
+	}).Result()
+	require.NoError(t, readErr)
+
+	require.NoError(t, pb.PurgeTopic("purging"))
+	require.Equal(t, int64(0), pb.client.XLen(ctx, "purging").Val())
+
+	var pending, pendingErr = pb.client.XPending(ctx, "purging", "purgers").Result()
+	require.NoError(t, pendingErr)
+	require.Equal(t, int64(0), pending.Count)
+
+	var lag, lagErr = pb.Lag("purging", "purgers")
+	require.NoError(t, lagErr)
+	require.Equal(t, int64(0), lag)
+
+	// purging a topic without a stream does nothing.
+	require.NoError(t, pb.PurgeTopic("never-published"))
+}
+
+func TestRedis_TraceIds(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+	requireRedis(t, &config.Redis)
+
+	var pb, err = PubSub(config)
+	require.NoError(t, err)
+	require.NoError(t, pb.Start())
+
+	var received = make(chan sabuhp.Message, 2)
+	var channel = pb.Listen("traced", AnyGroup, sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			received <- message
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	<-time.After(time.Millisecond * 100)
+
+	var untraced = sabuhp.NewMessage(sabuhp.T("traced"), "me", []byte("untraced"))
+	var preset = sabuhp.NewMessage(sabuhp.T("traced"), "me", []byte("preset"))
+	preset.Metadata = sabuhp.Params{sabuhp.TraceIdMetadata: "preset-trace"}
+	pb.Send(untraced, preset)
+
+	var first = <-received
+	var second = <-received
+	require.NotEmpty(t, sabuhp.TraceId(first))
+	require.Equal(t, "preset-trace", sabuhp.TraceId(second))
+
+	// the sent message itself was not modified.
+	require.Empty(t, sabuhp.TraceId(untraced))
+
+	canceler()
+	pb.Wait()
+}
+
+func TestRedis_ListenGroupsOfTopic(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+	requireRedis(t, &config.Redis)
+
+	var pb, err = Stream(config)
+	require.NoError(t, err)
+	require.NoError(t, pb.Start())
+
+	var received = make(chan string, 10)
+	var listen = func(grp string, mode AckMode) sabuhp.Channel {
+		return pb.ListenWithAckMode("grouped", grp, mode, sabuhp.TransportResponseFunc(
+			func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+				received <- grp
+				return nil
+			}))
+	}
+
+	// distinct groups each get the messages, the same group competes.
+	var billing = listen("billing", AckOnHandle)
+	require.NoError(t, billing.Err())
+	var billing2 = listen("billing", AckOnHandle)
+	require.NoError(t, billing2.Err())
+	var audit = listen("audit", AckOnRead)
+	require.NoError(t, audit.Err())
+
+	// the same group with another mode conflicts.
+	var conflicting = listen("billing", AckOnRead)
+	require.Error(t, conflicting.Err())
+
+	pb.Send(sabuhp.NewMessage(sabuhp.T("grouped"), "me", []byte("hello")))
+
+	var groups = map[string]int{}
+	for i := 0; i < 2; i++ {
+		select {
+		case grp := <-received:
+			groups[grp]++
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "message should be delivered to each group")
+		}
+	}
+	require.Equal(t, map[string]int{"billing": 1, "audit": 1}, groups)
+
+	select {
+	case grp := <-received:
+		require.Fail(t, "message delivered twice", grp)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// once all its listeners are closed, the group can change mode.
+	billing.Close()
+	billing2.Close()
+	var reopened = listen("billing", AckOnRead)
+	require.NoError(t, reopened.Err())
+
+	canceler()
+	pb.Wait()
+}
+
+func TestRedis_RegisterGroup(t *testing.T) {
+	var bus = &RedisMessageBus{groups: map[string]*streamGroup{}}
+
+	require.NoError(t, bus.registerGroup("orders", "billing", AckOnHandle))
+	require.NoError(t, bus.registerGroup("orders", "billing", AckOnHandle))
+	require.NoError(t, bus.registerGroup("orders", "audit", AckOnRead))
+	require.NoError(t, bus.registerGroup("payments", "billing", AckOnRead))
+	require.Error(t, bus.registerGroup("orders", "billing", AckOnRead))
+
+	bus.releaseGroup("orders", "billing")
+	require.Error(t, bus.registerGroup("orders", "billing", AckOnRead))
+
+	bus.releaseGroup("orders", "billing")
+	require.NoError(t, bus.registerGroup("orders", "billing", AckOnRead))
+}
+
+func TestRedis_ListenWithFilter(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.FilterAttributes = []string{"region"}
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+	requireRedis(t, &config.Redis)
+
+	var pb, err = PubSub(config)
+	require.NoError(t, err)
+	require.NoError(t, pb.Start())
+
+	var received = make(chan sabuhp.Message, 4)
+	var channel = pb.ListenWithFilter("regional", AnyGroup, sabuhp.Params{"region": "eu"}, sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			received <- message
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	var all = make(chan sabuhp.Message, 4)
+	var allChannel = pb.Listen("regional", AnyGroup, sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			all <- message
+			return nil
+		}))
+	require.NoError(t, allChannel.Err())
+	defer allChannel.Close()
+
+	var invalid = pb.ListenWithFilter("regional", AnyGroup, sabuhp.Params{"tier": "gold"}, nil)
+	require.Error(t, invalid.Err())
+
+	<-time.After(time.Millisecond * 100)
+
+	var us = sabuhp.NewMessage(sabuhp.T("regional"), "me", []byte("us"))
+	us.Metadata = sabuhp.Params{"region": "us"}
+	var eu = sabuhp.NewMessage(sabuhp.T("regional"), "me", []byte("eu"))
+	eu.Metadata = sabuhp.Params{"region": "eu"}
+	pb.Send(us, eu)
+
+	// the redis subscription only matched the eu channel, the handler
+	// does no filtering of its own.
+	var euMessage = <-received
+	require.Equal(t, "eu", string(euMessage.Bytes))
+
+	<-all
+	<-all
+
+	select {
+	case message := <-received:
+		require.Fail(t, "received message of another region", string(message.Bytes))
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	canceler()
+	pb.Wait()
+}
+
+func TestRedis_AttributeChannel(t *testing.T) {
+	var bus = &RedisMessageBus{config: Config{FilterAttributes: []string{"region", "tier"}}}
+
+	var msg = sabuhp.NewMessage(sabuhp.T("orders"), "me", nil)
+	msg.Metadata = sabuhp.Params{"region": "eu*west", "tier": "gold"}
+	require.Equal(t, "orders|region=eu%2Awest|tier=gold", bus.attributeChannel("orders", msg))
+
+	var pattern, patternErr = bus.attributePattern("orders", sabuhp.Params{"region": "eu*west"})
+	require.NoError(t, patternErr)
+	require.Equal(t, "orders|region=eu%2Awest|tier=*", pattern)
+
+	_, patternErr = bus.attributePattern("orders", sabuhp.Params{"zone": "a"})
+	require.Error(t, patternErr)
+}
+
+func TestRedis_SetCodec(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var config Config
+	config.Ctx = ctx
+	config.Codec = &codecs.MessageJsonCodec{}
+	config.Logger = &testingutils.LoggerPub{}
+
+	var bus = NewRedisMessageBus(config, nil, RedisPubSub)
+
+	var oldEncoded, oldErr = bus.encode(sabuhp.NewMessage(sabuhp.T("migrating"), "me", []byte("old")))
+	require.NoError(t, oldErr)
+
+	bus.SetCodec(&codecs.MessageMsgPackCodec{}, time.Hour)
+
+	var newEncoded, newErr = bus.encode(sabuhp.NewMessage(sabuhp.T("migrating"), "me", []byte("new")))
+	require.NoError(t, newErr)
+
+	// new messages use the new codec.
+	var _, jsonErr = (&codecs.MessageJsonCodec{}).Decode(newEncoded)
+	require.Error(t, jsonErr)
+
+	// both codecs decode during the transition window.
+	var oldMessage, oldDecodeErr = bus.decode(oldEncoded)
+	require.NoError(t, oldDecodeErr)
+	require.Equal(t, "old", string(oldMessage.Bytes))
+
+	var newMessage, newDecodeErr = bus.decode(newEncoded)
+	require.NoError(t, newDecodeErr)
+	require.Equal(t, "new", string(newMessage.Bytes))
+
+	// once the window passed, only the new codec decodes.
+	bus.SetCodec(&codecs.MessageMsgPackCodec{}, 0)
+	var _, expiredErr = bus.decode(oldEncoded)
+	require.Error(t, expiredErr)
+}
+
+func TestRedis_Supervise(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = &testingutils.LoggerPub{}
+	config.RestartOnPanic = true
+
+	var bus = NewRedisMessageBus(config, nil, RedisPubSub)
+	require.Nil(t, bus.LastPanic())
+
+	var runs int
+	bus.supervise("test", func() {
+		runs++
+		if runs == 1 {
+			panic("first run fails")
+		}
+	})
+	require.Equal(t, 2, runs)
+
+	var report = bus.LastPanic()
+	require.NotNil(t, report)
+	require.Equal(t, "test", report.Goroutine)
+	require.Equal(t, "first run fails", report.Value)
+	require.NotEmpty(t, report.Stack)
+
+	// without restarts the goroutine ends on its first panic.
+	bus.config.RestartOnPanic = false
+	runs = 0
+	bus.supervise("test", func() {
+		runs++
+		panic("fails")
+	})
+	require.Equal(t, 1, runs)
+}
+
+func TestRedis_HandlerPanic(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+	requireRedis(t, &config.Redis)
+
+	var pb, err = PubSub(config)
+	require.NoError(t, err)
+	require.NoError(t, pb.Start())
+
+	var received = make(chan sabuhp.Message, 1)
+	var channel = pb.Listen("panicking", AnyGroup, sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			if string(message.Bytes) == "panic" {
+				panic("handler failed")
+			}
+			received <- message
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	<-time.After(time.Millisecond * 100)
+
+	pb.Send(sabuhp.NewMessage(sabuhp.T("panicking"), "me", []byte("panic")))
+	pb.Send(sabuhp.NewMessage(sabuhp.T("panicking"), "me", []byte("fine")))
+
+	// the bus survived the panic and handled the next message.
+	var message = <-received
+	require.Equal(t, "fine", string(message.Bytes))
+	require.True(t, pb.Healthy())
+
+	var report = pb.LastPanic()
+	require.NotNil(t, report)
+	require.Equal(t, "handler:panicking", report.Goroutine)
+
+	canceler()
+	pb.Wait()
+}
+
+func TestRedis_SendWithReceipt(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+	requireRedis(t, &config.Redis)
+
+	var pb, err = Stream(config)
+	require.NoError(t, err)
+	require.NoError(t, pb.Start())
+
+	var before = time.Now().Add(-time.Second)
+	var msg = sabuhp.NewMessage(sabuhp.T("receipts"), "me", []byte("yes"))
+	var receipt, receiptErr = pb.SendWithReceipt(msg)
+	require.NoError(t, receiptErr)
+	require.Equal(t, "receipts", receipt.Topic)
+	require.Equal(t, msg.Id.String(), receipt.MessageId)
+	require.NotEmpty(t, receipt.StreamId)
+	require.True(t, receipt.AcceptedAt.After(before))
+
+	canceler()
+	pb.Wait()
+}
+
+func TestStreamIdTime(t *testing.T) {
+	var at, ok = streamIdTime("1526919030474-55")
+	require.True(t, ok)
+	require.Equal(t, int64(1526919030474), at.UnixNano()/int64(time.Millisecond))
+
+	_, ok = streamIdTime("invalid")
+	require.False(t, ok)
+}
+
+func TestRedis_HandlerErrorOutcomes(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+	requireRedis(t, &config.Redis)
+
+	var failing = func(handled chan string) sabuhp.TransportResponse {
+		return sabuhp.TransportResponseFunc(func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			defer func() { handled <- string(message.Bytes) }()
+			return sabuhp.WrapErr(nerror.New("failed"), string(message.Bytes) == "ack")
+		})
+	}
+
+	t.Run("stream messages failing without ack stay pending", func(t *testing.T) {
+		var pb, err = Stream(config)
+		require.NoError(t, err)
+		require.NoError(t, pb.client.Del(ctx, "failing-stream").Err())
+		defer pb.client.Del(ctx, "failing-stream")
+		require.NoError(t, pb.Start())
+
+		var handled = make(chan string, 2)
+		var channel = pb.Listen("failing-stream", "workers", failing(handled))
+		require.NoError(t, channel.Err())
+
+		pb.Send(sabuhp.NewMessage(sabuhp.T("failing-stream"), "me", []byte("nack")))
+		pb.Send(sabuhp.NewMessage(sabuhp.T("failing-stream"), "me", []byte("ack")))
+		<-handled
+		<-handled
+
+		require.Eventually(t, func() bool {
+			var pending, pendingErr = pb.client.XPending(ctx, "failing-stream", "workers").Result()
+			return pendingErr == nil && pending.Count == 1
+		}, 3*time.Second, 20*time.Millisecond)
+
+		channel.Close()
+		require.NoError(t, pb.Stop())
+	})
+
+	t.Run("pubsub messages failing without ack are dead-lettered", func(t *testing.T) {
+		var pb, err = PubSub(config)
+		require.NoError(t, err)
+		require.NoError(t, pb.client.Del(ctx, DeadLetterStream("failing-pubsub")).Err())
+		defer pb.client.Del(ctx, DeadLetterStream("failing-pubsub"))
+		require.NoError(t, pb.Start())
+
+		var handled = make(chan string, 2)
+		var channel = pb.Listen("failing-pubsub", AnyGroup, failing(handled))
+		require.NoError(t, channel.Err())
+
+		<-time.After(time.Millisecond * 100)
+
+		pb.Send(sabuhp.NewMessage(sabuhp.T("failing-pubsub"), "me", []byte("nack")))
+		pb.Send(sabuhp.NewMessage(sabuhp.T("failing-pubsub"), "me", []byte("ack")))
+		<-handled
+		<-handled
+
+		require.Eventually(t, func() bool {
+			var entries, rangeErr = pb.client.XRange(ctx, DeadLetterStream("failing-pubsub"), "-", "+").Result()
+			return rangeErr == nil && len(entries) == 1
+		}, 3*time.Second, 20*time.Millisecond)
+
+		require.NoError(t, pb.Stop())
+	})
+}
+
+func TestInstanceBus_RoutesTopics(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.StreamBlockTimeout = 100 * time.Millisecond
+
+	// two databases of the local redis stand in for two instances.
+	var options = []redis.Options{
+		{Network: "tcp", DB: 0},
+		{Network: "tcp", DB: 1},
+	}
+	requireRedis(t, &options[0])
+
+	var clients = make([]*redis.Client, len(options))
+	for index := range options {
+		clients[index] = redis.NewClient(&options[index])
+		defer clients[index].Close()
+		require.NoError(t, clients[index].Del(ctx, "instance-orders", "instance-users").Err())
+	}
+
+	var router = MappedTopicRouter(map[string]int{"instance-orders": 0, "instance-users": 1}, nil)
+	var pb, err = Instances(config, options, RedisStreams, router)
+	require.NoError(t, err)
+	require.Len(t, pb.Instances(), 2)
+	require.Equal(t, 0, pb.InstanceFor("instance-orders"))
+	require.Equal(t, 1, pb.InstanceFor("instance-users"))
+
+	require.NoError(t, pb.Start())
+	require.True(t, pb.Healthy())
+
+	// handlers reply through the InstanceBus, not the bus of one instance.
+	var transports = make(chan sabuhp.MessageBus, 2)
+	var received = make(chan sabuhp.Message, 2)
+	var handler = sabuhp.TransportResponseFunc(func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+		transports <- transport.Bus
+		received <- message
+		return nil
+	})
+
+	var ordersChannel = pb.Listen("instance-orders", "workers", handler)
+	require.NoError(t, ordersChannel.Err())
+	defer ordersChannel.Close()
+
+	var usersChannel = pb.Listen("instance-users", "workers", handler)
+	require.NoError(t, usersChannel.Err())
+	defer usersChannel.Close()
+
+	pb.Send(
+		sabuhp.NewMessage(sabuhp.T("instance-orders"), "me", []byte("order-1")),
+		sabuhp.NewMessage(sabuhp.T("instance-users"), "me", []byte("user-1")),
+	)
+
+	var payloads = map[string]string{}
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-received:
+			payloads[msg.Topic.String()] = string(msg.Bytes)
+			require.Equal(t, sabuhp.MessageBus(pb), <-transports)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "messages should be received from both instances")
+		}
+	}
+	require.Equal(t, map[string]string{"instance-orders": "order-1", "instance-users": "user-1"}, payloads)
+
+	// each stream only exists on the instance its topic is routed to.
+	require.Equal(t, int64(1), clients[0].Exists(ctx, "instance-orders").Val())
+	require.Equal(t, int64(0), clients[0].Exists(ctx, "instance-users").Val())
+	require.Equal(t, int64(0), clients[1].Exists(ctx, "instance-orders").Val())
+	require.Equal(t, int64(1), clients[1].Exists(ctx, "instance-users").Val())
+
+	require.NoError(t, pb.Stop())
+	pb.Wait()
+}
+
+func TestRedis_ReclaimsPendingMessagesOfDepartedConsumer(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var rebalances = make(chan RebalanceEvent, 1)
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.StreamBlockTimeout = 100 * time.Millisecond
+	config.ReclaimMinIdle = 200 * time.Millisecond
+	config.ReclaimInterval = 50 * time.Millisecond
+	config.OnRebalance = func(event RebalanceEvent) {
+		rebalances <- event
+	}
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	requireRedis(t, &config.Redis)
+
+	var client = redis.NewClient(&config.Redis)
+	defer client.Close()
+
+	require.NoError(t, client.Del(ctx, "reclaim-stream").Err())
+	require.NoError(t, client.XGroupCreateMkStream(ctx, "reclaim-stream", "workers", "0").Err())
+
+	var pb = NewRedisMessageBus(config, client, RedisStreams)
+	for _, payload := range []string{"pending-1", "pending-2"} {
+		var _, sendErr = pb.SendWithReceipt(sabuhp.NewMessage(sabuhp.T("reclaim-stream"), "me", []byte(payload)))
+		require.NoError(t, sendErr)
+	}
+
+	// a consumer of an instance which left reads the messages but never
+	// acknowledges them.
+	var read, readErr = client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    "workers",
+		Consumer: "departed-consumer",
+		Streams:  []string{"reclaim-stream", ">"},
+		Count:    10,
+	}).Result()
+	require.NoError(t, readErr)
+	require.Len(t, read[0].Messages, 2)
+
+	var received = make(chan string, 2)
+	var channel = pb.Listen("reclaim-stream", "workers", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			received <- string(message.Bytes)
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	require.NoError(t, pb.Start())
+
+	select {
+	case event := <-rebalances:
+		require.Equal(t, []string{"departed-consumer"}, event.From)
+		require.Len(t, event.Claimed, 2)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "pending messages should be reclaimed")
+	}
+
+	require.Equal(t, "pending-1", <-received)
+	require.Equal(t, "pending-2", <-received)
+
+	require.Eventually(t, func() bool {
+		var pending, pendingErr = client.XPending(ctx, "reclaim-stream", "workers").Result()
+		return pendingErr == nil && pending.Count == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	canceler()
+	pb.Wait()
+}
+
+func TestRedis_ReclaimDeadLettersMessagesOverMaxDeliveries(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.StreamBlockTimeout = 100 * time.Millisecond
+	config.ReclaimMinIdle = 200 * time.Millisecond
+	config.ReclaimInterval = 50 * time.Millisecond
+	config.MaxDeliveries = 1
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	requireRedis(t, &config.Redis)
+
+	var client = redis.NewClient(&config.Redis)
+	defer client.Close()
+
+	var topic = "reclaim-exhausted"
+	require.NoError(t, client.Del(ctx, topic, DeadLetterStream(topic), replayedSet(topic)).Err())
+	defer client.Del(ctx, DeadLetterStream(topic), replayedSet(topic))
+	require.NoError(t, client.XGroupCreateMkStream(ctx, topic, "workers", "0").Err())
+
+	var pb = NewRedisMessageBus(config, client, RedisStreams)
+	var _, sendErr = pb.SendWithReceipt(sabuhp.NewMessage(sabuhp.T(topic), "me", []byte("poison")))
+	require.NoError(t, sendErr)
+
+	// the message was delivered once to a consumer which crashed on it.
+	var read, readErr = client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    "workers",
+		Consumer: "crashed-consumer",
+		Streams:  []string{topic, ">"},
+		Count:    10,
+	}).Result()
+	require.NoError(t, readErr)
+	require.Len(t, read[0].Messages, 1)
+
+	var received = make(chan string, 1)
+	var channel = pb.Listen(topic, "workers", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			received <- string(message.Bytes)
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	require.NoError(t, pb.Start())
+
+	require.Eventually(t, func() bool {
+		var pending, pendingErr = client.XPending(ctx, topic, "workers").Result()
+		return pendingErr == nil && pending.Count == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	var letters, listErr = pb.DeadLetters().List(sabuhp.DeadLetterQuery{Topic: topic})
+	require.NoError(t, listErr)
+	require.Len(t, letters, 1)
+	require.Equal(t, "poison", string(letters[0].Message.Bytes))
+	require.Contains(t, letters[0].Reason, "maximum deliveries")
+
+	select {
+	case payload := <-received:
+		require.Fail(t, "message over its maximum deliveries should not be handled", payload)
+	default:
+	}
+
+	canceler()
+	pb.Wait()
+}
+
+func TestRedis_IncludeRawFrame(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.IncludeRawFrame = true
+	config.StreamBlockTimeout = 100 * time.Millisecond
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	requireRedis(t, &config.Redis)
+
+	var client = redis.NewClient(&config.Redis)
+	defer client.Close()
+	require.NoError(t, client.Del(ctx, "frame-stream").Err())
+
+	var pb = NewRedisMessageBus(config, client, RedisStreams)
+
+	var frames = make(chan sabuhp.Frame, 1)
+	var channel = pb.Listen("frame-stream", "readers", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			var frame, ok = sabuhp.RawFrame(ctx)
+			if ok {
+				frames <- frame
+			}
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	require.NoError(t, pb.Start())
+
+	var ack, sendErr = pb.SendWithReceipt(sabuhp.NewMessage(sabuhp.T("frame-stream"), "me", []byte("framed")))
+	require.NoError(t, sendErr)
+
+	select {
+	case frame := <-frames:
+		require.Equal(t, "redis-stream", frame.Transport)
+		require.Equal(t, ack.StreamId, frame.Id)
+		require.Equal(t, "frame-stream", frame.Channel)
+
+		var decoded, decodeErr = codec.Decode(frame.Bytes)
+		require.NoError(t, decodeErr)
+		require.Equal(t, "framed", string(decoded.Bytes))
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "handler should receive the raw frame")
+	}
+
+	canceler()
+	pb.Wait()
+}
+
+func TestRedis_RetentionPolicies(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.RetentionInterval = 50 * time.Millisecond
+	config.Retention = map[string]RetentionPolicy{
+		"retention-by-age":   {MaxAge: time.Hour},
+		"retention-by-count": {MaxLen: 3},
+	}
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	requireRedis(t, &config.Redis)
+
+	var client = redis.NewClient(&config.Redis)
+	defer client.Close()
+	require.NoError(t, client.Del(ctx, "retention-by-age", "retention-by-count").Err())
+	defer client.Del(ctx, "retention-by-age", "retention-by-count")
+
+	// entries with ids from two hours ago stand in for old messages.
+	var old = time.Now().Add(-2*time.Hour).UnixNano() / int64(time.Millisecond)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, client.XAdd(ctx, &redis.XAddArgs{
+			Stream: "retention-by-age",
+			ID:     fmt.Sprintf("%d-%d", old, i),
+			Values: map[string]interface{}{"data": "old"},
+		}).Err())
+	}
+	require.NoError(t, client.XAdd(ctx, &redis.XAddArgs{
+		Stream: "retention-by-age",
+		ID:     "*",
+		Values: map[string]interface{}{"data": "recent"},
+	}).Err())
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, client.XAdd(ctx, &redis.XAddArgs{
+			Stream: "retention-by-count",
+			ID:     "*",
+			Values: map[string]interface{}{"data": fmt.Sprintf("entry-%d", i)},
+		}).Err())
+	}
+
+	var pb = NewRedisMessageBus(config, client, RedisStreams)
+	require.NoError(t, pb.Start())
+
+	require.Eventually(t, func() bool {
+		return client.XLen(ctx, "retention-by-age").Val() == 1 &&
+			client.XLen(ctx, "retention-by-count").Val() == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	var remaining = client.XRange(ctx, "retention-by-age", "-", "+").Val()
+	require.Equal(t, "recent", remaining[0].Values["data"])
+
+	var kept = client.XRange(ctx, "retention-by-count", "-", "+").Val()
+	require.Equal(t, "entry-7", kept[0].Values["data"])
+
+	canceler()
+	pb.Wait()
+}
+
+func TestRedis_DisableReplies(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.DisableReplies = true
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	requireRedis(t, &config.Redis)
+
+	var pb, err = PubSub(config)
+	require.NoError(t, err)
+
+	var received = make(chan sabuhp.Message, 1)
+	var channel = pb.Listen("publish-only", "", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			received <- message
+			return nil
+		}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	pb.Start()
+
+	var _, replyErr = pb.SendForReply(time.Second, sabuhp.T("publish-only"), "", sabuhp.NewMessage(sabuhp.T("publish-only"), "me", []byte("ping"))).Get()
+	require.True(t, nerror.IsAny(replyErr, ErrRepliesDisabled))
+	require.Equal(t, 0, pb.PendingReplies())
+
+	var _, contextReplyErr = sabuhp.SendForReplyContext(ctx, pb, sabuhp.T("publish-only"), "", sabuhp.NewMessage(sabuhp.T("publish-only"), "me", []byte("ping"))).Get()
+	require.True(t, nerror.IsAny(contextReplyErr, ErrRepliesDisabled))
+
+	pb.Send(sabuhp.NewMessage(sabuhp.T("publish-only"), "me", []byte("hello")))
+	select {
+	case message := <-received:
+		require.Equal(t, "hello", string(message.Bytes))
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "send should still deliver messages")
+	}
+
+	pb.Stop()
+}
+
+func TestRedis_MigrateGroup(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	requireRedis(t, &config.Redis)
+
+	var pb = NewRedisMessageBus(config, redis.NewClient(&config.Redis), RedisStreams)
+	require.NoError(t, pb.client.Del(ctx, "migrating").Err())
+	require.NoError(t, pb.client.XGroupCreateMkStream(ctx, "migrating", "old-group", "$").Err())
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		var id, addErr = pb.client.XAdd(ctx, &redis.XAddArgs{
+			Stream: "migrating",
+			Values: map[string]interface{}{"index": i},
+		}).Result()
+		require.NoError(t, addErr)
+		ids = append(ids, id)
+	}
+
+	var read = func(group string, count int64) []string {
+		var streams, readErr = pb.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    group,
+			Consumer: group + "-consumer",
+			Streams:  []string{"migrating", ">"},
+			Count:    count,
+			Block:    -1,
+		}).Result()
+		if readErr == redis.Nil {
+			return nil
+		}
+		require.NoError(t, readErr)
+
+		var readIds []string
+		for _, message := range streams[0].Messages {
+			readIds = append(readIds, message.ID)
+		}
+		return readIds
+	}
+
+	var handled = read("old-group", 3)
+	require.Equal(t, ids[:3], handled)
+
+	// the old group can not be deleted while it has pending messages.
+	var pendingErr = pb.MigrateGroup("migrating", "old-group", "new-group", true)
+	require.Error(t, pendingErr)
+
+	require.NoError(t, pb.client.XAck(ctx, "migrating", "old-group", handled...).Err())
+
+	// a migration to an existing group fails without deleting the old one.
+	require.NoError(t, pb.client.XGroupCreate(ctx, "migrating", "taken-group", "0").Err())
+	require.Error(t, pb.MigrateGroup("migrating", "old-group", "taken-group", true))
+	require.NoError(t, pb.client.XGroupDestroy(ctx, "migrating", "taken-group").Err())
+
+	require.NoError(t, pb.MigrateGroup("migrating", "old-group", "new-group", true))
+
+	var groups, groupsErr = pb.client.XInfoGroups(ctx, "migrating").Result()
+	require.NoError(t, groupsErr)
+	require.Len(t, groups, 1)
+	require.Equal(t, "new-group", groups[0].Name)
+
+	// the new group resumes after the last message of the old group.
+	require.Equal(t, ids[3:], read("new-group", 10))
+
+	var missingErr = pb.MigrateGroup("migrating", "old-group", "newer-group", false)
+	require.Error(t, missingErr)
+}
+
+func TestRedis_PubSub_SendForReply(t *testing.T) {
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var logger = &testingutils.LoggerPub{}
+	var config Config
+	config.Ctx = ctx
+	config.Codec = codec
+	config.Logger = logger
+	config.Redis = redis.Options{
+		Network: "tcp",
+	}
+
+	requireRedis(t, &config.Redis)
+
+	var pb, err = PubSub(config)
+	require.NoError(t, err)
+
+	var responder = pb.Listen("pubsub-ping", "", sabuhp.TransportResponseFunc(func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+		pb.Send(*sabuhp.NewReply(&message, []byte("pong")))
+		return nil
+	}))
+	require.NoError(t, responder.Err())
+	defer responder.Close()
+
+	require.NoError(t, pb.Start())
+	defer pb.Stop()
+
+	// no reply group, pubsub reply topics have none.
+	var reply, replyErr = pb.SendForReply(5*time.Second, sabuhp.T("pubsub-ping"), "", sabuhp.NewMessage(sabuhp.T("pubsub-ping"), "me", []byte("ping"))).Get()
+	require.NoError(t, replyErr)
+	require.Equal(t, "pong", string(reply.Bytes))
+}
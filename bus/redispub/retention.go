@@ -0,0 +1,119 @@
+package redispub
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/njson"
+)
+
+// retentionPageSize is the number of expired entries deleted per command
+// when trimming a stream by age.
+const retentionPageSize = 500
+
+// RetentionPolicy bounds the entries kept by the stream of a topic, an
+// entry is trimmed once it exceeds either bound. A zero bound is not
+// applied.
+type RetentionPolicy struct {
+	// MaxLen is the number of most recent entries kept.
+	MaxLen int64
+
+	// MaxAge is how long an entry is kept after it was added.
+	MaxAge time.Duration
+}
+
+func (r *RedisMessageBus) manageRetention() {
+	defer r.waiter.Done()
+	r.supervise("retention", r.runRetention)
+}
+
+func (r *RedisMessageBus) runRetention() {
+	var ticker = time.NewTicker(r.config.RetentionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, retentionErr := r.ApplyRetention(time.Now()); retentionErr != nil {
+			njson.Log(r.logger).New().
+				LError().
+				Message("failed to apply stream retention").
+				Error("error", retentionErr).
+				End()
+		}
+	}
+}
+
+// ApplyRetention trims the stream of every topic of Config.Retention by its
+// policy as of now, returning the number of entries trimmed. The bus applies
+// it every Config.RetentionInterval once started.
+func (r *RedisMessageBus) ApplyRetention(now time.Time) (int64, error) {
+	var trimmed int64
+	for topic, policy := range r.config.Retention {
+		var count, trimErr = r.trimStream(topic, policy, now)
+		trimmed += count
+		if trimErr != nil {
+			return trimmed, nerror.WrapOnly(trimErr)
+		}
+	}
+	return trimmed, nil
+}
+
+func (r *RedisMessageBus) trimStream(topic string, policy RetentionPolicy, now time.Time) (int64, error) {
+	var trimmed int64
+
+	if policy.MaxAge > 0 {
+		// an end id of only milliseconds covers every entry of that
+		// millisecond, so this selects the entries older than the cutoff.
+		var cutoff = now.Add(-policy.MaxAge).UnixNano() / int64(time.Millisecond)
+		var end = strconv.FormatInt(cutoff-1, 10)
+
+		for {
+			var entries, rangeErr = r.client.XRangeN(r.ctx, topic, "-", end, retentionPageSize).Result()
+			if rangeErr != nil {
+				return trimmed, nerror.WrapOnly(rangeErr)
+			}
+			if len(entries) == 0 {
+				break
+			}
+
+			var ids = make([]string, 0, len(entries))
+			for _, entry := range entries {
+				ids = append(ids, entry.ID)
+			}
+
+			var deleted, delErr = r.client.XDel(r.ctx, topic, ids...).Result()
+			if delErr != nil {
+				return trimmed, nerror.WrapOnly(delErr)
+			}
+			trimmed += deleted
+
+			if len(entries) < retentionPageSize {
+				break
+			}
+		}
+	}
+
+	if policy.MaxLen > 0 {
+		var deleted, trimErr = r.client.XTrim(r.ctx, topic, policy.MaxLen).Result()
+		if trimErr != nil {
+			return trimmed, nerror.WrapOnly(trimErr)
+		}
+		trimmed += deleted
+	}
+
+	if trimmed > 0 {
+		njson.Log(r.logger).New().
+			LInfo().
+			Message("trimmed stream by retention policy").
+			String("topic", topic).
+			Int64("trimmed", trimmed).
+			End()
+	}
+	return trimmed, nil
+}
@@ -0,0 +1,176 @@
+package redispub
+
+import (
+	"strconv"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/njson"
+	"github.com/influx6/npkg/nthen"
+	"github.com/influx6/npkg/nunsafe"
+
+	"github.com/ewe-studios/sabuhp"
+)
+
+const (
+	// DefaultScheduleKey is the default sorted set holding scheduled messages.
+	DefaultScheduleKey = "sabuhp.scheduled"
+
+	maxDuePerRun = 100
+
+	// scheduleClaimLease is how long a message claimed for publishing is
+	// hidden from other buses. A message whose publish failed or whose bus
+	// stopped before removing it is published again once it elapsed.
+	scheduleClaimLease = 30 * time.Second
+)
+
+// claimDueScript claims the member ARGV[1] of the schedule set KEYS[1] if
+// it is still due by ARGV[2], moving its score to ARGV[3], the end of the
+// claim lease. Returns 1 if claimed, 0 if another bus claimed or removed
+// it first.
+var claimDueScript = redis.NewScript(`
+local score = redis.call("ZSCORE", KEYS[1], ARGV[1])
+if not score or tonumber(score) > tonumber(ARGV[2]) then
+	return 0
+end
+redis.call("ZADD", KEYS[1], ARGV[3], ARGV[1])
+return 1
+`)
+
+func isScheduled(msg sabuhp.Message) bool {
+	return !msg.ScheduledFor.IsZero() && msg.ScheduledFor.After(time.Now())
+}
+
+// schedule adds the encoded message into the schedule set, scored
+// by the unix milliseconds of its ScheduledFor time.
+func (r *RedisMessageBus) schedule(msg sabuhp.Message, encodedData []byte, pipelined redis.Pipeliner) *redis.IntCmd {
+	var addCmd = pipelined.ZAdd(r.ctx, r.config.ScheduleKey, &redis.Z{
+		Score:  float64(msg.ScheduledFor.UnixNano() / int64(time.Millisecond)),
+		Member: nunsafe.Bytes2String(encodedData),
+	})
+
+	njson.Log(r.logger).New().
+		LInfo().
+		Message("scheduled message for delivery").
+		String("topic", msg.Topic.String()).
+		String("message_id", msg.Id.String()).
+		String("scheduled_for", msg.ScheduledFor.Format(time.RFC3339Nano)).
+		End()
+
+	return addCmd
+}
+
+func (r *RedisMessageBus) manageSchedule() {
+	defer r.waiter.Done()
+	r.supervise("schedule", r.runSchedule)
+}
+
+func (r *RedisMessageBus) runSchedule() {
+	var ticker = time.NewTicker(r.config.ScheduleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, publishErr := r.publishDue(time.Now()); publishErr != nil {
+			njson.Log(r.logger).New().
+				LError().
+				Message("failed to publish scheduled messages").
+				String("schedule_key", r.config.ScheduleKey).
+				Error("error", publishErr).
+				End()
+		}
+	}
+}
+
+// publishDue publishes all scheduled messages due by now, returning the
+// number published.
+//
+// A due message is first claimed by the bus, which pushes its score past
+// scheduleClaimLease so other buses sharing the schedule skip it, then
+// published and only removed from the schedule set once published. A
+// message is never lost to a failed publish or a stopped bus, it is
+// published again once its claim lease elapsed.
+func (r *RedisMessageBus) publishDue(now time.Time) (int, error) {
+	var nowMillis = now.UnixNano() / int64(time.Millisecond)
+	var dueCmd = r.client.ZRangeByScore(r.ctx, r.config.ScheduleKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(nowMillis, 10),
+		Count: maxDuePerRun,
+	})
+	if dueErr := dueCmd.Err(); dueErr != nil {
+		return 0, nerror.WrapOnly(dueErr)
+	}
+
+	var leaseEnd = nowMillis + int64(scheduleClaimLease/time.Millisecond)
+
+	var batch = make([]sabuhp.Message, 0, len(dueCmd.Val()))
+	var members = make([]string, 0, len(dueCmd.Val()))
+	for _, member := range dueCmd.Val() {
+		var claimed, claimErr = claimDueScript.Run(
+			r.ctx,
+			r.client,
+			[]string{r.config.ScheduleKey},
+			member,
+			nowMillis,
+			leaseEnd,
+		).Int()
+		if claimErr != nil {
+			return 0, nerror.WrapOnly(claimErr)
+		}
+
+		// claimed by another bus.
+		if claimed == 0 {
+			continue
+		}
+
+		var msg, decodeErr = r.decode(nunsafe.String2Bytes(member))
+		if decodeErr != nil {
+			njson.Log(r.logger).New().
+				LError().
+				Message("failed to decode scheduled message, removing it").
+				String("schedule_key", r.config.ScheduleKey).
+				Error("error", decodeErr).
+				End()
+
+			// it never decodes, publishing it again would fail as well.
+			if removeErr := r.client.ZRem(r.ctx, r.config.ScheduleKey, member).Err(); removeErr != nil {
+				return 0, nerror.WrapOnly(removeErr)
+			}
+			continue
+		}
+
+		msg.ScheduledFor = time.Time{}
+		msg.Future = nthen.NewFuture()
+		batch = append(batch, msg)
+		members = append(members, member)
+	}
+
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	r.sendChannelBatch(batch, r.channel)
+
+	// futures are resolved by sendChannelBatch, messages it failed to
+	// publish are left claimed till their lease elapses.
+	var published = make([]interface{}, 0, len(batch))
+	for index, msg := range batch {
+		if publishErr := msg.Future.Err(); publishErr != nil {
+			continue
+		}
+		published = append(published, members[index])
+	}
+
+	if len(published) > 0 {
+		if removeErr := r.client.ZRem(r.ctx, r.config.ScheduleKey, published...).Err(); removeErr != nil {
+			return len(published), nerror.WrapOnly(removeErr)
+		}
+	}
+	return len(published), nil
+}
@@ -15,6 +15,12 @@ type Codec interface {
 	Decode(b []byte) (Message, error)
 }
 
+// ContentTyped is implemented by codecs and decoders reporting the
+// content types they decode, e.g. codecs.ContentTypeCodec.
+type ContentTyped interface {
+	ContentTypes() []string
+}
+
 type Client interface {
 	Send(data []byte, timeout time.Duration) error
 }
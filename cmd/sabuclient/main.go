@@ -2,18 +2,30 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
+	"strings"
 
 	"github.com/influx6/npkg/ndaemon"
 
 	"github.com/ewe-studios/sabuhp"
 
 	"github.com/ewe-studios/sabuhp/bus/redispub"
+	"github.com/ewe-studios/sabuhp/codecs"
 	"github.com/ewe-studios/sabuhp/servers/clientServer"
 	redis "github.com/go-redis/redis/v8"
 )
 
+var codecName = flag.String("codec", "msgpack", "codec of messages sent to the bus: "+strings.Join(codecs.Names(), ", "))
+
 func main() {
+	flag.Parse()
+
+	var codec, codecErr = codecs.Get(*codecName)
+	if codecErr != nil {
+		log.Fatalf("Failed to find codec: %q\n", codecErr.Error())
+	}
+
 	var ctx, canceler = context.WithCancel(context.Background())
 	ndaemon.WaiterForKillWithSignal(ndaemon.WaitForKillChan(), canceler)
 
@@ -23,7 +35,7 @@ func main() {
 		Logger: logger,
 		Ctx:    ctx,
 		Redis:  redis.Options{},
-		Codec:  clientServer.DefaultCodec,
+		Codec:  codec,
 	})
 
 	if busErr != nil {
@@ -0,0 +1,99 @@
+package codecs
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+
+	"github.com/ewe-studios/sabuhp"
+)
+
+var _ sabuhp.Codec = (*CachedCodec)(nil)
+
+// DefaultDecodeCacheSize is the number of decoded messages a CachedCodec
+// holds when no capacity is provided.
+const DefaultDecodeCacheSize = 1024
+
+type cachedMessage struct {
+	key     [32]byte
+	message sabuhp.Message
+}
+
+// CachedCodec wraps a Codec with a bounded least-recently-used cache of
+// decoded messages keyed by the sha256 hash of the encoded bytes, so
+// decoding the same bytes repeatedly (e.g a message fanned out to many
+// subscribers or replayed) decodes them only once.
+//
+// Decode returns the same cached Message for identical bytes, sharing its
+// maps, slices and Bytes with every other caller. Decoded messages must be
+// treated as immutable, callers must Copy a message before mutating it.
+type CachedCodec struct {
+	codec    sabuhp.Codec
+	capacity int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[[32]byte]*list.Element
+}
+
+// NewCachedCodec returns a CachedCodec holding at most capacity decoded
+// messages, capacity defaults to DefaultDecodeCacheSize if not positive.
+func NewCachedCodec(codec sabuhp.Codec, capacity int) *CachedCodec {
+	if capacity <= 0 {
+		capacity = DefaultDecodeCacheSize
+	}
+	return &CachedCodec{
+		codec:    codec,
+		capacity: capacity,
+		order:    list.New(),
+		entries:  map[[32]byte]*list.Element{},
+	}
+}
+
+func (c *CachedCodec) Encode(message sabuhp.Message) ([]byte, error) {
+	return c.codec.Encode(message)
+}
+
+// Decode returns the cached message for the bytes, decoding and caching
+// it with the wrapped codec on a miss. Failed decodes are not cached.
+func (c *CachedCodec) Decode(b []byte) (sabuhp.Message, error) {
+	var key = sha256.Sum256(b)
+
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		c.order.MoveToFront(element)
+		var message = element.Value.(*cachedMessage).message
+		c.mu.Unlock()
+		return message, nil
+	}
+	c.mu.Unlock()
+
+	var message, decodeErr = c.codec.Decode(b)
+	if decodeErr != nil {
+		return message, decodeErr
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// another caller may have decoded the same bytes meanwhile.
+	if element, ok := c.entries[key]; ok {
+		c.order.MoveToFront(element)
+		return element.Value.(*cachedMessage).message, nil
+	}
+
+	c.entries[key] = c.order.PushFront(&cachedMessage{key: key, message: message})
+	if c.order.Len() > c.capacity {
+		var oldest = c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedMessage).key)
+	}
+	return message, nil
+}
+
+// Len returns the number of decoded messages held by the cache.
+func (c *CachedCodec) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
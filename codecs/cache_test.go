@@ -0,0 +1,78 @@
+package codecs
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ewe-studios/sabuhp"
+)
+
+type countingCodec struct {
+	sabuhp.Codec
+	decodes int64
+}
+
+func (c *countingCodec) Decode(b []byte) (sabuhp.Message, error) {
+	atomic.AddInt64(&c.decodes, 1)
+	return c.Codec.Decode(b)
+}
+
+func TestCachedCodec(t *testing.T) {
+	var counter = &countingCodec{Codec: &MessageJsonCodec{}}
+	var codec = NewCachedCodec(counter, 2)
+
+	var encoded = make([][]byte, 3)
+	for index := range encoded {
+		var encodedMsg, encodeErr = codec.Encode(sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte(fmt.Sprintf("world %d", index))))
+		require.NoError(t, encodeErr)
+		encoded[index] = encodedMsg
+	}
+
+	var first, firstErr = codec.Decode(encoded[0])
+	require.NoError(t, firstErr)
+
+	var second, secondErr = codec.Decode(encoded[0])
+	require.NoError(t, secondErr)
+	require.Equal(t, first, second)
+	require.Equal(t, int64(1), counter.decodes)
+
+	// exceeding the capacity evicts the least recently used message.
+	_, _ = codec.Decode(encoded[1])
+	_, _ = codec.Decode(encoded[2])
+	require.Equal(t, 2, codec.Len())
+	require.Equal(t, int64(3), counter.decodes)
+
+	_, _ = codec.Decode(encoded[0])
+	require.Equal(t, int64(4), counter.decodes)
+
+	var _, invalidErr = codec.Decode([]byte("not json"))
+	require.Error(t, invalidErr)
+	require.Equal(t, 2, codec.Len())
+}
+
+func BenchmarkCachedCodec_Decode(b *testing.B) {
+	var encoded, encodeErr = (&MessageJsonCodec{}).Encode(mapHeavyMessage())
+	require.NoError(b, encodeErr)
+
+	b.Run("uncached", func(b *testing.B) {
+		var counter = &countingCodec{Codec: &MessageJsonCodec{}}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = counter.Decode(encoded)
+		}
+		b.ReportMetric(float64(counter.decodes)/float64(b.N), "decodes/op")
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		var counter = &countingCodec{Codec: &MessageJsonCodec{}}
+		var codec = NewCachedCodec(counter, DefaultDecodeCacheSize)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = codec.Decode(encoded)
+		}
+		b.ReportMetric(float64(counter.decodes)/float64(b.N), "decodes/op")
+	})
+}
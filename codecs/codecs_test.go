@@ -0,0 +1,441 @@
+package codecs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nxid"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/ewe-studios/sabuhp"
+)
+
+func mapHeavyMessage() sabuhp.Message {
+	var message = sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("world"))
+	message.Id = nxid.New()
+	message.Metadata = sabuhp.Params{}
+	message.Params = sabuhp.Params{}
+	message.Headers = sabuhp.Header{}
+	message.Query = url.Values{}
+	message.Form = url.Values{}
+	for i := 0; i < 20; i++ {
+		var key = fmt.Sprintf("key_%d", i)
+		var value = fmt.Sprintf("value_%d", i)
+		message.Metadata[key] = value
+		message.Params[key] = value
+		message.Headers[key] = []string{value}
+		message.Query[key] = []string{value}
+		message.Form[key] = []string{value}
+	}
+	return message
+}
+
+func TestDeterministicEncode(t *testing.T) {
+	var specs = []struct {
+		Name  string
+		Codec sabuhp.Codec
+	}{
+		{Name: "json", Codec: &MessageJsonCodec{}},
+		{Name: "msgpack", Codec: &MessageMsgPackCodec{}},
+		{Name: "proto", Codec: &MessageProtoCodec{}},
+	}
+
+	var message = mapHeavyMessage()
+	for _, spec := range specs {
+		t.Run(spec.Name, func(t *testing.T) {
+			var first, firstErr = spec.Codec.Encode(message)
+			require.NoError(t, firstErr)
+
+			for i := 0; i < 10; i++ {
+				var next, nextErr = spec.Codec.Encode(message)
+				require.NoError(t, nextErr)
+				require.Equal(t, first, next)
+			}
+
+			var decoded, decodeErr = spec.Codec.Decode(first)
+			require.NoError(t, decodeErr)
+			require.Equal(t, message.Metadata, decoded.Metadata)
+			require.Equal(t, message.Params, decoded.Params)
+		})
+	}
+}
+
+func TestTimeFieldsRoundTripInUTC(t *testing.T) {
+	var specs = []struct {
+		Name  string
+		Codec sabuhp.Codec
+	}{
+		{Name: "json", Codec: &MessageJsonCodec{}},
+		{Name: "msgpack", Codec: &MessageMsgPackCodec{}},
+		{Name: "gob", Codec: &MessageGobCodec{}},
+		{Name: "proto", Codec: &MessageProtoCodec{}},
+	}
+
+	var zone = time.FixedZone("UTC+5:30", 5*60*60+30*60)
+	var scheduledFor = time.Date(2021, time.March, 14, 9, 26, 53, 589793238, zone)
+
+	var message = sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("world"))
+	message.ScheduledFor = scheduledFor
+
+	for _, spec := range specs {
+		t.Run(spec.Name, func(t *testing.T) {
+			var encoded, encodeErr = spec.Codec.Encode(message)
+			require.NoError(t, encodeErr)
+
+			var decoded, decodeErr = spec.Codec.Decode(encoded)
+			require.NoError(t, decodeErr)
+
+			require.True(t, scheduledFor.Equal(decoded.ScheduledFor))
+			require.Equal(t, time.UTC, decoded.ScheduledFor.Location())
+			require.Equal(t, scheduledFor.UTC(), decoded.ScheduledFor)
+		})
+	}
+
+	t.Run("zero time stays zero", func(t *testing.T) {
+		var plain = sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("world"))
+		for _, spec := range specs {
+			var encoded, encodeErr = spec.Codec.Encode(plain)
+			require.NoError(t, encodeErr)
+
+			var decoded, decodeErr = spec.Codec.Decode(encoded)
+			require.NoError(t, decodeErr)
+			require.True(t, decoded.ScheduledFor.IsZero())
+		}
+	})
+}
+
+func BenchmarkCodec_Encode(b *testing.B) {
+	var message = mapHeavyMessage()
+	var specs = []struct {
+		Name  string
+		Codec sabuhp.Codec
+	}{
+		{Name: "json", Codec: &MessageJsonCodec{}},
+		{Name: "msgpack", Codec: &MessageMsgPackCodec{}},
+		{Name: "gob", Codec: &MessageGobCodec{}},
+		{Name: "proto", Codec: &MessageProtoCodec{}},
+	}
+
+	for _, spec := range specs {
+		var codec = spec.Codec
+		b.Run(spec.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, _ = codec.Encode(message)
+			}
+		})
+	}
+}
+
+func BenchmarkCodec_Decode(b *testing.B) {
+	var message = mapHeavyMessage()
+	var specs = []struct {
+		Name  string
+		Codec sabuhp.Codec
+	}{
+		{Name: "json", Codec: &MessageJsonCodec{}},
+		{Name: "proto", Codec: &MessageProtoCodec{}},
+	}
+
+	for _, spec := range specs {
+		var codec = spec.Codec
+		var encoded, encodeErr = codec.Encode(message)
+		require.NoError(b, encodeErr)
+
+		b.Run(spec.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, _ = codec.Decode(encoded)
+			}
+		})
+	}
+}
+
+func TestMessageProtoCodec(t *testing.T) {
+	var codec = &MessageProtoCodec{}
+
+	var message = mapHeavyMessage()
+	message.Topic = sabuhp.T("orders").WithSeparator("/")
+	message.ReplyGroup = "requesters"
+	message.CorrelationId = nxid.New()
+	message.PartitionKey = "customer-1"
+	message.ExpectReply = true
+	message.Within = -1500 * time.Millisecond
+
+	var encoded, encodeErr = codec.Encode(message)
+	require.NoError(t, encodeErr)
+
+	var decoded, decodeErr = codec.Decode(encoded)
+	require.NoError(t, decodeErr)
+	require.Equal(t, message.Id, decoded.Id)
+	require.Equal(t, message.Topic, decoded.Topic)
+	require.Equal(t, message.FromAddr, decoded.FromAddr)
+	require.Equal(t, message.ReplyGroup, decoded.ReplyGroup)
+	require.Equal(t, message.CorrelationId, decoded.CorrelationId)
+	require.Equal(t, message.PartitionKey, decoded.PartitionKey)
+	require.Equal(t, message.ContentType, decoded.ContentType)
+	require.Equal(t, message.Bytes, decoded.Bytes)
+	require.Equal(t, message.Metadata, decoded.Metadata)
+	require.Equal(t, message.Params, decoded.Params)
+	require.Equal(t, message.Within, decoded.Within)
+	require.True(t, decoded.ExpectReply)
+	require.True(t, decoded.PartId.IsNil())
+	require.Empty(t, decoded.UnknownFields)
+
+	_, decodeErr = codec.Decode([]byte{0x0a, 0x20, 0x01})
+	require.True(t, nerror.IsAny(decodeErr, ErrMalformedProto))
+}
+
+func TestMessageProtoCodec_PreservesUnknownFields(t *testing.T) {
+	var codec = &MessageProtoCodec{}
+
+	var encoded, encodeErr = codec.Encode(sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("world")))
+	require.NoError(t, encodeErr)
+
+	// a newer producer adds a string field 40 and a varint field 41.
+	var newer = append([]byte(nil), encoded...)
+	newer = protowire.AppendTag(newer, 40, protowire.BytesType)
+	newer = protowire.AppendString(newer, "from a newer schema")
+	newer = protowire.AppendTag(newer, 41, protowire.VarintType)
+	newer = protowire.AppendVarint(newer, 7)
+
+	var decoded, decodeErr = codec.Decode(newer)
+	require.NoError(t, decodeErr)
+	require.Equal(t, "hello", decoded.Topic.String())
+	require.Equal(t, "world", string(decoded.Bytes))
+	require.NotEmpty(t, decoded.UnknownFields)
+
+	// forwarding the message keeps the fields of the newer schema.
+	var forwarded, forwardErr = codec.Encode(decoded)
+	require.NoError(t, forwardErr)
+	require.Equal(t, newer, forwarded)
+
+	// other codecs do not carry the encoded fields of the proto codec.
+	for _, other := range []sabuhp.Codec{&MessageJsonCodec{}, &MessageMsgPackCodec{}, &MessageGobCodec{}} {
+		var otherEncoded, otherErr = other.Encode(decoded)
+		require.NoError(t, otherErr)
+
+		var otherDecoded, otherDecodeErr = other.Decode(otherEncoded)
+		require.NoError(t, otherDecodeErr)
+		require.Empty(t, otherDecoded.UnknownFields)
+	}
+}
+
+func TestEncode_OutlivesPooledBuffers(t *testing.T) {
+	for _, codec := range []sabuhp.Codec{&MessageJsonCodec{}, &MessageMsgPackCodec{}, &MessageGobCodec{}, &MessageProtoCodec{}} {
+		var first, firstErr = codec.Encode(sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("first")))
+		require.NoError(t, firstErr)
+		var kept = append([]byte(nil), first...)
+
+		// later encodes reuse the pooled buffer of the first one.
+		for i := 0; i < 10; i++ {
+			var _, nextErr = codec.Encode(mapHeavyMessage())
+			require.NoError(t, nextErr)
+		}
+		require.Equal(t, kept, first)
+
+		var decoded, decodeErr = codec.Decode(first)
+		require.NoError(t, decodeErr)
+		require.Equal(t, "first", string(decoded.Bytes))
+	}
+}
+
+func TestEncode_CompressedPayloads(t *testing.T) {
+	var specs = []struct {
+		Name  string
+		Codec sabuhp.Codec
+	}{
+		{Name: "json", Codec: &MessageJsonCodec{}},
+		{Name: "msgpack", Codec: &MessageMsgPackCodec{}},
+		{Name: "gob", Codec: &MessageGobCodec{}},
+		{Name: "proto", Codec: &MessageProtoCodec{}},
+	}
+
+	var payload = []byte(strings.Repeat("a highly repetitive payload ", 200))
+	for _, spec := range specs {
+		t.Run(spec.Name, func(t *testing.T) {
+			var plain = sabuhp.NewMessage(sabuhp.T("hello"), "me", payload)
+			var compressed = sabuhp.NewMessage(sabuhp.T("hello"), "me", payload)
+			compressed.Compressed = true
+
+			var plainData, plainErr = spec.Codec.Encode(plain)
+			require.NoError(t, plainErr)
+
+			var compressedData, compressedErr = spec.Codec.Encode(compressed)
+			require.NoError(t, compressedErr)
+			require.Less(t, len(compressedData), len(plainData)/4)
+
+			var decodedPlain, decodePlainErr = spec.Codec.Decode(plainData)
+			require.NoError(t, decodePlainErr)
+			require.False(t, decodedPlain.Compressed)
+			require.Equal(t, payload, decodedPlain.Bytes)
+
+			var decodedCompressed, decodeCompressedErr = spec.Codec.Decode(compressedData)
+			require.NoError(t, decodeCompressedErr)
+			require.True(t, decodedCompressed.Compressed)
+			require.Equal(t, payload, decodedCompressed.Bytes)
+		})
+	}
+}
+
+func TestDecode_RejectsOversizedCompressedPayloads(t *testing.T) {
+	var specs = []struct {
+		Name    string
+		Encoder sabuhp.Codec
+		Decoder sabuhp.Codec
+	}{
+		{Name: "json", Encoder: &MessageJsonCodec{}, Decoder: &MessageJsonCodec{MaxPayloadSize: 1024}},
+		{Name: "msgpack", Encoder: &MessageMsgPackCodec{}, Decoder: &MessageMsgPackCodec{MaxPayloadSize: 1024}},
+		{Name: "gob", Encoder: &MessageGobCodec{}, Decoder: &MessageGobCodec{MaxPayloadSize: 1024}},
+		{Name: "proto", Encoder: &MessageProtoCodec{}, Decoder: &MessageProtoCodec{MaxPayloadSize: 1024}},
+	}
+
+	for _, spec := range specs {
+		t.Run(spec.Name, func(t *testing.T) {
+			var fitting = sabuhp.NewMessage(sabuhp.T("hello"), "me", bytes.Repeat([]byte("a"), 1024))
+			fitting.Compressed = true
+
+			var fittingData, fittingErr = spec.Encoder.Encode(fitting)
+			require.NoError(t, fittingErr)
+
+			var decoded, decodeErr = spec.Decoder.Decode(fittingData)
+			require.NoError(t, decodeErr)
+			require.Equal(t, fitting.Bytes, decoded.Bytes)
+
+			// a few bytes of gzip expanding past the limit.
+			var bomb = sabuhp.NewMessage(sabuhp.T("hello"), "me", bytes.Repeat([]byte("a"), 1024*1024))
+			bomb.Compressed = true
+
+			var bombData, bombErr = spec.Encoder.Encode(bomb)
+			require.NoError(t, bombErr)
+			require.Less(t, len(bombData), 4096)
+
+			var _, oversizeErr = spec.Decoder.Decode(bombData)
+			require.Error(t, oversizeErr)
+			require.True(t, nerror.IsAny(oversizeErr, ErrDecompressedTooLarge))
+		})
+	}
+}
+
+func TestCompressedCodec(t *testing.T) {
+	var inner = &MessageJsonCodec{}
+	var codec = NewCompressedCodec(inner, gzip.BestCompression)
+
+	var payload = []byte(strings.Repeat(`{"order": "order-1", "total": 20},`, 300))
+	require.True(t, len(payload) >= 10*1024)
+
+	var message = mapHeavyMessage()
+	message.Bytes = payload
+
+	var plainData, plainErr = inner.Encode(message)
+	require.NoError(t, plainErr)
+
+	var compressedData, compressedErr = codec.Encode(message)
+	require.NoError(t, compressedErr)
+	require.Less(t, len(compressedData), len(plainData))
+
+	var decoded, decodeErr = codec.Decode(compressedData)
+	require.NoError(t, decodeErr)
+	require.Equal(t, message.Id, decoded.Id)
+	require.Equal(t, message.Metadata, decoded.Metadata)
+	require.Equal(t, payload, decoded.Bytes)
+
+	// bytes of producers without compression are decoded as is.
+	var legacy, legacyErr = codec.Decode(plainData)
+	require.NoError(t, legacyErr)
+	require.Equal(t, message.Id, legacy.Id)
+	require.Equal(t, payload, legacy.Bytes)
+}
+
+func TestCompressedCodec_MaxDecompressedSize(t *testing.T) {
+	var inner = &MessageJsonCodec{}
+	var codec = NewCompressedCodec(inner, gzip.BestCompression)
+
+	var message = sabuhp.NewMessage(sabuhp.T("hello"), "me", bytes.Repeat([]byte("a"), 1024*1024))
+	var data, encodeErr = codec.Encode(message)
+	require.NoError(t, encodeErr)
+
+	var plainData, plainErr = inner.Encode(message)
+	require.NoError(t, plainErr)
+
+	var decoded, decodeErr = codec.Decode(data)
+	require.NoError(t, decodeErr)
+	require.Equal(t, message.Bytes, decoded.Bytes)
+
+	codec.WithMaxDecompressedSize(len(plainData) - 1)
+	var _, oversizeErr = codec.Decode(data)
+	require.Error(t, oversizeErr)
+	require.True(t, nerror.IsAny(oversizeErr, ErrDecompressedTooLarge))
+
+	codec.WithMaxDecompressedSize(len(plainData))
+	var _, fittingErr = codec.Decode(data)
+	require.NoError(t, fittingErr)
+}
+
+// legacyMessage is the shape of a Message encoded by an older binary,
+// it lacks later optional fields and holds one since removed.
+type legacyMessage struct {
+	Id          nxid.ID
+	Topic       sabuhp.Topic
+	FromAddr    string
+	ContentType string
+	Bytes       []byte
+	Metadata    sabuhp.Params
+	Retired     string
+}
+
+func TestMessageGobCodec_RollingUpgrade(t *testing.T) {
+	var codec = &MessageGobCodec{}
+
+	t.Run("old payload decodes with the current struct", func(t *testing.T) {
+		var legacy = legacyMessage{
+			Id:          nxid.New(),
+			Topic:       sabuhp.T("hello"),
+			FromAddr:    "me",
+			ContentType: sabuhp.MessageContentType,
+			Bytes:       []byte("world"),
+			Metadata:    sabuhp.Params{"key": "value"},
+			Retired:     "dropped by newer binaries",
+		}
+
+		var fixture bytes.Buffer
+		require.NoError(t, gob.NewEncoder(&fixture).Encode(legacy))
+
+		var decoded, decodeErr = codec.Decode(fixture.Bytes())
+		require.NoError(t, decodeErr)
+		require.Equal(t, legacy.Id, decoded.Id)
+		require.Equal(t, legacy.Topic, decoded.Topic)
+		require.Equal(t, legacy.FromAddr, decoded.FromAddr)
+		require.Equal(t, legacy.Bytes, decoded.Bytes)
+		require.Equal(t, legacy.Metadata, decoded.Metadata)
+
+		// fields added after the payload was encoded keep their zero value.
+		require.Empty(t, decoded.PartitionKey)
+		require.True(t, decoded.CorrelationId.IsNil())
+		require.True(t, decoded.ScheduledFor.IsZero())
+	})
+
+	t.Run("current payload decodes with the old struct", func(t *testing.T) {
+		var message = sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("world"))
+		message.Id = nxid.New()
+		message.PartitionKey = "customer-1"
+
+		var encoded, encodeErr = codec.Encode(message)
+		require.NoError(t, encodeErr)
+
+		var legacy legacyMessage
+		require.NoError(t, gob.NewDecoder(bytes.NewReader(encoded)).Decode(&legacy))
+		require.Equal(t, message.Id, legacy.Id)
+		require.Equal(t, message.Topic, legacy.Topic)
+		require.Equal(t, message.Bytes, legacy.Bytes)
+		require.Empty(t, legacy.Retired)
+	})
+}
@@ -0,0 +1,151 @@
+package codecs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+
+	"github.com/ewe-studios/sabuhp"
+
+	"github.com/influx6/npkg/nerror"
+)
+
+// gzipHeader is the magic header (with the deflate compression method)
+// starting every gzip stream.
+var gzipHeader = []byte{0x1f, 0x8b, 0x08}
+
+// DefaultMaxDecompressedSize is the size gzip compressed data may expand
+// to when decoded by a codec without a limit of its own.
+const DefaultMaxDecompressedSize = 32 * 1024 * 1024
+
+// ErrDecompressedTooLarge is returned when decoding gzip compressed data
+// which expands past the maximum decompressed size of the codec, so a
+// small compressed message can not exhaust the memory of its consumer.
+var ErrDecompressedTooLarge = nerror.New("decompressed data exceeds the maximum size")
+
+var _ sabuhp.Codec = (*CompressedCodec)(nil)
+
+// CompressedCodec wraps a Codec, gzip compressing the encoded messages
+// as a whole, unlike the Compressed flag of a message which only
+// compresses its payload.
+//
+// Decode only decompresses bytes starting with the gzip magic header,
+// bytes encoded by the wrapped codec alone, e.g by older producers, are
+// decoded as is. Bytes expanding past DefaultMaxDecompressedSize, or the
+// size set with WithMaxDecompressedSize, fail with ErrDecompressedTooLarge.
+type CompressedCodec struct {
+	codec   sabuhp.Codec
+	level   int
+	maxSize int
+}
+
+// NewCompressedCodec returns a CompressedCodec compressing with the gzip
+// level, an invalid level uses gzip.DefaultCompression.
+func NewCompressedCodec(codec sabuhp.Codec, level int) *CompressedCodec {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		level = gzip.DefaultCompression
+	}
+	return &CompressedCodec{codec: codec, level: level, maxSize: DefaultMaxDecompressedSize}
+}
+
+// WithMaxDecompressedSize sets the size decoded bytes may expand to,
+// replacing DefaultMaxDecompressedSize, and returns the codec.
+func (c *CompressedCodec) WithMaxDecompressedSize(size int) *CompressedCodec {
+	c.maxSize = size
+	return c
+}
+
+func (c *CompressedCodec) Encode(message sabuhp.Message) ([]byte, error) {
+	var encoded, encodeErr = c.codec.Encode(message)
+	if encodeErr != nil {
+		return nil, nerror.WrapOnly(encodeErr)
+	}
+
+	var buf bytes.Buffer
+	var writer, writerErr = gzip.NewWriterLevel(&buf, c.level)
+	if writerErr != nil {
+		return nil, nerror.WrapOnly(writerErr)
+	}
+	if _, err := writer.Write(encoded); err != nil {
+		return nil, nerror.WrapOnly(err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, nerror.WrapOnly(err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *CompressedCodec) Decode(b []byte) (sabuhp.Message, error) {
+	if !bytes.HasPrefix(b, gzipHeader) {
+		return c.codec.Decode(b)
+	}
+
+	var decompressed, decompressErr = gunzip(b, c.maxSize)
+	if decompressErr != nil {
+		return sabuhp.Message{}, decompressErr
+	}
+	return c.codec.Decode(decompressed)
+}
+
+// gunzip decompresses the gzip data, failing with ErrDecompressedTooLarge
+// once it expands past maxSize, a maxSize of zero uses
+// DefaultMaxDecompressedSize.
+func gunzip(data []byte, maxSize int) ([]byte, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxDecompressedSize
+	}
+
+	var reader, readerErr = gzip.NewReader(bytes.NewReader(data))
+	if readerErr != nil {
+		return nil, nerror.WrapOnly(readerErr)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	// one byte past the limit tells data of exactly maxSize from larger.
+	var decompressed, readErr = ioutil.ReadAll(io.LimitReader(reader, int64(maxSize)+1))
+	if readErr != nil {
+		return nil, nerror.WrapOnly(readErr)
+	}
+	if len(decompressed) > maxSize {
+		return nil, nerror.Wrap(ErrDecompressedTooLarge, "limit of %d bytes", maxSize)
+	}
+	return decompressed, nil
+}
+
+// compressPayload gzips the payload of a message flagged Compressed, other
+// messages are returned unchanged.
+func compressPayload(message sabuhp.Message) (sabuhp.Message, error) {
+	if !message.Compressed || len(message.Bytes) == 0 {
+		return message, nil
+	}
+
+	var buf bytes.Buffer
+	var writer = gzip.NewWriter(&buf)
+	if _, err := writer.Write(message.Bytes); err != nil {
+		return message, nerror.WrapOnly(err)
+	}
+	if err := writer.Close(); err != nil {
+		return message, nerror.WrapOnly(err)
+	}
+	message.Bytes = buf.Bytes()
+	return message, nil
+}
+
+// decompressPayload reverses compressPayload for a decoded message, the
+// Compressed flag is kept so the message is compressed again if forwarded.
+// A payload expanding past maxSize fails with ErrDecompressedTooLarge.
+func decompressPayload(message sabuhp.Message, maxSize int) (sabuhp.Message, error) {
+	if !message.Compressed || len(message.Bytes) == 0 {
+		return message, nil
+	}
+
+	var decompressed, decompressErr = gunzip(message.Bytes, maxSize)
+	if decompressErr != nil {
+		return message, decompressErr
+	}
+	message.Bytes = decompressed
+	return message, nil
+}
@@ -0,0 +1,101 @@
+package codecs
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/influx6/npkg/nerror"
+
+	"github.com/ewe-studios/sabuhp"
+)
+
+// Content types of the codecs of this package, as used by ContentTypeCodec.
+const (
+	JsonContentType    = "application/json"
+	MsgPackContentType = "application/msgpack"
+	GobContentType     = "application/x-gob"
+	ProtoContentType   = "application/x-protobuf"
+)
+
+// contentTypeFrame starts the header line of a frame of a ContentTypeCodec.
+var contentTypeFrame = []byte("content-type: ")
+
+var _ sabuhp.Codec = (*ContentTypeCodec)(nil)
+var _ sabuhp.ContentTyped = (*ContentTypeCodec)(nil)
+
+// ContentTypeCodec frames encoded messages with a content type header line,
+// "content-type: application/json\n" followed by the encoded message, and
+// decodes a frame with the codec registered for its content type, so
+// producers of a topic can use different codecs.
+//
+// Unlike MultiCodec the decoder is picked from the header before any
+// decoding. Bytes without a header are decoded with the codec of the
+// encoding content type.
+type ContentTypeCodec struct {
+	contentType string
+	codecs      map[string]sabuhp.Codec
+}
+
+// NewContentTypeCodec returns a ContentTypeCodec encoding with the codec of
+// contentType and decoding with any of codecs, keyed by content type.
+//
+// If codecs is nil, the json, msgpack, gob and proto codecs are used.
+func NewContentTypeCodec(contentType string, codecs map[string]sabuhp.Codec) *ContentTypeCodec {
+	if codecs == nil {
+		codecs = map[string]sabuhp.Codec{
+			JsonContentType:    &MessageJsonCodec{},
+			MsgPackContentType: &MessageMsgPackCodec{},
+			GobContentType:     &MessageGobCodec{},
+			ProtoContentType:   &MessageProtoCodec{},
+		}
+	}
+	if _, ok := codecs[contentType]; !ok {
+		panic("ContentTypeCodec has no codec for its content type " + contentType)
+	}
+	return &ContentTypeCodec{contentType: contentType, codecs: codecs}
+}
+
+// ContentTypes returns the sorted content types of the codecs decoded by
+// the codec.
+func (c *ContentTypeCodec) ContentTypes() []string {
+	var contentTypes = make([]string, 0, len(c.codecs))
+	for contentType := range c.codecs {
+		contentTypes = append(contentTypes, contentType)
+	}
+	sort.Strings(contentTypes)
+	return contentTypes
+}
+
+func (c *ContentTypeCodec) Encode(message sabuhp.Message) ([]byte, error) {
+	var encoded, encodeErr = c.codecs[c.contentType].Encode(message)
+	if encodeErr != nil {
+		return nil, nerror.WrapOnly(encodeErr)
+	}
+
+	var frame bytes.Buffer
+	frame.Grow(len(contentTypeFrame) + len(c.contentType) + 1 + len(encoded))
+	frame.Write(contentTypeFrame)
+	frame.WriteString(c.contentType)
+	frame.WriteByte('\n')
+	frame.Write(encoded)
+	return frame.Bytes(), nil
+}
+
+func (c *ContentTypeCodec) Decode(b []byte) (sabuhp.Message, error) {
+	if !bytes.HasPrefix(b, contentTypeFrame) {
+		return c.codecs[c.contentType].Decode(b)
+	}
+
+	var header = b[len(contentTypeFrame):]
+	var end = bytes.IndexByte(header, '\n')
+	if end < 0 {
+		return sabuhp.Message{}, nerror.New("content type frame has no end of header")
+	}
+
+	var contentType = string(header[:end])
+	var codec, ok = c.codecs[contentType]
+	if !ok {
+		return sabuhp.Message{}, nerror.New("no codec for content type %q", contentType)
+	}
+	return codec.Decode(header[end+1:])
+}
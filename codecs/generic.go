@@ -7,6 +7,14 @@ import (
 
 	"github.com/influx6/npkg/nerror"
 	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/ewe-studios/sabuhp"
+)
+
+var (
+	_ sabuhp.ValueCodec = (*GenericMsgPackCodec)(nil)
+	_ sabuhp.ValueCodec = (*GenericJsonCodec)(nil)
+	_ sabuhp.ValueCodec = (*GenericGobCodec)(nil)
 )
 
 // GenericMsgPackCodec implements the LoginCodec interface for using
@@ -11,15 +11,42 @@ import (
 
 var _ sabuhp.Codec = (*MessageGobCodec)(nil)
 
-type MessageGobCodec struct{}
+// MessageGobCodec encodes messages with encoding/gob.
+//
+// Gob encodes maps in their iteration order, hence the output is not
+// deterministic for messages with more than one Metadata, Params, Headers,
+// Form or Query entry, use MessageJsonCodec or MessageMsgPackCodec where
+// identical messages must produce identical bytes.
+//
+// Time fields are encoded in UTC with the binary encoding of time.Time
+// and are decoded in UTC.
+//
+// Gob matches struct fields by name, hence binaries with and without an
+// optional Message field can exchange messages during a rolling upgrade:
+// a field unknown to the decoding side is skipped and a field missing
+// from the payload keeps its zero value. Changing the type of an existing
+// field or reusing the name of a removed one is not compatible, such
+// changes need a new field name.
+type MessageGobCodec struct {
+	// MaxPayloadSize is the size a Compressed payload may expand to when
+	// decoded, DefaultMaxDecompressedSize if zero. Larger payloads fail
+	// with ErrDecompressedTooLarge.
+	MaxPayloadSize int
+}
 
 func (j *MessageGobCodec) Encode(message sabuhp.Message) ([]byte, error) {
 	message.Parts = nil
-	var buf bytes.Buffer
-	if encodedErr := gob.NewEncoder(&buf).Encode(message); encodedErr != nil {
+	message.UnknownFields = nil
+	var compressed, compressErr = compressPayload(message)
+	if compressErr != nil {
+		return nil, compressErr
+	}
+	var buf = getBuffer()
+	defer putBuffer(buf)
+	if encodedErr := gob.NewEncoder(buf).Encode(toUTC(compressed)); encodedErr != nil {
 		return nil, nerror.WrapOnly(encodedErr)
 	}
-	return buf.Bytes(), nil
+	return copyBytes(buf.Bytes()), nil
 }
 
 func (j *MessageGobCodec) Decode(b []byte) (sabuhp.Message, error) {
@@ -28,5 +55,5 @@ func (j *MessageGobCodec) Decode(b []byte) (sabuhp.Message, error) {
 		return message, nerror.WrapOnly(jsonErr)
 	}
 	message.Future = nil
-	return message, nil
+	return decompressPayload(toUTC(message), j.MaxPayloadSize)
 }
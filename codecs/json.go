@@ -1,6 +1,7 @@
 package codecs
 
 import (
+	"bytes"
 	"encoding/json"
 
 	"github.com/ewe-studios/sabuhp"
@@ -10,16 +11,32 @@ import (
 
 var _ sabuhp.Codec = (*MessageJsonCodec)(nil)
 
-type MessageJsonCodec struct{}
+// MessageJsonCodec encodes messages as json, map keys are always sorted by
+// encoding/json, so identical messages encode to identical bytes.
+//
+// Time fields are encoded in UTC as RFC 3339 strings with nanoseconds
+// and are decoded in UTC.
+type MessageJsonCodec struct {
+	// MaxPayloadSize is the size a Compressed payload may expand to when
+	// decoded, DefaultMaxDecompressedSize if zero. Larger payloads fail
+	// with ErrDecompressedTooLarge.
+	MaxPayloadSize int
+}
 
 func (j *MessageJsonCodec) Encode(message sabuhp.Message) ([]byte, error) {
 	message.Parts = nil
-	encoded, encodedErr := json.Marshal(message)
-	if encodedErr != nil {
+	var compressed, compressErr = compressPayload(message)
+	if compressErr != nil {
+		return nil, compressErr
+	}
+	var buf = getBuffer()
+	defer putBuffer(buf)
+	if encodedErr := json.NewEncoder(buf).Encode(toUTC(compressed)); encodedErr != nil {
 		return nil, nerror.WrapOnly(encodedErr)
 	}
 
-	return encoded, nil
+	// unlike json.Marshal the encoder ends the value with a newline.
+	return copyBytes(bytes.TrimSuffix(buf.Bytes(), []byte("\n"))), nil
 }
 
 func (j *MessageJsonCodec) Decode(b []byte) (sabuhp.Message, error) {
@@ -28,5 +45,5 @@ func (j *MessageJsonCodec) Decode(b []byte) (sabuhp.Message, error) {
 		return message, nerror.WrapOnly(jsonErr)
 	}
 	message.Future = nil
-	return message, nil
+	return decompressPayload(toUTC(message), j.MaxPayloadSize)
 }
@@ -11,15 +11,32 @@ import (
 
 var _ sabuhp.Codec = (*MessageMsgPackCodec)(nil)
 
-type MessageMsgPackCodec struct{}
+// MessageMsgPackCodec encodes messages with msgpack, map keys (e.g Metadata,
+// Params, Headers) are sorted, so identical messages encode to identical bytes.
+//
+// Time fields are encoded with the msgpack timestamp extension, which holds
+// no zone, and are decoded in UTC.
+type MessageMsgPackCodec struct {
+	// MaxPayloadSize is the size a Compressed payload may expand to when
+	// decoded, DefaultMaxDecompressedSize if zero. Larger payloads fail
+	// with ErrDecompressedTooLarge.
+	MaxPayloadSize int
+}
 
 func (j *MessageMsgPackCodec) Encode(message sabuhp.Message) ([]byte, error) {
 	message.Parts = nil
-	var buf bytes.Buffer
-	if encodedErr := msgpack.NewEncoder(&buf).Encode(message); encodedErr != nil {
+	var compressed, compressErr = compressPayload(message)
+	if compressErr != nil {
+		return nil, compressErr
+	}
+	var buf = getBuffer()
+	defer putBuffer(buf)
+	var encoder = msgpack.NewEncoder(buf)
+	encoder.SetSortMapKeys(true)
+	if encodedErr := encoder.Encode(toUTC(compressed)); encodedErr != nil {
 		return nil, nerror.WrapOnly(encodedErr)
 	}
-	return buf.Bytes(), nil
+	return copyBytes(buf.Bytes()), nil
 }
 
 func (j *MessageMsgPackCodec) Decode(b []byte) (sabuhp.Message, error) {
@@ -28,5 +45,5 @@ func (j *MessageMsgPackCodec) Decode(b []byte) (sabuhp.Message, error) {
 		return message, nerror.WrapOnly(jsonErr)
 	}
 	message.Future = nil
-	return message, nil
+	return decompressPayload(toUTC(message), j.MaxPayloadSize)
 }
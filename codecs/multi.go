@@ -0,0 +1,44 @@
+package codecs
+
+import (
+	"github.com/influx6/npkg/nerror"
+
+	"github.com/ewe-studios/sabuhp"
+)
+
+var _ sabuhp.Codec = (*MultiCodec)(nil)
+
+// MultiCodec encodes messages with its first codec and decodes messages
+// encoded with any of its codecs, e.g. while migrating a topic from one
+// codec to another.
+//
+// Decode tries the codecs in order and returns the message of the first
+// one which decodes the bytes, the formats of the json, msgpack and gob
+// codecs never decode as one another.
+type MultiCodec struct {
+	codecs []sabuhp.Codec
+}
+
+// NewMultiCodec returns a MultiCodec encoding with primary and decoding
+// with primary or any of others.
+func NewMultiCodec(primary sabuhp.Codec, others ...sabuhp.Codec) *MultiCodec {
+	return &MultiCodec{codecs: append([]sabuhp.Codec{primary}, others...)}
+}
+
+func (m *MultiCodec) Encode(message sabuhp.Message) ([]byte, error) {
+	return m.codecs[0].Encode(message)
+}
+
+func (m *MultiCodec) Decode(b []byte) (sabuhp.Message, error) {
+	var firstErr error
+	for _, codec := range m.codecs {
+		var message, decodeErr = codec.Decode(b)
+		if decodeErr == nil {
+			return message, nil
+		}
+		if firstErr == nil {
+			firstErr = decodeErr
+		}
+	}
+	return sabuhp.Message{}, nerror.WrapOnly(firstErr)
+}
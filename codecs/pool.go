@@ -0,0 +1,38 @@
+package codecs
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledBufferSize is the capacity above which buffers are dropped
+// rather than pooled, so one large message does not pin its memory.
+const maxPooledBufferSize = 64 * 1024
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer returns the buffer to the pool, its bytes must no longer be
+// referenced as the next user overwrites them.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// copyBytes returns a copy of the bytes which outlives the pooled buffer
+// holding them.
+func copyBytes(b []byte) []byte {
+	var copied = make([]byte, len(b))
+	copy(copied, b)
+	return copied
+}
@@ -0,0 +1,149 @@
+package codecs
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nxid"
+
+	"github.com/ewe-studios/sabuhp"
+	"github.com/ewe-studios/sabuhp/codecs/protopb"
+)
+
+// ErrMalformedProto is returned when decoding bytes which are not a valid
+// protobuf encoding of a message.
+var ErrMalformedProto = nerror.New("malformed protobuf message")
+
+var _ sabuhp.Codec = (*MessageProtoCodec)(nil)
+
+// MessageProtoCodec encodes messages as the protobuf Message of
+// protopb/message.proto, so services in other languages can decode them
+// with code generated from the schema. Map entries are written sorted by
+// key, so identical messages encode to identical bytes.
+//
+// The schema covers the fields of a message sent over a bus, the fields
+// of http requests (e.g Headers, Cookies, Form) are not encoded. Fields
+// of a newer schema are kept in Message.UnknownFields by Decode and
+// written back by Encode, so forwarding a message does not drop them.
+//
+// Time fields are encoded as google.protobuf.Timestamp and Duration and
+// are decoded in UTC.
+type MessageProtoCodec struct {
+	// MaxPayloadSize is the size a Compressed payload may expand to when
+	// decoded, DefaultMaxDecompressedSize if zero. Larger payloads fail
+	// with ErrDecompressedTooLarge.
+	MaxPayloadSize int
+}
+
+func (j *MessageProtoCodec) Encode(message sabuhp.Message) ([]byte, error) {
+	var compressed, compressErr = compressPayload(message)
+	if compressErr != nil {
+		return nil, compressErr
+	}
+
+	var encoded = &protopb.Message{
+		Topic: &protopb.Topic{
+			T: compressed.Topic.T,
+			R: compressed.Topic.R,
+			S: compressed.Topic.S,
+		},
+		FromAddr:       compressed.FromAddr,
+		ReplyGroup:     compressed.ReplyGroup,
+		ContentType:    compressed.ContentType,
+		Payload:        compressed.Bytes,
+		Compressed:     compressed.Compressed,
+		Metadata:       compressed.Metadata,
+		Params:         compressed.Params,
+		PartitionKey:   compressed.PartitionKey,
+		SubscribeGroup: compressed.SubscribeGroup,
+		SubscribeTo:    compressed.SubscribeTo,
+		ExpectReply:    compressed.ExpectReply,
+		Id:             idBytes(compressed.Id),
+		CorrelationId:  idBytes(compressed.CorrelationId),
+		PartId:         idBytes(compressed.PartId),
+		EndPartId:      idBytes(compressed.EndPartId),
+	}
+	if compressed.Within != 0 {
+		encoded.Within = durationpb.New(compressed.Within)
+	}
+	if !compressed.ScheduledFor.IsZero() {
+		encoded.ScheduledFor = timestamppb.New(compressed.ScheduledFor)
+	}
+	if len(compressed.UnknownFields) > 0 {
+		encoded.ProtoReflect().SetUnknown(compressed.UnknownFields)
+	}
+
+	var data, encodeErr = proto.MarshalOptions{Deterministic: true}.Marshal(encoded)
+	if encodeErr != nil {
+		return nil, nerror.WrapOnly(encodeErr)
+	}
+	return data, nil
+}
+
+func (j *MessageProtoCodec) Decode(b []byte) (sabuhp.Message, error) {
+	var message sabuhp.Message
+
+	var decoded protopb.Message
+	if decodeErr := proto.Unmarshal(b, &decoded); decodeErr != nil {
+		return message, nerror.Wrap(ErrMalformedProto, "%s", decodeErr)
+	}
+
+	message.FromAddr = decoded.FromAddr
+	message.ReplyGroup = decoded.ReplyGroup
+	message.ContentType = decoded.ContentType
+	message.Bytes = decoded.Payload
+	message.Compressed = decoded.Compressed
+	message.Metadata = decoded.Metadata
+	message.Params = decoded.Params
+	message.PartitionKey = decoded.PartitionKey
+	message.SubscribeGroup = decoded.SubscribeGroup
+	message.SubscribeTo = decoded.SubscribeTo
+	message.ExpectReply = decoded.ExpectReply
+	if decoded.Topic != nil {
+		message.Topic = sabuhp.Topic{T: decoded.Topic.T, R: decoded.Topic.R, S: decoded.Topic.S}
+	}
+	if decoded.Within != nil {
+		message.Within = decoded.Within.AsDuration()
+	}
+	if decoded.ScheduledFor != nil {
+		message.ScheduledFor = decoded.ScheduledFor.AsTime()
+	}
+	if unknown := decoded.ProtoReflect().GetUnknown(); len(unknown) > 0 {
+		message.UnknownFields = unknown
+	}
+
+	var idErr error
+	if message.Id, idErr = idFromBytes(decoded.Id); idErr != nil {
+		return message, idErr
+	}
+	if message.CorrelationId, idErr = idFromBytes(decoded.CorrelationId); idErr != nil {
+		return message, idErr
+	}
+	if message.PartId, idErr = idFromBytes(decoded.PartId); idErr != nil {
+		return message, idErr
+	}
+	if message.EndPartId, idErr = idFromBytes(decoded.EndPartId); idErr != nil {
+		return message, idErr
+	}
+	return decompressPayload(message, j.MaxPayloadSize)
+}
+
+func idBytes(id nxid.ID) []byte {
+	if id.IsNil() {
+		return nil
+	}
+	return id.Bytes()
+}
+
+func idFromBytes(b []byte) (nxid.ID, error) {
+	if len(b) == 0 {
+		return nxid.ID{}, nil
+	}
+	var id, idErr = nxid.FromBytes(b)
+	if idErr != nil {
+		return id, nerror.Wrap(ErrMalformedProto, "invalid id: %s", idErr)
+	}
+	return id, nil
+}
@@ -0,0 +1,5 @@
+// Package protopb holds the Go types generated from message.proto, the
+// protobuf schema of the messages encoded by codecs.MessageProtoCodec.
+package protopb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative message.proto
@@ -0,0 +1,60 @@
+package codecs
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/influx6/npkg/nerror"
+
+	"github.com/ewe-studios/sabuhp"
+)
+
+// CodecCreator returns a new instance of a codec.
+type CodecCreator func() sabuhp.Codec
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]CodecCreator{}
+)
+
+func init() {
+	Register("json", func() sabuhp.Codec { return &MessageJsonCodec{} })
+	Register("msgpack", func() sabuhp.Codec { return &MessageMsgPackCodec{} })
+	Register("gob", func() sabuhp.Codec { return &MessageGobCodec{} })
+	Register("proto", func() sabuhp.Codec { return &MessageProtoCodec{} })
+}
+
+// Register registers the creator of a codec under the name, replacing
+// any codec registered with the same name before.
+//
+// The json, msgpack and gob codecs are registered by default.
+func Register(name string, creator CodecCreator) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = creator
+}
+
+// Get returns a new instance of the codec registered with the name.
+func Get(name string) (sabuhp.Codec, error) {
+	registryMu.RLock()
+	var creator, ok = registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, nerror.New("no codec registered with name %q", name)
+	}
+	return creator(), nil
+}
+
+// Names returns the sorted names of all registered codecs.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	var names = make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,92 @@
+package codecs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ewe-studios/sabuhp"
+)
+
+type customCodec struct {
+	MessageJsonCodec
+}
+
+func TestRegistry(t *testing.T) {
+	var jsonCodec, jsonErr = Get("json")
+	require.NoError(t, jsonErr)
+	require.IsType(t, &MessageJsonCodec{}, jsonCodec)
+
+	var msgpackCodec, msgpackErr = Get("msgpack")
+	require.NoError(t, msgpackErr)
+	require.IsType(t, &MessageMsgPackCodec{}, msgpackCodec)
+
+	var gobCodec, gobErr = Get("gob")
+	require.NoError(t, gobErr)
+	require.IsType(t, &MessageGobCodec{}, gobCodec)
+
+	Register("custom", func() sabuhp.Codec { return &customCodec{} })
+
+	var custom, customErr = Get("custom")
+	require.NoError(t, customErr)
+	require.IsType(t, &customCodec{}, custom)
+	require.Contains(t, Names(), "custom")
+
+	var _, unknownErr = Get("unknown")
+	require.Error(t, unknownErr)
+	require.Contains(t, unknownErr.Error(), "unknown")
+}
+
+func TestMultiCodec(t *testing.T) {
+	var multi = NewMultiCodec(&MessageMsgPackCodec{}, &MessageJsonCodec{}, &MessageGobCodec{})
+
+	for _, codec := range []sabuhp.Codec{&MessageMsgPackCodec{}, &MessageJsonCodec{}, &MessageGobCodec{}} {
+		var encoded, encodeErr = codec.Encode(sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("world")))
+		require.NoError(t, encodeErr)
+
+		var decoded, decodeErr = multi.Decode(encoded)
+		require.NoError(t, decodeErr)
+		require.Equal(t, "world", string(decoded.Bytes))
+	}
+
+	var encoded, encodeErr = multi.Encode(sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("world")))
+	require.NoError(t, encodeErr)
+	var _, decodeErr = (&MessageMsgPackCodec{}).Decode(encoded)
+	require.NoError(t, decodeErr)
+
+	var _, invalidErr = multi.Decode([]byte("not a message"))
+	require.Error(t, invalidErr)
+}
+
+func TestContentTypeCodec(t *testing.T) {
+	var jsonProducer = NewContentTypeCodec(JsonContentType, nil)
+	var msgpackProducer = NewContentTypeCodec(MsgPackContentType, nil)
+
+	var jsonFrame, jsonErr = jsonProducer.Encode(sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("json")))
+	require.NoError(t, jsonErr)
+	require.Contains(t, string(jsonFrame), "content-type: application/json\n")
+
+	var msgpackFrame, msgpackErr = msgpackProducer.Encode(sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("msgpack")))
+	require.NoError(t, msgpackErr)
+
+	// a single consumer decodes the frames of both producers.
+	var consumer = NewContentTypeCodec(JsonContentType, nil)
+
+	var fromJson, fromJsonErr = consumer.Decode(jsonFrame)
+	require.NoError(t, fromJsonErr)
+	require.Equal(t, "json", string(fromJson.Bytes))
+
+	var fromMsgpack, fromMsgpackErr = consumer.Decode(msgpackFrame)
+	require.NoError(t, fromMsgpackErr)
+	require.Equal(t, "msgpack", string(fromMsgpack.Bytes))
+
+	// unframed bytes use the codec of the consumer's content type.
+	var unframed, unframedErr = (&MessageJsonCodec{}).Encode(sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("plain")))
+	require.NoError(t, unframedErr)
+	var plain, plainErr = consumer.Decode(unframed)
+	require.NoError(t, plainErr)
+	require.Equal(t, "plain", string(plain.Bytes))
+
+	var _, unknownErr = consumer.Decode([]byte("content-type: text/xml\n<message/>"))
+	require.Error(t, unknownErr)
+}
@@ -0,0 +1,15 @@
+package codecs
+
+import (
+	"github.com/ewe-studios/sabuhp"
+)
+
+// toUTC returns the message with all its time fields in UTC, so every
+// codec encodes and decodes the same instant regardless of the zone the
+// time was created in.
+func toUTC(message sabuhp.Message) sabuhp.Message {
+	if !message.ScheduledFor.IsZero() {
+		message.ScheduledFor = message.ScheduledFor.UTC()
+	}
+	return message
+}
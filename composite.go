@@ -0,0 +1,296 @@
+package sabuhp
+
+import (
+	"sync"
+	"time"
+
+	"github.com/influx6/npkg/njson"
+	"github.com/influx6/npkg/nxid"
+)
+
+// Lifecycle is implemented by components started and stopped by their
+// owner, e.g a MessageBus, allowing them to be managed uniformly.
+//
+// Start returns ErrBusClosed once stopped, Stop returns ErrAlreadyStopped
+// on all but the first call and Wait blocks till the component stopped.
+type Lifecycle interface {
+	Start() error
+	Stop() error
+	Wait()
+}
+
+// HealthReporter is implemented by a MessageBus which is able to report
+// on the health of its underline connection.
+type HealthReporter interface {
+	Healthy() bool
+}
+
+// IsHealthy returns true if the bus reports itself as healthy, buses
+// which do not implement HealthReporter are always considered healthy.
+func IsHealthy(bus MessageBus) bool {
+	if reporter, ok := bus.(HealthReporter); ok {
+		return reporter.Healthy()
+	}
+	return true
+}
+
+// DefaultCompositeCheckInterval is the interval at which a CompositeBus
+// created without one checks the health of its buses.
+var DefaultCompositeCheckInterval = time.Second
+
+var _ MessageBus = (*CompositeBus)(nil)
+
+// CompositeBus wraps an ordered list of message buses, where the first healthy
+// bus in order of precedence is used for Send and SendForReply. When a bus
+// becomes unhealthy, traffic moves to the next healthy one and returns
+// to it once it recovers.
+//
+// Listen subscribes on all buses, so messages are received regardless of
+// which bus delivered them. A subscription failing on a bus, e.g as it is
+// down, is retried once a health check sees the bus healthy again.
+//
+// Be aware that fallback buses are often process-local (e.g an in-memory bus),
+// hence messages sent while the primary is unhealthy will only reach listeners
+// sharing that fallback, and guarantees like ordering and durability are
+// only as strong as the bus carrying the message at that time.
+type CompositeBus struct {
+	logger        Logger
+	checkInterval time.Duration
+	buses         []MessageBus
+
+	cl        sync.Mutex
+	active    int
+	checking  bool
+	lastCheck time.Time
+
+	// channels are the open channels of Listen, whose failed subscriptions
+	// are retried by health checks.
+	channelsMu sync.Mutex
+	channels   map[*compositeChannel]struct{}
+}
+
+// NewCompositeBus returns a new CompositeBus which checks the health of
+// its buses at most once every checkInterval, DefaultCompositeCheckInterval
+// if zero.
+func NewCompositeBus(logger Logger, checkInterval time.Duration, buses ...MessageBus) *CompositeBus {
+	if len(buses) == 0 {
+		panic("CompositeBus requires at least one MessageBus")
+	}
+	if checkInterval <= 0 {
+		checkInterval = DefaultCompositeCheckInterval
+	}
+	return &CompositeBus{
+		logger:        logger,
+		checkInterval: checkInterval,
+		buses:         buses,
+		channels:      map[*compositeChannel]struct{}{},
+	}
+}
+
+// Active returns the bus currently used for sending messages.
+//
+// Health checks may take a network round trip, e.g a redis PING, hence
+// they run outside the lock of the bus: while one caller checks, others
+// get the last known active bus rather than waiting on it.
+func (c *CompositeBus) Active() MessageBus {
+	c.cl.Lock()
+	if c.checking || (!c.lastCheck.IsZero() && time.Since(c.lastCheck) < c.checkInterval) {
+		var active = c.buses[c.active]
+		c.cl.Unlock()
+		return active
+	}
+	c.checking = true
+	c.lastCheck = time.Now()
+	c.cl.Unlock()
+
+	var selected = 0
+	for index, bus := range c.buses {
+		if IsHealthy(bus) {
+			selected = index
+			break
+		}
+	}
+
+	c.resubscribe()
+
+	c.cl.Lock()
+	defer c.cl.Unlock()
+
+	c.checking = false
+	if selected != c.active {
+		njson.Log(c.logger).New().
+			LWarn().
+			Message("composite bus switched active bus").
+			Int("from_bus", c.active).
+			Int("to_bus", selected).
+			End()
+		c.active = selected
+	}
+
+	return c.buses[c.active]
+}
+
+// Healthy returns true if any of the underline buses is healthy.
+func (c *CompositeBus) Healthy() bool {
+	for _, bus := range c.buses {
+		if IsHealthy(bus) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CompositeBus) Send(data ...Message) {
+	c.Active().Send(data...)
+}
+
+func (c *CompositeBus) SendForReply(tm time.Duration, fromTopic Topic, replyGroup string, data ...Message) *ReplyFuture {
+	return c.Active().SendForReply(tm, fromTopic, replyGroup, data...)
+}
+
+func (c *CompositeBus) Listen(topic string, grp string, handler TransportResponse) Channel {
+	var channel = &compositeChannel{
+		id:       nxid.New(),
+		topic:    topic,
+		group:    grp,
+		handler:  handler,
+		host:     c,
+		channels: make([]Channel, len(c.buses)),
+	}
+	for index, bus := range c.buses {
+		channel.channels[index] = bus.Listen(topic, grp, handler)
+	}
+
+	c.channelsMu.Lock()
+	c.channels[channel] = struct{}{}
+	c.channelsMu.Unlock()
+	return channel
+}
+
+// resubscribe retries the failed subscriptions of the open channels on
+// the buses which are healthy again.
+func (c *CompositeBus) resubscribe() {
+	c.channelsMu.Lock()
+	var open = make([]*compositeChannel, 0, len(c.channels))
+	for channel := range c.channels {
+		open = append(open, channel)
+	}
+	c.channelsMu.Unlock()
+
+	var healthy = map[int]bool{}
+	for _, channel := range open {
+		for _, index := range channel.failed() {
+			var isHealthy, checked = healthy[index]
+			if !checked {
+				isHealthy = IsHealthy(c.buses[index])
+				healthy[index] = isHealthy
+			}
+			if isHealthy {
+				channel.resubscribe(index, c.buses[index])
+			}
+		}
+	}
+}
+
+type compositeChannel struct {
+	id      nxid.ID
+	topic   string
+	group   string
+	handler TransportResponse
+	host    *CompositeBus
+
+	mu       sync.Mutex
+	closed   bool
+	channels []Channel
+}
+
+func (cc *compositeChannel) ID() nxid.ID {
+	return cc.id
+}
+
+func (cc *compositeChannel) Topic() string {
+	return cc.topic
+}
+
+func (cc *compositeChannel) Group() string {
+	return cc.group
+}
+
+func (cc *compositeChannel) Close() {
+	cc.host.channelsMu.Lock()
+	delete(cc.host.channels, cc)
+	cc.host.channelsMu.Unlock()
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	cc.closed = true
+	for _, channel := range cc.channels {
+		channel.Close()
+	}
+}
+
+// Err returns the first error seen from the underline channels if the
+// subscription failed on all buses, a subscription on any bus receives
+// messages.
+func (cc *compositeChannel) Err() error {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	var firstErr error
+	for _, channel := range cc.channels {
+		var err = channel.Err()
+		if err == nil {
+			return nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// failed returns the indexes of the buses the subscription failed on.
+func (cc *compositeChannel) failed() []int {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	var indexes []int
+	for index, channel := range cc.channels {
+		if channel.Err() != nil {
+			indexes = append(indexes, index)
+		}
+	}
+	return indexes
+}
+
+// resubscribe listens again on the bus at the index if the subscription
+// on it failed and the channel is open.
+func (cc *compositeChannel) resubscribe(index int, bus MessageBus) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if cc.closed || cc.channels[index].Err() == nil {
+		return
+	}
+
+	var channel = bus.Listen(cc.topic, cc.group, cc.handler)
+	cc.channels[index] = channel
+	if listenErr := channel.Err(); listenErr != nil {
+		njson.Log(cc.host.logger).New().
+			LWarn().
+			Message("composite bus failed to resubscribe on recovered bus").
+			String("topic", cc.topic).
+			Int("bus", index).
+			Error("error", listenErr).
+			End()
+		return
+	}
+
+	njson.Log(cc.host.logger).New().
+		LInfo().
+		Message("composite bus resubscribed on recovered bus").
+		String("topic", cc.topic).
+		Int("bus", index).
+		End()
+}
@@ -0,0 +1,190 @@
+package sabuhp
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nxid"
+	"github.com/stretchr/testify/require"
+)
+
+type healthBus struct {
+	healthy  int32
+	sent     int32
+	listens  int32
+	handlers []TransportResponse
+}
+
+func (h *healthBus) setHealthy(healthy bool) {
+	if healthy {
+		atomic.StoreInt32(&h.healthy, 1)
+		return
+	}
+	atomic.StoreInt32(&h.healthy, 0)
+}
+
+func (h *healthBus) Healthy() bool {
+	return atomic.LoadInt32(&h.healthy) == 1
+}
+
+func (h *healthBus) Send(data ...Message) {
+	atomic.AddInt32(&h.sent, int32(len(data)))
+	for _, msg := range data {
+		for _, handler := range h.handlers {
+			_ = handler.Handle(context.Background(), msg, Transport{Bus: h})
+		}
+	}
+}
+
+// Listen fails while the bus is unhealthy.
+func (h *healthBus) Listen(topic string, grp string, handler TransportResponse) Channel {
+	if !h.Healthy() {
+		return &healthChannel{topic: topic, group: grp, err: nerror.New("bus is down")}
+	}
+	atomic.AddInt32(&h.listens, 1)
+	h.handlers = append(h.handlers, handler)
+	return &healthChannel{topic: topic, group: grp}
+}
+
+type healthChannel struct {
+	topic string
+	group string
+	err   error
+}
+
+func (h *healthChannel) ID() nxid.ID   { return nxid.ID{} }
+func (h *healthChannel) Topic() string { return h.topic }
+func (h *healthChannel) Group() string { return h.group }
+func (h *healthChannel) Close()        {}
+func (h *healthChannel) Err() error    { return h.err }
+
+func (h *healthBus) SendForReply(tm time.Duration, fromTopic Topic, replyGroup string, data ...Message) *ReplyFuture {
+	var ft = NewReplyFuture()
+	ft.WithError(ErrReplyTimeout)
+	return ft
+}
+
+func TestCompositeBus_FallbackAndRecovery(t *testing.T) {
+	var logger GoLogImpl
+	var primary = &healthBus{healthy: 1}
+	var fallback = &healthBus{healthy: 1}
+
+	var received int32
+	var composite = NewCompositeBus(logger, 10*time.Millisecond, primary, fallback)
+	composite.Listen("hello", "*", TransportResponseFunc(func(ctx context.Context, message Message, tr Transport) MessageErr {
+		atomic.AddInt32(&received, 1)
+		return nil
+	}))
+
+	var msg = BasicMsg(T("hello"), "hello", "me")
+
+	composite.Send(msg)
+	require.Equal(t, primary, composite.Active())
+	require.Equal(t, int32(1), atomic.LoadInt32(&primary.sent))
+	require.Equal(t, int32(0), atomic.LoadInt32(&fallback.sent))
+
+	primary.setHealthy(false)
+	require.Eventually(t, func() bool {
+		return composite.Active() == MessageBus(fallback)
+	}, time.Second, time.Millisecond)
+
+	composite.Send(msg)
+	require.Equal(t, int32(1), atomic.LoadInt32(&primary.sent))
+	require.Equal(t, int32(1), atomic.LoadInt32(&fallback.sent))
+	require.True(t, composite.Healthy())
+
+	primary.setHealthy(true)
+	require.Eventually(t, func() bool {
+		return composite.Active() == MessageBus(primary)
+	}, time.Second, time.Millisecond)
+
+	composite.Send(msg)
+	require.Equal(t, int32(2), atomic.LoadInt32(&primary.sent))
+	require.Equal(t, int32(1), atomic.LoadInt32(&fallback.sent))
+
+	require.Equal(t, int32(3), atomic.LoadInt32(&received))
+}
+
+func TestCompositeBus_ListenWhilePrimaryIsDown(t *testing.T) {
+	var logger GoLogImpl
+	var primary = &healthBus{healthy: 0}
+	var fallback = &healthBus{healthy: 1}
+
+	var received int32
+	var composite = NewCompositeBus(logger, 10*time.Millisecond, primary, fallback)
+	var channel = composite.Listen("hello", "*", TransportResponseFunc(func(ctx context.Context, message Message, tr Transport) MessageErr {
+		atomic.AddInt32(&received, 1)
+		return nil
+	}))
+
+	// the fallback subscribed, so the listen did not fail.
+	require.NoError(t, channel.Err())
+	require.Equal(t, int32(0), atomic.LoadInt32(&primary.listens))
+
+	var msg = BasicMsg(T("hello"), "hello", "me")
+	composite.Send(msg)
+	require.Equal(t, int32(1), atomic.LoadInt32(&fallback.sent))
+
+	// the health check seeing the primary recover subscribes on it.
+	primary.setHealthy(true)
+	require.Eventually(t, func() bool {
+		return composite.Active() == MessageBus(primary) && atomic.LoadInt32(&primary.listens) == 1
+	}, time.Second, time.Millisecond)
+
+	composite.Send(msg)
+	require.Equal(t, int32(1), atomic.LoadInt32(&primary.sent))
+	require.Equal(t, int32(2), atomic.LoadInt32(&received))
+
+	// later checks do not subscribe again.
+	time.Sleep(20 * time.Millisecond)
+	composite.Active()
+	require.Equal(t, int32(1), atomic.LoadInt32(&primary.listens))
+	channel.Close()
+
+	// a listen failing on every bus fails.
+	fallback.setHealthy(false)
+	primary.setHealthy(false)
+	require.Error(t, composite.Listen("hello", "*", TransportResponseFunc(func(ctx context.Context, message Message, tr Transport) MessageErr {
+		return nil
+	})).Err())
+}
+
+type slowHealthBus struct {
+	healthBus
+	checks int32
+}
+
+func (s *slowHealthBus) Healthy() bool {
+	atomic.AddInt32(&s.checks, 1)
+	time.Sleep(100 * time.Millisecond)
+	return s.healthBus.Healthy()
+}
+
+func TestCompositeBus_ChecksHealthOutsideSends(t *testing.T) {
+	var logger GoLogImpl
+	var primary = &slowHealthBus{healthBus: healthBus{healthy: 1}}
+	var composite = NewCompositeBus(logger, 0, primary)
+
+	// the first send runs the slow check, concurrent ones use the last
+	// known active bus rather than waiting on it.
+	go composite.Send(BasicMsg(T("hello"), "hello", "me"))
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&primary.checks) == 1
+	}, time.Second, time.Millisecond)
+
+	var start = time.Now()
+	for i := 0; i < 10; i++ {
+		composite.Send(BasicMsg(T("hello"), "hello", "me"))
+	}
+	require.Less(t, int64(time.Since(start)), int64(50*time.Millisecond))
+
+	// a zero interval defaults to DefaultCompositeCheckInterval rather
+	// than checking on every send.
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&primary.sent) == 11
+	}, time.Second, time.Millisecond)
+	require.Equal(t, int32(1), atomic.LoadInt32(&primary.checks))
+}
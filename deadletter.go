@@ -0,0 +1,137 @@
+package sabuhp
+
+import (
+	"sync"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nxid"
+)
+
+// DeadLetter is a message quarantined in a DeadLetterStore with the
+// reason it failed.
+type DeadLetter struct {
+	// Id is the id of the dead letter in its store, unique per topic.
+	Id       string
+	Message  Message
+	Reason   string
+	StoredAt time.Time
+
+	// Replayed is set once the dead letter was replayed.
+	Replayed bool
+}
+
+// DeadLetterQuery selects the dead letters listed by a DeadLetterStore.
+type DeadLetterQuery struct {
+	// Topic is the topic of the dead letters.
+	Topic string
+
+	// IncludeReplayed lists dead letters already replayed as well.
+	IncludeReplayed bool
+
+	// Match when set further selects the dead letters listed.
+	Match func(letter DeadLetter) bool
+}
+
+func (q DeadLetterQuery) matches(letter DeadLetter) bool {
+	if letter.Replayed && !q.IncludeReplayed {
+		return false
+	}
+	return q.Match == nil || q.Match(letter)
+}
+
+// DeadLetterStore is the destination of dead-lettered messages, from
+// which they can be listed and replayed.
+type DeadLetterStore interface {
+	// Store quarantines the message with the reason, returning the id of
+	// the dead letter.
+	Store(msg Message, reason error) (string, error)
+
+	// List returns the dead letters selected by the query, oldest first.
+	List(query DeadLetterQuery) ([]DeadLetter, error)
+
+	// MarkReplayed marks the dead letters of the topic as replayed.
+	MarkReplayed(topic string, ids ...string) error
+
+	// Delete removes the dead letter of the topic.
+	Delete(topic string, id string) error
+}
+
+var _ DeadLetterStore = (*MemoryDeadLetterStore)(nil)
+
+// MemoryDeadLetterStore is a process-local DeadLetterStore, useful for
+// tests and buses without a durable store.
+type MemoryDeadLetterStore struct {
+	mu      sync.Mutex
+	letters map[string][]DeadLetter
+}
+
+func NewMemoryDeadLetterStore() *MemoryDeadLetterStore {
+	return &MemoryDeadLetterStore{letters: map[string][]DeadLetter{}}
+}
+
+func (m *MemoryDeadLetterStore) Store(msg Message, reason error) (string, error) {
+	var letter = DeadLetter{
+		Id:       nxid.New().String(),
+		Message:  msg,
+		StoredAt: time.Now(),
+	}
+	if reason != nil {
+		letter.Reason = reason.Error()
+	}
+
+	// the reply future is not part of the dead-lettered message.
+	letter.Message.Future = nil
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var topic = msg.Topic.String()
+	m.letters[topic] = append(m.letters[topic], letter)
+	return letter.Id, nil
+}
+
+func (m *MemoryDeadLetterStore) List(query DeadLetterQuery) ([]DeadLetter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var letters []DeadLetter
+	for _, letter := range m.letters[query.Topic] {
+		if query.matches(letter) {
+			letters = append(letters, letter)
+		}
+	}
+	return letters, nil
+}
+
+func (m *MemoryDeadLetterStore) MarkReplayed(topic string, ids ...string) error {
+	var replayed = make(map[string]bool, len(ids))
+	for _, id := range ids {
+		replayed[id] = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var letters = m.letters[topic]
+	for index := range letters {
+		if replayed[letters[index].Id] {
+			letters[index].Replayed = true
+		}
+	}
+	return nil
+}
+
+func (m *MemoryDeadLetterStore) Delete(topic string, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var letters = m.letters[topic]
+	for index, letter := range letters {
+		if letter.Id == id {
+			m.letters[topic] = append(letters[:index:index], letters[index+1:]...)
+			return nil
+		}
+	}
+	return nerror.New("no dead letter %q for topic %q", id, topic)
+}
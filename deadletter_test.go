@@ -0,0 +1,57 @@
+package sabuhp
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryDeadLetterStore(t *testing.T) {
+	var store = NewMemoryDeadLetterStore()
+
+	var first = BasicMsg(T("orders"), "first", "me")
+	var second = BasicMsg(T("orders"), "second", "me")
+	var other = BasicMsg(T("users"), "other", "me")
+
+	var firstId, firstErr = store.Store(first, fmt.Errorf("bad first"))
+	require.NoError(t, firstErr)
+	var secondId, secondErr = store.Store(second, fmt.Errorf("bad second"))
+	require.NoError(t, secondErr)
+	var _, otherErr = store.Store(other, nil)
+	require.NoError(t, otherErr)
+
+	var letters, listErr = store.List(DeadLetterQuery{Topic: "orders"})
+	require.NoError(t, listErr)
+	require.Len(t, letters, 2)
+	require.Equal(t, firstId, letters[0].Id)
+	require.Equal(t, "first", string(letters[0].Message.Bytes))
+	require.Equal(t, "bad first", letters[0].Reason)
+	require.Equal(t, secondId, letters[1].Id)
+
+	letters, listErr = store.List(DeadLetterQuery{Topic: "orders", Match: func(letter DeadLetter) bool {
+		return letter.Reason == "bad second"
+	}})
+	require.NoError(t, listErr)
+	require.Len(t, letters, 1)
+	require.Equal(t, secondId, letters[0].Id)
+
+	// replayed dead letters are only listed on request.
+	require.NoError(t, store.MarkReplayed("orders", firstId))
+	letters, listErr = store.List(DeadLetterQuery{Topic: "orders"})
+	require.NoError(t, listErr)
+	require.Len(t, letters, 1)
+
+	letters, listErr = store.List(DeadLetterQuery{Topic: "orders", IncludeReplayed: true})
+	require.NoError(t, listErr)
+	require.Len(t, letters, 2)
+	require.True(t, letters[0].Replayed)
+
+	require.NoError(t, store.Delete("orders", secondId))
+	require.Error(t, store.Delete("orders", secondId))
+
+	letters, listErr = store.List(DeadLetterQuery{Topic: "orders", IncludeReplayed: true})
+	require.NoError(t, listErr)
+	require.Len(t, letters, 1)
+	require.Equal(t, firstId, letters[0].Id)
+}
@@ -0,0 +1,121 @@
+package sabuhp
+
+import (
+	"sync"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+)
+
+var _ MessageBus = (*DebouncingPublisher)(nil)
+
+// ErrMessageSuperseded is set on the future of a message a
+// DebouncingPublisher dropped for a later message of the same key.
+var ErrMessageSuperseded = nerror.New("message superseded by a later message")
+
+// DebounceKeyFunc returns the key messages are coalesced by.
+type DebounceKeyFunc func(msg Message) string
+
+// DebounceByTopic coalesces messages of the same topic, it's the default
+// DebounceKeyFunc of a DebouncingPublisher.
+func DebounceByTopic(msg Message) string {
+	return msg.Topic.String()
+}
+
+// DebouncingPublisher wraps a MessageBus, coalescing bursts of messages
+// with the same key into one publish. A message is held for the window,
+// a later message of the same key within the window replaces it and
+// restarts the window, so only the latest message of a burst is sent
+// once the key went quiet.
+//
+// SendForReply and Listen are not debounced.
+type DebouncingPublisher struct {
+	bus    MessageBus
+	window time.Duration
+	key    DebounceKeyFunc
+
+	mu      sync.Mutex
+	pending map[string]*debounced
+}
+
+type debounced struct {
+	msg   Message
+	timer *time.Timer
+}
+
+// NewDebouncingPublisher returns a new DebouncingPublisher holding messages
+// for the window, keyed by the key function or by topic if key is nil.
+func NewDebouncingPublisher(bus MessageBus, window time.Duration, key DebounceKeyFunc) *DebouncingPublisher {
+	if key == nil {
+		key = DebounceByTopic
+	}
+	return &DebouncingPublisher{
+		bus:     bus,
+		window:  window,
+		key:     key,
+		pending: map[string]*debounced{},
+	}
+}
+
+func (d *DebouncingPublisher) Send(data ...Message) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, msg := range data {
+		var key = d.key(msg)
+		if current, ok := d.pending[key]; ok && current.timer.Stop() {
+			supersede(current.msg)
+			current.msg = msg
+			current.timer.Reset(d.window)
+			continue
+		}
+
+		var next = &debounced{msg: msg}
+		next.timer = time.AfterFunc(d.window, func() {
+			d.flush(key, next)
+		})
+		d.pending[key] = next
+	}
+}
+
+// Flush sends all held messages immediately.
+func (d *DebouncingPublisher) Flush() {
+	d.mu.Lock()
+	var messages = make([]Message, 0, len(d.pending))
+	for key, current := range d.pending {
+		if current.timer.Stop() {
+			messages = append(messages, current.msg)
+		}
+		delete(d.pending, key)
+	}
+	d.mu.Unlock()
+
+	if len(messages) > 0 {
+		d.bus.Send(messages...)
+	}
+}
+
+func (d *DebouncingPublisher) flush(key string, current *debounced) {
+	d.mu.Lock()
+	if d.pending[key] == current {
+		delete(d.pending, key)
+	}
+	var msg = current.msg
+	d.mu.Unlock()
+
+	d.bus.Send(msg)
+}
+
+func (d *DebouncingPublisher) SendForReply(tm time.Duration, fromTopic Topic, replyGroup string, data ...Message) *ReplyFuture {
+	return d.bus.SendForReply(tm, fromTopic, replyGroup, data...)
+}
+
+func (d *DebouncingPublisher) Listen(topic string, grp string, handler TransportResponse) Channel {
+	return d.bus.Listen(topic, grp, handler)
+}
+
+func supersede(msg Message) {
+	if msg.Future != nil {
+		msg.Future.WithError(ErrMessageSuperseded)
+	}
+}
@@ -0,0 +1,46 @@
+package sabuhp
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebouncingPublisher(t *testing.T) {
+	var published = make(chan Message, 10)
+
+	var mb BusBuilder
+	mb.SendFunc = func(data ...Message) {
+		for _, msg := range data {
+			published <- msg
+		}
+	}
+
+	var window = 100 * time.Millisecond
+	var debouncer = NewDebouncingPublisher(&mb, window, func(msg Message) string {
+		return msg.Metadata["key"]
+	})
+
+	for i := 1; i <= 5; i++ {
+		var msg = BasicMsg(T("ui.state"), fmt.Sprintf("update-%d", i), "me")
+		msg.Metadata = Params{"key": "panel"}
+		debouncer.Send(msg)
+	}
+
+	require.Len(t, published, 0)
+
+	select {
+	case msg := <-published:
+		require.Equal(t, "update-5", string(msg.Bytes))
+	case <-time.After(5 * window):
+		require.Fail(t, "latest update should be published after the window")
+	}
+
+	select {
+	case msg := <-published:
+		require.Fail(t, "only the latest update should be published", string(msg.Bytes))
+	case <-time.After(2 * window):
+	}
+}
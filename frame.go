@@ -0,0 +1,35 @@
+package sabuhp
+
+import "context"
+
+// Frame is the raw transport frame a message was decoded from, e.g the
+// redis stream entry holding it.
+type Frame struct {
+	// Transport names the transport of the frame, e.g "redis-stream".
+	Transport string
+
+	// Id is the transport's own id of the frame, e.g the redis stream
+	// entry id or the SSE event id, empty if the transport has none.
+	Id string
+
+	// Channel is the stream or channel the frame was read from.
+	Channel string
+
+	// Bytes are the raw bytes of the frame before decoding.
+	Bytes []byte
+}
+
+type frameKey struct{}
+
+// WithFrame returns a context carrying the raw frame of the message
+// handled with it.
+func WithFrame(ctx context.Context, frame Frame) context.Context {
+	return context.WithValue(ctx, frameKey{}, frame)
+}
+
+// RawFrame returns the raw frame of the message handled with the context,
+// if the transport delivering it was asked to include it.
+func RawFrame(ctx context.Context) (Frame, bool) {
+	var frame, ok = ctx.Value(frameKey{}).(Frame)
+	return frame, ok
+}
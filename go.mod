@@ -5,9 +5,11 @@ go 1.15
 require (
 	github.com/ewe-studios/websocket v1.4.5
 	github.com/go-redis/redis/v8 v8.4.8
+	github.com/golang/protobuf v1.4.3
 	github.com/influx6/npkg v0.8.9
 	github.com/stretchr/testify v1.6.1
 	github.com/vmihailenco/msgpack/v5 v5.0.0-beta.4
 	golang.org/x/crypto v0.0.0-20200820211705-5c72a883971a
 	golang.org/x/sync v0.0.0-20200625203802-6e8e738ad208
+	google.golang.org/protobuf v1.25.0
 )
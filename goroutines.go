@@ -0,0 +1,103 @@
+package sabuhp
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/influx6/npkg/nerror"
+)
+
+// ErrGoroutineLimit is returned when a goroutine can not be started as
+// its GoroutineLimiter has no slot left.
+var ErrGoroutineLimit = nerror.New("goroutine limit reached")
+
+// GoroutineLimiter is a semaphore capping the goroutines spawned by one
+// or more buses sharing it, so a spike of subscriptions or requests can
+// not exhaust the scheduler. A slot is acquired before starting a
+// goroutine and released once it returns.
+type GoroutineLimiter struct {
+	slots  chan struct{}
+	active int64
+
+	// acquireMu makes callers of AcquireN take turns.
+	acquireMu sync.Mutex
+}
+
+// NewGoroutineLimiter returns a limiter allowing max goroutines at once, a
+// max of zero or less only counts them.
+func NewGoroutineLimiter(max int) *GoroutineLimiter {
+	var limiter = &GoroutineLimiter{}
+	if max > 0 {
+		limiter.slots = make(chan struct{}, max)
+	}
+	return limiter
+}
+
+// Max returns the number of goroutines allowed at once, 0 if unlimited.
+func (g *GoroutineLimiter) Max() int {
+	return cap(g.slots)
+}
+
+// Active returns the number of goroutines holding a slot.
+func (g *GoroutineLimiter) Active() int {
+	return int(atomic.LoadInt64(&g.active))
+}
+
+// TryAcquire acquires a slot if one is free, returning ErrGoroutineLimit
+// otherwise.
+func (g *GoroutineLimiter) TryAcquire() error {
+	if g.slots != nil {
+		select {
+		case g.slots <- struct{}{}:
+		default:
+			return ErrGoroutineLimit
+		}
+	}
+	atomic.AddInt64(&g.active, 1)
+	return nil
+}
+
+// Acquire blocks till a slot is free or the context is done.
+func (g *GoroutineLimiter) Acquire(ctx context.Context) error {
+	if g.slots != nil {
+		select {
+		case g.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nerror.WrapOnly(ctx.Err())
+		}
+	}
+	atomic.AddInt64(&g.active, 1)
+	return nil
+}
+
+// AcquireN blocks till n slots are free or the context is done, acquiring
+// all of them or none. Callers of AcquireN take turns, so two of them never
+// each hold part of the slots the other waits for. It fails with
+// ErrGoroutineLimit if the limiter has fewer than n slots.
+func (g *GoroutineLimiter) AcquireN(ctx context.Context, n int) error {
+	if g.slots != nil && n > cap(g.slots) {
+		return nerror.WrapOnly(ErrGoroutineLimit)
+	}
+
+	g.acquireMu.Lock()
+	defer g.acquireMu.Unlock()
+
+	for acquired := 0; acquired < n; acquired++ {
+		if acquireErr := g.Acquire(ctx); acquireErr != nil {
+			for ; acquired > 0; acquired-- {
+				g.Release()
+			}
+			return acquireErr
+		}
+	}
+	return nil
+}
+
+// Release frees a slot acquired with TryAcquire or Acquire.
+func (g *GoroutineLimiter) Release() {
+	atomic.AddInt64(&g.active, -1)
+	if g.slots != nil {
+		<-g.slots
+	}
+}
@@ -0,0 +1,59 @@
+package sabuhp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoroutineLimiter(t *testing.T) {
+	var limiter = NewGoroutineLimiter(2)
+	require.Equal(t, 2, limiter.Max())
+
+	require.NoError(t, limiter.TryAcquire())
+	require.NoError(t, limiter.Acquire(context.Background()))
+	require.Equal(t, 2, limiter.Active())
+
+	require.True(t, nerror.IsAny(limiter.TryAcquire(), ErrGoroutineLimit))
+
+	var ctx, canceler = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer canceler()
+	require.Error(t, limiter.Acquire(ctx))
+
+	limiter.Release()
+	require.Equal(t, 1, limiter.Active())
+	require.NoError(t, limiter.TryAcquire())
+
+	// an unlimited limiter only counts.
+	var unlimited = NewGoroutineLimiter(0)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, unlimited.TryAcquire())
+	}
+	require.Equal(t, 100, unlimited.Active())
+}
+
+func TestGoroutineLimiter_AcquireN(t *testing.T) {
+	var limiter = NewGoroutineLimiter(3)
+	require.True(t, nerror.IsAny(limiter.AcquireN(context.Background(), 4), ErrGoroutineLimit))
+
+	require.NoError(t, limiter.TryAcquire())
+	require.NoError(t, limiter.TryAcquire())
+
+	// only one slot is free, none is kept on failure.
+	var ctx, canceler = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer canceler()
+	require.Error(t, limiter.AcquireN(ctx, 2))
+	require.Equal(t, 2, limiter.Active())
+
+	var acquired = make(chan error, 1)
+	go func() {
+		acquired <- limiter.AcquireN(context.Background(), 2)
+	}()
+
+	limiter.Release()
+	require.NoError(t, <-acquired)
+	require.Equal(t, 3, limiter.Active())
+}
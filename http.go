@@ -110,12 +110,29 @@ type HttpDecoderImpl struct {
 	Codec       Codec
 	Logger      Logger
 	MaxBodySize int64
+
+	// MaxParts when above zero is the maximum number of parts
+	// a multipart request may contain.
+	MaxParts int
+
+	// MaxTotalPartBytes when above zero is the maximum combined
+	// size of all parts of a multipart request.
+	MaxTotalPartBytes int64
 }
 
 func NewHttpDecoderImpl(codec Codec, logger Logger, maxBody int64) *HttpDecoderImpl {
 	return &HttpDecoderImpl{Codec: codec, Logger: logger, MaxBodySize: maxBody}
 }
 
+// ContentTypes returns the content types decoded by the Codec, nil if
+// the Codec is not ContentTyped.
+func (r *HttpDecoderImpl) ContentTypes() []string {
+	if typed, ok := r.Codec.(ContentTyped); ok {
+		return typed.ContentTypes()
+	}
+	return nil
+}
+
 func (r *HttpDecoderImpl) Decode(req *http.Request, params Params) (Message, error) {
 	var contentType = req.Header.Get("Content-Type")
 	var contentTypeLower = strings.ToLower(contentType)
@@ -147,6 +164,7 @@ func (r *HttpDecoderImpl) Decode(req *http.Request, params Params) (Message, err
 			partId = nxid.New()
 		)
 
+		var totalPartBytes int64
 		var messages = make([]Message, 0, 3)
 		var readBuffer bytes.Buffer
 		for {
@@ -158,12 +176,30 @@ func (r *HttpDecoderImpl) Decode(req *http.Request, params Params) (Message, err
 				return Message{}, nerror.WrapOnly(partErr)
 			}
 
+			if r.MaxParts > 0 && len(messages) >= r.MaxParts {
+				r.logPartsLimit(ErrTooManyParts, requestPath)
+				return Message{}, ErrTooManyParts
+			}
+
+			var partReader io.Reader = part
+			if r.MaxTotalPartBytes > 0 {
+				// read at most one byte past the remaining budget, enough to
+				// know the limit was crossed without buffering the rest.
+				partReader = io.LimitReader(part, r.MaxTotalPartBytes-totalPartBytes+1)
+			}
+
 			readBuffer.Reset()
-			var _, readErr = readBuffer.ReadFrom(part)
+			var _, readErr = readBuffer.ReadFrom(partReader)
 			if readErr != nil {
 				return Message{}, nerror.WrapOnly(readErr)
 			}
 
+			totalPartBytes += int64(readBuffer.Len())
+			if r.MaxTotalPartBytes > 0 && totalPartBytes > r.MaxTotalPartBytes {
+				r.logPartsLimit(ErrPartsTooLarge, requestPath)
+				return Message{}, ErrPartsTooLarge
+			}
+
 			var messageBytes = make([]byte, readBuffer.Len())
 			_ = copy(messageBytes, readBuffer.Bytes())
 
@@ -280,6 +316,37 @@ func (r *HttpDecoderImpl) Decode(req *http.Request, params Params) (Message, err
 	return message, nil
 }
 
+func (r *HttpDecoderImpl) logPartsLimit(err error, path string) {
+	njson.Log(r.Logger).New().
+		LWarn().
+		Message("rejected multipart request").
+		String("path", path).
+		Int("max_parts", r.MaxParts).
+		Int64("max_total_part_bytes", r.MaxTotalPartBytes).
+		Error("error", err).
+		End()
+}
+
+var (
+	// ErrTooManyParts is returned when a multipart request has more parts
+	// than HttpDecoderImpl.MaxParts.
+	ErrTooManyParts = &PartsLimitErr{Err: errors.New("http: multipart request has too many parts")}
+
+	// ErrPartsTooLarge is returned when the parts of a multipart request
+	// exceed HttpDecoderImpl.MaxTotalPartBytes.
+	ErrPartsTooLarge = &PartsLimitErr{Err: errors.New("http: multipart request parts too large")}
+)
+
+// PartsLimitErr is the error type returned when a multipart request
+// exceeds the limits of a HttpDecoderImpl.
+type PartsLimitErr struct {
+	Err error
+}
+
+func (p *PartsLimitErr) Error() string {
+	return p.Err.Error()
+}
+
 // MaxBytesReader is similar to io.LimitReader but is intended for
 // limiting the size of incoming request bodies. In contrast to
 // io.LimitReader, MaxBytesReader's result is a ReadCloser, returns a
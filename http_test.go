@@ -3,8 +3,11 @@ package sabuhp
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"log"
+	"mime/multipart"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/influx6/npkg/nerror"
@@ -57,3 +60,58 @@ func TestHttpCodec(t *testing.T) {
 	require.Equal(t, "plain/html", salesRequestMessage.ContentType)
 	require.Equal(t, "alex", string(salesRequestMessage.Bytes))
 }
+
+func createMultipartRequest(t *testing.T, parts ...string) *http.Request {
+	var body bytes.Buffer
+	var writer = multipart.NewWriter(&body)
+	for index, part := range parts {
+		var partWriter, partErr = writer.CreateFormFile("file", fmt.Sprintf("file_%d", index))
+		require.NoError(t, partErr)
+
+		var _, writeErr = partWriter.Write([]byte(part))
+		require.NoError(t, writeErr)
+	}
+	require.NoError(t, writer.Close())
+
+	var req, reqErr = http.NewRequest("POST", "/upload", &body)
+	require.NoError(t, reqErr)
+
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestHttpCodec_Multipart(t *testing.T) {
+	var logger = new(LoggerPub)
+	var decoder = NewHttpDecoderImpl(&jsonCodec{}, logger, -1)
+	decoder.MaxParts = 3
+	decoder.MaxTotalPartBytes = 12
+
+	var message, messageErr = decoder.Decode(createMultipartRequest(t, "alex", "wale", "tobi"), Params{})
+	require.NoError(t, messageErr)
+	require.Equal(t, "alex", string(message.Bytes))
+	require.Len(t, message.Parts, 3)
+}
+
+func TestHttpCodec_MaxParts(t *testing.T) {
+	var logger = new(LoggerPub)
+	var decoder = NewHttpDecoderImpl(&jsonCodec{}, logger, -1)
+	decoder.MaxParts = 2
+
+	var _, messageErr = decoder.Decode(createMultipartRequest(t, "a", "b", "c"), Params{})
+	require.Error(t, messageErr)
+	require.Equal(t, ErrTooManyParts, messageErr)
+}
+
+func TestHttpCodec_MaxTotalPartBytes(t *testing.T) {
+	var logger = new(LoggerPub)
+	var decoder = NewHttpDecoderImpl(&jsonCodec{}, logger, -1)
+	decoder.MaxTotalPartBytes = 10
+
+	var _, messageErr = decoder.Decode(createMultipartRequest(t, "alex", "wale", "tobi"), Params{})
+	require.Error(t, messageErr)
+	require.Equal(t, ErrPartsTooLarge, messageErr)
+
+	var _, singleErr = decoder.Decode(createMultipartRequest(t, strings.Repeat("x", 1<<20)), Params{})
+	require.Error(t, singleErr)
+	require.Equal(t, ErrPartsTooLarge, singleErr)
+}
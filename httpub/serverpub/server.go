@@ -22,6 +22,7 @@ import (
 
 const (
 	shutdownDuration = time.Second * 30
+	shutdownDelay    = time.Second * 20
 )
 
 var (
@@ -64,6 +65,16 @@ type Server struct {
 	TLSConfig       *tls.Config
 	Man             *autocert.Manager
 
+	// ShutdownDelay is how long the server keeps serving once marked
+	// unhealthy before shutting down, letting load balancers stop
+	// routing to it.
+	ShutdownDelay time.Duration
+
+	// OnShutdown functions are called once shutdown begins, to end
+	// long-lived handlers (e.g event streams) which would otherwise
+	// block the shutdown till its timeout.
+	OnShutdown []func()
+
 	waiter   sync.WaitGroup
 	closer   chan struct{}
 	server   *http.Server
@@ -83,6 +94,7 @@ func NewServer(handler http.Handler, shutdown ...time.Duration) *Server {
 	server.Health = &health
 	server.Handler = handler
 	server.ShutdownTimeout = shutdownDur
+	server.ShutdownDelay = shutdownDelay
 	return &server
 }
 
@@ -100,6 +112,7 @@ func NewServerWithTLS(http2 bool, tconfig *tls.Config, handler http.Handler, shu
 	server.Handler = handler
 	server.TLSConfig = tconfig
 	server.ShutdownTimeout = shutdownDur
+	server.ShutdownDelay = shutdownDelay
 	return &server
 }
 
@@ -118,6 +131,7 @@ func NewServerWithCertMan(http2 bool, man *autocert.Manager, handler http.Handle
 	server.Health = &health
 	server.Handler = handler
 	server.ShutdownTimeout = shutdownDur
+	server.ShutdownDelay = shutdownDelay
 	return &server
 }
 
@@ -151,6 +165,10 @@ func (s *Server) Listen(ctx context.Context, addr string) error {
 		TLSConfig:      tlsConfig,
 	}
 
+	for _, onShutdown := range s.OnShutdown {
+		server.RegisterOnShutdown(onShutdown)
+	}
+
 	s.Health.SetHealthy()
 
 	var errs = make(chan error, 1)
@@ -196,7 +214,7 @@ func (s *Server) Listen(ctx context.Context, addr string) error {
 
 func (s *Server) gracefulShutdown(server *http.Server) {
 	s.Health.SetUnhealthy()
-	time.Sleep(20 * time.Second)
+	time.Sleep(s.ShutdownDelay)
 	var ctx, cancel = context.WithTimeout(context.Background(), s.ShutdownTimeout)
 	if err := server.Shutdown(ctx); err != nil {
 		log.Printf("Close server returned error: %+q", nerror.WrapOnly(err))
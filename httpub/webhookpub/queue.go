@@ -0,0 +1,291 @@
+package webhookpub
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/influx6/npkg/nerror"
+)
+
+const (
+	queueEntryExt     = ".entry"
+	queueEntryTemp    = ".tmp"
+	queueEntryCorrupt = ".corrupt"
+)
+
+var (
+	// ErrQueueFull is returned when pushing to a full Queue with the
+	// OverflowReject policy.
+	ErrQueueFull = nerror.New("webhook queue is full")
+
+	// ErrCorruptEntry is returned when reading a queued entry which can not
+	// be decoded.
+	ErrCorruptEntry = nerror.New("corrupt webhook queue entry")
+)
+
+// OverflowPolicy decides what a full Queue does with a pushed entry.
+type OverflowPolicy int
+
+const (
+	// OverflowReject rejects the pushed entry with ErrQueueFull.
+	OverflowReject OverflowPolicy = iota
+
+	// OverflowDropOldest drops the oldest entry to make room for the
+	// pushed one.
+	OverflowDropOldest
+)
+
+// QueueEntry is a message awaiting delivery, kept as the body sent to
+// the webhook.
+type QueueEntry struct {
+	Id    string
+	Topic string
+	Body  []byte
+
+	// Attempts is the number of failed redeliveries of the entry.
+	Attempts int
+}
+
+// Queue holds the messages a WebHook failed to deliver till the
+// webhook recovers, oldest first.
+type Queue interface {
+	// Push adds the entry to the queue, assigning its id. An entry dropped
+	// to make room for it by the overflow policy of the queue is returned.
+	Push(entry QueueEntry) (*QueueEntry, error)
+
+	// Peek returns the oldest entry, false if the queue is empty.
+	Peek() (QueueEntry, bool, error)
+
+	// Remove removes the entry with the id.
+	Remove(id string) error
+
+	// RecordAttempt records a failed redelivery of the entry with the id,
+	// returning its attempts so far.
+	RecordAttempt(id string) (int, error)
+
+	// Len returns the number of entries of the queue.
+	Len() int
+}
+
+var _ Queue = (*FileQueue)(nil)
+
+// FileQueue is a Queue persisting each entry as a file of a directory,
+// so undelivered messages survive restarts. An entry is written and synced
+// to a temporary file then renamed into place, a crash never leaves a
+// partial entry behind.
+//
+// An entry which can not be decoded is moved aside with a .corrupt
+// extension rather than blocking the entries queued after it.
+type FileQueue struct {
+	dir      string
+	maxSize  int
+	overflow OverflowPolicy
+
+	mu   sync.Mutex
+	ids  []string
+	next uint64
+}
+
+// NewFileQueue returns a FileQueue of the directory holding up to maxSize
+// entries, a maxSize of zero is unbounded. Entries left in the directory
+// by an earlier FileQueue are loaded, temporary files of writes it did not
+// complete are removed.
+func NewFileQueue(dir string, maxSize int, overflow OverflowPolicy) (*FileQueue, error) {
+	if mkdirErr := os.MkdirAll(dir, 0700); mkdirErr != nil {
+		return nil, nerror.WrapOnly(mkdirErr)
+	}
+
+	var files, readErr = ioutil.ReadDir(dir)
+	if readErr != nil {
+		return nil, nerror.WrapOnly(readErr)
+	}
+
+	var queue = &FileQueue{dir: dir, maxSize: maxSize, overflow: overflow}
+	for _, file := range files {
+		var name = file.Name()
+		if strings.HasSuffix(name, queueEntryTemp) {
+			if removeErr := os.Remove(filepath.Join(dir, name)); removeErr != nil && !os.IsNotExist(removeErr) {
+				return nil, nerror.WrapOnly(removeErr)
+			}
+			continue
+		}
+		if !strings.HasSuffix(name, queueEntryExt) {
+			continue
+		}
+
+		var id = strings.TrimSuffix(name, queueEntryExt)
+		var seq, parseErr = strconv.ParseUint(id, 10, 64)
+		if parseErr != nil {
+			continue
+		}
+		if seq >= queue.next {
+			queue.next = seq + 1
+		}
+		queue.ids = append(queue.ids, id)
+	}
+
+	// ids are zero padded, so they sort in the order of their entries.
+	sort.Strings(queue.ids)
+	return queue, nil
+}
+
+func (f *FileQueue) path(id string) string {
+	return filepath.Join(f.dir, id+queueEntryExt)
+}
+
+func (f *FileQueue) Push(entry QueueEntry) (*QueueEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var dropped *QueueEntry
+	if f.maxSize > 0 && len(f.ids) >= f.maxSize {
+		if f.overflow != OverflowDropOldest {
+			return nil, nerror.WrapOnly(ErrQueueFull)
+		}
+
+		var oldest, ok, readErr = f.oldest()
+		if readErr != nil {
+			return nil, readErr
+		}
+
+		// moving corrupt entries aside may have made room already.
+		if ok && len(f.ids) >= f.maxSize {
+			if removeErr := f.remove(oldest.Id); removeErr != nil {
+				return nil, removeErr
+			}
+			dropped = &oldest
+		}
+	}
+
+	entry.Id = fmt.Sprintf("%020d", f.next)
+	if writeErr := f.write(entry); writeErr != nil {
+		return dropped, writeErr
+	}
+
+	f.next++
+	f.ids = append(f.ids, entry.Id)
+	return dropped, nil
+}
+
+func (f *FileQueue) Peek() (QueueEntry, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.oldest()
+}
+
+func (f *FileQueue) Remove(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.remove(id)
+}
+
+func (f *FileQueue) RecordAttempt(id string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var entry, readErr = f.read(id)
+	if readErr != nil {
+		return 0, readErr
+	}
+
+	entry.Attempts++
+	if writeErr := f.write(entry); writeErr != nil {
+		return 0, writeErr
+	}
+	return entry.Attempts, nil
+}
+
+func (f *FileQueue) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.ids)
+}
+
+// oldest returns the oldest readable entry, moving corrupt entries aside
+// and forgetting those removed from the directory.
+func (f *FileQueue) oldest() (QueueEntry, bool, error) {
+	for len(f.ids) > 0 {
+		var id = f.ids[0]
+
+		var entry, readErr = f.read(id)
+		switch {
+		case readErr == nil:
+			return entry, true, nil
+		case os.IsNotExist(nerror.UnwrapDeep(readErr)):
+			f.ids = f.ids[1:]
+		case nerror.IsAny(readErr, ErrCorruptEntry):
+			if renameErr := os.Rename(f.path(id), filepath.Join(f.dir, id+queueEntryCorrupt)); renameErr != nil {
+				return QueueEntry{}, false, nerror.WrapOnly(renameErr)
+			}
+			f.ids = f.ids[1:]
+		default:
+			return QueueEntry{}, false, readErr
+		}
+	}
+	return QueueEntry{}, false, nil
+}
+
+func (f *FileQueue) read(id string) (QueueEntry, error) {
+	var entry QueueEntry
+
+	var data, readErr = ioutil.ReadFile(f.path(id))
+	if readErr != nil {
+		return entry, nerror.WrapOnly(readErr)
+	}
+	if decodeErr := json.Unmarshal(data, &entry); decodeErr != nil {
+		return entry, nerror.Wrap(ErrCorruptEntry, "entry %s: %s", id, decodeErr)
+	}
+	return entry, nil
+}
+
+// write persists the entry to a temporary file synced to disk before
+// renaming it into place, so the entry survives a crash once renamed.
+func (f *FileQueue) write(entry QueueEntry) error {
+	var data, encodeErr = json.Marshal(entry)
+	if encodeErr != nil {
+		return nerror.WrapOnly(encodeErr)
+	}
+
+	var path = f.path(entry.Id)
+	var file, openErr = os.OpenFile(path+queueEntryTemp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if openErr != nil {
+		return nerror.WrapOnly(openErr)
+	}
+	if _, writeErr := file.Write(data); writeErr != nil {
+		_ = file.Close()
+		return nerror.WrapOnly(writeErr)
+	}
+	if syncErr := file.Sync(); syncErr != nil {
+		_ = file.Close()
+		return nerror.WrapOnly(syncErr)
+	}
+	if closeErr := file.Close(); closeErr != nil {
+		return nerror.WrapOnly(closeErr)
+	}
+	if renameErr := os.Rename(path+queueEntryTemp, path); renameErr != nil {
+		return nerror.WrapOnly(renameErr)
+	}
+	return nil
+}
+
+func (f *FileQueue) remove(id string) error {
+	if removeErr := os.Remove(f.path(id)); removeErr != nil && !os.IsNotExist(removeErr) {
+		return nerror.WrapOnly(removeErr)
+	}
+
+	for index, queued := range f.ids {
+		if queued == id {
+			f.ids = append(f.ids[:index:index], f.ids[index+1:]...)
+			break
+		}
+	}
+	return nil
+}
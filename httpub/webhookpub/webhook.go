@@ -0,0 +1,441 @@
+package webhookpub
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/njson"
+
+	"github.com/ewe-studios/sabuhp"
+	"github.com/ewe-studios/sabuhp/codecs"
+	"github.com/ewe-studios/sabuhp/utils"
+)
+
+const (
+	// SignatureHeader holds the hex encoded HMAC-SHA256 signature of the
+	// request body when a secret is configured.
+	SignatureHeader = "X-Sabuhp-Signature"
+
+	// TopicHeader holds the topic of the delivered message.
+	TopicHeader = "X-Sabuhp-Topic"
+
+	signaturePrefix = "sha256="
+)
+
+var (
+	// DefaultMaxRetries is the number of retries after a failed first
+	// delivery of a message before it is queued or dead-lettered, when
+	// Config.MaxRetries is unset.
+	DefaultMaxRetries = 5
+
+	// DefaultQueueInterval is the interval at which queued messages are
+	// redelivered, when Config.QueueInterval is unset.
+	DefaultQueueInterval = 5 * time.Second
+
+	// DefaultMaxRedeliveries keeps a queued message for an hour of
+	// redeliveries at the DefaultQueueInterval.
+	DefaultMaxRedeliveries = 720
+
+	// ErrDeliveryFailed is returned when a message could not be delivered
+	// to the webhook after exhausting the retry budget.
+	ErrDeliveryFailed = nerror.New("failed to deliver message to webhook")
+
+	// ErrRedeliveriesExhausted is the reason queued messages which failed
+	// Config.MaxRedeliveries redeliveries are dead-lettered with.
+	ErrRedeliveriesExhausted = nerror.New("exhausted webhook redelivery attempts")
+)
+
+// DeadLetterFunc is called with a message which failed to be delivered
+// after all retries and the last error seen.
+type DeadLetterFunc func(msg sabuhp.Message, reason error)
+
+func linearBackOff(i int) time.Duration {
+	return time.Duration(i) * (10 * time.Millisecond)
+}
+
+type Config struct {
+	Ctx        context.Context
+	Logger     sabuhp.Logger
+	Codec      sabuhp.Codec
+	Client     sabuhp.HttpClient
+	URL        string
+	Method     string
+	Headers    http.Header
+	Secret     []byte
+	MaxRetries int
+	RetryFunc  sabuhp.RetryFunc
+	DeadLetter DeadLetterFunc
+
+	// Queue when set persists messages which exhausted their retries
+	// instead of dead-lettering them, they are redelivered oldest first
+	// every QueueInterval till the webhook accepts them. Messages the
+	// queue rejects or drops by its overflow policy are dead-lettered, as
+	// are queued messages the webhook rejects with a status retrying does
+	// not change (a 4xx other than 408 and 429) or which failed
+	// MaxRedeliveries redeliveries, so they do not block the queue.
+	Queue           Queue
+	QueueInterval   time.Duration
+	MaxRedeliveries int
+}
+
+func (c *Config) ensure() {
+	if c.Logger == nil {
+		panic("Config.Logger is required")
+	}
+	if c.Ctx == nil {
+		panic("Config.Ctx is required")
+	}
+	if len(c.URL) == 0 {
+		panic("Config.URL is required")
+	}
+	if c.Codec == nil {
+		c.Codec = &codecs.MessageJsonCodec{}
+	}
+	if c.Client == nil {
+		c.Client = utils.CreateDefaultHttpClient()
+	}
+	if len(c.Method) == 0 {
+		c.Method = http.MethodPost
+	}
+	if c.MaxRetries <= 0 {
+		c.MaxRetries = DefaultMaxRetries
+	}
+	if c.RetryFunc == nil {
+		c.RetryFunc = linearBackOff
+	}
+	if c.QueueInterval <= 0 {
+		c.QueueInterval = DefaultQueueInterval
+	}
+	if c.MaxRedeliveries <= 0 {
+		c.MaxRedeliveries = DefaultMaxRedeliveries
+	}
+}
+
+var _ sabuhp.TransportResponse = (*WebHook)(nil)
+
+// WebHook is a delivery sink which POSTs every message it handles
+// to a configured url.
+//
+// A WebHook is a sabuhp.TransportResponse, so it can be attached to
+// any topic of a sabuhp.MessageBus through WebHook.Listen.
+type WebHook struct {
+	config Config
+	waiter sync.WaitGroup
+}
+
+func NewWebHook(config Config) *WebHook {
+	config.ensure()
+	var hook = &WebHook{config: config}
+	if config.Queue != nil {
+		hook.waiter.Add(1)
+		go hook.manageQueue()
+	}
+	return hook
+}
+
+// Wait blocks till the redelivery of queued messages stopped once
+// Config.Ctx is done.
+func (w *WebHook) Wait() {
+	w.waiter.Wait()
+}
+
+// Listen subscribes the webhook to the giving topic and group on the bus.
+func (w *WebHook) Listen(bus sabuhp.MessageBus, topic string, grp string) sabuhp.Channel {
+	return bus.Listen(topic, grp, w)
+}
+
+// Handle implements the sabuhp.TransportResponse interface.
+//
+// Messages which failed delivery are dead-lettered, hence
+// the returned error will still acknowledge the message.
+func (w *WebHook) Handle(ctx context.Context, message sabuhp.Message, _ sabuhp.Transport) sabuhp.MessageErr {
+	if err := w.Deliver(ctx, message); err != nil {
+		return sabuhp.WrapErr(err, true)
+	}
+	return nil
+}
+
+// Deliver encodes and sends the message to the webhook url, retrying
+// on failure (including non-2xx responses) till the retry budget is
+// exhausted, after which the message is queued for redelivery with the
+// Config.Queue, if any, else handed to the Config.DeadLetter function.
+// A message rejected with a status retrying does not change, see
+// isRetryable, is dead-lettered right away.
+func (w *WebHook) Deliver(ctx context.Context, message sabuhp.Message) error {
+	var stack = njson.Log(w.config.Logger)
+
+	var body, encodeErr = w.config.Codec.Encode(message)
+	if encodeErr != nil {
+		var wrappedErr = nerror.WrapOnly(encodeErr)
+		stack.New().
+			LError().
+			Message("failed to encode message for webhook").
+			String("topic", message.Topic.String()).
+			Error("error", wrappedErr).
+			End()
+		return wrappedErr
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= w.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nerror.WrapOnly(ctx.Err())
+			case <-w.config.Ctx.Done():
+				return nerror.WrapOnly(w.config.Ctx.Err())
+			case <-time.After(w.config.RetryFunc(attempt)):
+			}
+		}
+
+		var err = w.post(ctx, message.Topic.String(), body)
+		if err != nil {
+			lastErr = err
+			stack.New().
+				LWarn().
+				Message("failed webhook delivery attempt").
+				String("topic", message.Topic.String()).
+				String("url", w.config.URL).
+				Int("attempt", attempt).
+				String("error", err.Error()).
+				End()
+			if !isRetryable(err) {
+				break
+			}
+			continue
+		}
+
+		return nil
+	}
+
+	if w.config.Queue != nil && isRetryable(lastErr) {
+		return w.enqueue(message, body, lastErr)
+	}
+
+	stack.New().
+		LError().
+		Message("failed webhook delivery, dead-lettering message").
+		String("topic", message.Topic.String()).
+		String("url", w.config.URL).
+		String("error", lastErr.Error()).
+		End()
+
+	if w.config.DeadLetter != nil {
+		w.config.DeadLetter(message, lastErr)
+	}
+
+	return nerror.WrapOnly(ErrDeliveryFailed)
+}
+
+// post sends the body of a message of the topic to the webhook url once.
+func (w *WebHook) post(ctx context.Context, topic string, body []byte) error {
+	var header = http.Header{}
+	for key, values := range w.config.Headers {
+		header[key] = values
+	}
+	header.Set(TopicHeader, topic)
+	if len(w.config.Secret) != 0 {
+		header.Set(SignatureHeader, Sign(w.config.Secret, body))
+	}
+
+	var _, response, err = utils.DoRequest(
+		ctx,
+		w.config.Client,
+		w.config.Method,
+		w.config.URL,
+		bytes.NewReader(body),
+		header,
+	)
+	if err != nil {
+		return err
+	}
+
+	_ = response.Body.Close()
+
+	njson.Log(w.config.Logger).New().
+		LInfo().
+		Message("delivered message to webhook").
+		String("topic", topic).
+		String("url", w.config.URL).
+		Int("response_status_code", response.StatusCode).
+		End()
+	return nil
+}
+
+// enqueue persists the message which failed delivery with lastErr to the
+// Config.Queue, dead-lettering it or the entry the queue dropped for it.
+func (w *WebHook) enqueue(message sabuhp.Message, body []byte, lastErr error) error {
+	var stack = njson.Log(w.config.Logger)
+
+	var dropped, pushErr = w.config.Queue.Push(QueueEntry{Topic: message.Topic.String(), Body: body})
+	if dropped != nil {
+		stack.New().
+			LError().
+			Message("webhook queue is full, dead-lettering oldest queued message").
+			String("topic", dropped.Topic).
+			String("url", w.config.URL).
+			End()
+
+		w.deadLetterEntry(*dropped, nerror.WrapOnly(ErrQueueFull))
+	}
+
+	if pushErr != nil {
+		stack.New().
+			LError().
+			Message("failed to queue message for webhook, dead-lettering message").
+			String("topic", message.Topic.String()).
+			String("url", w.config.URL).
+			Error("error", pushErr).
+			End()
+
+		if w.config.DeadLetter != nil {
+			w.config.DeadLetter(message, pushErr)
+		}
+		return nerror.WrapOnly(ErrDeliveryFailed)
+	}
+
+	stack.New().
+		LWarn().
+		Message("exhausted webhook delivery retries, queued message for redelivery").
+		String("topic", message.Topic.String()).
+		String("url", w.config.URL).
+		String("error", lastErr.Error()).
+		End()
+	return nil
+}
+
+func (w *WebHook) manageQueue() {
+	defer w.waiter.Done()
+
+	var ticker = time.NewTicker(w.config.QueueInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.config.Ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		w.redeliver()
+	}
+}
+
+// redeliver delivers the queued messages oldest first, stopping at the
+// first failure which may be retried so they are retried in order with
+// the next interval. Messages which can not be delivered are dead-lettered
+// and removed.
+func (w *WebHook) redeliver() {
+	var stack = njson.Log(w.config.Logger)
+	for w.config.Ctx.Err() == nil {
+		var entry, ok, peekErr = w.config.Queue.Peek()
+		if peekErr != nil {
+			stack.New().
+				LError().
+				Message("failed to read webhook queue").
+				Error("error", peekErr).
+				End()
+			return
+		}
+		if !ok {
+			return
+		}
+
+		if postErr := w.post(w.config.Ctx, entry.Topic, entry.Body); postErr != nil {
+			if isRetryable(postErr) {
+				var attempts, attemptErr = w.config.Queue.RecordAttempt(entry.Id)
+				if attemptErr != nil {
+					stack.New().
+						LError().
+						Message("failed to record redelivery attempt of queued message").
+						String("topic", entry.Topic).
+						Error("error", attemptErr).
+						End()
+					return
+				}
+
+				if attempts < w.config.MaxRedeliveries {
+					stack.New().
+						LWarn().
+						Message("failed to redeliver queued message to webhook").
+						String("topic", entry.Topic).
+						String("url", w.config.URL).
+						Int("attempts", attempts).
+						Int("queued", w.config.Queue.Len()).
+						String("error", postErr.Error()).
+						End()
+					return
+				}
+				postErr = nerror.Wrap(ErrRedeliveriesExhausted, "%s", postErr)
+			}
+
+			stack.New().
+				LError().
+				Message("failed to redeliver queued message to webhook, dead-lettering message").
+				String("topic", entry.Topic).
+				String("url", w.config.URL).
+				String("error", postErr.Error()).
+				End()
+			w.deadLetterEntry(entry, postErr)
+		}
+
+		if removeErr := w.config.Queue.Remove(entry.Id); removeErr != nil {
+			stack.New().
+				LError().
+				Message("failed to remove message from webhook queue").
+				String("topic", entry.Topic).
+				Error("error", removeErr).
+				End()
+			return
+		}
+	}
+}
+
+// deadLetterEntry hands the message of the queued entry to the
+// Config.DeadLetter function, an entry whose body does not decode is
+// dead-lettered as the raw body on its topic.
+func (w *WebHook) deadLetterEntry(entry QueueEntry, reason error) {
+	if w.config.DeadLetter == nil {
+		return
+	}
+
+	var message, decodeErr = w.config.Codec.Decode(entry.Body)
+	if decodeErr != nil {
+		message = sabuhp.Message{Topic: sabuhp.T(entry.Topic), Bytes: entry.Body}
+	}
+	w.config.DeadLetter(message, reason)
+}
+
+// isRetryable returns false if the webhook rejected a delivery with a
+// status retrying does not change, a 4xx other than 408 Request Timeout
+// and 429 Too Many Requests.
+func isRetryable(err error) bool {
+	var requestErr, ok = nerror.UnwrapDeep(err).(*utils.RequestErr)
+	if !ok {
+		return true
+	}
+	switch requestErr.Code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return requestErr.Code < 400 || requestErr.Code > 499
+}
+
+// Sign returns the value of the SignatureHeader for the giving body.
+func Sign(secret []byte, body []byte) string {
+	var mac = hmac.New(sha256.New, secret)
+	_, _ = mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify validates that signature is a valid SignatureHeader value for body.
+func Verify(secret []byte, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
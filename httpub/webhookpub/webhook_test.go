@@ -0,0 +1,391 @@
+package webhookpub
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ewe-studios/sabuhp"
+	"github.com/ewe-studios/sabuhp/codecs"
+	"github.com/ewe-studios/sabuhp/testingutils"
+)
+
+// delivery is a request received by a test endpoint, handed to the test
+// goroutine to assert on, as require must not fail a test from handlers.
+type delivery struct {
+	header http.Header
+	body   []byte
+	err    error
+}
+
+func readDelivery(r *http.Request) delivery {
+	var body, err = ioutil.ReadAll(r.Body)
+	return delivery{header: r.Header.Clone(), body: body, err: err}
+}
+
+func TestWebHook_DeliversWithSignatureAndRetries(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var codec = &codecs.MessageJsonCodec{}
+	var secret = []byte("secret")
+
+	var attempts int32
+	var received = make(chan delivery, 1)
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		received <- readDelivery(r)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	var deadLettered = make(chan error, 1)
+	var hook = NewWebHook(Config{
+		Ctx:    context.Background(),
+		Logger: logger,
+		Codec:  codec,
+		Client: server.Client(),
+		URL:    server.URL,
+		Secret: secret,
+		DeadLetter: func(msg sabuhp.Message, reason error) {
+			deadLettered <- reason
+		},
+	})
+
+	var msg = sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("yes"))
+	require.NoError(t, hook.Deliver(context.Background(), msg))
+
+	select {
+	case delivered := <-received:
+		require.NoError(t, delivered.err)
+		require.True(t, Verify(secret, delivered.body, delivered.header.Get(SignatureHeader)))
+		require.Equal(t, "hello", delivered.header.Get(TopicHeader))
+
+		var deliveredMsg, decodeErr = codec.Decode(delivered.body)
+		require.NoError(t, decodeErr)
+		require.Equal(t, "yes", string(deliveredMsg.Bytes))
+	case <-time.After(time.Second):
+		require.Fail(t, "message should have been delivered")
+	}
+
+	require.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+	require.Empty(t, deadLettered, "should not dead-letter message")
+}
+
+func TestWebHook_DeadLettersAfterRetries(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+
+	var attempts int32
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	var deadLettered = make(chan sabuhp.Message, 1)
+	var reasons = make(chan error, 1)
+	var hook = NewWebHook(Config{
+		Ctx:        context.Background(),
+		Logger:     logger,
+		Client:     server.Client(),
+		URL:        server.URL,
+		MaxRetries: 2,
+		DeadLetter: func(msg sabuhp.Message, reason error) {
+			reasons <- reason
+			deadLettered <- msg
+		},
+	})
+
+	var msg = sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("yes"))
+	var handleErr = hook.Handle(context.Background(), msg, sabuhp.Transport{})
+	require.Error(t, handleErr)
+	require.True(t, handleErr.ShouldAck())
+
+	var deadMsg = <-deadLettered
+	require.Equal(t, msg.Id, deadMsg.Id)
+	require.Error(t, <-reasons)
+	require.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+}
+
+func TestWebHook_RedeliversQueuedMessagesOnRecovery(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var codec = &codecs.MessageJsonCodec{}
+	var dir = t.TempDir()
+
+	var down int32 = 1
+	var received = make(chan delivery, 2)
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&down) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		received <- readDelivery(r)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	var deadLettered = make(chan error, 2)
+
+	var newHook = func(ctx context.Context) *WebHook {
+		var queue, queueErr = NewFileQueue(dir, 10, OverflowReject)
+		require.NoError(t, queueErr)
+
+		return NewWebHook(Config{
+			Ctx:           ctx,
+			Logger:        logger,
+			Codec:         codec,
+			Client:        server.Client(),
+			URL:           server.URL,
+			MaxRetries:    1,
+			Queue:         queue,
+			QueueInterval: 10 * time.Millisecond,
+			DeadLetter: func(msg sabuhp.Message, reason error) {
+				deadLettered <- reason
+			},
+		})
+	}
+
+	var ctx, canceler = context.WithCancel(context.Background())
+	var hook = newHook(ctx)
+
+	var first = sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("first"))
+	var second = sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("second"))
+	require.NoError(t, hook.Deliver(context.Background(), first))
+	require.NoError(t, hook.Deliver(context.Background(), second))
+	require.Equal(t, 2, hook.config.Queue.Len())
+
+	// the process restarts while the endpoint is down, the queued
+	// messages are loaded from disk.
+	canceler()
+	hook.Wait()
+
+	var restartCtx, restartCanceler = context.WithCancel(context.Background())
+	defer restartCanceler()
+
+	var restarted = newHook(restartCtx)
+	require.Equal(t, 2, restarted.config.Queue.Len())
+
+	atomic.StoreInt32(&down, 0)
+	for _, expected := range []sabuhp.Message{first, second} {
+		select {
+		case delivered := <-received:
+			require.NoError(t, delivered.err)
+
+			var msg, decodeErr = codec.Decode(delivered.body)
+			require.NoError(t, decodeErr)
+			require.Equal(t, expected.Id, msg.Id)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "queued message should be redelivered")
+		}
+	}
+
+	require.Eventually(t, func() bool {
+		return restarted.config.Queue.Len() == 0
+	}, time.Second, 10*time.Millisecond)
+	require.Empty(t, deadLettered, "should not dead-letter queued messages")
+}
+
+func TestFileQueue_Overflow(t *testing.T) {
+	var rejecting, rejectingErr = NewFileQueue(t.TempDir(), 1, OverflowReject)
+	require.NoError(t, rejectingErr)
+
+	var _, pushErr = rejecting.Push(QueueEntry{Topic: "hello", Body: []byte("first")})
+	require.NoError(t, pushErr)
+	_, pushErr = rejecting.Push(QueueEntry{Topic: "hello", Body: []byte("second")})
+	require.True(t, nerror.IsAny(pushErr, ErrQueueFull))
+
+	var dropping, droppingErr = NewFileQueue(t.TempDir(), 1, OverflowDropOldest)
+	require.NoError(t, droppingErr)
+
+	var dropped *QueueEntry
+	dropped, pushErr = dropping.Push(QueueEntry{Topic: "hello", Body: []byte("first")})
+	require.NoError(t, pushErr)
+	require.Nil(t, dropped)
+
+	dropped, pushErr = dropping.Push(QueueEntry{Topic: "hello", Body: []byte("second")})
+	require.NoError(t, pushErr)
+	require.Equal(t, "first", string(dropped.Body))
+
+	var oldest, ok, peekErr = dropping.Peek()
+	require.NoError(t, peekErr)
+	require.True(t, ok)
+	require.Equal(t, "second", string(oldest.Body))
+	require.Equal(t, 1, dropping.Len())
+}
+
+func TestWebHook_DeadLettersUndeliverableQueuedMessages(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var codec = &codecs.MessageJsonCodec{}
+
+	var down int32 = 1
+	var received = make(chan sabuhp.Message, 1)
+	var failures = make(chan error, 10)
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var delivered = readDelivery(r)
+		if delivered.err != nil {
+			failures <- delivered.err
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var msg, decodeErr = codec.Decode(delivered.body)
+		if decodeErr != nil {
+			failures <- decodeErr
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		switch {
+		case atomic.LoadInt32(&down) == 1, string(msg.Bytes) == "flaky":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case string(msg.Bytes) == "rejected":
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		default:
+			received <- msg
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	var queue, queueErr = NewFileQueue(t.TempDir(), 10, OverflowReject)
+	require.NoError(t, queueErr)
+
+	var deadLetters = make(chan error, 2)
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var hook = NewWebHook(Config{
+		Ctx:             ctx,
+		Logger:          logger,
+		Codec:           codec,
+		Client:          server.Client(),
+		URL:             server.URL,
+		MaxRetries:      1,
+		Queue:           queue,
+		QueueInterval:   10 * time.Millisecond,
+		MaxRedeliveries: 3,
+		DeadLetter: func(msg sabuhp.Message, reason error) {
+			deadLetters <- reason
+		},
+	})
+
+	for _, payload := range []string{"rejected", "flaky", "accepted"} {
+		require.NoError(t, hook.Deliver(context.Background(), sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte(payload))))
+	}
+	require.Equal(t, 3, queue.Len())
+
+	atomic.StoreInt32(&down, 0)
+
+	// the rejected message is dead-lettered right away, the flaky one once
+	// its redeliveries are exhausted, neither blocks the accepted one.
+	select {
+	case reason := <-deadLetters:
+		require.False(t, isRetryable(reason))
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "rejected message should be dead-lettered")
+	}
+	select {
+	case reason := <-deadLetters:
+		require.True(t, nerror.IsAny(reason, ErrRedeliveriesExhausted))
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "flaky message should be dead-lettered")
+	}
+	select {
+	case msg := <-received:
+		require.Equal(t, "accepted", string(msg.Bytes))
+	case err := <-failures:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "accepted message should be redelivered")
+	}
+
+	require.Eventually(t, func() bool {
+		return queue.Len() == 0
+	}, time.Second, 10*time.Millisecond)
+	require.Empty(t, failures)
+}
+
+func TestWebHook_DeadLettersRejectedMessagesWithoutQueueing(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+
+	var attempts int32
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	var queue, queueErr = NewFileQueue(t.TempDir(), 10, OverflowReject)
+	require.NoError(t, queueErr)
+
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var deadLettered int32
+	var hook = NewWebHook(Config{
+		Ctx:        ctx,
+		Logger:     logger,
+		Client:     server.Client(),
+		URL:        server.URL,
+		MaxRetries: 3,
+		Queue:      queue,
+		DeadLetter: func(msg sabuhp.Message, reason error) {
+			atomic.AddInt32(&deadLettered, 1)
+		},
+	})
+
+	var deliverErr = hook.Deliver(context.Background(), sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("bad")))
+	require.True(t, nerror.IsAny(deliverErr, ErrDeliveryFailed))
+	require.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+	require.Equal(t, int32(1), atomic.LoadInt32(&deadLettered))
+	require.Equal(t, 0, queue.Len())
+}
+
+func TestFileQueue_RecoversFromCorruptAndPartialEntries(t *testing.T) {
+	var dir = t.TempDir()
+
+	var queue, queueErr = NewFileQueue(dir, 10, OverflowReject)
+	require.NoError(t, queueErr)
+	for _, payload := range []string{"first", "second"} {
+		var _, pushErr = queue.Push(QueueEntry{Topic: "hello", Body: []byte(payload)})
+		require.NoError(t, pushErr)
+	}
+
+	var first, _, _ = queue.Peek()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, first.Id+queueEntryExt), []byte("{not json"), 0600))
+
+	// a crash left the temporary file of a write behind.
+	var partial = filepath.Join(dir, fmt.Sprintf("%020d", 2)+queueEntryExt+queueEntryTemp)
+	require.NoError(t, ioutil.WriteFile(partial, []byte(`{"Id":`), 0600))
+
+	var reopened, reopenErr = NewFileQueue(dir, 10, OverflowReject)
+	require.NoError(t, reopenErr)
+	require.NoFileExists(t, partial)
+
+	// the corrupt entry is moved aside rather than blocking the queue.
+	var oldest, ok, peekErr = reopened.Peek()
+	require.NoError(t, peekErr)
+	require.True(t, ok)
+	require.Equal(t, "second", string(oldest.Body))
+	require.Equal(t, 1, reopened.Len())
+	require.FileExists(t, filepath.Join(dir, first.Id+queueEntryCorrupt))
+
+	var attempts, attemptErr = reopened.RecordAttempt(oldest.Id)
+	require.NoError(t, attemptErr)
+	require.Equal(t, 1, attempts)
+
+	oldest, _, _ = reopened.Peek()
+	require.Equal(t, 1, oldest.Attempts)
+}
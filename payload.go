@@ -1,6 +1,8 @@
 package sabuhp
 
 import (
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/url"
@@ -35,6 +37,10 @@ type BytesSplitter interface {
 type Topic struct {
 	T string
 	R string
+
+	// S is the separator of the segments of the topic, empty for the
+	// DefaultTopicSeparator. See TopicHierarchy.
+	S string `json:",omitempty" msgpack:",omitempty"`
 }
 
 // T creates a topic with a 20 length random string suffix.
@@ -73,8 +79,34 @@ func (t Topic) String() string {
 	return t.T
 }
 
+// Separator returns the separator of the segments of the topic.
+func (t Topic) Separator() string {
+	if t.S == "" {
+		return DefaultTopicSeparator
+	}
+	return t.S
+}
+
+// WithSeparator returns a copy of the topic using the separator.
+func (t Topic) WithSeparator(separator string) Topic {
+	t.S = separator
+	return t
+}
+
+// Segments returns the segments of the topic split by its separator.
+func (t Topic) Segments() []string {
+	return TopicHierarchy{Separator: t.Separator()}.Segments(t.T)
+}
+
+// ReplyTopic returns the topic replies to a message of the topic are sent
+// to. A topic with its own separator replies on a sub topic of it, e.g.
+// "orders/reply/<suffix>", others keep the "<topic>-reply-<suffix>" form
+// existing responders expect.
 func (t Topic) ReplyTopic() Topic {
-	return NewTopic(fmt.Sprintf("%s-reply-%s", t.T, t.R), "")
+	if t.S == "" {
+		return NewTopic(fmt.Sprintf("%s-reply-%s", t.T, t.R), "")
+	}
+	return NewTopic(TopicHierarchy{Separator: t.S}.Join(t.T, replySegment, t.R), "").WithSeparator(t.S)
 }
 
 type Message struct {
@@ -135,6 +167,12 @@ type Message struct {
 	// Only available when set, so it's very optional
 	Query url.Values
 
+	// ScheduledFor when set is the time at which the message should be
+	// delivered, buses supporting scheduling hold the message till then.
+	//
+	// Codecs encode and decode it in UTC.
+	ScheduledFor time.Time
+
 	// Within indicates senders intent on how long they are
 	// willing to wait for message delivery. Usually this should end
 	// with error resolution of attached future if present.
@@ -145,6 +183,14 @@ type Message struct {
 	// points where it was handled.
 	Id nxid.ID
 
+	// CorrelationId is the id shared by a request and all its replies,
+	// allowing a sender to match replies to the request they answer.
+	CorrelationId nxid.ID
+
+	// PartitionKey when set groups related messages, a ShardedBus sends
+	// all messages with the same key to the same shard of a topic.
+	PartitionKey string
+
 	// EndPartId is the unique id attached to giving messages which
 	// indicate the expected end id which when seen as the Id
 	// should consider a part stream as completed.
@@ -181,6 +227,12 @@ type Message struct {
 	// Bytes is the payload for giving message.
 	Bytes []byte
 
+	// Compressed asks the message codecs to gzip the payload of the message
+	// on the wire, leaving other messages (e.g small control messages)
+	// uncompressed. Decoded messages hold the decompressed payload with
+	// the flag still set.
+	Compressed bool
+
 	// Metadata are related facts attached to a message.
 	Metadata Params
 
@@ -198,6 +250,13 @@ type Message struct {
 	// parts of themselves, this field is an option, generally.
 	// Codecs should never read this
 	Parts []Message
+
+	// UnknownFields are the encoded fields of a decoded message which its
+	// codec did not know, e.g fields added by a newer producer. The codec
+	// writes them back when encoding the message again, so forwarding a
+	// message does not drop them. Only set by MessageProtoCodec, other
+	// codecs and Message.Hash ignore them.
+	UnknownFields []byte `json:"-" msgpack:"-"`
 }
 
 // ReplyWithTopic returns a new message with provided topic.
@@ -254,6 +313,29 @@ func NewMessage(topic Topic, fromAddr string, payload []byte) Message {
 	}
 }
 
+// NewReply returns a reply to the request with the body, addressed to the
+// reply topic of the request and carrying its ReplyGroup and
+// CorrelationId. All other fields are left blank.
+//
+// A request without a CorrelationId is correlated by its Id: SendForReply
+// sets the CorrelationId of such requests to their Id before sending them
+// and ignores replies carrying another one, so the reply of a request read
+// from an older sender still reaches the requester.
+func NewReply(request *Message, body []byte) *Message {
+	var correlationId = request.CorrelationId
+	if correlationId.IsNil() {
+		correlationId = request.Id
+	}
+	return &Message{
+		Id:            nxid.New(),
+		Topic:         request.Topic.ReplyTopic(),
+		ReplyGroup:    request.ReplyGroup,
+		CorrelationId: correlationId,
+		Bytes:         body,
+		ContentType:   MessageContentType,
+	}
+}
+
 func NOTOK(message string, fromAddr string) Message {
 	return Message{
 		Id:          nxid.New(),
@@ -358,7 +440,8 @@ func (m Message) String() string {
 }
 
 // Copy returns a copy of this commands with underline data
-// copied across. The copy
+// copied across. The copy shares no maps, slices or bytes with
+// the message, so either can be mutated without affecting the other.
 func (m Message) Copy() Message {
 	var meta = map[string]string{}
 	for key, val := range m.Metadata {
@@ -367,5 +450,79 @@ func (m Message) Copy() Message {
 	var clone = m
 	clone.Metadata = meta
 	clone.Bytes = append([]byte{}, m.Bytes...)
+	if m.UnknownFields != nil {
+		clone.UnknownFields = append([]byte(nil), m.UnknownFields...)
+	}
+
+	if m.Params != nil {
+		clone.Params = Params{}
+		for key, val := range m.Params {
+			clone.Params[key] = val
+		}
+	}
+	if m.Headers != nil {
+		clone.Headers = Header{}
+		for key, values := range m.Headers {
+			clone.Headers[key] = append([]string(nil), values...)
+		}
+	}
+	if m.Form != nil {
+		clone.Form = url.Values{}
+		for key, values := range m.Form {
+			clone.Form[key] = append([]string(nil), values...)
+		}
+	}
+	if m.Query != nil {
+		clone.Query = url.Values{}
+		for key, values := range m.Query {
+			clone.Query[key] = append([]string(nil), values...)
+		}
+	}
+	if m.Cookies != nil {
+		clone.Cookies = make([]Cookie, len(m.Cookies))
+		for index, cookie := range m.Cookies {
+			cookie.Unparsed = append([]string(nil), cookie.Unparsed...)
+			clone.Cookies[index] = cookie
+		}
+	}
+	if m.Parts != nil {
+		clone.Parts = make([]Message, len(m.Parts))
+		for index, part := range m.Parts {
+			clone.Parts[index] = part.Copy()
+		}
+	}
 	return clone
 }
+
+// Hash returns a stable content hash of the message usable as an
+// idempotency key, two messages with the same content hash equal.
+//
+// The hash excludes the fields which differ between sends of the same
+// content: Id, CorrelationId, the TraceIdMetadata entry of Metadata,
+// Future, ReplyErr, Parts and UnknownFields. It uses the json encoding of
+// the message, which writes maps in sorted key order.
+func (m Message) Hash() [32]byte {
+	m.Id = nxid.ID{}
+	m.CorrelationId = nxid.ID{}
+	m.Future = nil
+	m.ReplyErr = nil
+	m.Parts = nil
+	m.UnknownFields = nil
+
+	// the metadata is copied, the map of the message is never modified.
+	var meta Params
+	for key, val := range m.Metadata {
+		if key == TraceIdMetadata {
+			continue
+		}
+		if meta == nil {
+			meta = make(Params, len(m.Metadata))
+		}
+		meta[key] = val
+	}
+	m.Metadata = meta
+
+	// marshalling can not fail once the future and error are cleared.
+	var encoded, _ = json.Marshal(m)
+	return sha256.Sum256(encoded)
+}
@@ -0,0 +1,98 @@
+package sabuhp
+
+import (
+	"testing"
+
+	"github.com/influx6/npkg/nthen"
+	"github.com/influx6/npkg/nxid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessage_Hash(t *testing.T) {
+	var first = NewMessage(T("hello"), "me", []byte("world"))
+	first.Metadata = Params{"a": "1", "b": "2", "c": "3"}
+	first.Future = nthen.NewFuture()
+
+	var second = NewMessage(T("hello"), "me", []byte("world"))
+	second.Metadata = Params{"c": "3", "b": "2", "a": "1"}
+	second.Parts = []Message{NewMessage(T("hello"), "me", []byte("part"))}
+	second.UnknownFields = []byte{0xc2, 0x02, 0x01, 0x61}
+
+	require.NotEqual(t, first.Id, second.Id)
+	require.Equal(t, first.Hash(), second.Hash())
+
+	var differentPayload = second.Copy()
+	differentPayload.Bytes = []byte("planet")
+	require.NotEqual(t, first.Hash(), differentPayload.Hash())
+
+	var differentMeta = second.Copy()
+	differentMeta.Metadata["a"] = "4"
+	require.NotEqual(t, first.Hash(), differentMeta.Hash())
+
+	var differentTopic = second.Copy()
+	differentTopic.Topic = T("goodbye")
+	require.NotEqual(t, first.Hash(), differentTopic.Hash())
+}
+
+func TestMessage_HashIgnoresSendFields(t *testing.T) {
+	// each send of the same content gets its own id, trace id and, when
+	// sent for a reply, correlation id.
+	var send = func() Message {
+		var msg = NewMessage(T("hello"), "me", []byte("world"))
+		msg.Metadata = Params{"tenant": "acme"}
+		msg = WithTraceId(msg)
+		msg.CorrelationId = msg.Id
+		return msg
+	}
+
+	var first, second = send(), send()
+	require.NotEqual(t, TraceId(first), TraceId(second))
+	require.Equal(t, first.Hash(), second.Hash())
+
+	// the trace id of the message is left untouched.
+	require.NotEmpty(t, TraceId(first))
+
+	// a message without metadata besides its trace id hashes like one
+	// which was never traced.
+	var untraced = NewMessage(T("hello"), "me", []byte("world"))
+	untraced.Metadata = nil
+	require.Equal(t, untraced.Hash(), WithTraceId(untraced).Hash())
+}
+
+func TestMessage_Copy(t *testing.T) {
+	var message = NewMessage(T("hello"), "me", []byte("world"))
+	message.Params = Params{"id": "1"}
+	message.Headers = Header{"X-Id": []string{"1"}}
+	message.Parts = []Message{NewMessage(T("hello"), "me", []byte("part"))}
+
+	var copied = message.Copy()
+	copied.Params["id"] = "2"
+	copied.Headers["X-Id"][0] = "2"
+	copied.Bytes[0] = 'W'
+	copied.Parts[0].Bytes[0] = 'P'
+
+	require.Equal(t, "1", message.Params["id"])
+	require.Equal(t, "1", message.Headers["X-Id"][0])
+	require.Equal(t, "world", string(message.Bytes))
+	require.Equal(t, "part", string(message.Parts[0].Bytes))
+}
+
+func TestNewReply(t *testing.T) {
+	var request = NewMessage(T("orders").WithSeparator("/"), "me", []byte("order-1"))
+	request.ReplyGroup = "requesters"
+	request.CorrelationId = nxid.New()
+	request.Metadata = Params{"tenant": "acme"}
+
+	var reply = NewReply(&request, []byte("accepted"))
+	require.Equal(t, request.Topic.ReplyTopic(), reply.Topic)
+	require.Equal(t, "requesters", reply.ReplyGroup)
+	require.Equal(t, request.CorrelationId, reply.CorrelationId)
+	require.Equal(t, "accepted", string(reply.Bytes))
+	require.NotEqual(t, request.Id, reply.Id)
+	require.Empty(t, reply.FromAddr)
+	require.Empty(t, reply.Metadata)
+
+	// requests without a correlation id are correlated by their id.
+	var uncorrelated = NewMessage(T("orders"), "me", nil)
+	require.Equal(t, uncorrelated.Id, NewReply(&uncorrelated, nil).CorrelationId)
+}
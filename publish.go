@@ -0,0 +1,38 @@
+package sabuhp
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/influx6/npkg/nerror"
+)
+
+// ValueCodec encodes and decodes the values carried as message payloads,
+// it's implemented by the generic codecs of the codecs package, e.g
+// codecs.GenericJsonCodec.
+type ValueCodec interface {
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+// Publish encodes the value with the codec as the payload of a new message
+// for the topic and sends it on the bus, returning the codec error if the
+// value failed to encode.
+func Publish(bus MessageBus, codec ValueCodec, topic string, v interface{}) error {
+	var payload bytes.Buffer
+	if encodeErr := codec.Encode(&payload, v); encodeErr != nil {
+		return nerror.WrapOnly(encodeErr)
+	}
+
+	bus.Send(NewMessage(T(topic), "", payload.Bytes()))
+	return nil
+}
+
+// DecodePayload decodes the payload of a message published with Publish
+// into the value v, which must be a pointer.
+func DecodePayload(codec ValueCodec, msg Message, v interface{}) error {
+	if decodeErr := codec.Decode(bytes.NewReader(msg.Bytes), v); decodeErr != nil {
+		return nerror.WrapOnly(decodeErr)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package sabuhp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type jsonValueCodec struct{}
+
+func (jsonValueCodec) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (jsonValueCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+type orderPlaced struct {
+	Id    string
+	Total int
+}
+
+func TestPublish(t *testing.T) {
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var bus = relayBus(controlCtx)
+	var codec jsonValueCodec
+
+	var received = make(chan orderPlaced, 1)
+	var channel = bus.Listen("orders", "*", TransportResponseFunc(func(ctx context.Context, message Message, transport Transport) MessageErr {
+		var order orderPlaced
+		if decodeErr := DecodePayload(codec, message, &order); decodeErr != nil {
+			return WrapErr(decodeErr, false)
+		}
+		received <- order
+		return nil
+	}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	require.NoError(t, Publish(bus, codec, "orders", orderPlaced{Id: "order-1", Total: 42}))
+	require.Equal(t, orderPlaced{Id: "order-1", Total: 42}, <-received)
+
+	// values the codec can not encode are reported.
+	require.Error(t, Publish(bus, codec, "orders", make(chan int)))
+}
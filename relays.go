@@ -66,7 +66,8 @@ func (tm *PbRelay) Handle(ctx context.Context, message Message, transport Transp
 		if err := channel.Notify(ctx, message, transport); err != nil {
 			logStack.New().LInfo().Message("channel failed to handle message").
 				Error("error", err).
-				String("topic", message.Topic.String())
+				String("topic", message.Topic.String()).
+				End()
 			if message.Future != nil {
 				message.Future.WithError(err)
 			}
@@ -141,7 +142,7 @@ func (tm *PbRelay) Group(topic string, group string) *PbGroup {
 	var newChannel = &PbGroup{
 		topic:         topic,
 		group:         group,
-		logger:        tm.logger,
+		logger:        WithFields(tm.logger, LogFields{"topic": topic, "group": group}),
 		commands:      make(chan func()),
 		ctx:           newCtx,
 		canceler:      canceler,
@@ -183,6 +184,25 @@ func (tm *PbRelay) Group(topic string, group string) *PbGroup {
 	return newChannel
 }
 
+// Subscribers returns the number of subscribers of each topic
+// across all its groups.
+func (tm *PbRelay) Subscribers() map[string]int {
+	tm.chl.RLock()
+	var groups = make([]*PbGroup, 0, len(tm.channels))
+	for _, channels := range tm.channels {
+		groups = append(groups, channels...)
+	}
+	tm.chl.RUnlock()
+
+	var counts = map[string]int{}
+	for _, group := range groups {
+		if count := group.Count(); count > 0 {
+			counts[group.topic] += count
+		}
+	}
+	return counts
+}
+
 func (tm *PbRelay) Wait() {
 	tm.waiter.Wait()
 }
@@ -229,11 +249,17 @@ type subInfo struct {
 	group   string
 	id      nxid.ID
 	err     error
+	weight  int
+	current int
 	handler TransportResponse
 	sub     *PbGroup
 	manager *PbGroup
 }
 
+func (info *subInfo) ID() nxid.ID {
+	return info.id
+}
+
 func (info *subInfo) Group() string {
 	return info.group
 }
@@ -250,8 +276,25 @@ func (info subInfo) Close() {
 	info.manager.remove(info)
 }
 
+// DeliveryMode defines how a PbGroup delivers a message to its subscribers.
+type DeliveryMode int
+
+const (
+	// Broadcast delivers every message to all subscribers of the group.
+	Broadcast DeliveryMode = iota
+
+	// RoundRobin delivers every message to one subscriber of the group,
+	// taking turns in proportion to the weight of each subscriber.
+	RoundRobin
+)
+
+// ErrHandlerTimeout is reported by a PbGroup for a subscriber which did
+// not handle a message within the timeout set with SetHandlerTimeout.
+var ErrHandlerTimeout = nerror.New("subscriber failed to handle message in time")
+
 type PbGroup struct {
 	id            nxid.ID
+	delivery      DeliveryMode
 	topic         string
 	group         string
 	logger        Logger
@@ -261,6 +304,8 @@ type PbGroup struct {
 	channel       Channel
 	manager       *PbRelay
 	subscriptions map[nxid.ID]*subInfo
+
+	handlerTimeout time.Duration
 }
 
 func (sc *PbGroup) Listen(handler TransportResponse) Channel {
@@ -269,6 +314,75 @@ func (sc *PbGroup) Listen(handler TransportResponse) Channel {
 	sub.group = sc.group
 	sub.topic = sc.topic
 	sub.id = nxid.New()
+	sub.weight = 1
+	sub.manager = sc
+	sub.handler = handler
+	sub.err = sc.add(sub)
+	return &sub
+}
+
+// ListenWithWeight adds the handler as a subscriber with the provided
+// weight, with RoundRobin delivery a subscriber receives messages in
+// proportion to its weight, e.g a subscriber of weight 3 receives three
+// times as many messages as one of weight 1.
+func (sc *PbGroup) ListenWithWeight(weight int, handler TransportResponse) Channel {
+	if weight < 1 {
+		weight = 1
+	}
+
+	var sub subInfo
+	sub.sub = sc
+	sub.group = sc.group
+	sub.topic = sc.topic
+	sub.id = nxid.New()
+	sub.weight = weight
+	sub.manager = sc
+	sub.handler = handler
+	sub.err = sc.add(sub)
+	return &sub
+}
+
+// SetDelivery sets how messages are delivered to the subscribers of
+// the group, it defaults to Broadcast.
+func (sc *PbGroup) SetDelivery(mode DeliveryMode) {
+	var doAction = func() {
+		sc.delivery = mode
+	}
+
+	select {
+	case sc.commands <- doAction:
+	case <-sc.ctx.Done():
+	}
+}
+
+// SetHandlerTimeout sets how long a subscriber may take to handle a
+// message before the group logs it, reports ErrHandlerTimeout and moves
+// on to the next subscriber. The context given to the subscriber is
+// cancelled at the timeout, a subscriber ignoring it is abandoned to
+// finish in the background.
+//
+// A zero timeout, the default, waits on subscribers indefinitely.
+func (sc *PbGroup) SetHandlerTimeout(timeout time.Duration) {
+	var doAction = func() {
+		sc.handlerTimeout = timeout
+	}
+
+	select {
+	case sc.commands <- doAction:
+	case <-sc.ctx.Done():
+	}
+}
+
+// ListenWithId adds the handler as a subscriber with the provided id,
+// allowing it to be removed later with PbGroup.Remove or
+// PbRelay.UnlistenAllWithId.
+func (sc *PbGroup) ListenWithId(id nxid.ID, handler TransportResponse) Channel {
+	var sub subInfo
+	sub.sub = sc
+	sub.group = sc.group
+	sub.topic = sc.topic
+	sub.id = id
+	sub.weight = 1
 	sub.manager = sc
 	sub.handler = handler
 	sub.err = sc.add(sub)
@@ -276,6 +390,11 @@ func (sc *PbGroup) Listen(handler TransportResponse) Channel {
 }
 
 func (sc *PbGroup) IsEmpty() bool {
+	return sc.Count() == 0
+}
+
+// Count returns the number of subscribers of the group.
+func (sc *PbGroup) Count() int {
 	var count = make(chan int, 1)
 	var doDistribution = func() {
 		count <- len(sc.subscriptions)
@@ -283,9 +402,9 @@ func (sc *PbGroup) IsEmpty() bool {
 
 	select {
 	case sc.commands <- doDistribution:
-		return 0 == <-count
+		return <-count
 	case <-sc.ctx.Done():
-		return true
+		return 0
 	}
 }
 
@@ -299,7 +418,6 @@ func (sc *PbGroup) Link(bus MessageBus) {
 
 		logStack.New().LInfo().
 			Message("added subscriber to topic").
-			String("topic", sc.topic).
 			String("id", sc.id.String()).
 			End()
 
@@ -322,6 +440,7 @@ func (sc *PbGroup) Link(bus MessageBus) {
 func (sc *PbGroup) Add(id nxid.ID, tr TransportResponse) error {
 	var info subInfo
 	info.id = id
+	info.weight = 1
 	info.sub = sc
 	info.handler = tr
 	info.manager = sc
@@ -342,10 +461,10 @@ func (sc *PbGroup) add(info subInfo) error {
 		}
 
 		sc.subscriptions[info.id] = &info
+		sc.rebalance()
 
 		logStack.New().LInfo().
 			Message("added subscriber to topic").
-			String("topic", sc.topic).
 			String("id", info.id.String()).
 			End()
 
@@ -378,10 +497,10 @@ func (sc *PbGroup) remove(info subInfo) {
 		var logStack = njson.Log(sc.logger)
 
 		delete(sc.subscriptions, info.id)
+		sc.rebalance()
 
 		logStack.New().LInfo().
 			Message("removing subscriber from topic").
-			String("topic", sc.topic).
 			String("id", info.id.String()).
 			End()
 	}
@@ -394,6 +513,37 @@ func (sc *PbGroup) remove(info subInfo) {
 	}
 }
 
+// rebalance resets the round robin turns after subscribers changed, so
+// the remaining subscribers share messages by their weights again.
+func (sc *PbGroup) rebalance() {
+	for _, sub := range sc.subscriptions {
+		sub.current = 0
+	}
+}
+
+// next returns the subscriber whose turn it is with RoundRobin delivery,
+// using smooth weighted round robin which spreads the turns of heavier
+// subscribers between those of lighter ones.
+func (sc *PbGroup) next() *subInfo {
+	var total int
+	var selected *subInfo
+	for _, sub := range sc.subscriptions {
+		var weight = sub.weight
+		if weight < 1 {
+			weight = 1
+		}
+		sub.current += weight
+		total += weight
+		if selected == nil || sub.current > selected.current {
+			selected = sub
+		}
+	}
+	if selected != nil {
+		selected.current -= total
+	}
+	return selected
+}
+
 // Notify will notify the groups of handlers and will return the first occurrence
 // of a message error seen by one of the handlers. This means if one of the handlers returns
 // an error then the publisher will be notified as if all handlers failed to handle the
@@ -405,54 +555,88 @@ func (sc *PbGroup) Notify(ctx context.Context, msg Message, transport Transport)
 	logStack.New().LInfo().
 		Message("received new message").
 		Object("message", msg).
-		String("topic", sc.topic).
 		End()
 
 	var errChan = make(chan MessageErr, 1)
 	var doDistribution = func() {
 		var logStack = njson.Log(sc.logger)
 
-		logStack.New().Message("notifying all handlers with message").
-			String("topic", sc.topic).
-			End()
+		var handle = func(handleCtx context.Context, subscriber TransportResponse, m Message) (handleErr MessageErr) {
+			defer func() {
+				if panicInfo := recover(); panicInfo != nil {
+					logStack.New().LPanic().
+						Message("message handler panic during handling").
+						Object("message", m).
+						Formatted("panic_data", "%#v", panicInfo).
+						End()
+				}
+			}()
 
-		for _, sub := range sc.subscriptions {
-			func(subscriber TransportResponse, m Message) {
-				defer func() {
-					if panicInfo := recover(); panicInfo != nil {
-						logStack.New().LPanic().
-							Message("message handler panic during handling").
-							Object("message", m).
-							String("topic", sc.topic).
-							Formatted("panic_data", "%#v", panicInfo).
-							End()
-					}
-				}()
-
-				logStack.New().Message("calling handler with message").
+			return subscriber.Handle(handleCtx, m, transport)
+		}
+
+		var handleWithin = func(subscriber TransportResponse, m Message) MessageErr {
+			if sc.handlerTimeout <= 0 {
+				return handle(ctx, subscriber, m)
+			}
+
+			var handleCtx, canceler = context.WithTimeout(ctx, sc.handlerTimeout)
+			defer canceler()
+
+			var done = make(chan MessageErr, 1)
+			go func() {
+				done <- handle(handleCtx, subscriber, m)
+			}()
+
+			select {
+			case handleErr := <-done:
+				return handleErr
+			case <-handleCtx.Done():
+				logStack.New().LWarn().
+					Message("handler failed to handle message in time, moving on").
 					Object("message", m).
-					String("topic", sc.topic).
+					String("timeout", sc.handlerTimeout.String()).
 					End()
+				return WrapErr(ErrHandlerTimeout, false)
+			}
+		}
 
-				if handleErr := subscriber.Handle(ctx, m, transport); handleErr != nil {
-					logStack.New().Message("error occurred handled message").
-						Object("message", m).
-						String("topic", sc.topic).
-						String("error", nerror.WrapOnly(handleErr).Error()).
-						End()
-
-					// if one item failed to handle the message, report this.
-					if len(errChan) == 0 {
-						errChan <- handleErr
-					}
-					return
-				}
+		var deliver = func(subscriber TransportResponse, m Message) {
+			logStack.New().Message("calling handler with message").
+				Object("message", m).
+				End()
 
-				logStack.New().Message("handled message delivery successfully").
+			if handleErr := handleWithin(subscriber, m); handleErr != nil {
+				logStack.New().Message("error occurred handled message").
 					Object("message", m).
-					String("topic", sc.topic).
+					String("error", nerror.WrapOnly(handleErr).Error()).
 					End()
-			}(sub.handler, msg)
+
+				// if one item failed to handle the message, report this.
+				if len(errChan) == 0 {
+					errChan <- handleErr
+				}
+				return
+			}
+
+			logStack.New().Message("handled message delivery successfully").
+				Object("message", m).
+				End()
+		}
+
+		if sc.delivery == RoundRobin {
+			if sub := sc.next(); sub != nil {
+				deliver(sub.handler, msg)
+			}
+			errChan <- nil
+			return
+		}
+
+		logStack.New().Message("notifying all handlers with message").
+			End()
+
+		for _, sub := range sc.subscriptions {
+			deliver(sub.handler, msg)
 		}
 
 		errChan <- nil
@@ -468,7 +652,6 @@ func (sc *PbGroup) Notify(ctx context.Context, msg Message, transport Transport)
 		logStack.New().LWarn().
 			Message("failed to deliver message to handlers due to timeout").
 			Object("message", msg).
-			String("topic", sc.topic).
 			End()
 		return WrapErr(nerror.New("failed to deliver"), false)
 	case sc.commands <- doDistribution:
@@ -477,7 +660,6 @@ func (sc *PbGroup) Notify(ctx context.Context, msg Message, transport Transport)
 		logStack.New().LWarn().
 			Message("failed to deliver message to handlers").
 			Object("message", msg).
-			String("topic", sc.topic).
 			End()
 		return WrapErr(nerror.New("context was closed"), false)
 	}
@@ -488,13 +670,11 @@ func (sc *PbGroup) Run() {
 
 	logStack.New().LInfo().
 		Message("starting subscription management loop").
-		String("topic", sc.topic).
 		End()
 
 	defer func() {
 		logStack.New().LInfo().
 			Message("ending subscription management loop").
-			String("topic", sc.topic).
 			End()
 	}()
 
@@ -3,9 +3,13 @@ package sabuhp
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/njson"
 	"github.com/stretchr/testify/require"
 )
 
@@ -46,3 +50,139 @@ func TestPbRelay(t *testing.T) {
 
 	manager.Wait()
 }
+
+type captureLogger struct {
+	sync.Mutex
+	lines []string
+}
+
+func (c *captureLogger) Log(cb *njson.JSON) {
+	c.Lock()
+	c.lines = append(c.lines, cb.Message())
+	c.Unlock()
+}
+
+func (c *captureLogger) Find(text string) string {
+	c.Lock()
+	defer c.Unlock()
+	for _, line := range c.lines {
+		if strings.Contains(line, text) {
+			return line
+		}
+	}
+	return ""
+}
+
+func TestWithFields(t *testing.T) {
+	var logger = &captureLogger{}
+	var fieldLogger = WithFields(logger, LogFields{"topic": "hello", "group": "g1"})
+
+	njson.Log(fieldLogger).New().LInfo().Message("delivered").End()
+
+	var line = logger.Find("delivered")
+	require.Contains(t, line, `"topic":"hello"`)
+	require.Contains(t, line, `"group":"g1"`)
+}
+
+func TestPbGroup_LogsTopic(t *testing.T) {
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+
+	var logger = &captureLogger{}
+	var mb BusBuilder
+
+	var manager = NewPbRelay(controlCtx, logger)
+	var group = manager.Group("hello", "g1")
+	var channel = group.Listen(TransportResponseFunc(func(_ context.Context, message Message, tr Transport) MessageErr {
+		return WrapErr(nerror.New("failed"), false)
+	}))
+
+	require.Error(t, group.Notify(controlCtx, BasicMsg(T("hello"), "hello ", "you"), Transport{Bus: &mb}))
+
+	var line = logger.Find("error occurred handled message")
+	require.Contains(t, line, `"topic":"hello"`)
+	require.Contains(t, line, `"group":"g1"`)
+
+	channel.Close()
+	controlStopFunc()
+	manager.Wait()
+}
+
+func TestPbGroup_WeightedRoundRobin(t *testing.T) {
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+
+	var logger GoLogImpl
+	var mb BusBuilder
+
+	var manager = NewPbRelay(controlCtx, logger)
+	var group = manager.Group("hello", "g1")
+	group.SetDelivery(RoundRobin)
+
+	var light, heavy int
+	var lightChannel = group.ListenWithWeight(1, TransportResponseFunc(func(_ context.Context, message Message, tr Transport) MessageErr {
+		light++
+		return nil
+	}))
+	var heavyChannel = group.ListenWithWeight(3, TransportResponseFunc(func(_ context.Context, message Message, tr Transport) MessageErr {
+		heavy++
+		return nil
+	}))
+
+	for i := 0; i < 400; i++ {
+		require.NoError(t, group.Notify(controlCtx, BasicMsg(T("hello"), "hello ", "you"), Transport{Bus: &mb}))
+	}
+
+	require.Equal(t, 400, light+heavy)
+	require.InDelta(t, 300, heavy, 10)
+	require.InDelta(t, 100, light, 10)
+
+	// the remaining subscriber receives every message once the heavier one closed.
+	heavyChannel.Close()
+	light, heavy = 0, 0
+	for i := 0; i < 40; i++ {
+		require.NoError(t, group.Notify(controlCtx, BasicMsg(T("hello"), "hello ", "you"), Transport{Bus: &mb}))
+	}
+	require.Equal(t, 40, light)
+	require.Equal(t, 0, heavy)
+
+	lightChannel.Close()
+	controlStopFunc()
+	manager.Wait()
+}
+
+func TestPbGroup_HandlerTimeout(t *testing.T) {
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+
+	var logger GoLogImpl
+	var mb BusBuilder
+
+	var manager = NewPbRelay(controlCtx, logger)
+	var group = manager.Group("hello", "g1")
+	group.SetHandlerTimeout(50 * time.Millisecond)
+
+	// the hanging subscriber ignores its context and is abandoned.
+	var release = make(chan struct{})
+	defer close(release)
+	var hangingChannel = group.Listen(TransportResponseFunc(func(_ context.Context, message Message, tr Transport) MessageErr {
+		<-release
+		return nil
+	}))
+
+	var received = make(chan Message, 3)
+	var otherChannel = group.Listen(TransportResponseFunc(func(_ context.Context, message Message, tr Transport) MessageErr {
+		received <- message
+		return nil
+	}))
+
+	for i := 0; i < 3; i++ {
+		var start = time.Now()
+		var notifyErr = group.Notify(controlCtx, BasicMsg(T("hello"), "hello ", "you"), Transport{Bus: &mb})
+		require.Error(t, notifyErr)
+		require.Less(t, int64(time.Since(start)), int64(time.Second))
+	}
+	require.Len(t, received, 3)
+
+	hangingChannel.Close()
+	otherChannel.Close()
+	controlStopFunc()
+	manager.Wait()
+}
@@ -0,0 +1,190 @@
+package sabuhp
+
+import (
+	"context"
+	"sync"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nxid"
+)
+
+// ErrReplyTimeout is the error a ReplyFuture resolves with when no
+// reply was received within the expected duration.
+var ErrReplyTimeout = nerror.New("timed out waiting for reply")
+
+// ErrBusClosed is the error a ReplyFuture resolves with when the bus
+// was closed before a reply was received.
+var ErrBusClosed = nerror.New("bus is closed")
+
+// ErrAlreadyStopped is the error returned by Lifecycle.Stop when
+// called on an already stopped component.
+var ErrAlreadyStopped = nerror.New("already stopped")
+
+// ReplyTopicResolver is implemented by buses which send the replies of a
+// topic to another topic than Topic.ReplyTopic, e.g because they apply a
+// TopicHierarchy to the topics of messages they send.
+type ReplyTopicResolver interface {
+	ReplyTopicOf(topic Topic) Topic
+}
+
+// replyTopicOf returns the topic the replies of the topic are sent to on
+// the bus.
+func replyTopicOf(bus MessageBus, topic Topic) Topic {
+	if resolver, ok := bus.(ReplyTopicResolver); ok {
+		return resolver.ReplyTopicOf(topic)
+	}
+	return topic.ReplyTopic()
+}
+
+// ReplyGroupResolver is implemented by buses whose listeners of a group
+// compete for messages, e.g stream buses, so each request needs a group
+// of its own on the reply topic to receive every reply.
+type ReplyGroupResolver interface {
+	// ReplyGroupFor returns the group a request listens with on the reply
+	// topic: a new group if replyGroup is empty, else replyGroup. The
+	// release function frees the state of a new group once the request
+	// is done, it is nil for a given group. An error is returned if the
+	// bus does not serve replies.
+	ReplyGroupFor(replyTopic string, replyGroup string) (group string, release func(), err error)
+}
+
+// listenForReplies listens with the handler on the reply topic of the
+// fromTopic, resolving the group with the ReplyGroupResolver of the bus if
+// it has one. The returned function closes the listen and releases its
+// group.
+func listenForReplies(bus MessageBus, fromTopic Topic, replyGroup string, handler TransportResponse) (func(), error) {
+	var replyTopic = replyTopicOf(bus, fromTopic).String()
+
+	var release func()
+	if resolver, ok := bus.(ReplyGroupResolver); ok {
+		var group, groupRelease, groupErr = resolver.ReplyGroupFor(replyTopic, replyGroup)
+		if groupErr != nil {
+			return nil, nerror.WrapOnly(groupErr)
+		}
+		replyGroup = group
+		release = groupRelease
+	}
+
+	var channel = bus.Listen(replyTopic, replyGroup, handler)
+	var closer = func() {
+		channel.Close()
+		if release != nil {
+			release()
+		}
+	}
+	if listenErr := channel.Err(); listenErr != nil {
+		closer()
+		return nil, nerror.WrapOnly(listenErr)
+	}
+	return closer, nil
+}
+
+// ReplyFuture is a future which resolves with the reply Message
+// of a MessageBus.SendForReply request or an error.
+//
+// A ReplyFuture resolves only once, all later resolutions are ignored.
+type ReplyFuture struct {
+	once  sync.Once
+	done  chan struct{}
+	reply Message
+	err   error
+}
+
+func NewReplyFuture() *ReplyFuture {
+	return &ReplyFuture{done: make(chan struct{})}
+}
+
+// WithReply resolves the future with the reply message.
+func (f *ReplyFuture) WithReply(reply Message) {
+	f.once.Do(func() {
+		f.reply = reply
+		close(f.done)
+	})
+}
+
+// WithError resolves the future with an error.
+func (f *ReplyFuture) WithError(err error) {
+	f.once.Do(func() {
+		f.err = err
+		close(f.done)
+	})
+}
+
+// Done returns a channel which is closed once the future is resolved.
+func (f *ReplyFuture) Done() <-chan struct{} {
+	return f.done
+}
+
+// Get blocks till the future is resolved, returning the reply or the error.
+func (f *ReplyFuture) Get() (Message, error) {
+	<-f.done
+	return f.reply, f.err
+}
+
+// SendForReplyContext sends the messages on the bus and returns right
+// away with a future resolved by the first reply on the reply topic of
+// fromTopic, so callers can do other work and Get the reply later.
+//
+// The context alone governs the request: a deadline takes the place of
+// the timeout of MessageBus.SendForReply and resolves the future with
+// ErrReplyTimeout, a cancellation resolves it with the context error.
+// Replies are correlated to the messages by their CorrelationId like
+// with SendForStream.
+//
+// Every call needs a reply group of its own on buses whose listeners of a
+// group compete for messages, else concurrent calls consume each other's
+// replies. An empty replyGroup gets one from the ReplyGroupResolver of
+// such a bus, released once the call is done.
+func SendForReplyContext(ctx context.Context, bus MessageBus, fromTopic Topic, replyGroup string, data ...Message) *ReplyFuture {
+	var ft = NewReplyFuture()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		ft.WithError(replyContextErr(ctxErr))
+		return ft
+	}
+
+	var requests = make([]Message, 0, len(data))
+	var correlationIds = make(map[nxid.ID]struct{}, len(data))
+	for _, msg := range data {
+		if msg.Id.IsNil() {
+			msg.Id = nxid.New()
+		}
+		if msg.CorrelationId.IsNil() {
+			msg.CorrelationId = msg.Id
+		}
+		correlationIds[msg.CorrelationId] = struct{}{}
+		requests = append(requests, msg)
+	}
+
+	var closeReplies, listenErr = listenForReplies(bus, fromTopic, replyGroup, TransportResponseFunc(func(_ context.Context, reply Message, _ Transport) MessageErr {
+		if !reply.CorrelationId.IsNil() {
+			if _, requested := correlationIds[reply.CorrelationId]; !requested {
+				return nil
+			}
+		}
+		ft.WithReply(reply)
+		return nil
+	}))
+	if listenErr != nil {
+		ft.WithError(listenErr)
+		return ft
+	}
+
+	go func() {
+		select {
+		case <-ft.Done():
+		case <-ctx.Done():
+			ft.WithError(replyContextErr(ctx.Err()))
+		}
+		closeReplies()
+	}()
+
+	bus.Send(requests...)
+	return ft
+}
+
+func replyContextErr(err error) error {
+	if err == context.DeadlineExceeded {
+		return ErrReplyTimeout
+	}
+	return nerror.WrapOnly(err)
+}
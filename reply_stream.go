@@ -0,0 +1,106 @@
+package sabuhp
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/influx6/npkg/nxid"
+)
+
+// StreamEndMetadata is the Metadata key a responder sets on the final
+// reply of a stream started with SendForStream.
+const StreamEndMetadata = "x-stream-end"
+
+// DefaultStreamBuffer is the buffer size of the channel returned
+// by SendForStream.
+const DefaultStreamBuffer = 10
+
+// IsStreamEnd returns true if the message is the terminal marker of
+// a reply stream.
+func IsStreamEnd(msg Message) bool {
+	return msg.Metadata != nil && msg.Metadata[StreamEndMetadata] == "true"
+}
+
+// MarkStreamEnd marks the message as the terminal marker of a reply stream.
+func MarkStreamEnd(msg *Message) {
+	if msg.Metadata == nil {
+		msg.Metadata = Params{}
+	}
+	msg.Metadata[StreamEndMetadata] = "true"
+}
+
+// SendForStream sends the message on the bus and returns a channel which
+// receives all replies sent by a responder on the reply topic of fromTopic,
+// unlike SendForReply which resolves with only the first reply.
+//
+// Replies are correlated to the request by the message's CorrelationId,
+// which will be set to the message id if empty, so responders must copy it
+// into every reply. The channel is closed once a reply marked with
+// MarkStreamEnd is received, the marker itself is not delivered, or once
+// the timeout elapses.
+//
+// An empty replyGroup is resolved like with SendForReplyContext. An error
+// is returned without sending the message if listening on the reply topic
+// fails.
+func SendForStream(bus MessageBus, tm time.Duration, fromTopic Topic, replyGroup string, msg Message) (<-chan Message, error) {
+	if msg.Id.IsNil() {
+		msg.Id = nxid.New()
+	}
+	if msg.CorrelationId.IsNil() {
+		msg.CorrelationId = msg.Id
+	}
+
+	var correlationId = msg.CorrelationId
+	var replies = make(chan Message, DefaultStreamBuffer)
+	var ctx, canceler = context.WithTimeout(context.Background(), tm)
+
+	var closer sync.Mutex
+	var closed bool
+	var closeReplies = func() {
+		closer.Lock()
+		defer closer.Unlock()
+		if !closed {
+			closed = true
+			close(replies)
+		}
+	}
+
+	var closeListen, listenErr = listenForReplies(bus, fromTopic, replyGroup, TransportResponseFunc(func(_ context.Context, reply Message, _ Transport) MessageErr {
+		if reply.CorrelationId != correlationId {
+			return nil
+		}
+
+		if IsStreamEnd(reply) {
+			canceler()
+			return nil
+		}
+
+		closer.Lock()
+		defer closer.Unlock()
+		if closed {
+			return nil
+		}
+
+		select {
+		case replies <- reply:
+		case <-ctx.Done():
+		}
+		return nil
+	}))
+
+	if listenErr != nil {
+		canceler()
+		closeReplies()
+		return nil, listenErr
+	}
+
+	go func() {
+		<-ctx.Done()
+		closeListen()
+		closeReplies()
+	}()
+
+	bus.Send(msg)
+	return replies, nil
+}
@@ -0,0 +1,116 @@
+package sabuhp
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nxid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendForStream(t *testing.T) {
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var logger GoLogImpl
+	var relay = NewPbRelay(controlCtx, logger)
+
+	var mb BusBuilder
+	mb.ListenFunc = func(topic string, grp string, handler TransportResponse) Channel {
+		return relay.Group(topic, grp).Listen(handler)
+	}
+	mb.SendFunc = func(data ...Message) {
+		for _, msg := range data {
+			go relay.Handle(controlCtx, msg, Transport{Bus: &mb})
+		}
+	}
+
+	var responder = mb.Listen("search", "*", TransportResponseFunc(func(_ context.Context, message Message, tr Transport) MessageErr {
+		for i := 1; i <= 3; i++ {
+			var partial = NewMessage(message.Topic.ReplyTopic(), "responder", []byte(fmt.Sprintf("result %d", i)))
+			partial.CorrelationId = message.CorrelationId
+			tr.Bus.Send(partial)
+
+			// allow the partials to be delivered in order.
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		var final = NewMessage(message.Topic.ReplyTopic(), "responder", nil)
+		final.CorrelationId = message.CorrelationId
+		MarkStreamEnd(&final)
+		tr.Bus.Send(final)
+		return nil
+	}))
+	defer responder.Close()
+
+	var request = NewMessage(T("search"), "me", []byte("query"))
+	var replies, sendErr = SendForStream(&mb, 5*time.Second, request.Topic, "*", request)
+	require.NoError(t, sendErr)
+
+	var received []string
+	for reply := range replies {
+		received = append(received, string(reply.Bytes))
+	}
+
+	require.Equal(t, []string{"result 1", "result 2", "result 3"}, received)
+}
+
+func TestSendForStream_Timeout(t *testing.T) {
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var logger GoLogImpl
+	var relay = NewPbRelay(controlCtx, logger)
+
+	var mb BusBuilder
+	mb.ListenFunc = func(topic string, grp string, handler TransportResponse) Channel {
+		return relay.Group(topic, grp).Listen(handler)
+	}
+	mb.SendFunc = func(data ...Message) {}
+
+	var request = NewMessage(T("search"), "me", []byte("query"))
+	var replies, sendErr = SendForStream(&mb, 50*time.Millisecond, request.Topic, "*", request)
+	require.NoError(t, sendErr)
+
+	select {
+	case _, ok := <-replies:
+		require.False(t, ok)
+	case <-time.After(time.Second):
+		require.Fail(t, "stream should have closed after the timeout")
+	}
+}
+
+func TestSendForStream_ListenError(t *testing.T) {
+	var listenErr = nerror.New("listen failed")
+
+	var sent bool
+	var mb BusBuilder
+	mb.ListenFunc = func(topic string, grp string, handler TransportResponse) Channel {
+		return &failedChannel{topic: topic, group: grp, err: listenErr}
+	}
+	mb.SendFunc = func(data ...Message) {
+		sent = true
+	}
+
+	var request = NewMessage(T("search"), "me", []byte("query"))
+	var replies, sendErr = SendForStream(&mb, time.Second, request.Topic, "*", request)
+	require.Error(t, sendErr)
+	require.True(t, nerror.IsAny(sendErr, listenErr))
+	require.Nil(t, replies)
+	require.False(t, sent)
+}
+
+type failedChannel struct {
+	topic string
+	group string
+	err   error
+}
+
+func (f *failedChannel) ID() nxid.ID   { return nxid.ID{} }
+func (f *failedChannel) Topic() string { return f.topic }
+func (f *failedChannel) Group() string { return f.group }
+func (f *failedChannel) Close()        {}
+func (f *failedChannel) Err() error    { return f.err }
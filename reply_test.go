@@ -0,0 +1,112 @@
+package sabuhp
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplyFuture_WithReply(t *testing.T) {
+	var ft = NewReplyFuture()
+	var reply = BasicMsg(T("hello"), "yo!", "me")
+
+	go ft.WithReply(reply)
+
+	var replyMsg, replyErr = ft.Get()
+	require.NoError(t, replyErr)
+	require.Equal(t, "yo!", string(replyMsg.Bytes))
+
+	// later resolutions are ignored.
+	ft.WithError(ErrReplyTimeout)
+
+	replyMsg, replyErr = ft.Get()
+	require.NoError(t, replyErr)
+	require.Equal(t, reply.Id, replyMsg.Id)
+}
+
+func TestReplyFuture_WithTimeout(t *testing.T) {
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	// no responder listens on the topic, so no reply ever arrives.
+	var bus = relayBus(controlCtx)
+
+	var ctx, canceler = context.WithTimeout(controlCtx, 50*time.Millisecond)
+	defer canceler()
+
+	var request = NewMessage(T("search"), "me", []byte("query"))
+	var _, replyErr = SendForReplyContext(ctx, bus, request.Topic, "caller", request).Get()
+	require.Equal(t, ErrReplyTimeout, replyErr)
+}
+
+func TestSendForReplyContext(t *testing.T) {
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var bus = relayBus(controlCtx)
+
+	// the responder holds on to requests, replying once all arrived.
+	var requests = make(chan Message, 3)
+	var responder = bus.Listen("search", "*", TransportResponseFunc(func(_ context.Context, message Message, _ Transport) MessageErr {
+		requests <- message
+		return nil
+	}))
+	defer responder.Close()
+
+	var futures = make([]*ReplyFuture, 3)
+	for index := range futures {
+		var request = NewMessage(T("search"), "me", []byte(fmt.Sprintf("query %d", index)))
+		futures[index] = SendForReplyContext(controlCtx, bus, request.Topic, fmt.Sprintf("caller-%d", index), request)
+	}
+
+	var received = make([]Message, 0, 3)
+	for range futures {
+		received = append(received, <-requests)
+	}
+
+	// every call returned right away, none is resolved yet.
+	for _, ft := range futures {
+		select {
+		case <-ft.Done():
+			require.Fail(t, "future should not resolve before its reply")
+		default:
+		}
+	}
+
+	// reply in reverse order of the requests.
+	for index := len(received) - 1; index >= 0; index-- {
+		var reply = NewMessage(received[index].Topic.ReplyTopic(), "responder", received[index].Bytes)
+		reply.CorrelationId = received[index].CorrelationId
+		bus.Send(reply)
+	}
+
+	for index, ft := range futures {
+		var reply, replyErr = ft.Get()
+		require.NoError(t, replyErr)
+		require.Equal(t, fmt.Sprintf("query %d", index), string(reply.Bytes))
+	}
+}
+
+func TestSendForReplyContext_Deadline(t *testing.T) {
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var bus = relayBus(controlCtx)
+
+	var deadlineCtx, deadlineCancel = context.WithTimeout(controlCtx, 50*time.Millisecond)
+	defer deadlineCancel()
+
+	var _, timeoutErr = SendForReplyContext(deadlineCtx, bus, T("search"), "*", BasicMsg(T("search"), "query", "me")).Get()
+	require.Equal(t, ErrReplyTimeout, timeoutErr)
+
+	var cancelCtx, cancel = context.WithCancel(controlCtx)
+	var ft = SendForReplyContext(cancelCtx, bus, T("search"), "*", BasicMsg(T("search"), "query", "me"))
+	cancel()
+
+	var _, cancelErr = ft.Get()
+	require.True(t, nerror.IsAny(cancelErr, context.Canceled))
+}
@@ -0,0 +1,33 @@
+package sabuhp
+
+import (
+	"context"
+	"time"
+)
+
+// WithHandlerRetry returns a TransportResponse which retries delivery of a
+// message to the handler when it fails with a temporary MessageErr (see
+// WrapTemporaryErr), up to maxAttempts deliveries in total, waiting for the
+// duration returned by retry between attempts.
+//
+// Messages are retried in place without being fetched again from the bus,
+// non-temporary errors and the last attempt's error are returned as is.
+func WithHandlerRetry(maxAttempts int, retry RetryFunc, handler TransportResponse) TransportResponse {
+	return TransportResponseFunc(func(ctx context.Context, message Message, transport Transport) MessageErr {
+		var attempt int
+		for {
+			attempt++
+
+			var handleErr = handler.Handle(ctx, message, transport)
+			if handleErr == nil || attempt >= maxAttempts || !IsTemporary(handleErr) {
+				return handleErr
+			}
+
+			select {
+			case <-ctx.Done():
+				return handleErr
+			case <-time.After(retry(attempt)):
+			}
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package sabuhp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func noDelay(_ int) time.Duration {
+	return 0
+}
+
+func TestWithHandlerRetry(t *testing.T) {
+	var invocations int
+	var handler = WithHandlerRetry(5, noDelay, TransportResponseFunc(func(ctx context.Context, message Message, transport Transport) MessageErr {
+		invocations++
+		if invocations < 3 {
+			return WrapTemporaryErr(errors.New("unavailable"), false)
+		}
+		return nil
+	}))
+
+	var handleErr = handler.Handle(context.Background(), BasicMsg(T("hello"), "yo!", "me"), Transport{})
+	require.Nil(t, handleErr)
+	require.Equal(t, 3, invocations)
+}
+
+func TestWithHandlerRetry_SkipsNonTemporaryErrors(t *testing.T) {
+	var invocations int
+	var handler = WithHandlerRetry(5, noDelay, TransportResponseFunc(func(ctx context.Context, message Message, transport Transport) MessageErr {
+		invocations++
+		return WrapErr(errors.New("invalid"), false)
+	}))
+
+	var handleErr = handler.Handle(context.Background(), BasicMsg(T("hello"), "yo!", "me"), Transport{})
+	require.Error(t, handleErr)
+	require.False(t, IsTemporary(handleErr))
+	require.Equal(t, 1, invocations)
+}
+
+func TestWithHandlerRetry_GivesUpAfterMaxAttempts(t *testing.T) {
+	var invocations int
+	var handler = WithHandlerRetry(3, noDelay, TransportResponseFunc(func(ctx context.Context, message Message, transport Transport) MessageErr {
+		invocations++
+		return WrapTemporaryErr(errors.New("unavailable"), false)
+	}))
+
+	var handleErr = handler.Handle(context.Background(), BasicMsg(T("hello"), "yo!", "me"), Transport{})
+	require.Error(t, handleErr)
+	require.True(t, IsTemporary(handleErr))
+	require.Equal(t, 3, invocations)
+}
@@ -3,13 +3,13 @@ package sabuhp
 import (
 	"context"
 	"net/http"
+	"sort"
 	"time"
 
-	"github.com/influx6/npkg/nthen"
-
 	"github.com/influx6/npkg"
 	"github.com/influx6/npkg/njson"
 	"github.com/influx6/npkg/nnet"
+	"github.com/influx6/npkg/nxid"
 )
 
 type RetryFunc func(last int) time.Duration
@@ -18,11 +18,44 @@ type Logger interface {
 	njson.Logger
 }
 
+// LogFields are structured fields added to every log entry
+// of a Logger created with WithFields.
+type LogFields map[string]string
+
+// WithFields returns a Logger which adds the fields to every log
+// entry before passing it on to the logger, e.g topic and group of a
+// subscription, so all entries of the subscription can be found by them.
+func WithFields(logger Logger, fields LogFields) Logger {
+	var keys = make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return &fieldLogger{logger: logger, keys: keys, fields: fields}
+}
+
+type fieldLogger struct {
+	logger Logger
+	keys   []string
+	fields LogFields
+}
+
+func (f *fieldLogger) Log(cb *njson.JSON) {
+	for _, key := range f.keys {
+		cb.String(key, f.fields[key])
+	}
+	f.logger.Log(cb)
+}
+
 // Channel represents a generated subscription on a
 // topic which provides the giving callback an handler
 // to define the point at which the channel should be
 // closed and stopped from receiving updates.
+//
+// ID uniquely identifies the subscription, allowing monitoring code
+// to correlate channels to the subscriptions they belong to.
 type Channel interface {
+	ID() nxid.ID
 	Topic() string
 	Group() string
 	Close()
@@ -66,16 +99,39 @@ func WrapErrWithStatusCode(err error, code int, shouldAck bool) MessageErr {
 	}
 }
 
+// WrapTemporaryErr returns a MessageErr marking a transient failure, which
+// handlers wrapped with WithHandlerRetry retry.
+func WrapTemporaryErr(err error, shouldAck bool) MessageErr {
+	return messageErr{
+		code:      500,
+		error:     err,
+		shouldAck: shouldAck,
+		temporary: true,
+	}
+}
+
+// IsTemporary returns true if the error reports itself as a
+// temporary failure with a Temporary() bool method.
+func IsTemporary(err error) bool {
+	var temp, ok = err.(interface{ Temporary() bool })
+	return ok && temp.Temporary()
+}
+
 type messageErr struct {
 	error
 	code      int
 	shouldAck bool
+	temporary bool
 }
 
 func (m messageErr) StatusCode() int {
 	return m.code
 }
 
+func (m messageErr) Temporary() bool {
+	return m.temporary
+}
+
 func (m messageErr) ShouldAck() bool {
 	return m.shouldAck
 }
@@ -226,7 +282,7 @@ func (t TransportResponseFunc) Handle(ctx context.Context, message Message, tr T
 type MessageBus interface {
 	Send(data ...Message)
 	Listen(topic string, grp string, handler TransportResponse) Channel
-	SendForReply(tm time.Duration, fromTopic Topic, replyGroup string, data ...Message) *nthen.Future
+	SendForReply(tm time.Duration, fromTopic Topic, replyGroup string, data ...Message) *ReplyFuture
 }
 
 type (
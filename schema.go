@@ -0,0 +1,219 @@
+package sabuhp
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+)
+
+// ErrSchemaViolation is returned for a message whose payload does not
+// match the schema registered for its topic.
+var ErrSchemaViolation = nerror.New("message violates the schema of its topic")
+
+// ErrIncompatibleSchema is returned when registering a schema for a topic
+// which can not read payloads valid under the schema registered before.
+var ErrIncompatibleSchema = nerror.New("schema is not backward compatible")
+
+// Schema describes the payloads allowed on a topic.
+type Schema interface {
+	// Validate returns an error if the payload violates the schema.
+	Validate(payload []byte) error
+
+	// CompatibleWith returns an error if payloads valid under the previous
+	// schema are not valid under this one, i.e if consumers moving to
+	// this schema could no longer read messages already published.
+	CompatibleWith(previous Schema) error
+}
+
+// JSONType is the type of a property of a JSONSchema.
+type JSONType string
+
+const (
+	JSONString  JSONType = "string"
+	JSONNumber  JSONType = "number"
+	JSONBoolean JSONType = "boolean"
+	JSONObject  JSONType = "object"
+	JSONArray   JSONType = "array"
+)
+
+var _ Schema = (*JSONSchema)(nil)
+
+// JSONSchema is a Schema for json object payloads, covering the subset of
+// JSON Schema most topics need: the required properties and the types
+// of known properties. Properties not listed are allowed with any type.
+type JSONSchema struct {
+	Required   []string
+	Properties map[string]JSONType
+}
+
+func (s JSONSchema) Validate(payload []byte) error {
+	var object map[string]interface{}
+	if jsonErr := json.Unmarshal(payload, &object); jsonErr != nil {
+		return nerror.Wrap(ErrSchemaViolation, "payload is not a json object: %s", jsonErr)
+	}
+
+	for _, name := range s.Required {
+		if _, ok := object[name]; !ok {
+			return nerror.Wrap(ErrSchemaViolation, "required property %q is missing", name)
+		}
+	}
+
+	for name, value := range object {
+		var expected, known = s.Properties[name]
+		if !known || value == nil {
+			continue
+		}
+		if actual := jsonTypeOf(value); actual != expected {
+			return nerror.Wrap(ErrSchemaViolation, "property %q is a %s, expected a %s", name, actual, expected)
+		}
+	}
+	return nil
+}
+
+// CompatibleWith allows relaxing required properties and dropping the
+// type of a property, but neither requiring a property which was optional
+// nor typing a property which was untyped or changing its type: old
+// payloads may hold any value for a property without a type.
+func (s JSONSchema) CompatibleWith(previous Schema) error {
+	var old, ok = previous.(JSONSchema)
+	if !ok {
+		return nerror.Wrap(ErrIncompatibleSchema, "previous schema is a %T", previous)
+	}
+
+	var wasRequired = map[string]bool{}
+	for _, name := range old.Required {
+		wasRequired[name] = true
+	}
+	for _, name := range s.Required {
+		if !wasRequired[name] {
+			return nerror.Wrap(ErrIncompatibleSchema, "property %q became required", name)
+		}
+	}
+
+	for name, newType := range s.Properties {
+		var oldType, typed = old.Properties[name]
+		if !typed {
+			return nerror.Wrap(ErrIncompatibleSchema, "untyped property %q became a %s", name, newType)
+		}
+		if newType != oldType {
+			return nerror.Wrap(ErrIncompatibleSchema, "property %q changed from %s to %s", name, oldType, newType)
+		}
+	}
+	return nil
+}
+
+func jsonTypeOf(value interface{}) JSONType {
+	switch value.(type) {
+	case string:
+		return JSONString
+	case float64:
+		return JSONNumber
+	case bool:
+		return JSONBoolean
+	case []interface{}:
+		return JSONArray
+	}
+	return JSONObject
+}
+
+// SchemaRegistry holds the schema of each topic.
+type SchemaRegistry struct {
+	mu      sync.RWMutex
+	schemas map[string]Schema
+}
+
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{schemas: map[string]Schema{}}
+}
+
+// Register sets the schema of the topic. A schema replacing an earlier
+// one must be compatible with it, else ErrIncompatibleSchema is returned
+// and the earlier schema is kept.
+func (s *SchemaRegistry) Register(topic string, schema Schema) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if previous, ok := s.schemas[topic]; ok {
+		if compatibleErr := schema.CompatibleWith(previous); compatibleErr != nil {
+			return compatibleErr
+		}
+	}
+	s.schemas[topic] = schema
+	return nil
+}
+
+// Schema returns the schema of the topic.
+func (s *SchemaRegistry) Schema(topic string) (Schema, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var schema, ok = s.schemas[topic]
+	return schema, ok
+}
+
+// Validate validates the payload of the message against the schema of its
+// topic, messages of topics without a schema are always valid.
+func (s *SchemaRegistry) Validate(msg Message) error {
+	var schema, ok = s.Schema(msg.Topic.String())
+	if !ok {
+		return nil
+	}
+	return schema.Validate(msg.Bytes)
+}
+
+var _ MessageBus = (*SchemaBus)(nil)
+
+// SchemaBus wraps a MessageBus, rejecting messages which violate the
+// schema registered for their topic. A rejected message is not sent and
+// its Future, if any, is resolved with the validation error.
+type SchemaBus struct {
+	bus      MessageBus
+	registry *SchemaRegistry
+}
+
+func NewSchemaBus(bus MessageBus, registry *SchemaRegistry) *SchemaBus {
+	return &SchemaBus{bus: bus, registry: registry}
+}
+
+// Validate returns the first validation error of the messages.
+func (s *SchemaBus) Validate(data ...Message) error {
+	for _, msg := range data {
+		if validateErr := s.registry.Validate(msg); validateErr != nil {
+			return validateErr
+		}
+	}
+	return nil
+}
+
+func (s *SchemaBus) Send(data ...Message) {
+	var valid = make([]Message, 0, len(data))
+	for _, msg := range data {
+		if validateErr := s.registry.Validate(msg); validateErr != nil {
+			if msg.Future != nil {
+				msg.Future.WithError(validateErr)
+			}
+			continue
+		}
+		valid = append(valid, msg)
+	}
+
+	if len(valid) > 0 {
+		s.bus.Send(valid...)
+	}
+}
+
+// SendForReply sends the messages only if all are valid, otherwise the
+// returned future resolves with the validation error.
+func (s *SchemaBus) SendForReply(tm time.Duration, fromTopic Topic, replyGroup string, data ...Message) *ReplyFuture {
+	if validateErr := s.Validate(data...); validateErr != nil {
+		var ft = NewReplyFuture()
+		ft.WithError(validateErr)
+		return ft
+	}
+	return s.bus.SendForReply(tm, fromTopic, replyGroup, data...)
+}
+
+func (s *SchemaBus) Listen(topic string, grp string, handler TransportResponse) Channel {
+	return s.bus.Listen(topic, grp, handler)
+}
@@ -0,0 +1,84 @@
+package sabuhp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nthen"
+	"github.com/stretchr/testify/require"
+)
+
+var orderSchema = JSONSchema{
+	Required:   []string{"id"},
+	Properties: map[string]JSONType{"id": JSONString, "total": JSONNumber},
+}
+
+func TestSchemaBus(t *testing.T) {
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var registry = NewSchemaRegistry()
+	require.NoError(t, registry.Register("orders", orderSchema))
+
+	var bus = NewSchemaBus(relayBus(controlCtx), registry)
+
+	var received = make(chan Message, 2)
+	var channel = bus.Listen("orders", "", TransportResponseFunc(func(ctx context.Context, message Message, transport Transport) MessageErr {
+		received <- message
+		return nil
+	}))
+	defer channel.Close()
+
+	bus.Send(BasicMsg(T("orders"), `{"id": "order-1", "total": 20}`, "me"))
+	select {
+	case msg := <-received:
+		require.Equal(t, `{"id": "order-1", "total": 20}`, string(msg.Bytes))
+	case <-time.After(time.Second):
+		require.Fail(t, "valid message should be delivered")
+	}
+
+	var invalid = BasicMsg(T("orders"), `{"id": 1}`, "me")
+	invalid.Future = nthen.NewFuture()
+	bus.Send(invalid)
+
+	require.True(t, nerror.IsAny(invalid.Future.Err(), ErrSchemaViolation))
+	require.Len(t, received, 0)
+
+	var _, replyErr = bus.SendForReply(time.Second, T("me"), "", BasicMsg(T("orders"), `{"total": 20}`, "me")).Get()
+	require.True(t, nerror.IsAny(replyErr, ErrSchemaViolation))
+}
+
+func TestSchemaRegistry_CompatibleUpdates(t *testing.T) {
+	var registry = NewSchemaRegistry()
+	require.NoError(t, registry.Register("orders", orderSchema))
+
+	// dropping the type of a property keeps old payloads valid.
+	require.NoError(t, registry.Register("orders", JSONSchema{
+		Required:   []string{"id"},
+		Properties: map[string]JSONType{"id": JSONString},
+	}))
+
+	// old payloads may hold any value for an untyped property.
+	var untypedErr = registry.Register("orders", JSONSchema{
+		Required:   []string{"id"},
+		Properties: map[string]JSONType{"id": JSONString, "note": JSONString},
+	})
+	require.True(t, nerror.IsAny(untypedErr, ErrIncompatibleSchema))
+
+	var requiredErr = registry.Register("orders", JSONSchema{
+		Required:   []string{"id", "customer"},
+		Properties: map[string]JSONType{"id": JSONString},
+	})
+	require.True(t, nerror.IsAny(requiredErr, ErrIncompatibleSchema))
+
+	var typeErr = registry.Register("orders", JSONSchema{
+		Required:   []string{"id"},
+		Properties: map[string]JSONType{"id": JSONNumber},
+	})
+	require.True(t, nerror.IsAny(typeErr, ErrIncompatibleSchema))
+
+	// the rejected updates kept the last compatible schema.
+	require.NoError(t, registry.Validate(BasicMsg(T("orders"), `{"id": "order-1", "total": "ten", "note": 3}`, "me")))
+}
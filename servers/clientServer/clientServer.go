@@ -1,10 +1,16 @@
 package clientServer
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"io/ioutil"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ewe-studios/sabuhp/codecs"
@@ -31,8 +37,25 @@ import (
 
 const (
 	DefaultMaxSize = 4096
+
+	// DefaultPublishReadTimeout is the time allowed to read the body of
+	// a publish request.
+	DefaultPublishReadTimeout = 10 * time.Second
+
+	// DefaultSSERetryAfter is the Retry-After sent to sse clients
+	// rejected for exceeding the MaxSSEConnections.
+	DefaultSSERetryAfter = 5 * time.Second
 )
 
+// DefaultPublishContentTypes are the content types accepted by the
+// publish route, besides sabuhp.MessageContentType for encoded messages,
+// when the decoder does not report the content types of its codec.
+var DefaultPublishContentTypes = []string{
+	"application/json",
+	"application/octet-stream",
+	"text/plain",
+}
+
 var (
 	DefaultCodec = &codecs.MessageMsgPackCodec{}
 	upgrader     = &websocket.Upgrader{
@@ -105,6 +128,80 @@ func WithHttpEncoder(this sabuhp.HttpEncoder) Mod {
 	}
 }
 
+// WithHealthEndpoints mounts liveness and readiness endpoints on the given
+// routes, an empty route leaves that endpoint unregistered.
+//
+// The liveness endpoint responds with 200 while the http server is healthy,
+// the readiness endpoint additionally requires the server to be listening
+// and the bus to report itself as healthy, else both respond with 503.
+func WithHealthEndpoints(live string, ready string) Mod {
+	return func(cs *ClientServer) {
+		cs.LivenessRoute = live
+		cs.ReadinessRoute = ready
+	}
+}
+
+// WithStatsEndpoint mounts an endpoint on the given route which renders
+// the ClientServer.Stats as json.
+func WithStatsEndpoint(route string) Mod {
+	return func(cs *ClientServer) {
+		cs.StatsRoute = route
+	}
+}
+
+// Authenticator authorizes a http request, returning an error if the
+// request is not allowed.
+type Authenticator func(request *http.Request) error
+
+// WithAuthenticator sets the authenticator of the publish route.
+func WithAuthenticator(auth Authenticator) Mod {
+	return func(cs *ClientServer) {
+		cs.Authenticator = auth
+	}
+}
+
+// WithPublishRoute mounts an endpoint on the given route which publishes
+// the body of POST requests to the bus, responding with 202 once sent.
+//
+// A body with the sabuhp.MessageContentType is decoded as a Message,
+// any other body becomes the payload of a message to the topic of the
+// "topic" query parameter.
+func WithPublishRoute(route string) Mod {
+	return func(cs *ClientServer) {
+		cs.PublishRoute = route
+	}
+}
+
+// WithPublishLimits sets the maximum body size and read timeout of
+// requests to the publish route.
+//
+// The limit of the HttpDecoder applies as well, so a larger maxBytes
+// requires a decoder with a matching limit (see WithHttpDecoder).
+func WithPublishLimits(maxBytes int64, readTimeout time.Duration) Mod {
+	return func(cs *ClientServer) {
+		cs.MaxPublishBytes = maxBytes
+		cs.PublishReadTimeout = readTimeout
+	}
+}
+
+// WithPublishContentTypes sets the content types of raw bodies accepted
+// by the publish route, replacing the content types of the decoder.
+func WithPublishContentTypes(contentTypes ...string) Mod {
+	return func(cs *ClientServer) {
+		cs.PublishContentTypes = contentTypes
+	}
+}
+
+// WithMaxSSEConnections limits the number of live sse connections, further
+// clients are rejected with a 503 and a Retry-After of retryAfter, which
+// defaults to DefaultSSERetryAfter.
+func WithMaxSSEConnections(max int, retryAfter time.Duration) Mod {
+	return func(cs *ClientServer) {
+		cs.MaxSSEConnections = max
+		cs.SSERetryAfter = retryAfter
+	}
+}
+
 func WithMux(config radar.MuxConfig) Mod {
 	return func(cs *ClientServer) {
 		if config.NotFound == nil {
@@ -143,6 +240,21 @@ type ClientServer struct {
 	WebsocketHeader gorillapub.ResponseHeadersFromRequest
 	WebsocketServer *gorillapub.GorillaHub
 	StreamBinder    *sabuhp.StreamBusRelay
+	LivenessRoute   string
+	ReadinessRoute  string
+	StatsRoute      string
+	PublishRoute    string
+	Authenticator   Authenticator
+
+	MaxPublishBytes     int64
+	PublishReadTimeout  time.Duration
+	PublishContentTypes []string
+
+	MaxSSEConnections int
+	SSERetryAfter     time.Duration
+
+	serving        uint32
+	sseConnections int64
 }
 
 func New(ctx context.Context, logger sabuhp.Logger, bus sabuhp.MessageBus, mods ...Mod) *ClientServer {
@@ -198,6 +310,13 @@ func (c *ClientServer) Start() {
 	})
 }
 
+// Shutdown ends all sse streams and gracefully shuts down the http
+// server, blocking till it's closed.
+func (c *ClientServer) Shutdown() {
+	c.HttpServer.Close()
+	c.HttpServer.Wait()
+}
+
 // Wait will block till all services are closed and existed included created
 // goroutines. You can confident use wait to block and know that once done
 // there is zero chances of goroutine or memory leak as regards started resources.
@@ -270,8 +389,28 @@ func (c *ClientServer) initializeComponents() {
 		c.HttpServer = serverpub.NewServer(c.Mux, time.Minute)
 	}
 
+	if c.MaxPublishBytes <= 0 {
+		c.MaxPublishBytes = DefaultMaxSize
+	}
+
+	if c.PublishReadTimeout <= 0 {
+		c.PublishReadTimeout = DefaultPublishReadTimeout
+	}
+
+	if c.PublishContentTypes == nil {
+		c.PublishContentTypes = c.decoderContentTypes()
+	}
+
+	if c.SSERetryAfter <= 0 {
+		c.SSERetryAfter = DefaultSSERetryAfter
+	}
+
 	c.HttpServer.ReadyFunc = c.readyServer
 
+	// end sse streams first, so the http server's shutdown does not
+	// wait on them.
+	c.HttpServer.OnShutdown = append(c.HttpServer.OnShutdown, c.SSEServer.Shutdown)
+
 	if c.Upgrader == nil {
 		c.Upgrader = upgrader
 	}
@@ -299,18 +438,245 @@ func (c *ClientServer) initializeComponents() {
 		writer.WriteHeader(http.StatusOK)
 	}), "GET", "HEAD")
 
+	if len(c.LivenessRoute) != 0 {
+		c.Mux.Http(c.LivenessRoute, sabuhp.HandlerFunc(c.livenessHandler), "GET", "HEAD")
+	}
+
+	if len(c.StatsRoute) != 0 {
+		c.Mux.Http(c.StatsRoute, sabuhp.HandlerFunc(c.statsHandler), "GET", "HEAD")
+	}
+
+	if len(c.ReadinessRoute) != 0 {
+		c.Mux.Http(c.ReadinessRoute, sabuhp.HandlerFunc(c.readinessHandler), "GET", "HEAD")
+	}
+
+	if len(c.PublishRoute) != 0 {
+		c.Mux.Http(c.PublishRoute, sabuhp.HandlerFunc(c.publishHandler), "POST")
+	}
+
 	// setup stream routes for http
 	c.Mux.Http("/streams/http", c.HttpServlet)
 
 	// setup stream routes for sse
-	c.Mux.Http("/streams/sse", c.SSEServer, "GET", "HEAD")
+	c.Mux.Http("/streams/sse", sabuhp.HandlerFunc(c.sseHandler), "GET", "HEAD")
 
 	// setup routes for websocket
 	var websocketHandler = gorillapub.UpgraderHandler(c.Logger, c.WebsocketServer, c.Upgrader, c.WebsocketHeader)
 	c.Mux.Http("/streams/ws", websocketHandler, "GET", "HEAD")
 }
 
+// Stats are gauges of the current load of a ClientServer.
+type Stats struct {
+	// SSEConnections is the number of live sse connections.
+	SSEConnections int `json:"sse_connections"`
+
+	// Subscribers is the number of socket subscribers per topic.
+	Subscribers map[string]int `json:"subscribers"`
+}
+
+// Stats returns the current connection and subscriber gauges.
+func (c *ClientServer) Stats() Stats {
+	c.Init()
+	return Stats{
+		SSEConnections: c.SSEServer.Connections(),
+		Subscribers:    c.BusRelay.Relay.Subscribers(),
+	}
+}
+
+func (c *ClientServer) statsHandler(writer http.ResponseWriter, request *http.Request, params sabuhp.Params) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(writer).Encode(c.Stats()); err != nil {
+		var logMessage = njson.MJSON("failed to render response")
+		logMessage.String("addr", c.Addr)
+		logMessage.Int("_level", int(npkg.ERROR))
+		logMessage.Error("error", err)
+		c.Logger.Log(logMessage)
+	}
+}
+
+func (c *ClientServer) publishHandler(writer http.ResponseWriter, request *http.Request, params sabuhp.Params) {
+	var logStack = njson.Log(c.Logger)
+
+	if c.Authenticator != nil {
+		if authErr := c.Authenticator(request); authErr != nil {
+			logStack.New().
+				LWarn().
+				Message("rejected unauthorized publish request").
+				String("remote_addr", request.RemoteAddr).
+				String("error", authErr.Error()).
+				End()
+			writer.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var mediaType, _, mediaErr = mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if mediaErr != nil || !c.acceptsContentType(mediaType) {
+		writer.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if status := c.readPublishBody(writer, request); status != 0 {
+		logStack.New().
+			LWarn().
+			Message("rejected publish request body").
+			String("remote_addr", request.RemoteAddr).
+			Int("status", status).
+			End()
+		writer.WriteHeader(status)
+		return
+	}
+
+	var message, decodeErr = c.Decoder.Decode(request, params)
+	if decodeErr != nil {
+		logStack.New().
+			LError().
+			Message("failed to decode publish request").
+			String("remote_addr", request.RemoteAddr).
+			String("error", decodeErr.Error()).
+			End()
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// raw bodies are published to the topic of the query.
+	if mediaType != sabuhp.MessageContentType {
+		var topic = request.URL.Query().Get("topic")
+		if len(topic) == 0 {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		message.Topic = sabuhp.T(topic)
+	}
+
+	c.Bus.Send(message)
+
+	logStack.New().
+		LInfo().
+		Message("published message from http request").
+		String("topic", message.Topic.String()).
+		String("message_id", message.Id.String()).
+		String("remote_addr", request.RemoteAddr).
+		End()
+
+	writer.WriteHeader(http.StatusAccepted)
+}
+
+// decoderContentTypes returns the content types decoded by the codec of
+// the Decoder if it's sabuhp.ContentTyped, else DefaultPublishContentTypes.
+func (c *ClientServer) decoderContentTypes() []string {
+	if typed, ok := c.Decoder.(sabuhp.ContentTyped); ok {
+		if contentTypes := typed.ContentTypes(); len(contentTypes) != 0 {
+			return contentTypes
+		}
+	}
+	return DefaultPublishContentTypes
+}
+
+func (c *ClientServer) acceptsContentType(mediaType string) bool {
+	if mediaType == sabuhp.MessageContentType {
+		return true
+	}
+	for _, contentType := range c.PublishContentTypes {
+		if strings.EqualFold(contentType, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// readPublishBody reads the body of the publish request within the
+// MaxPublishBytes and PublishReadTimeout limits, replacing the request
+// body with the read bytes. It returns the status to reject the request
+// with if a limit was crossed, else 0.
+func (c *ClientServer) readPublishBody(writer http.ResponseWriter, request *http.Request) int {
+	if request.ContentLength > c.MaxPublishBytes {
+		return http.StatusRequestEntityTooLarge
+	}
+
+	var body = http.MaxBytesReader(writer, request.Body, c.MaxPublishBytes)
+
+	type readResult struct {
+		data []byte
+		err  error
+	}
+
+	var read = make(chan readResult, 1)
+	go func() {
+		var data, readErr = ioutil.ReadAll(body)
+		read <- readResult{data: data, err: readErr}
+	}()
+
+	var timer = time.NewTimer(c.PublishReadTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		// closing the body would wait for the pending read, which holds
+		// the lock of the body, so the connection is closed after the
+		// response instead, which ends the read.
+		writer.Header().Set("Connection", "close")
+		return http.StatusRequestTimeout
+	case result := <-read:
+		if result.err != nil {
+			// the reader fails once it read the limit without reaching the end.
+			if int64(len(result.data)) >= c.MaxPublishBytes {
+				return http.StatusRequestEntityTooLarge
+			}
+			return http.StatusBadRequest
+		}
+		request.Body = ioutil.NopCloser(bytes.NewReader(result.data))
+		return 0
+	}
+}
+
+// sseHandler hands streams to the SSEServer while fewer than the
+// MaxSSEConnections are live, a stream holds its slot till it ends.
+func (c *ClientServer) sseHandler(writer http.ResponseWriter, request *http.Request, params sabuhp.Params) {
+	if c.MaxSSEConnections <= 0 || request.Method == http.MethodHead {
+		c.SSEServer.Handle(writer, request, params)
+		return
+	}
+
+	defer atomic.AddInt64(&c.sseConnections, -1)
+	if atomic.AddInt64(&c.sseConnections, 1) > int64(c.MaxSSEConnections) {
+		var retryAfter = int(c.SSERetryAfter.Round(time.Second) / time.Second)
+		writer.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	c.SSEServer.Handle(writer, request, params)
+}
+
+func (c *ClientServer) livenessHandler(writer http.ResponseWriter, request *http.Request, params sabuhp.Params) {
+	if err := c.HttpServer.Health.Ping(); err != nil {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (c *ClientServer) readinessHandler(writer http.ResponseWriter, request *http.Request, params sabuhp.Params) {
+	if atomic.LoadUint32(&c.serving) == 0 {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if err := c.HttpServer.Health.Ping(); err != nil {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if !sabuhp.IsHealthy(c.Bus) {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	writer.WriteHeader(http.StatusOK)
+}
+
 func (c *ClientServer) readyServer() {
+	atomic.StoreUint32(&c.serving, 1)
+
 	var logMessage = njson.MJSON("http server is ready")
 	logMessage.String("addr", c.Addr)
 	logMessage.Int("_level", int(npkg.INFO))
@@ -0,0 +1,415 @@
+package clientServer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/influx6/npkg/nxid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ewe-studios/sabuhp"
+	"github.com/ewe-studios/sabuhp/bus/membus"
+	"github.com/ewe-studios/sabuhp/codecs"
+	"github.com/ewe-studios/sabuhp/sockets/ssepub"
+	"github.com/ewe-studios/sabuhp/testingutils"
+)
+
+type noopChannel struct {
+	topic string
+	group string
+}
+
+func (n noopChannel) ID() nxid.ID   { return nxid.ID{} }
+func (n noopChannel) Topic() string { return n.topic }
+func (n noopChannel) Group() string { return n.group }
+func (n noopChannel) Err() error    { return nil }
+func (n noopChannel) Close()        {}
+
+type healthBus struct {
+	healthy uint32
+}
+
+func (h *healthBus) Healthy() bool {
+	return atomic.LoadUint32(&h.healthy) == 1
+}
+
+func (h *healthBus) Send(data ...sabuhp.Message) {}
+
+func (h *healthBus) SendForReply(tm time.Duration, fromTopic sabuhp.Topic, replyGroup string, data ...sabuhp.Message) *sabuhp.ReplyFuture {
+	var ft = sabuhp.NewReplyFuture()
+	ft.WithError(sabuhp.ErrReplyTimeout)
+	return ft
+}
+
+func (h *healthBus) Listen(topic string, grp string, handler sabuhp.TransportResponse) sabuhp.Channel {
+	return noopChannel{topic: topic, group: grp}
+}
+
+func getStatus(t *testing.T, handler http.Handler, route string) int {
+	var req = httptest.NewRequest("GET", route, nil)
+	var recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder.Code
+}
+
+func TestClientServer_HealthEndpoints(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var bus = &healthBus{}
+	var cs = New(ctx, logger, bus, WithHealthEndpoints("/healthz", "/readyz"))
+	cs.Init()
+
+	require.Equal(t, http.StatusOK, getStatus(t, cs.Mux, "/healthz"))
+	require.Equal(t, http.StatusServiceUnavailable, getStatus(t, cs.Mux, "/readyz"))
+
+	// server is listening but bus is yet to connect.
+	cs.HttpServer.ReadyFunc()
+	require.Equal(t, http.StatusOK, getStatus(t, cs.Mux, "/healthz"))
+	require.Equal(t, http.StatusServiceUnavailable, getStatus(t, cs.Mux, "/readyz"))
+
+	atomic.StoreUint32(&bus.healthy, 1)
+	require.Equal(t, http.StatusOK, getStatus(t, cs.Mux, "/healthz"))
+	require.Equal(t, http.StatusOK, getStatus(t, cs.Mux, "/readyz"))
+
+	cs.HttpServer.Health.SetUnhealthy()
+	require.Equal(t, http.StatusServiceUnavailable, getStatus(t, cs.Mux, "/healthz"))
+	require.Equal(t, http.StatusServiceUnavailable, getStatus(t, cs.Mux, "/readyz"))
+}
+
+func openStream(t *testing.T, ctx context.Context, serverURL string, topic string) *http.Response {
+	var subscribe = sabuhp.Message{
+		Id:             nxid.New(),
+		Topic:          sabuhp.SUBSCRIBE,
+		SubscribeTo:    topic,
+		SubscribeGroup: "*",
+		ContentType:    sabuhp.MessageContentType,
+	}
+
+	var body, encodeErr = DefaultCodec.Encode(subscribe)
+	require.NoError(t, encodeErr)
+
+	var req, reqErr = http.NewRequestWithContext(ctx, "GET", serverURL+"/streams/sse", bytes.NewReader(body))
+	require.NoError(t, reqErr)
+	req.Header.Set(ssepub.ClientIdentificationHeader, nxid.New().String())
+
+	var res, resErr = http.DefaultClient.Do(req)
+	require.NoError(t, resErr)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	return res
+}
+
+func TestClientServer_Stats(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var cs = New(ctx, logger, &healthBus{}, WithStatsEndpoint("/_stats"))
+	cs.Init()
+
+	var httpServer = httptest.NewServer(cs.Mux)
+	defer httpServer.Close()
+
+	require.Equal(t, 0, cs.Stats().SSEConnections)
+
+	var firstCtx, firstCancel = context.WithCancel(ctx)
+	var first = openStream(t, firstCtx, httpServer.URL, "orders")
+
+	var secondCtx, secondCancel = context.WithCancel(ctx)
+	defer secondCancel()
+	var second = openStream(t, secondCtx, httpServer.URL, "orders")
+	defer second.Body.Close()
+
+	require.Eventually(t, func() bool {
+		var stats = cs.Stats()
+		return stats.SSEConnections == 2 && stats.Subscribers["orders"] == 2
+	}, 3*time.Second, 10*time.Millisecond)
+
+	var statsResponse, statsErr = http.Get(httpServer.URL + "/_stats")
+	require.NoError(t, statsErr)
+
+	var rendered Stats
+	require.NoError(t, json.NewDecoder(statsResponse.Body).Decode(&rendered))
+	require.NoError(t, statsResponse.Body.Close())
+	require.Equal(t, 2, rendered.SSEConnections)
+	require.Equal(t, 2, rendered.Subscribers["orders"])
+
+	// abruptly drop the first client.
+	firstCancel()
+	_ = first.Body.Close()
+
+	require.Eventually(t, func() bool {
+		var stats = cs.Stats()
+		return stats.SSEConnections == 1 && stats.Subscribers["orders"] == 1
+	}, 3*time.Second, 10*time.Millisecond)
+}
+
+func TestClientServer_MaxSSEConnections(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var cs = New(ctx, logger, &healthBus{}, WithMaxSSEConnections(2, 3*time.Second))
+	cs.Init()
+
+	var httpServer = httptest.NewServer(cs.Mux)
+	defer httpServer.Close()
+
+	var firstCtx, firstCancel = context.WithCancel(ctx)
+	var first = openStream(t, firstCtx, httpServer.URL, "orders")
+
+	var secondCtx, secondCancel = context.WithCancel(ctx)
+	defer secondCancel()
+	var second = openStream(t, secondCtx, httpServer.URL, "orders")
+	defer second.Body.Close()
+
+	var reject = func() *http.Response {
+		var req, reqErr = http.NewRequestWithContext(ctx, "GET", httpServer.URL+"/streams/sse", nil)
+		require.NoError(t, reqErr)
+		req.Header.Set(ssepub.ClientIdentificationHeader, nxid.New().String())
+
+		var res, resErr = http.DefaultClient.Do(req)
+		require.NoError(t, resErr)
+		return res
+	}
+
+	var rejected = reject()
+	require.NoError(t, rejected.Body.Close())
+	require.Equal(t, http.StatusServiceUnavailable, rejected.StatusCode)
+	require.Equal(t, "3", rejected.Header.Get("Retry-After"))
+
+	// closing a stream frees its slot.
+	firstCancel()
+	_ = first.Body.Close()
+
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt64(&cs.sseConnections) == 1
+	}, 3*time.Second, 10*time.Millisecond)
+
+	var third = openStream(t, ctx, httpServer.URL, "orders")
+	defer third.Body.Close()
+}
+
+func TestClientServer_ShutdownEndsStreams(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var listener, listenErr = net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, listenErr)
+	var addr = listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	var cs = New(ctx, logger, &healthBus{}, WithHttpAddr(addr))
+	cs.Init()
+	cs.HttpServer.ShutdownDelay = 0
+	cs.HttpServer.ShutdownTimeout = 5 * time.Second
+	cs.Start()
+
+	require.Eventually(t, func() bool {
+		var res, err = http.Get("http://" + addr + "/health")
+		if err != nil {
+			return false
+		}
+		_ = res.Body.Close()
+		return res.StatusCode == http.StatusOK
+	}, 3*time.Second, 10*time.Millisecond)
+
+	var stream = openStream(t, ctx, "http://"+addr, "orders")
+	defer stream.Body.Close()
+
+	require.Eventually(t, func() bool {
+		return cs.Stats().SSEConnections == 1
+	}, 3*time.Second, 10*time.Millisecond)
+
+	var shutdown = make(chan struct{})
+	go func() {
+		cs.Shutdown()
+		close(shutdown)
+	}()
+
+	select {
+	case <-shutdown:
+	case <-time.After(3 * time.Second):
+		require.Fail(t, "shutdown should not wait on open sse streams")
+	}
+
+	// the stream was ended by the server.
+	var _, readErr = ioutil.ReadAll(stream.Body)
+	require.NoError(t, readErr)
+	require.Equal(t, 0, cs.Stats().SSEConnections)
+}
+
+func TestClientServer_PublishRoute(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var bus = membus.NewMemoryBus(ctx, logger)
+	var received = make(chan sabuhp.Message, 2)
+	bus.Listen("orders", "*", sabuhp.TransportResponseFunc(
+		func(ctx context.Context, message sabuhp.Message, transport sabuhp.Transport) sabuhp.MessageErr {
+			received <- message
+			return nil
+		}))
+
+	var cs = New(ctx, logger, bus,
+		WithPublishRoute("/publish"),
+		WithAuthenticator(func(request *http.Request) error {
+			if request.Header.Get("Authorization") != "Bearer secret" {
+				return errors.New("invalid token")
+			}
+			return nil
+		}),
+	)
+	cs.Init()
+
+	var publish = func(body []byte, contentType string, query string, auth bool) int {
+		var req = httptest.NewRequest("POST", "/publish"+query, bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		if auth {
+			req.Header.Set("Authorization", "Bearer secret")
+		}
+		var recorder = httptest.NewRecorder()
+		cs.Mux.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	require.Equal(t, http.StatusUnauthorized, publish([]byte("{}"), "application/json", "?topic=orders", false))
+
+	// a raw body is published to the topic of the query.
+	require.Equal(t, http.StatusAccepted, publish([]byte(`{"id": 1}`), "application/json", "?topic=orders", true))
+	var raw = <-received
+	require.Equal(t, "orders", raw.Topic.String())
+	require.Equal(t, `{"id": 1}`, string(raw.Bytes))
+
+	require.Equal(t, http.StatusBadRequest, publish([]byte("{}"), "application/json", "", true))
+
+	// an encoded message keeps its own topic.
+	var encoded, encodeErr = DefaultCodec.Encode(sabuhp.NewMessage(sabuhp.T("orders"), "me", []byte("created")))
+	require.NoError(t, encodeErr)
+	require.Equal(t, http.StatusAccepted, publish(encoded, sabuhp.MessageContentType, "", true))
+
+	var message = <-received
+	require.Equal(t, "orders", message.Topic.String())
+	require.Equal(t, "created", string(message.Bytes))
+	require.Equal(t, "me", message.FromAddr)
+}
+
+func TestClientServer_PublishLimits(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var bus = membus.NewMemoryBus(ctx, logger)
+	var cs = New(ctx, logger, bus,
+		WithPublishRoute("/publish"),
+		WithPublishLimits(16, time.Second),
+	)
+	cs.Init()
+
+	var publish = func(body []byte, contentType string, knownLength bool) int {
+		var req = httptest.NewRequest("POST", "/publish?topic=orders", bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		if !knownLength {
+			req.ContentLength = -1
+		}
+		var recorder = httptest.NewRecorder()
+		cs.Mux.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	require.Equal(t, http.StatusAccepted, publish([]byte("small"), "text/plain; charset=utf-8", true))
+
+	var oversized = bytes.Repeat([]byte("a"), 64)
+	require.Equal(t, http.StatusRequestEntityTooLarge, publish(oversized, "text/plain", true))
+	require.Equal(t, http.StatusRequestEntityTooLarge, publish(oversized, "text/plain", false))
+
+	require.Equal(t, http.StatusUnsupportedMediaType, publish([]byte("<p>hi</p>"), "text/html", true))
+	require.Equal(t, http.StatusUnsupportedMediaType, publish([]byte("small"), "", true))
+}
+
+func TestClientServer_PublishReadTimeout(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var bus = membus.NewMemoryBus(ctx, logger)
+	var cs = New(ctx, logger, bus,
+		WithPublishRoute("/publish"),
+		WithPublishLimits(1024, 100*time.Millisecond),
+	)
+	cs.Init()
+
+	var server = httptest.NewServer(cs.Mux)
+	defer server.Close()
+
+	// the body trickles a byte every 200ms, slower than the read timeout.
+	var bodyReader, bodyWriter = io.Pipe()
+	var done = make(chan struct{})
+	defer close(done)
+	go func() {
+		defer bodyWriter.Close()
+		for i := 0; i < 50; i++ {
+			select {
+			case <-done:
+				return
+			case <-time.After(200 * time.Millisecond):
+			}
+			if _, err := bodyWriter.Write([]byte("a")); err != nil {
+				return
+			}
+		}
+	}()
+
+	var started = time.Now()
+	var response, err = server.Client().Post(server.URL+"/publish?topic=orders", "text/plain", bodyReader)
+	require.NoError(t, err)
+	_ = response.Body.Close()
+
+	require.Equal(t, http.StatusRequestTimeout, response.StatusCode)
+	require.Less(t, int64(time.Since(started)), int64(2*time.Second))
+}
+
+func TestClientServer_PublishContentTypesOfDecoder(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var ctx, canceler = context.WithCancel(context.Background())
+	defer canceler()
+
+	var codec = codecs.NewContentTypeCodec(codecs.JsonContentType, map[string]sabuhp.Codec{
+		codecs.JsonContentType:    &codecs.MessageJsonCodec{},
+		codecs.MsgPackContentType: &codecs.MessageMsgPackCodec{},
+	})
+
+	var bus = membus.NewMemoryBus(ctx, logger)
+	var cs = New(ctx, logger, bus,
+		WithPublishRoute("/publish"),
+		WithHttpDecoder(sabuhp.NewHttpDecoderImpl(codec, logger, DefaultMaxSize)),
+	)
+	cs.Init()
+	require.Equal(t, []string{codecs.JsonContentType, codecs.MsgPackContentType}, cs.PublishContentTypes)
+
+	var publish = func(contentType string) int {
+		var req = httptest.NewRequest("POST", "/publish?topic=orders", bytes.NewReader([]byte("created")))
+		req.Header.Set("Content-Type", contentType)
+		var recorder = httptest.NewRecorder()
+		cs.Mux.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	require.Equal(t, http.StatusAccepted, publish(codecs.MsgPackContentType))
+	require.Equal(t, http.StatusAccepted, publish("application/json; charset=utf-8"))
+	require.Equal(t, http.StatusUnsupportedMediaType, publish("text/plain"))
+	require.Equal(t, http.StatusUnsupportedMediaType, publish(codecs.GobContentType))
+}
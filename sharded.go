@@ -0,0 +1,105 @@
+package sabuhp
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/influx6/npkg/nxid"
+)
+
+// PartitionHasher hashes a partition key, the hash decides the
+// shard the key belongs to.
+type PartitionHasher func(key string) uint32
+
+// FNVHasher hashes partition keys with 32-bit FNV-1a.
+func FNVHasher(key string) uint32 {
+	var hasher = fnv.New32a()
+	_, _ = hasher.Write([]byte(key))
+	return hasher.Sum32()
+}
+
+// ShardTopic returns the physical topic of a shard of the logical topic.
+func ShardTopic(topic string, shard int) string {
+	return fmt.Sprintf("%s.shard-%d", topic, shard)
+}
+
+var _ MessageBus = (*ShardedBus)(nil)
+
+// ShardedBus wraps a MessageBus, spreading every logical topic across a
+// number of physical topics named by ShardTopic.
+//
+// Send writes a message to the shard picked by the hash of its PartitionKey,
+// so messages sharing a key stay on one shard, messages without a key are
+// spread by their Id. Listen subscribes to all shards of the topic, hence
+// a consumer group reads every shard.
+//
+// Shards only name the physical topics, handlers receive messages with
+// their logical topic, so replies to them are sent to the reply topic
+// of the logical topic SendForReply listens on.
+type ShardedBus struct {
+	bus    MessageBus
+	shards int
+	hasher PartitionHasher
+}
+
+// NewShardedBus returns a new ShardedBus with the number of shards per topic,
+// the hasher defaults to FNVHasher if nil.
+func NewShardedBus(bus MessageBus, shards int, hasher PartitionHasher) *ShardedBus {
+	if shards <= 0 {
+		panic("ShardedBus requires at least one shard")
+	}
+	if hasher == nil {
+		hasher = FNVHasher
+	}
+	return &ShardedBus{
+		bus:    bus,
+		shards: shards,
+		hasher: hasher,
+	}
+}
+
+// ShardFor returns the shard of the partition key.
+func (s *ShardedBus) ShardFor(key string) int {
+	return int(s.hasher(key) % uint32(s.shards))
+}
+
+func (s *ShardedBus) shard(msg Message) Message {
+	var key = msg.PartitionKey
+	if len(key) == 0 {
+		key = msg.Id.String()
+	}
+	msg.Topic.T = ShardTopic(msg.Topic.T, s.ShardFor(key))
+	return msg
+}
+
+func (s *ShardedBus) Send(data ...Message) {
+	var sharded = make([]Message, 0, len(data))
+	for _, msg := range data {
+		sharded = append(sharded, s.shard(msg))
+	}
+	s.bus.Send(sharded...)
+}
+
+func (s *ShardedBus) SendForReply(tm time.Duration, fromTopic Topic, replyGroup string, data ...Message) *ReplyFuture {
+	var sharded = make([]Message, 0, len(data))
+	for _, msg := range data {
+		sharded = append(sharded, s.shard(msg))
+	}
+	return s.bus.SendForReply(tm, fromTopic, replyGroup, sharded...)
+}
+
+// Listen subscribes the handler with the group to every shard of the topic.
+func (s *ShardedBus) Listen(topic string, grp string, handler TransportResponse) Channel {
+	var logical = TransportResponseFunc(func(ctx context.Context, message Message, transport Transport) MessageErr {
+		message.Topic.T = topic
+		return handler.Handle(ctx, message, transport)
+	})
+
+	var channels = make([]Channel, 0, s.shards)
+	for shard := 0; shard < s.shards; shard++ {
+		channels = append(channels, s.bus.Listen(ShardTopic(topic, shard), grp, logical))
+	}
+	return &compositeChannel{id: nxid.New(), topic: topic, group: grp, channels: channels}
+}
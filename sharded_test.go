@@ -0,0 +1,111 @@
+package sabuhp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedBus(t *testing.T) {
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var logger GoLogImpl
+	var relay = NewPbRelay(controlCtx, logger)
+
+	var mb BusBuilder
+	mb.ListenFunc = func(topic string, grp string, handler TransportResponse) Channel {
+		return relay.Group(topic, grp).Listen(handler)
+	}
+	mb.SendFunc = func(data ...Message) {
+		for _, msg := range data {
+			_ = relay.Handle(controlCtx, msg, Transport{Bus: &mb})
+		}
+	}
+
+	var sharded = NewShardedBus(&mb, 4, nil)
+
+	var countMu sync.Mutex
+	var counts = map[string]int{}
+	var channel = sharded.Listen("orders", "workers", TransportResponseFunc(func(ctx context.Context, message Message, transport Transport) MessageErr {
+		require.Equal(t, "orders", message.Topic.String())
+
+		countMu.Lock()
+		counts[ShardTopic("orders", sharded.ShardFor(message.PartitionKey))]++
+		countMu.Unlock()
+		return nil
+	}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	var total = 400
+	for i := 0; i < total; i++ {
+		var msg = BasicMsg(T("orders"), "order", "me")
+		msg.PartitionKey = fmt.Sprintf("customer-%d", i)
+		sharded.Send(msg)
+	}
+
+	countMu.Lock()
+	defer countMu.Unlock()
+
+	require.Len(t, counts, 4)
+
+	var received int
+	for shard := 0; shard < 4; shard++ {
+		var count = counts[ShardTopic("orders", shard)]
+		require.True(t, count > total/8, "shard %d received only %d messages", shard, count)
+		received += count
+	}
+	require.Equal(t, total, received)
+}
+
+func TestShardedBus_SameKeySameShard(t *testing.T) {
+	var sharded = NewShardedBus(&BusBuilder{}, 4, nil)
+
+	var first = BasicMsg(T("orders"), "order", "me")
+	first.PartitionKey = "customer-1"
+
+	var second = BasicMsg(T("orders"), "order", "me")
+	second.PartitionKey = "customer-1"
+
+	require.Equal(t, sharded.shard(first).Topic.String(), sharded.shard(second).Topic.String())
+	require.Equal(t, ShardTopic("orders", sharded.ShardFor("customer-1")), sharded.shard(first).Topic.String())
+}
+
+func TestShardedBus_SendForReply(t *testing.T) {
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var bus = relayBus(controlCtx)
+	bus.SendForReplyFunc = func(tm time.Duration, from Topic, replyGroup string, data ...Message) *ReplyFuture {
+		var ctx, cancel = context.WithTimeout(controlCtx, tm)
+		var ft = SendForReplyContext(ctx, bus, from, replyGroup, data...)
+		go func() {
+			<-ft.Done()
+			cancel()
+		}()
+		return ft
+	}
+
+	var sharded = NewShardedBus(bus, 4, nil)
+
+	// responders reply on the bus the request came from, not through
+	// the shards.
+	var channel = sharded.Listen("orders", "workers", TransportResponseFunc(func(ctx context.Context, message Message, transport Transport) MessageErr {
+		transport.Bus.Send(*NewReply(&message, []byte("accepted")))
+		return nil
+	}))
+	require.NoError(t, channel.Err())
+	defer channel.Close()
+
+	var request = BasicMsg(T("orders").WithSeparator("/"), "order", "me")
+	request.PartitionKey = "customer-1"
+
+	var reply, replyErr = sharded.SendForReply(time.Second, request.Topic, "", request).Get()
+	require.NoError(t, replyErr)
+	require.Equal(t, "accepted", string(reply.Bytes))
+}
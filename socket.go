@@ -64,6 +64,48 @@ type Socket interface {
 	Listen(SocketMessageHandler)
 }
 
+// ReconnectNotifier is optionally implemented by a Socket which re-establishes
+// its connection when it drops, allowing higher layers to restore state
+// (e.g re-subscribe topics) after a reconnect.
+type ReconnectNotifier interface {
+	OnReconnect(fn func())
+}
+
+// OnReconnect registers fn with the transport if it implements
+// ReconnectNotifier, returning false if it does not.
+func OnReconnect(transport interface{}, fn func()) bool {
+	if notifier, ok := transport.(ReconnectNotifier); ok {
+		notifier.OnReconnect(fn)
+		return true
+	}
+	return false
+}
+
+// ReconnectCallbacks is a list of reconnect callbacks usable by
+// implementations of the ReconnectNotifier.
+type ReconnectCallbacks struct {
+	cl        sync.Mutex
+	callbacks []func()
+}
+
+// OnReconnect adds the callback to the list.
+func (rc *ReconnectCallbacks) OnReconnect(fn func()) {
+	rc.cl.Lock()
+	rc.callbacks = append(rc.callbacks, fn)
+	rc.cl.Unlock()
+}
+
+// Notify calls all registered callbacks in order of registration.
+func (rc *ReconnectCallbacks) Notify() {
+	rc.cl.Lock()
+	var callbacks = append([]func(){}, rc.callbacks...)
+	rc.cl.Unlock()
+
+	for _, callback := range callbacks {
+		callback()
+	}
+}
+
 type SocketService interface {
 	SocketOpened(Socket)
 	SocketClosed(Socket)
@@ -255,7 +297,7 @@ func (st *StreamBus) SocketBusSend(b Message, sock Socket) MessageErr {
 
 	var ft = st.Bus.SendForReply(b.Within, b.Topic, b.ReplyGroup, b)
 
-	var result, sendErr = ft.Get()
+	var messageResult, sendErr = ft.Get()
 	if sendErr != nil {
 		var replyMsg = b.ReplyWithTopic(b.Topic.ReplyTopic())
 		replyMsg.ReplyErr = nerror.WrapOnly(sendErr)
@@ -263,15 +305,6 @@ func (st *StreamBus) SocketBusSend(b Message, sock Socket) MessageErr {
 		return WrapErr(sendErr, false)
 	}
 
-	var messageResult, ok = result.(Message)
-	if !ok {
-		var sendErr = nerror.New("reply is not a Message type")
-		var replyMsg = b.ReplyWithTopic(b.Topic.ReplyTopic())
-		replyMsg.ReplyErr = nerror.WrapOnly(sendErr)
-		sock.Send(replyMsg)
-		return WrapErr(sendErr, false)
-	}
-
 	sock.Send(messageResult)
 	return nil
 }
@@ -342,7 +375,10 @@ func (st *StreamBusRelay) SocketOpened(socket Socket) {
 
 func (st *StreamBusRelay) SocketSubscribe(b Message, socket Socket) MessageErr {
 	var group = st.BusRelay.Group(b.SubscribeTo, b.SubscribeGroup)
-	var channel = group.Listen(TransportResponseFunc(func(ctx context.Context, message Message, transport Transport) MessageErr {
+
+	// subscribe with the socket id, so the subscription is removed
+	// when the socket unsubscribes or closes.
+	var channel = group.ListenWithId(socket.ID(), TransportResponseFunc(func(ctx context.Context, message Message, transport Transport) MessageErr {
 		var ft = message.Future
 		if message.Future == nil {
 			ft = nthen.NewFuture()
@@ -407,7 +443,7 @@ func (st *StreamBusRelay) SocketBusSend(b Message, sock Socket) MessageErr {
 
 	var ft = st.Bus.SendForReply(b.Within, b.Topic, b.ReplyGroup, b)
 
-	var result, sendErr = ft.Get()
+	var messageResult, sendErr = ft.Get()
 	if sendErr != nil {
 		var replyMsg = b.ReplyWithTopic(b.Topic.ReplyTopic())
 		replyMsg.ReplyErr = nerror.WrapOnly(sendErr)
@@ -415,15 +451,6 @@ func (st *StreamBusRelay) SocketBusSend(b Message, sock Socket) MessageErr {
 		return WrapErr(sendErr, false)
 	}
 
-	var messageResult, ok = result.(Message)
-	if !ok {
-		var sendErr = nerror.New("reply is not a Message type")
-		var replyMsg = b.ReplyWithTopic(b.Topic.ReplyTopic())
-		replyMsg.ReplyErr = nerror.WrapOnly(sendErr)
-		sock.Send(replyMsg)
-		return WrapErr(sendErr, false)
-	}
-
 	sock.Send(messageResult)
 	return nil
 }
@@ -559,7 +586,7 @@ func (st *StreamRelay) SocketBusSend(b Message, sock Socket) MessageErr {
 
 	var ft = st.Bus.SendForReply(b.Within, b.Topic, b.ReplyGroup, b)
 
-	var result, sendErr = ft.Get()
+	var messageResult, sendErr = ft.Get()
 	if sendErr != nil {
 		var replyMsg = b.ReplyWithTopic(b.Topic.ReplyTopic())
 		replyMsg.ReplyErr = nerror.WrapOnly(sendErr)
@@ -567,15 +594,6 @@ func (st *StreamRelay) SocketBusSend(b Message, sock Socket) MessageErr {
 		return WrapErr(sendErr, false)
 	}
 
-	var messageResult, ok = result.(Message)
-	if !ok {
-		var sendErr = nerror.New("reply is not a Message type")
-		var replyMsg = b.ReplyWithTopic(b.Topic.ReplyTopic())
-		replyMsg.ReplyErr = nerror.WrapOnly(sendErr)
-		sock.Send(replyMsg)
-		return WrapErr(sendErr, false)
-	}
-
 	sock.Send(messageResult)
 	return nil
 }
@@ -0,0 +1,38 @@
+package sabuhp
+
+import (
+	"net"
+	"testing"
+
+	"github.com/influx6/npkg/nxid"
+	"github.com/stretchr/testify/require"
+)
+
+type reconnectingSocket struct {
+	ReconnectCallbacks
+	id nxid.ID
+}
+
+func (r *reconnectingSocket) ID() nxid.ID                 { return r.id }
+func (r *reconnectingSocket) Send(...Message)             {}
+func (r *reconnectingSocket) Stat() SocketStat            { return SocketStat{} }
+func (r *reconnectingSocket) LocalAddr() net.Addr         { return nil }
+func (r *reconnectingSocket) RemoteAddr() net.Addr        { return nil }
+func (r *reconnectingSocket) Listen(SocketMessageHandler) {}
+func (r *reconnectingSocket) reconnect()                  { r.Notify() }
+
+func TestOnReconnect(t *testing.T) {
+	var socket = &reconnectingSocket{id: nxid.New()}
+
+	var calls []int
+	require.True(t, OnReconnect(socket, func() { calls = append(calls, 1) }))
+	require.True(t, OnReconnect(socket, func() { calls = append(calls, 2) }))
+
+	socket.reconnect()
+	require.Equal(t, []int{1, 2}, calls)
+
+	socket.reconnect()
+	require.Equal(t, []int{1, 2, 1, 2}, calls)
+
+	require.False(t, OnReconnect(BusBuilder{}, func() {}))
+}
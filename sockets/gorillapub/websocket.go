@@ -536,6 +536,8 @@ func (s *SocketConfig) ensure() {
 
 var _ sabuhp.Socket = (*GorillaSocket)(nil)
 
+var _ sabuhp.ReconnectNotifier = (*GorillaSocket)(nil)
+
 type GorillaSocket struct {
 	id           nxid.ID
 	config       *SocketConfig
@@ -556,6 +558,13 @@ type GorillaSocket struct {
 	received     int64
 	sent         int64
 	handled      int64
+	reconnects   sabuhp.ReconnectCallbacks
+}
+
+// OnReconnect registers a callback called after the client socket
+// re-established its connection.
+func (g *GorillaSocket) OnReconnect(fn func()) {
+	g.reconnects.OnReconnect(fn)
 }
 
 func (g *GorillaSocket) Listen(handler sabuhp.SocketMessageHandler) {
@@ -1028,6 +1037,8 @@ func (g *GorillaSocket) attemptReconnection() (continueLoop bool) {
 		event.String("socket_id", g.id.String())
 		event.String("socket_network", g.socket.RemoteAddr().Network())
 	}))
+
+	g.reconnects.Notify()
 	return
 }
 
@@ -22,6 +22,10 @@ type subChannel struct {
 	gp    string
 }
 
+func (s subChannel) ID() nxid.ID {
+	return nxid.ID{}
+}
+
 func (s subChannel) Topic() string {
 	return s.topic
 }
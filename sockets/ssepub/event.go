@@ -0,0 +1,273 @@
+package ssepub
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	dataHeader = "data:"
+	idHeader   = "id:"
+
+	// metadataHeaderPrefix starts the field lines carrying an entry of
+	// the Metadata of a message, e.g "x-meta-tenant: acme". Keys and
+	// values are query escaped.
+	metadataHeaderPrefix = "x-meta-"
+
+	byteOrderMark = "\uFEFF"
+
+	lastEventIdSeparator     = "="
+	lastEventIdListSeparator = ","
+)
+
+// sseEvent is a single event read from a server-sent event stream.
+type sseEvent struct {
+	Id          string
+	ContentType string
+	Data        []byte
+	Metadata    map[string]string
+}
+
+// eventReader reads server-sent events from a stream, following the
+// line assembly rules of the SSE specification: consecutive data lines
+// of an event are joined with a newline, a single space after the field
+// colon is dropped and an empty line dispatches the event.
+//
+// A leading UTF-8 byte order mark is dropped and lines holding only
+// whitespace are treated as empty lines, so stray whitespace sent by
+// some servers before the first event does not corrupt parsing.
+//
+// Parsing is safe for UTF-8 payloads: lines are only split on '\n' and
+// field names are only trimmed as ASCII prefixes, neither of which can
+// occur inside a multibyte sequence. Only ASCII whitespace makes a line
+// blank, so a stray line of e.g an ideographic space does not end an
+// event.
+//
+// Lines are only parsed once complete: the bufio.Reader keeps reading
+// till a newline, so a field arriving in fragments over several reads of
+// the stream is parsed as one line. A failed read ends the stream, the
+// client reconnects with a new reader.
+type eventReader struct {
+	reader  *bufio.Reader
+	started bool
+}
+
+func newEventReader(r io.Reader) *eventReader {
+	return &eventReader{reader: bufio.NewReader(r)}
+}
+
+// readLine returns the next complete line without its newline.
+func (er *eventReader) readLine() (string, error) {
+	var line, lineErr = er.reader.ReadString('\n')
+	if lineErr != nil {
+		return "", lineErr
+	}
+
+	if !er.started {
+		er.started = true
+		line = strings.TrimPrefix(line, byteOrderMark)
+	}
+	return strings.TrimSuffix(line, newLine), nil
+}
+
+// Next returns the next event which has data.
+func (er *eventReader) Next() (sseEvent, error) {
+	var hasData bool
+	var event sseEvent
+	var buffer bytes.Buffer
+	for {
+		var line, lineErr = er.readLine()
+		if lineErr != nil {
+			return sseEvent{}, lineErr
+		}
+
+		// an empty line marks the end of an event.
+		if isBlankLine(line) {
+			if !hasData {
+				event = sseEvent{}
+				continue
+			}
+			event.Data = buffer.Bytes()
+			return event, nil
+		}
+
+		// lines starting with a colon are comments.
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		if strings.HasPrefix(line, eventHeader) {
+			event.ContentType = strings.TrimSpace(strings.TrimPrefix(line, eventHeader))
+			continue
+		}
+
+		if strings.HasPrefix(line, idHeader) {
+			event.Id = strings.TrimSpace(strings.TrimPrefix(line, idHeader))
+			continue
+		}
+
+		if strings.HasPrefix(line, metadataHeaderPrefix) {
+			if key, value, ok := parseMetadataField(line); ok {
+				if event.Metadata == nil {
+					event.Metadata = map[string]string{}
+				}
+				event.Metadata[key] = value
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, dataHeader) {
+			var value = strings.TrimPrefix(line, dataHeader)
+			value = strings.TrimPrefix(value, " ")
+			if hasData {
+				buffer.WriteString(newLine)
+			}
+			buffer.WriteString(value)
+			hasData = true
+		}
+	}
+}
+
+// isBlankLine returns true if the line holds only ASCII whitespace.
+func isBlankLine(line string) bool {
+	return len(strings.Trim(line, " \t\r\v\f")) == 0
+}
+
+// DefaultMaxLastEventIdHeaderSize is the default maximum size of a single
+// LastEventIdListHeader line, larger lists are split across multiple lines
+// of the header to stay within per-line limits of servers and proxies.
+const DefaultMaxLastEventIdHeaderSize = 4096
+
+func lastEventIdEntries(lastIds map[string]string) []string {
+	var streams = make([]string, 0, len(lastIds))
+	for stream := range lastIds {
+		streams = append(streams, stream)
+	}
+	sort.Strings(streams)
+
+	var entries = make([]string, 0, len(streams))
+	for _, stream := range streams {
+		entries = append(entries, stream+lastEventIdSeparator+lastIds[stream])
+	}
+	return entries
+}
+
+// FormatLastEventIds returns the value of the LastEventIdListHeader for
+// the last event ids of each stream, sorted by stream for a stable output.
+func FormatLastEventIds(lastIds map[string]string) string {
+	return strings.Join(lastEventIdEntries(lastIds), lastEventIdListSeparator)
+}
+
+// FormatLastEventIdChunks returns the values of the LastEventIdListHeader
+// for the last event ids of each stream, where each value is at most
+// maxSize long unless a single entry exceeds it.
+func FormatLastEventIdChunks(lastIds map[string]string, maxSize int) []string {
+	var chunks []string
+	var chunk strings.Builder
+	for _, entry := range lastEventIdEntries(lastIds) {
+		if chunk.Len() > 0 && chunk.Len()+len(lastEventIdListSeparator)+len(entry) > maxSize {
+			chunks = append(chunks, chunk.String())
+			chunk.Reset()
+		}
+		if chunk.Len() > 0 {
+			chunk.WriteString(lastEventIdListSeparator)
+		}
+		chunk.WriteString(entry)
+	}
+	if chunk.Len() > 0 {
+		chunks = append(chunks, chunk.String())
+	}
+	return chunks
+}
+
+// SetLastEventIds sets the LastEventIdListHeader on the header, split
+// across multiple lines of maxSize if needed. A maxSize of zero uses
+// DefaultMaxLastEventIdHeaderSize.
+func SetLastEventIds(header http.Header, lastIds map[string]string, maxSize int) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxLastEventIdHeaderSize
+	}
+
+	header.Del(LastEventIdListHeader)
+	for _, chunk := range FormatLastEventIdChunks(lastIds, maxSize) {
+		header.Add(LastEventIdListHeader, chunk)
+	}
+}
+
+// ReadLastEventIds returns the last event id of each stream from all
+// lines of the LastEventIdListHeader of the header.
+func ReadLastEventIds(header http.Header) map[string]string {
+	var lastIds = map[string]string{}
+	for _, value := range header.Values(LastEventIdListHeader) {
+		for stream, id := range ParseLastEventIds(value) {
+			lastIds[stream] = id
+		}
+	}
+	return lastIds
+}
+
+// ParseLastEventIds parses the value of the LastEventIdListHeader into
+// the last event id of each stream.
+func ParseLastEventIds(value string) map[string]string {
+	var lastIds = map[string]string{}
+	for _, entry := range strings.Split(value, lastEventIdListSeparator) {
+		entry = strings.TrimSpace(entry)
+		var index = strings.LastIndex(entry, lastEventIdSeparator)
+		if index <= 0 {
+			continue
+		}
+		lastIds[entry[:index]] = entry[index+1:]
+	}
+	return lastIds
+}
+
+// parseMetadataField returns the unescaped key and value of a metadata
+// field line.
+func parseMetadataField(line string) (string, string, bool) {
+	var field = strings.TrimPrefix(line, metadataHeaderPrefix)
+	var index = strings.Index(field, ":")
+	if index <= 0 {
+		return "", "", false
+	}
+
+	var key, keyErr = url.QueryUnescape(field[:index])
+	var value, valueErr = url.QueryUnescape(strings.TrimPrefix(field[index+1:], " "))
+	if keyErr != nil || valueErr != nil {
+		return "", "", false
+	}
+	return key, value, true
+}
+
+// writeEventMetadata writes the metadata as field lines sorted by key,
+// clients ignoring unknown fields skip them.
+func writeEventMetadata(builder *strings.Builder, metadata map[string]string) {
+	var keys = make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		builder.WriteString(metadataHeaderPrefix)
+		builder.WriteString(url.QueryEscape(key))
+		builder.WriteString(": ")
+		builder.WriteString(url.QueryEscape(metadata[key]))
+		builder.WriteString(newLine)
+	}
+}
+
+// writeEventData writes the data as one or more data lines, splitting
+// on newlines so the content survives the SSE line assembly.
+func writeEventData(builder *strings.Builder, data []byte) {
+	for _, line := range bytes.Split(data, []byte(newLine)) {
+		builder.WriteString(dataHeader)
+		builder.WriteString(" ")
+		builder.Write(line)
+		builder.WriteString(newLine)
+	}
+}
@@ -0,0 +1,233 @@
+package ssepub
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/influx6/npkg/nxid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ewe-studios/sabuhp"
+	"github.com/ewe-studios/sabuhp/codecs"
+	"github.com/ewe-studios/sabuhp/utils"
+)
+
+func TestEventReader(t *testing.T) {
+	var specs = []struct {
+		Name        string
+		Stream      string
+		ContentType string
+		Data        string
+	}{
+		{
+			Name:        "single data line",
+			Stream:      "event: text/plain\ndata: hello\n\n",
+			ContentType: "text/plain",
+			Data:        "hello",
+		},
+		{
+			Name:        "multiple data lines are joined with newlines",
+			Stream:      "event: text/plain\ndata: first\ndata: second\ndata: third\n\n",
+			ContentType: "text/plain",
+			Data:        "first\nsecond\nthird",
+		},
+		{
+			Name:        "leading and empty data lines are kept",
+			Stream:      "event: text/plain\ndata:\ndata:  indented\ndata:\n\n",
+			ContentType: "text/plain",
+			Data:        "\n indented\n",
+		},
+		{
+			Name:        "leading byte order mark and blank lines are skipped",
+			Stream:      "\uFEFF\n  \n\t\nevent: text/plain\ndata: value\n\n",
+			ContentType: "text/plain",
+			Data:        "value",
+		},
+		{
+			Name:        "byte order mark before the first field",
+			Stream:      "\uFEFFevent: text/plain\ndata: value\n\n",
+			ContentType: "text/plain",
+			Data:        "value",
+		},
+		{
+			Name:        "comments are skipped",
+			Stream:      ": keep-alive\n\nevent: text/plain\n: note\ndata: value\n\n",
+			ContentType: "text/plain",
+			Data:        "value",
+		},
+	}
+
+	for _, spec := range specs {
+		t.Run(spec.Name, func(t *testing.T) {
+			var reader = newEventReader(strings.NewReader(spec.Stream))
+			var event, err = reader.Next()
+			require.NoError(t, err)
+			require.Equal(t, spec.ContentType, event.ContentType)
+			require.Equal(t, spec.Data, string(event.Data))
+		})
+	}
+}
+
+// chunkedReader returns its chunks one per read, like a connection
+// flushing them separately would.
+type chunkedReader struct {
+	chunks []string
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	if len(c.chunks) == 0 {
+		return 0, io.EOF
+	}
+
+	var chunk = c.chunks[0]
+	c.chunks = c.chunks[1:]
+	return copy(p, chunk), nil
+}
+
+func chunks(parts ...string) *chunkedReader {
+	return &chunkedReader{chunks: parts}
+}
+
+func TestEventReader_LinesSplitAcrossReads(t *testing.T) {
+	// the event header and a data line arrive in fragments.
+	var reader = newEventReader(utils.NewNormalisedReader(chunks(
+		"id: 4\nev",
+		"ent: text/pl",
+		"ain\r",
+		"\ndata: hel",
+		"lo\r",
+		"\n\r",
+		"\n",
+	)))
+
+	var event, err = reader.Next()
+	require.NoError(t, err)
+	require.Equal(t, "4", event.Id)
+	require.Equal(t, "text/plain", event.ContentType)
+	require.Equal(t, "hello", string(event.Data))
+
+	_, err = reader.Next()
+	require.Equal(t, io.EOF, err)
+}
+
+func TestWriteEventData_RoundTrip(t *testing.T) {
+	var payload = "line one\n\nline three\n  with indent"
+
+	var builder strings.Builder
+	builder.WriteString("event: text/plain\n")
+	writeEventData(&builder, []byte(payload))
+	builder.WriteString("\n")
+
+	var reader = newEventReader(strings.NewReader(builder.String()))
+	var event, err = reader.Next()
+	require.NoError(t, err)
+	require.Equal(t, "text/plain", event.ContentType)
+	require.Equal(t, payload, string(event.Data))
+}
+
+func TestEventReader_MultibytePayloads(t *testing.T) {
+	var payloads = []string{
+		"héllo wörld 👋🏽",
+		"你好，世界\n日本語のテキスト",
+		"data: nested data: fields 🚀 data:",
+		"\u3000",
+		strings.Repeat("表情😀", 20000),
+	}
+
+	var builder strings.Builder
+	for _, payload := range payloads {
+		builder.WriteString("event: text/plain\n")
+		writeEventData(&builder, []byte(payload))
+		builder.WriteString("\n")
+	}
+
+	// split the stream at odd offsets, so reads end mid-rune.
+	var stream = builder.String()
+	var parts []string
+	for len(stream) > 0 {
+		var size = 7
+		if size > len(stream) {
+			size = len(stream)
+		}
+		parts = append(parts, stream[:size])
+		stream = stream[size:]
+	}
+
+	var reader = newEventReader(chunks(parts...))
+	for _, payload := range payloads {
+		var event, err = reader.Next()
+		require.NoError(t, err)
+		require.Equal(t, "text/plain", event.ContentType)
+		require.Equal(t, payload, string(event.Data))
+	}
+}
+
+func TestEventReader_DecodesFirstEventAfterBOM(t *testing.T) {
+	var codec = &codecs.MessageJsonCodec{}
+	var encoded, encodeErr = codec.Encode(sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("world")))
+	require.NoError(t, encodeErr)
+
+	var builder strings.Builder
+	builder.WriteString("\uFEFF\n \n")
+	builder.WriteString("event: " + sabuhp.MessageContentType + "\n")
+	writeEventData(&builder, encoded)
+	builder.WriteString("\n")
+
+	var reader = newEventReader(strings.NewReader(builder.String()))
+	var event, err = reader.Next()
+	require.NoError(t, err)
+	require.Equal(t, sabuhp.MessageContentType, event.ContentType)
+
+	var message, decodeErr = codec.Decode(event.Data)
+	require.NoError(t, decodeErr)
+	require.Equal(t, "hello", message.Topic.String())
+	require.Equal(t, "world", string(message.Bytes))
+}
+
+func TestSetLastEventIds_ChunksLargeLists(t *testing.T) {
+	var lastIds = map[string]string{}
+	for i := 0; i < 100; i++ {
+		var stream = fmt.Sprintf("service.accounts.notifications.stream-%03d", i)
+		lastIds[stream] = nxid.New().String()
+	}
+	require.Greater(t, len(FormatLastEventIds(lastIds)), DefaultMaxLastEventIdHeaderSize)
+
+	var header = http.Header{}
+	SetLastEventIds(header, lastIds, 0)
+
+	var values = header.Values(LastEventIdListHeader)
+	require.Greater(t, len(values), 1)
+	for _, value := range values {
+		require.LessOrEqual(t, len(value), DefaultMaxLastEventIdHeaderSize)
+	}
+
+	var received = make(chan map[string]string, 1)
+	var httpServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- ReadLastEventIds(r.Header)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer httpServer.Close()
+
+	var req, reqErr = http.NewRequest(http.MethodGet, httpServer.URL, nil)
+	require.NoError(t, reqErr)
+	req.Header = header
+
+	var res, resErr = httpServer.Client().Do(req)
+	require.NoError(t, resErr)
+	_ = res.Body.Close()
+	require.Equal(t, http.StatusNoContent, res.StatusCode)
+
+	require.Equal(t, lastIds, <-received)
+}
+
+func TestFormatLastEventIdChunks(t *testing.T) {
+	var lastIds = map[string]string{"a": "1", "b": "2", "c": "3"}
+	require.Equal(t, []string{"a=1,b=2", "c=3"}, FormatLastEventIdChunks(lastIds, 7))
+	require.Equal(t, []string{"a=1", "b=2", "c=3"}, FormatLastEventIdChunks(lastIds, 1))
+	require.Equal(t, []string{"a=1,b=2,c=3"}, FormatLastEventIdChunks(lastIds, 100))
+	require.Empty(t, FormatLastEventIdChunks(map[string]string{}, 100))
+}
@@ -0,0 +1,129 @@
+package ssepub
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/influx6/npkg/nxid"
+
+	"github.com/ewe-studios/sabuhp"
+)
+
+// DefaultReplayBufferSize is the number of messages a ReplayBuffer keeps
+// per topic when no size is given.
+const DefaultReplayBufferSize = 256
+
+// ReplayBuffer assigns the event ids of every topic from a server wide
+// sequence and keeps the last messages written of each topic by id, so a
+// client reconnecting with the last event id it saw is replayed the
+// messages it missed.
+//
+// A message written to many connections gets the same id on all of them,
+// messages are told apart by their Id. Sequences start from the time the
+// buffer is created, so ids keep increasing across server restarts.
+//
+// Replays include every message of a topic written to any client, so the
+// buffer suits topics whose messages are broadcast to all subscribers.
+type ReplayBuffer struct {
+	size  int
+	start uint64
+
+	mu     sync.Mutex
+	topics map[string]*replayRing
+}
+
+// replayRing holds the last messages of a topic in the order of their ids.
+type replayRing struct {
+	seq    uint64
+	events []replayEvent
+	next   int
+	ids    map[nxid.ID]uint64
+}
+
+type replayEvent struct {
+	id  uint64
+	msg sabuhp.Message
+}
+
+// NewReplayBuffer returns a ReplayBuffer keeping up to size messages per
+// topic, defaulting to DefaultReplayBufferSize.
+func NewReplayBuffer(size int) *ReplayBuffer {
+	if size <= 0 {
+		size = DefaultReplayBufferSize
+	}
+	return &ReplayBuffer{
+		size:   size,
+		start:  uint64(time.Now().UnixNano() / int64(time.Microsecond)),
+		topics: map[string]*replayRing{},
+	}
+}
+
+// Record returns the event id of the message, assigning the next id of its
+// topic and keeping the message if it's not been recorded before.
+func (b *ReplayBuffer) Record(msg sabuhp.Message) uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var ring = b.ring(msg.Topic.String())
+	if !msg.Id.IsNil() {
+		if id, recorded := ring.ids[msg.Id]; recorded {
+			return id
+		}
+	}
+
+	ring.seq++
+	var event = replayEvent{id: ring.seq, msg: msg}
+	event.msg.Future = nil
+
+	if len(ring.events) < b.size {
+		ring.events = append(ring.events, event)
+	} else {
+		var evicted = ring.events[ring.next]
+		if !evicted.msg.Id.IsNil() {
+			delete(ring.ids, evicted.msg.Id)
+		}
+		ring.events[ring.next] = event
+		ring.next = (ring.next + 1) % b.size
+	}
+	if !msg.Id.IsNil() {
+		ring.ids[msg.Id] = event.id
+	}
+	return event.id
+}
+
+// Since returns the messages of the topic kept with ids after the last
+// event id, oldest first. Messages dropped from the buffer are missed.
+func (b *ReplayBuffer) Since(topic string, lastEventId string) []sabuhp.Message {
+	var lastId, parseErr = strconv.ParseUint(lastEventId, 10, 64)
+	if parseErr != nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var ring, hasRing = b.topics[topic]
+	if !hasRing {
+		return nil
+	}
+
+	var messages []sabuhp.Message
+	for i := 0; i < len(ring.events); i++ {
+		var event = ring.events[(ring.next+i)%len(ring.events)]
+		if event.id > lastId {
+			messages = append(messages, event.msg)
+		}
+	}
+	return messages
+}
+
+// ring returns the ring of the topic, it must be called with mu held.
+func (b *ReplayBuffer) ring(topic string) *replayRing {
+	var ring, hasRing = b.topics[topic]
+	if !hasRing {
+		ring = &replayRing{seq: b.start, ids: map[nxid.ID]uint64{}}
+		b.topics[topic] = ring
+	}
+	return ring
+}
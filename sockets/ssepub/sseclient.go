@@ -1,12 +1,13 @@
 package ssepub
 
 import (
-	"bufio"
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/url"
-	"strings"
 	"sync"
 	"time"
 
@@ -22,13 +23,94 @@ import (
 )
 
 var (
-	newLine         = "\n"
-	spaceBytes      = []byte(" ")
-	dataHeaderBytes = []byte("data:")
+	newLine = "\n"
 )
 
+var _ sabuhp.ReconnectNotifier = (*SSEClient)(nil)
+
+// ErrHandlerMode is returned by SSEClient.Next for a client created
+// with a MessageHandler, which receives all its messages.
+var ErrHandlerMode = nerror.New("client delivers messages to its handler")
+
+// ErrClientClosed is returned by SSEClient.Next once the client is
+// closed or failed to reconnect.
+var ErrClientClosed = nerror.New("client is closed")
+
+// ErrReconnectFailed is reported by SSEClient.Err once the client gave
+// up reconnecting to the server.
+var ErrReconnectFailed = nerror.New("client failed to reconnect")
+
 type MessageHandler func(message sabuhp.Message, socket *SSEClient) error
 
+// ClientMod defines a function which modifies an SSEClient before
+// it starts reading from its connection.
+type ClientMod func(sc *SSEClient)
+
+// WithHeaders adds the headers to every request the client makes,
+// including reconnects, e.g. an Authorization header.
+func WithHeaders(header http.Header) ClientMod {
+	return func(sc *SSEClient) {
+		for k, v := range header {
+			sc.headers[k] = append(sc.headers[k], v...)
+		}
+	}
+}
+
+// WithMaxReconnectDuration limits the total time the client spends
+// reconnecting after losing its connection, across all retries. Once
+// exceeded the client gives up and closes, Wait returns and Err reports
+// ErrReconnectFailed.
+func WithMaxReconnectDuration(max time.Duration) ClientMod {
+	return func(sc *SSEClient) {
+		sc.maxReconnect = max
+	}
+}
+
+// WithoutReconnect makes the client close once its stream ends, whether
+// the server closed it or reading failed, instead of reconnecting.
+func WithoutReconnect() ClientMod {
+	return func(sc *SSEClient) {
+		sc.noReconnect = true
+	}
+}
+
+// WithMaxLastEventIdHeaderSize sets the maximum size of a single line of
+// the LastEventIdListHeader sent on reconnects, replacing
+// DefaultMaxLastEventIdHeaderSize, for servers or proxies with a lower
+// per-line limit.
+func WithMaxLastEventIdHeaderSize(size int) ClientMod {
+	return func(sc *SSEClient) {
+		sc.maxLastIdsSize = size
+	}
+}
+
+// PayloadRedactor returns the text logged in place of an event payload.
+type PayloadRedactor func(data []byte) string
+
+// RedactPayload is the default PayloadRedactor of a SSEClient, it logs
+// only the length of a payload, never its contents.
+func RedactPayload(data []byte) string {
+	return fmt.Sprintf("[redacted %d bytes]", len(data))
+}
+
+// WithPayloadRedactor sets the function applied to event payloads before
+// they are logged, replacing RedactPayload.
+func WithPayloadRedactor(redactor PayloadRedactor) ClientMod {
+	return func(sc *SSEClient) {
+		sc.redactor = redactor
+	}
+}
+
+// WithRawMode makes the client treat the data of every event as a raw
+// payload, creating a Message with the topic and path of the route
+// instead of decoding a full Message with the codec, for servers which
+// emit plain bodies without the Message envelope.
+func WithRawMode() ClientMod {
+	return func(sc *SSEClient) {
+		sc.rawMode = true
+	}
+}
+
 type SSEClient struct {
 	id         nxid.ID
 	maxRetries int
@@ -37,20 +119,33 @@ type SSEClient struct {
 	retryFunc  sabuhp.RetryFunc
 	codec      sabuhp.Codec
 	handler    MessageHandler // ensure to copy the bytes if your use will span multiple goroutines
+	messages   chan sabuhp.Message
+	done       chan struct{}
 	ctx        context.Context
 	canceler   context.CancelFunc
 	client     sabuhp.HttpClient
 	request    *http.Request
 	response   *http.Response
-	lastId     nxid.ID
+	lastIdsMu  sync.Mutex
+	lastIds    map[string]string
+	reconnects sabuhp.ReconnectCallbacks
 	retry      time.Duration
+	rawMode    bool
+	headers    http.Header
 	waiter     sync.WaitGroup
+
+	maxReconnect   time.Duration
+	maxLastIdsSize int
+	noReconnect    bool
+	redactor       PayloadRedactor
+	errMu          sync.Mutex
+	err            error
 }
+
 func linearBackOff(i int) time.Duration {
 	return time.Duration(i) * (10 * time.Millisecond)
 }
 
-
 func NewSSEClient3(
 	ctx context.Context,
 	route string,
@@ -58,6 +153,7 @@ func NewSSEClient3(
 	handler MessageHandler,
 	codec sabuhp.Codec,
 	logger sabuhp.Logger,
+	mods ...ClientMod,
 ) (*SSEClient, error) {
 	return NewSSEClient(
 		ctx,
@@ -70,6 +166,7 @@ func NewSSEClient3(
 		codec,
 		logger,
 		utils.CreateDefaultHttpClient(),
+		mods...,
 	)
 }
 
@@ -81,6 +178,7 @@ func NewSSEClient2(
 	codec sabuhp.Codec,
 	logger sabuhp.Logger,
 	reqClient sabuhp.HttpClient,
+	mods ...ClientMod,
 ) (*SSEClient, error) {
 	return NewSSEClient(
 		ctx,
@@ -93,6 +191,7 @@ func NewSSEClient2(
 		codec,
 		logger,
 		reqClient,
+		mods...,
 	)
 }
 
@@ -107,8 +206,45 @@ func NewSSEClient(
 	codec sabuhp.Codec,
 	logger sabuhp.Logger,
 	reqClient sabuhp.HttpClient,
+	mods ...ClientMod,
+) (*SSEClient, error) {
+	return NewSSEClientWithHeaders(
+		ctx,
+		id,
+		maxRetries,
+		route,
+		method,
+		nil,
+		handler,
+		retryFn,
+		codec,
+		logger,
+		reqClient,
+		mods...,
+	)
+}
+
+// NewSSEClientWithHeaders returns a new SSEClient which adds the provided
+// headers to the initial request and every later request, including
+// reconnects.
+func NewSSEClientWithHeaders(
+	ctx context.Context,
+	id nxid.ID,
+	maxRetries int,
+	route string,
+	method string,
+	customHeader http.Header,
+	handler MessageHandler,
+	retryFn sabuhp.RetryFunc,
+	codec sabuhp.Codec,
+	logger sabuhp.Logger,
+	reqClient sabuhp.HttpClient,
+	mods ...ClientMod,
 ) (*SSEClient, error) {
 	var header = http.Header{}
+	for k, v := range customHeader {
+		header[k] = append(header[k], v...)
+	}
 	header.Set(ClientIdentificationHeader, id.String())
 	header.Set("Cache-Control", "no-cache")
 	header.Set("Accept", "text/event-stream")
@@ -130,9 +266,15 @@ func NewSSEClient(
 		codec,
 		logger,
 		reqClient,
+		append([]ClientMod{WithHeaders(customHeader)}, mods...)...,
 	), nil
 }
 
+// NewSSEClientWithRequestResponse returns a new SSEClient reading from the
+// provided response.
+//
+// A client created with a nil handler is pulled from instead, its
+// messages are read one at a time with SSEClient.Next.
 func NewSSEClientWithRequestResponse(
 	ctx context.Context,
 	id nxid.ID,
@@ -145,6 +287,7 @@ func NewSSEClientWithRequestResponse(
 	codec sabuhp.Codec,
 	logger sabuhp.Logger,
 	reqClient sabuhp.HttpClient,
+	mods ...ClientMod,
 ) *SSEClient {
 	if req.Context() == nil {
 		panic("Request is required to have a context.Context attached")
@@ -160,11 +303,25 @@ func NewSSEClientWithRequestResponse(
 		client:     reqClient,
 		retryFunc:  retryFn,
 		handler:    handler,
+		done:       make(chan struct{}),
 		canceler:   canceler,
 		ctx:        newCtx,
 		request:    req,
 		response:   res,
+		lastIds:    map[string]string{},
+		redactor:   RedactPayload,
+		headers:    http.Header{},
 		retry:      0,
+
+		maxLastIdsSize: DefaultMaxLastEventIdHeaderSize,
+	}
+
+	if handler == nil {
+		client.messages = make(chan sabuhp.Message)
+	}
+
+	for _, mod := range mods {
+		mod(client)
 	}
 
 	client.waiter.Add(1)
@@ -177,6 +334,57 @@ func (sc *SSEClient) Wait() {
 	sc.waiter.Wait()
 }
 
+// Next blocks till the next message is received and returns it, for
+// clients created without a MessageHandler.
+//
+// It returns the context error if ctx is done first, ErrClientClosed once
+// the client is closed and ErrHandlerMode for a client with a handler.
+func (sc *SSEClient) Next(ctx context.Context) (*sabuhp.Message, error) {
+	if sc.messages == nil {
+		return nil, nerror.WrapOnly(ErrHandlerMode)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, nerror.WrapOnly(ctx.Err())
+	case <-sc.done:
+		return nil, nerror.WrapOnly(ErrClientClosed)
+	case message := <-sc.messages:
+		return &message, nil
+	}
+}
+
+// Err returns ErrReconnectFailed once the client gave up reconnecting,
+// nil otherwise.
+func (sc *SSEClient) Err() error {
+	sc.errMu.Lock()
+	defer sc.errMu.Unlock()
+	return sc.err
+}
+
+// giveUp ends a client which failed to reconnect.
+func (sc *SSEClient) giveUp(err error) {
+	njson.Log(sc.logger).New().
+		LError().
+		Message("failed to reconnect, closing client").
+		String("error", nerror.WrapOnly(err).Error()).
+		End()
+
+	sc.errMu.Lock()
+	sc.err = ErrReconnectFailed
+	sc.errMu.Unlock()
+
+	sc.canceler()
+	sc.finish()
+}
+
+// finish marks the client as done, it's called once by the last run
+// or reconnect of the client.
+func (sc *SSEClient) finish() {
+	close(sc.done)
+	sc.waiter.Done()
+}
+
 func (sc *SSEClient) Send(msgs ...sabuhp.Message) {
 	for _, msg := range msgs {
 		if err := sc.SendAsMethod(sc.method, msg); err != nil {
@@ -188,16 +396,14 @@ func (sc *SSEClient) Send(msgs ...sabuhp.Message) {
 }
 
 func (sc *SSEClient) SendAsMethod(method string, msg sabuhp.Message) error {
-	var header = http.Header{}
+	var header = sc.customHeaders()
 	for k, v := range msg.Headers {
 		header[k] = v
 	}
 
 	header.Set("Cache-Control", "no-cache")
 	header.Set(ClientIdentificationHeader, sc.id.String())
-	if !sc.lastId.IsNil() {
-		header.Set(LastEventIdListHeader, sc.lastId.String())
-	}
+	sc.setLastEventIdHeader(header)
 
 	var ctx = sc.ctx
 	var canceler context.CancelFunc
@@ -251,6 +457,8 @@ func (sc *SSEClient) SendAsMethod(method string, msg sabuhp.Message) error {
 	return nil
 }
 
+// Start does nothing, the client starts reading once created, so it's
+// safe to call any number of times.
 func (sc *SSEClient) Start() {
 	// do nothing
 }
@@ -273,142 +481,206 @@ func (sc *SSEClient) Close() error {
 
 func (sc *SSEClient) run() {
 	var normalized = utils.NewNormalisedReader(sc.response.Body)
-	var reader = bufio.NewReader(normalized)
-
-	var contentType string
-	var decoding = false
-	var data bytes.Buffer
+	var reader = newEventReader(normalized)
 
 doLoop:
 	for {
 		select {
 		case <-sc.ctx.Done():
-			sc.waiter.Done()
 			_ = sc.response.Body.Close()
+			sc.finish()
 			return
 		default:
 			// do nothing.
 		}
 
-		var line, lineErr = reader.ReadString('\n')
-		if lineErr != nil {
+		var event, readErr = reader.Next()
+		if errors.Is(readErr, io.EOF) {
+			// the server ended the stream cleanly, which is not an error.
+			njson.Log(sc.logger).New().
+				LInfo().
+				Message("server closed the stream").
+				End()
+			break doLoop
+		}
+		if readErr != nil {
 			njson.Log(sc.logger).New().
 				LError().
 				Message("failed to read more data").
-				String("error", nerror.WrapOnly(lineErr).Error()).
+				String("error", nerror.WrapOnly(readErr).Error()).
 				End()
 			break doLoop
 		}
 
-		if line == "" {
-			continue doLoop
-		}
+		njson.Log(sc.logger).New().
+			LInfo().
+			Message("received complete data").
+			String("data", sc.redactor(event.Data)).
+			End()
 
-		// if we see only a new line then this is the end of
-		// an event data section.
-		if line == "\n" && decoding {
-			decoding = false
+		var contentType = event.ContentType
+		var dataLine = event.Data
 
-			// if we have data, then decode and
-			// deliver to handler.
-			if data.Len() != 0 {
+		var messageErr error
+		var message sabuhp.Message
+		if !sc.rawMode && contentType == sabuhp.MessageContentType {
+			message, messageErr = sc.codec.Decode(dataLine)
+			if messageErr != nil {
+				var wrappedErr = nerror.WrapOnly(messageErr)
 				njson.Log(sc.logger).New().
-					LInfo().
-					Message("received complete data").
-					String("data", data.String()).
+					LError().
+					Message("failed to handle message").
+					Error("error", wrappedErr).
 					End()
-
-				var dataLine = bytes.TrimPrefix(data.Bytes(), dataHeaderBytes)
-				dataLine = bytes.TrimPrefix(dataLine, spaceBytes)
-
-				var messageErr error
-				var message sabuhp.Message
-				if contentType == sabuhp.MessageContentType {
-					message, messageErr = sc.codec.Decode(dataLine)
-					if messageErr != nil {
-						var wrappedErr = nerror.WrapOnly(messageErr)
-						njson.Log(sc.logger).New().
-							LError().
-							Message("failed to handle message").
-							Error("error", wrappedErr).
-							End()
-						continue doLoop
-					}
-					if len(message.Path) == 0 {
-						message.Path = sc.request.URL.Path
-					}
-				} else {
-					var payload = make([]byte, len(dataLine))
-					_ = copy(payload, dataLine)
-
-					message = sabuhp.Message{
-						Topic:       sabuhp.T(sc.request.URL.Path),
-						Id:          nxid.New(),
-						Path:        sc.request.URL.Path,
-						ContentType: contentType,
-						Query:       url.Values{},
-						Form:        url.Values{},
-						Headers:     sabuhp.Header{},
-						Cookies:     nil,
-						Bytes:       payload,
-						Metadata:    map[string]string{},
-						Params:      map[string]string{},
-					}
-				}
-
-				if handleErr := sc.handler(message, sc); handleErr != nil {
-					var wrappedErr = nerror.WrapOnly(handleErr)
-					njson.Log(sc.logger).New().
-						LError().
-						Message("failed to handle message").
-						Error("error", wrappedErr).
-						End()
-				}
+				continue doLoop
 			}
+			if len(message.Path) == 0 {
+				message.Path = sc.request.URL.Path
+			}
+		} else {
+			var payload = make([]byte, len(dataLine))
+			_ = copy(payload, dataLine)
+
+			message = sabuhp.Message{
+				Topic:       sabuhp.T(sc.request.URL.Path),
+				Id:          nxid.New(),
+				Path:        sc.request.URL.Path,
+				ContentType: contentType,
+				Query:       url.Values{},
+				Form:        url.Values{},
+				Headers:     sabuhp.Header{},
+				Cookies:     nil,
+				Bytes:       payload,
+				Metadata:    map[string]string{},
+				Params:      map[string]string{},
+			}
+		}
 
-			continue doLoop
+		// metadata sent as field lines adds to the message's own.
+		if len(event.Metadata) != 0 {
+			if message.Metadata == nil {
+				message.Metadata = map[string]string{}
+			}
+			for key, value := range event.Metadata {
+				message.Metadata[key] = value
+			}
 		}
 
-		if line == "\n" && !decoding {
-			continue doLoop
+		if len(event.Id) != 0 {
+			sc.setLastEventId(message.Topic.String(), event.Id)
 		}
 
-		var stripLine = strings.TrimSpace(line)
-		if strings.HasPrefix(stripLine, eventHeader) {
-			contentType = strings.TrimSpace(strings.TrimPrefix(stripLine, eventHeader))
-			decoding = true
-			data.Reset()
-			continue
+		if sc.messages != nil {
+			select {
+			case sc.messages <- message:
+				continue doLoop
+			case <-sc.ctx.Done():
+				break doLoop
+			}
 		}
 
-		line = strings.TrimSuffix(line, newLine)
-		line = strings.TrimPrefix(line, newLine)
-		data.WriteString(line)
+		if handleErr := sc.handler(message, sc); handleErr != nil {
+			var wrappedErr = nerror.WrapOnly(handleErr)
+			njson.Log(sc.logger).New().
+				LError().
+				Message("failed to handle message").
+				String("topic", message.Topic.String()).
+				String("event_id", event.Id).
+				Error("error", wrappedErr).
+				End()
+		}
 	}
 
+	_ = sc.response.Body.Close()
+	if sc.noReconnect {
+		sc.finish()
+		return
+	}
 	sc.reconnect()
 }
 
+// OnReconnect registers a callback called after the client
+// re-established its connection to the server.
+func (sc *SSEClient) OnReconnect(fn func()) {
+	sc.reconnects.OnReconnect(fn)
+}
+
+// LastEventIds returns a copy of the last event id received for each
+// stream, keyed by the topic of the stream's messages.
+func (sc *SSEClient) LastEventIds() map[string]string {
+	sc.lastIdsMu.Lock()
+	defer sc.lastIdsMu.Unlock()
+
+	var lastIds = make(map[string]string, len(sc.lastIds))
+	for stream, id := range sc.lastIds {
+		lastIds[stream] = id
+	}
+	return lastIds
+}
+
+func (sc *SSEClient) setLastEventId(stream string, id string) {
+	sc.lastIdsMu.Lock()
+	sc.lastIds[stream] = id
+	sc.lastIdsMu.Unlock()
+}
+
+func (sc *SSEClient) setLastEventIdHeader(header http.Header) {
+	sc.lastIdsMu.Lock()
+	defer sc.lastIdsMu.Unlock()
+	SetLastEventIds(header, sc.lastIds, sc.maxLastIdsSize)
+}
+
+// customHeaders returns a copy of the headers added with WithHeaders.
+func (sc *SSEClient) customHeaders() http.Header {
+	var header = make(http.Header, len(sc.headers))
+	for k, v := range sc.headers {
+		header[k] = append([]string(nil), v...)
+	}
+	return header
+}
+
+// reconnect re-establishes the connection to the server, launching a new
+// run on success. Once retries are exhausted or the client is closed, the
+// client is done and Wait returns.
 func (sc *SSEClient) reconnect() {
 	select {
 	case <-sc.ctx.Done():
-		sc.waiter.Done()
+		sc.finish()
 		return
 	default:
 	}
-	var header = http.Header{}
+	var header = sc.customHeaders()
 	header.Set("Connection", "keep-alive")
 	header.Set("Cache-Control", "no-cache")
 	header.Set("Accept", "text/event-stream")
 	header.Set(ClientIdentificationHeader, sc.id.String())
-	if !sc.lastId.IsNil() {
-		header.Set(LastEventIdListHeader, sc.lastId.String())
+	sc.setLastEventIdHeader(header)
+
+	var deadline time.Time
+	if sc.maxReconnect > 0 {
+		deadline = time.Now().Add(sc.maxReconnect)
 	}
 
 	var retryCount int
 	for {
 		var delay = sc.retryFunc(retryCount)
-		<-time.After(delay)
+		if !deadline.IsZero() {
+			var remaining = time.Until(deadline)
+			if remaining <= 0 {
+				sc.giveUp(nerror.New("reconnect exceeded %s", sc.maxReconnect))
+				return
+			}
+			if delay > remaining {
+				delay = remaining
+			}
+		}
+
+		select {
+		case <-sc.ctx.Done():
+			sc.finish()
+			return
+		case <-time.After(delay):
+		}
 
 		var req, response, err = utils.DoRequest(
 			sc.ctx,
@@ -423,17 +695,15 @@ func (sc *SSEClient) reconnect() {
 			continue
 		}
 		if err != nil && retryCount >= sc.maxRetries {
-			njson.Log(sc.logger).New().
-				LError().
-				Message("failed to create request").
-				String("error", nerror.WrapOnly(err).Error()).
-				End()
+			sc.giveUp(err)
 			return
 		}
 
 		sc.request = req
 		sc.response = response
 		go sc.run()
+
+		sc.reconnects.Notify()
 		return
 	}
 }
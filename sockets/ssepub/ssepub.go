@@ -6,6 +6,7 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -28,8 +29,51 @@ const (
 	LastEventIdListHeader      = "X-SSE-Last-Event-Ids"
 
 	eventHeader = "event:"
+
+	// DefaultWriteQueueSize is the number of messages queued for a client
+	// before it's considered too slow and evicted.
+	DefaultWriteQueueSize = 128
 )
 
+// ErrSlowClient is set on the future of the message which overflowed the
+// write queue of a client, evicting it.
+var ErrSlowClient = nerror.New("sse client is too slow, evicted")
+
+// ErrSocketClosed is set on the future of messages sent to a closed socket.
+var ErrSocketClosed = nerror.New("sse socket is closed")
+
+// SlowClient describes a client evicted for not keeping up with the
+// messages sent to it.
+type SlowClient struct {
+	ClientId  string
+	SocketId  string
+	Topic     string
+	QueueSize int
+}
+
+// ConnectionMetrics describes the backpressure of a sse connection, a
+// write queue staying near its size marks a client falling behind before
+// it's evicted.
+type ConnectionMetrics struct {
+	ClientId string
+	SocketId string
+
+	// BytesWritten is the number of bytes of events written to the client.
+	BytesWritten int64
+
+	// EventsSent is the number of events written to the client.
+	EventsSent int64
+
+	// QueueDepth is the number of messages waiting to be written and
+	// QueueSize the number of messages queued before the client is evicted.
+	QueueDepth int
+	QueueSize  int
+
+	// Dropped is the number of messages never written, as the queue was
+	// full or the connection closed.
+	Dropped int64
+}
+
 var doubleLine = []byte("\n\n")
 
 var _ sabuhp.Handler = (*SSEServer)(nil)
@@ -40,8 +84,10 @@ func ManagedSSEServer(
 	optionalHeaders sabuhp.HeaderModifications,
 	codec sabuhp.Codec,
 ) *SSEServer {
+	var newCtx, canceler = context.WithCancel(ctx)
 	return &SSEServer{
-		ctx:             ctx,
+		ctx:             newCtx,
+		canceler:        canceler,
 		logger:          logger,
 		codec:           codec,
 		optionalHeaders: optionalHeaders,
@@ -51,15 +97,105 @@ func ManagedSSEServer(
 }
 
 type SSEServer struct {
+	// ReplayThrottle when set paces new connections of clients
+	// reconnecting with last event ids, which request a replay.
+	ReplayThrottle *ReplayThrottle
+
+	// WriteQueueSize is the number of messages queued for a client before
+	// it's evicted, defaults to DefaultWriteQueueSize.
+	WriteQueueSize int
+
+	// OnSlowClient when set is called with every evicted client.
+	OnSlowClient func(SlowClient)
+
+	// MetadataFields when set writes the Metadata of every message as
+	// "x-meta-<key>: <value>" field lines of its event as well, so
+	// lightweight clients can read it without decoding the message.
+	MetadataFields bool
+
+	// Replay when set assigns event ids from its per topic sequences, so a
+	// message has the same id on every connection, and replays the
+	// messages a client reconnecting with last event ids missed.
+	Replay *ReplayBuffer
+
+	evictions int64
+
 	logger          sabuhp.Logger
 	codec           sabuhp.Codec
 	optionalHeaders sabuhp.HeaderModifications
 	ctx             context.Context
+	canceler        context.CancelFunc
 	streams         *sabuhp.SocketServers
 	ssl             sync.RWMutex
 	sockets         map[string]*SSESocket
 }
 
+// Connections returns the number of live sse connections.
+func (sse *SSEServer) Connections() int {
+	sse.ssl.RLock()
+	defer sse.ssl.RUnlock()
+	return len(sse.sockets)
+}
+
+// Evictions returns the number of clients evicted for being too slow.
+func (sse *SSEServer) Evictions() int64 {
+	return atomic.LoadInt64(&sse.evictions)
+}
+
+// ConnectionMetrics returns the metrics of every live sse connection,
+// keyed by client id.
+func (sse *SSEServer) ConnectionMetrics() map[string]ConnectionMetrics {
+	sse.ssl.RLock()
+	defer sse.ssl.RUnlock()
+
+	var metrics = make(map[string]ConnectionMetrics, len(sse.sockets))
+	for clientId, socket := range sse.sockets {
+		metrics[clientId] = socket.Metrics()
+	}
+	return metrics
+}
+
+// evict closes the subscriptions of a slow client right away, rather
+// than once its blocked writes fail, and reports it.
+func (sse *SSEServer) evict(socket *SSESocket, msg sabuhp.Message) {
+	atomic.AddInt64(&sse.evictions, 1)
+
+	var slow = SlowClient{
+		ClientId:  socket.clientId,
+		SocketId:  socket.xid.String(),
+		Topic:     msg.Topic.String(),
+		QueueSize: cap(socket.queue),
+	}
+
+	njson.Log(sse.logger).New().
+		LWarn().
+		Message("evicted slow sse client").
+		String("client_id", slow.ClientId).
+		String("socket_id", slow.SocketId).
+		String("topic", slow.Topic).
+		Int("queue_size", slow.QueueSize).
+		End()
+
+	sse.closeSocket(socket)
+
+	if sse.OnSlowClient != nil {
+		sse.OnSlowClient(slow)
+	}
+}
+
+func (sse *SSEServer) closeSocket(socket *SSESocket) {
+	socket.closed.Do(func() {
+		sse.streams.SocketClosed(socket)
+	})
+}
+
+// Shutdown ends all live sse connections, returning from their handlers,
+// and rejects new ones. It's meant to be called as the http server shuts
+// down, which would otherwise wait on the never ending streams.
+func (sse *SSEServer) Shutdown() {
+	sse.canceler()
+}
+
 func (sse *SSEServer) Stream(server sabuhp.SocketService) {
 	sse.streams.Stream(server)
 }
@@ -91,6 +227,16 @@ func (sse *SSEServer) Handle(w http.ResponseWriter, r *http.Request, p sabuhp.Pa
 		String("client_id", clientId).
 		End()
 
+	if sse.ctx.Err() != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		stack.New().
+			LWarn().
+			Message("rejected sse request as server is shutting down").
+			String("client_id", clientId).
+			End()
+		return
+	}
+
 	clientIdCount := len(clientId)
 	if clientIdCount == 0 {
 		var cerr = nerror.New("Request does not have the client identification header")
@@ -149,6 +295,17 @@ func (sse *SSEServer) Handle(w http.ResponseWriter, r *http.Request, p sabuhp.Pa
 	sse.ssl.RUnlock()
 
 	if !hasSocket {
+		if throttleErr := sse.throttleReplay(r); throttleErr != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			stack.New().
+				LWarn().
+				Message("client went away while waiting to replay").
+				String("client_id", clientId).
+				String("error", throttleErr.Error()).
+				End()
+			return
+		}
+
 		var socket = NewSSESocket(
 			clientId,
 			sse.ctx,
@@ -159,6 +316,16 @@ func (sse *SSEServer) Handle(w http.ResponseWriter, r *http.Request, p sabuhp.Pa
 			sse.logger,
 			sse.optionalHeaders,
 		)
+		if sse.WriteQueueSize > 0 {
+			socket.queue = make(chan sabuhp.Message, sse.WriteQueueSize)
+		}
+		socket.metadataFields = sse.MetadataFields
+		socket.replay = sse.Replay
+		socket.onEvict = func(msg sabuhp.Message) {
+			// sends come from the socket services, which must not be
+			// called back into while delivering.
+			go sse.evict(socket, msg)
+		}
 
 		stack.New().
 			LInfo().
@@ -188,7 +355,7 @@ func (sse *SSEServer) Handle(w http.ResponseWriter, r *http.Request, p sabuhp.Pa
 				String("error", nerror.WrapOnly(startSocketErr).Error()).
 				End()
 
-			sse.streams.SocketClosed(socket)
+			sse.closeSocket(socket)
 			return
 		}
 
@@ -204,7 +371,11 @@ func (sse *SSEServer) Handle(w http.ResponseWriter, r *http.Request, p sabuhp.Pa
 
 		socket.Wait()
 
-		sse.streams.SocketClosed(socket)
+		sse.ssl.Lock()
+		delete(sse.sockets, clientId)
+		sse.ssl.Unlock()
+
+		sse.closeSocket(socket)
 
 		return
 	}
@@ -247,6 +418,21 @@ func (sse *SSEServer) Handle(w http.ResponseWriter, r *http.Request, p sabuhp.Pa
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// throttleReplay waits on the ReplayThrottle if the request is a
+// reconnect asking for a replay.
+func (sse *SSEServer) throttleReplay(r *http.Request) error {
+	if sse.ReplayThrottle == nil || len(ReadLastEventIds(r.Header)) == 0 {
+		return nil
+	}
+	if waitErr := sse.ReplayThrottle.Wait(r.Context()); waitErr != nil {
+		return waitErr
+	}
+	if shutdownErr := sse.ctx.Err(); shutdownErr != nil {
+		return nerror.WrapOnly(shutdownErr)
+	}
+	return nil
+}
+
 var _ sabuhp.Socket = (*SSESocket)(nil)
 
 type SSESocket struct {
@@ -267,10 +453,31 @@ type SSESocket struct {
 	headers    sabuhp.HeaderModifications
 	remoteAddr net.Addr
 	localAddr  net.Addr
+	lastIds    map[string]string
+
+	// writeMu orders writes of events, so their ids are written in order.
+	writeMu  sync.Mutex
+	eventIds map[string]uint64
+	replay   *ReplayBuffer
+
+	// queue holds messages till written, a full queue evicts the client.
+	// queueMu orders sends with the drain of a stopped socket, which sets
+	// queueClosed so no message is queued after the drain.
+	queue       chan sabuhp.Message
+	queueMu     sync.Mutex
+	queueClosed bool
+
+	onEvict   func(msg sabuhp.Message)
+	evictOnce sync.Once
+	closed    sync.Once
+
+	metadataFields bool
 
 	sent     int64
 	handled  int64
 	received int64
+	written  int64
+	dropped  int64
 }
 
 func NewSSESocket(
@@ -304,6 +511,9 @@ func NewSSESocket(
 		canceler: newCanceler,
 		headers:  optionalHeaders,
 		handlers: sabuhp.NewSock(nil),
+		lastIds:  ReadLastEventIds(r.Header),
+		eventIds: map[string]uint64{},
+		queue:    make(chan sabuhp.Message, DefaultWriteQueueSize),
 	}
 }
 
@@ -335,6 +545,78 @@ func (se *SSESocket) Stat() sabuhp.SocketStat {
 	return stat
 }
 
+// Metrics returns the backpressure metrics of the connection.
+func (se *SSESocket) Metrics() ConnectionMetrics {
+	return ConnectionMetrics{
+		ClientId:     se.clientId,
+		SocketId:     se.xid.String(),
+		BytesWritten: atomic.LoadInt64(&se.written),
+		EventsSent:   atomic.LoadInt64(&se.sent),
+		QueueDepth:   len(se.queue),
+		QueueSize:    cap(se.queue),
+		Dropped:      atomic.LoadInt64(&se.dropped),
+	}
+}
+
+// LastEventIds returns the last event id of each stream the client
+// reported when connecting, keyed by the topic of the stream's messages.
+func (se *SSESocket) LastEventIds() map[string]string {
+	return se.lastIds
+}
+
+// SentEventIds returns the id of the last event written for each topic,
+// which the server's ReplayBuffer maps to the message written.
+func (se *SSESocket) SentEventIds() map[string]string {
+	se.writeMu.Lock()
+	defer se.writeMu.Unlock()
+
+	var sentIds = make(map[string]string, len(se.eventIds))
+	for topic, id := range se.eventIds {
+		sentIds[topic] = strconv.FormatUint(id, 10)
+	}
+	return sentIds
+}
+
+// nextEventId returns the id of the event of the message and false if the
+// client has already seen it. Ids come from the replay buffer when set,
+// else they are sequences per topic continuing after the last event id
+// the client reported, so they increase across reconnects.
+//
+// It must be called with writeMu held.
+func (se *SSESocket) nextEventId(msg sabuhp.Message) (string, bool) {
+	var topic = msg.Topic.String()
+	var lastId, seen = se.eventIds[topic]
+	if !seen {
+		// a last id not assigned by the server fails to parse and
+		// starts the sequence over.
+		lastId, _ = strconv.ParseUint(se.lastIds[topic], 10, 64)
+	}
+
+	var id = lastId + 1
+	if se.replay != nil {
+		id = se.replay.Record(msg)
+		if id <= lastId {
+			// replayed or written before the client reconnected.
+			return "", false
+		}
+	}
+	se.eventIds[topic] = id
+	return strconv.FormatUint(id, 10), true
+}
+
+// replayMissed writes the messages the replay buffer kept after the last
+// event ids the client reported, before any queued message.
+func (se *SSESocket) replayMissed() {
+	if se.replay == nil {
+		return
+	}
+	for topic, lastId := range se.lastIds {
+		for _, msg := range se.replay.Since(topic, lastId) {
+			se.sendWrite(msg)
+		}
+	}
+}
+
 func (se *SSESocket) RemoteAddr() net.Addr {
 	return se.remoteAddr
 }
@@ -343,9 +625,86 @@ func (se *SSESocket) LocalAddr() net.Addr {
 	return se.localAddr
 }
 
+// Send queues the messages to be written to the client, it never blocks
+// on a slow client. A client whose write queue is full is evicted.
 func (se *SSESocket) Send(messages ...sabuhp.Message) {
 	for _, msg := range messages {
-		se.sendWrite(msg)
+		var queued, closed = se.enqueue(msg)
+		if closed {
+			atomic.AddInt64(&se.dropped, 1)
+			failMessage(msg, ErrSocketClosed)
+			continue
+		}
+		if !queued {
+			atomic.AddInt64(&se.dropped, 1)
+			se.evict(msg)
+		}
+	}
+}
+
+// enqueue queues the message unless the queue is full or was drained.
+func (se *SSESocket) enqueue(msg sabuhp.Message) (queued bool, closed bool) {
+	se.queueMu.Lock()
+	defer se.queueMu.Unlock()
+
+	if se.queueClosed || se.ctx.Err() != nil {
+		return false, true
+	}
+
+	select {
+	case se.queue <- msg:
+		return true, false
+	default:
+		return false, false
+	}
+}
+
+// drainQueue fails the queued messages of the stopped socket. The queue
+// is closed to sends in the same step, so none is left behind unfailed.
+func (se *SSESocket) drainQueue() {
+	se.queueMu.Lock()
+	defer se.queueMu.Unlock()
+
+	se.queueClosed = true
+	for {
+		select {
+		case msg := <-se.queue:
+			atomic.AddInt64(&se.dropped, 1)
+			failMessage(msg, ErrSocketClosed)
+		default:
+			return
+		}
+	}
+}
+
+// evict stops the socket once its write queue overflowed.
+func (se *SSESocket) evict(msg sabuhp.Message) {
+	failMessage(msg, ErrSlowClient)
+	se.evictOnce.Do(func() {
+		se.canceler()
+		if se.onEvict != nil {
+			se.onEvict(msg)
+		}
+	})
+}
+
+// writeQueue writes the queued messages till the socket is stopped.
+func (se *SSESocket) writeQueue() {
+	defer se.waiter.Done()
+	for {
+		select {
+		case <-se.ctx.Done():
+			se.drainQueue()
+			return
+		case msg := <-se.queue:
+			se.sendWrite(msg)
+		}
+	}
+}
+
+func failMessage(msg sabuhp.Message, err error) {
+	if msg.Future != nil {
+		msg.Future.WithError(nerror.WrapOnly(err))
 	}
 }
 
@@ -460,13 +819,17 @@ func (se *SSESocket) Start() error {
 
 	se.flusher = flusher
 
-	se.res.WriteHeader(http.StatusOK)
-
-	// Set the headers related to event streaming.
+	// Set the headers related to event streaming, they must be set before
+	// the header is written.
+	//
+	// Proxies like nginx buffer responses by default, which holds back
+	// events till the connection closes, X-Accel-Buffering disables it
+	// for the stream without changing the proxy configuration.
 	se.res.Header().Set("Content-Type", "text/event-stream")
 	se.res.Header().Set("Cache-Control", "no-cache")
 	se.res.Header().Set("Connection", "keep-alive")
 	se.res.Header().Set("Transfer-Encoding", "chunked")
+	se.res.Header().Set("X-Accel-Buffering", "no")
 	se.res.Header().Set("Access-Control-Allow-Origin", "*")
 	se.res.Header().Set(ClientIdentificationHeader, se.clientId)
 
@@ -474,15 +837,26 @@ func (se *SSESocket) Start() error {
 		se.headers(se.res.Header())
 	}
 
+	se.res.WriteHeader(http.StatusOK)
+
 	if err := se.readRequest(se.req); err != nil {
 		return nerror.WrapOnly(err)
 	}
 
 	se.flusher.Flush()
 
-	se.waiter.Add(1)
+	se.replayMissed()
+
+	se.waiter.Add(2)
+	go se.writeQueue()
 	go func() {
-		<-se.ctx.Done()
+		// the request context is canceled when the client goes away,
+		// which must end the socket as well.
+		select {
+		case <-se.ctx.Done():
+		case <-se.req.Context().Done():
+			se.canceler()
+		}
 		se.waiter.Done()
 	}()
 	return nil
@@ -497,23 +871,32 @@ func (se *SSESocket) sendWrite(msg sabuhp.Message) {
 		return
 	}
 
+	se.writeMu.Lock()
+	defer se.writeMu.Unlock()
+
+	var eventId, unseen = se.nextEventId(msg)
+	if !unseen {
+		if msg.Future != nil {
+			msg.Future.WithValue(nil)
+		}
+		return
+	}
+
 	var builder strings.Builder
 	builder.Reset()
+	builder.WriteString("id: ")
+	builder.WriteString(eventId)
+	builder.WriteString("\n")
 	builder.WriteString("event: ")
 	builder.WriteString(msg.ContentType)
 	builder.WriteString("\n")
-	builder.WriteString("data: ")
-	builder.Write(encodedMessage)
-	builder.WriteString("\n\n")
+	if se.metadataFields {
+		writeEventMetadata(&builder, msg.Metadata)
+	}
+	writeEventData(&builder, encodedMessage)
+	builder.WriteString("\n")
 
 	var stack = njson.Log(se.logger)
-
-	stack.New().
-		LInfo().
-		Message("sending new data into writer").
-		String("data", builder.String()).
-		End()
-
 	if sentCount, writeErr := se.res.Write(nunsafe.String2Bytes(builder.String())); writeErr != nil {
 		stack.New().
 			LError().
@@ -529,6 +912,9 @@ func (se *SSESocket) sendWrite(msg sabuhp.Message) {
 
 	se.flusher.Flush()
 
+	atomic.AddInt64(&se.sent, 1)
+	atomic.AddInt64(&se.written, int64(builder.Len()))
+
 	if msg.Future != nil {
 		msg.Future.WithValue(nil)
 	}
@@ -4,8 +4,18 @@ import (
 	"context"
 	"fmt"
 	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/influx6/npkg/njson"
+	"github.com/influx6/npkg/nthen"
+	"github.com/influx6/npkg/nxid"
 
 	"github.com/ewe-studios/sabuhp"
 	"github.com/ewe-studios/sabuhp/codecs"
@@ -63,3 +73,1140 @@ func TestNewSSEHub(t *testing.T) {
 	httpServer.Close()
 	socket.Wait()
 }
+
+func TestSSEClient_ReconnectWithLastEventIds(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var codec = &codecs.MessageJsonCodec{}
+
+	var writeEvent = func(w http.ResponseWriter, id string, msg sabuhp.Message) {
+		var encoded, encodeErr = codec.Encode(msg)
+		require.NoError(t, encodeErr)
+
+		var builder strings.Builder
+		builder.WriteString("id: " + id + "\n")
+		builder.WriteString("event: " + sabuhp.MessageContentType + "\n")
+		writeEventData(&builder, encoded)
+		builder.WriteString("\n")
+
+		var _, writeErr = w.Write([]byte(builder.String()))
+		require.NoError(t, writeErr)
+	}
+
+	var requests int32
+	var reconnectHeader = make(chan string, 1)
+	var httpServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+
+		if atomic.AddInt32(&requests, 1) > 1 {
+			reconnectHeader <- r.Header.Get(LastEventIdListHeader)
+			<-r.Context().Done()
+			return
+		}
+
+		var orders = testingutils.Msg(sabuhp.T("orders"), "order", "me")
+		var users = testingutils.Msg(sabuhp.T("users"), "user", "me")
+
+		writeEvent(w, "orders-1", orders)
+		writeEvent(w, "users-1", users)
+		writeEvent(w, "orders-2", orders)
+		w.(http.Flusher).Flush()
+	}))
+	defer httpServer.Close()
+
+	var received = make(chan sabuhp.Message, 3)
+	var socket, err = NewSSEClient(
+		controlCtx,
+		nxid.New(),
+		5,
+		httpServer.URL,
+		"GET",
+		func(b sabuhp.Message, socket *SSEClient) error {
+			received <- b
+			return nil
+		},
+		linearBackOff,
+		codec,
+		logger,
+		httpServer.Client(),
+	)
+	require.NoError(t, err)
+
+	var reconnected = make(chan struct{}, 1)
+	require.True(t, sabuhp.OnReconnect(socket, func() {
+		reconnected <- struct{}{}
+	}))
+
+	for i := 0; i < 3; i++ {
+		<-received
+	}
+
+	var header = <-reconnectHeader
+	require.Equal(t, map[string]string{
+		"orders": "orders-2",
+		"users":  "users-1",
+	}, ParseLastEventIds(header))
+	require.Equal(t, "orders=orders-2,users=users-1", header)
+	require.Equal(t, ParseLastEventIds(header), socket.LastEventIds())
+
+	select {
+	case <-reconnected:
+	case <-time.After(time.Second):
+		require.Fail(t, "reconnect callback should have been called")
+	}
+
+	controlStopFunc()
+	socket.Wait()
+}
+
+func TestSSEClient_MaxLastEventIdHeaderSize(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var codec = &codecs.MessageJsonCodec{}
+
+	var requests int32
+	var reconnectHeader = make(chan []string, 1)
+	var httpServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+
+		if atomic.AddInt32(&requests, 1) > 1 {
+			reconnectHeader <- r.Header.Values(LastEventIdListHeader)
+			<-r.Context().Done()
+			return
+		}
+
+		for _, event := range []struct{ id, topic string }{{"orders-2", "orders"}, {"users-1", "users"}} {
+			var encoded, encodeErr = codec.Encode(testingutils.Msg(sabuhp.T(event.topic), "data", "me"))
+			require.NoError(t, encodeErr)
+
+			var builder strings.Builder
+			builder.WriteString("id: " + event.id + "\n")
+			builder.WriteString("event: " + sabuhp.MessageContentType + "\n")
+			writeEventData(&builder, encoded)
+			builder.WriteString("\n")
+
+			var _, writeErr = w.Write([]byte(builder.String()))
+			require.NoError(t, writeErr)
+		}
+		w.(http.Flusher).Flush()
+	}))
+	defer httpServer.Close()
+
+	var received = make(chan sabuhp.Message, 2)
+	var socket, err = NewSSEClient(
+		controlCtx,
+		nxid.New(),
+		5,
+		httpServer.URL,
+		"GET",
+		func(b sabuhp.Message, socket *SSEClient) error {
+			received <- b
+			return nil
+		},
+		linearBackOff,
+		codec,
+		logger,
+		httpServer.Client(),
+		WithMaxLastEventIdHeaderSize(16),
+	)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		<-received
+	}
+
+	require.Equal(t, []string{"orders=orders-2", "users=users-1"}, <-reconnectHeader)
+
+	controlStopFunc()
+	socket.Wait()
+}
+
+func TestSSEClient_RawMode(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var httpServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+
+		var _, writeErr = w.Write([]byte("data: {\"name\": \"alex\"}\n\n"))
+		require.NoError(t, writeErr)
+
+		_, writeErr = w.Write([]byte("event: " + sabuhp.MessageContentType + "\ndata: {\"name\": \"wale\"}\n\n"))
+		require.NoError(t, writeErr)
+
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer httpServer.Close()
+
+	var received = make(chan sabuhp.Message, 2)
+	var socket, err = NewSSEClient2(
+		controlCtx,
+		httpServer.URL+"/users",
+		"GET",
+		func(b sabuhp.Message, socket *SSEClient) error {
+			received <- b
+			return nil
+		},
+		&codecs.MessageJsonCodec{},
+		logger,
+		httpServer.Client(),
+		WithRawMode(),
+	)
+	require.NoError(t, err)
+
+	var first = <-received
+	require.Equal(t, "/users", first.Topic.String())
+	require.Equal(t, "/users", first.Path)
+	require.Equal(t, `{"name": "alex"}`, string(first.Bytes))
+
+	var second = <-received
+	require.Equal(t, "/users", second.Topic.String())
+	require.Equal(t, `{"name": "wale"}`, string(second.Bytes))
+
+	controlStopFunc()
+	socket.Wait()
+}
+
+func TestSSEClient_FieldsSplitAcrossWrites(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var codec = &codecs.MessageJsonCodec{}
+	var encoded, encodeErr = codec.Encode(sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("world")))
+	require.NoError(t, encodeErr)
+
+	var httpServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+
+		// the event header and data line are flushed in fragments, so the
+		// client reads each of them over several reads.
+		var stream = "event: " + sabuhp.MessageContentType + "\ndata: " + string(encoded) + "\n\n"
+		for len(stream) > 0 {
+			var size = 5
+			if size > len(stream) {
+				size = len(stream)
+			}
+			var _, writeErr = w.Write([]byte(stream[:size]))
+			require.NoError(t, writeErr)
+			w.(http.Flusher).Flush()
+			stream = stream[size:]
+			time.Sleep(time.Millisecond)
+		}
+		<-r.Context().Done()
+	}))
+	defer httpServer.Close()
+
+	var received = make(chan sabuhp.Message, 1)
+	var socket, err = NewSSEClient2(
+		controlCtx,
+		httpServer.URL+"/users",
+		"GET",
+		func(b sabuhp.Message, socket *SSEClient) error {
+			received <- b
+			return nil
+		},
+		codec,
+		logger,
+		httpServer.Client(),
+	)
+	require.NoError(t, err)
+
+	select {
+	case message := <-received:
+		require.Equal(t, "hello", message.Topic.String())
+		require.Equal(t, "world", string(message.Bytes))
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "message should be received")
+	}
+
+	controlStopFunc()
+	socket.Wait()
+}
+
+type captureLogger struct {
+	sync.Mutex
+	lines []string
+}
+
+func (c *captureLogger) Log(cb *njson.JSON) {
+	c.Lock()
+	c.lines = append(c.lines, cb.Message())
+	c.Unlock()
+}
+
+func (c *captureLogger) Contains(text string) bool {
+	c.Lock()
+	defer c.Unlock()
+	for _, line := range c.lines {
+		if strings.Contains(line, text) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSSEClient_RedactsLoggedPayloads(t *testing.T) {
+	var logger = &captureLogger{}
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var payload = `{"card": "4111-1111-1111-1111"}`
+	var httpServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+
+		var _, writeErr = w.Write([]byte("data: " + payload + "\n\n"))
+		require.NoError(t, writeErr)
+
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer httpServer.Close()
+
+	var received = make(chan sabuhp.Message, 1)
+	var socket, err = NewSSEClient2(
+		controlCtx,
+		httpServer.URL+"/payments",
+		"GET",
+		func(b sabuhp.Message, socket *SSEClient) error {
+			received <- b
+			return nil
+		},
+		&codecs.MessageJsonCodec{},
+		logger,
+		httpServer.Client(),
+		WithRawMode(),
+	)
+	require.NoError(t, err)
+
+	var message = <-received
+	require.Equal(t, payload, string(message.Bytes))
+
+	controlStopFunc()
+	socket.Wait()
+
+	require.True(t, logger.Contains(RedactPayload([]byte(payload))))
+	require.False(t, logger.Contains("4111-1111-1111-1111"))
+}
+
+func TestSSEClient_WaitReturnsWhenReconnectFails(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var httpServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+
+		var _, writeErr = w.Write([]byte("data: hello\n\n"))
+		require.NoError(t, writeErr)
+		w.(http.Flusher).Flush()
+	}))
+
+	var received = make(chan sabuhp.Message, 10)
+	var socket, err = NewSSEClient(
+		controlCtx,
+		nxid.New(),
+		2,
+		httpServer.URL,
+		"GET",
+		func(b sabuhp.Message, socket *SSEClient) error {
+			received <- b
+			return nil
+		},
+		linearBackOff,
+		&codecs.MessageJsonCodec{},
+		logger,
+		httpServer.Client(),
+	)
+	require.NoError(t, err)
+
+	<-received
+
+	// the server becomes unreachable, so reconnects fail.
+	httpServer.Close()
+
+	var waited = make(chan struct{})
+	go func() {
+		socket.Wait()
+		close(waited)
+	}()
+
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "Wait should return once reconnect retries are exhausted")
+	}
+}
+
+func TestSSEClient_CleanCloseIsNotAnError(t *testing.T) {
+	var logger = &captureLogger{}
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var httpServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+
+		var _, writeErr = w.Write([]byte("data: hello\n\n"))
+		require.NoError(t, writeErr)
+		w.(http.Flusher).Flush()
+	}))
+	defer httpServer.Close()
+
+	var received = make(chan sabuhp.Message, 1)
+	var socket, err = NewSSEClient(
+		controlCtx,
+		nxid.New(),
+		2,
+		httpServer.URL,
+		"GET",
+		func(b sabuhp.Message, socket *SSEClient) error {
+			received <- b
+			return nil
+		},
+		linearBackOff,
+		&codecs.MessageJsonCodec{},
+		logger,
+		httpServer.Client(),
+		WithoutReconnect(),
+	)
+	require.NoError(t, err)
+
+	<-received
+
+	var waited = make(chan struct{})
+	go func() {
+		socket.Wait()
+		close(waited)
+	}()
+
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "Wait should return once the server closed the stream")
+	}
+
+	require.NoError(t, socket.Err())
+	require.True(t, logger.Contains("server closed the stream"))
+	require.False(t, logger.Contains("failed to read more data"))
+}
+
+func TestSSEClient_MaxReconnectDuration(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var release = make(chan struct{})
+	var httpServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+
+		var _, writeErr = w.Write([]byte("data: hello\n\n"))
+		require.NoError(t, writeErr)
+		w.(http.Flusher).Flush()
+
+		<-release
+	}))
+
+	var maxDuration = 300 * time.Millisecond
+	var received = make(chan sabuhp.Message, 10)
+	var socket, err = NewSSEClient(
+		controlCtx,
+		nxid.New(),
+		1000,
+		httpServer.URL,
+		"GET",
+		func(b sabuhp.Message, socket *SSEClient) error {
+			received <- b
+			return nil
+		},
+		linearBackOff,
+		&codecs.MessageJsonCodec{},
+		logger,
+		httpServer.Client(),
+		WithMaxReconnectDuration(maxDuration),
+	)
+	require.NoError(t, err)
+
+	<-received
+
+	// the server becomes unreachable, so every reconnect fails and the
+	// retries alone would take far longer than the max duration.
+	var disconnectedAt = time.Now()
+	close(release)
+	httpServer.Close()
+
+	var waited = make(chan struct{})
+	go func() {
+		socket.Wait()
+		close(waited)
+	}()
+
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "Wait should return once the max reconnect duration passed")
+	}
+
+	require.True(t, time.Since(disconnectedAt) >= maxDuration)
+	require.Equal(t, ErrReconnectFailed, socket.Err())
+}
+
+func TestSSEClient_CustomHeaders(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var requests int32
+	var authorizations = make(chan string, 2)
+	var httpServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations <- r.Header.Get("Authorization")
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+
+		if atomic.AddInt32(&requests, 1) > 1 {
+			<-r.Context().Done()
+			return
+		}
+
+		var _, writeErr = w.Write([]byte("data: hello\n\n"))
+		require.NoError(t, writeErr)
+		w.(http.Flusher).Flush()
+	}))
+	defer httpServer.Close()
+
+	var header = http.Header{}
+	header.Set("Authorization", "Bearer secret-token")
+
+	var socket, err = NewSSEClientWithHeaders(
+		controlCtx,
+		nxid.New(),
+		5,
+		httpServer.URL,
+		"GET",
+		header,
+		func(b sabuhp.Message, socket *SSEClient) error {
+			return nil
+		},
+		linearBackOff,
+		&codecs.MessageJsonCodec{},
+		logger,
+		httpServer.Client(),
+	)
+	require.NoError(t, err)
+
+	require.Equal(t, "Bearer secret-token", <-authorizations)
+
+	select {
+	case authorization := <-authorizations:
+		require.Equal(t, "Bearer secret-token", authorization)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "client should have reconnected")
+	}
+
+	controlStopFunc()
+	socket.Wait()
+}
+
+func TestSSEClient_Next(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var httpServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+
+		for i := 0; i < 3; i++ {
+			var _, writeErr = w.Write([]byte(fmt.Sprintf("data: event-%d\n\n", i)))
+			require.NoError(t, writeErr)
+		}
+
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer httpServer.Close()
+
+	var socket, err = NewSSEClient2(
+		controlCtx,
+		httpServer.URL+"/users",
+		"GET",
+		nil,
+		&codecs.MessageJsonCodec{},
+		logger,
+		httpServer.Client(),
+	)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		var message, nextErr = socket.Next(controlCtx)
+		require.NoError(t, nextErr)
+		require.Equal(t, fmt.Sprintf("event-%d", i), string(message.Bytes))
+	}
+
+	// no more events, so the call ends with its context.
+	var nextCtx, nextCancel = context.WithTimeout(controlCtx, 50*time.Millisecond)
+	defer nextCancel()
+
+	var _, nextErr = socket.Next(nextCtx)
+	require.Error(t, nextErr)
+
+	controlStopFunc()
+	socket.Wait()
+
+	_, nextErr = socket.Next(context.Background())
+	require.Error(t, nextErr)
+}
+
+func TestSSEClient_NextWithHandler(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var httpServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer httpServer.Close()
+
+	var socket, err = NewSSEClient2(
+		controlCtx,
+		httpServer.URL,
+		"GET",
+		func(b sabuhp.Message, socket *SSEClient) error {
+			return nil
+		},
+		&codecs.MessageJsonCodec{},
+		logger,
+		httpServer.Client(),
+	)
+	require.NoError(t, err)
+
+	var _, nextErr = socket.Next(controlCtx)
+	require.Error(t, nextErr)
+
+	controlStopFunc()
+	socket.Wait()
+}
+
+type openRecorder struct {
+	sync.Mutex
+	opened []time.Time
+}
+
+func (o *openRecorder) SocketOpened(sabuhp.Socket) {
+	o.Lock()
+	o.opened = append(o.opened, time.Now())
+	o.Unlock()
+}
+
+func (o *openRecorder) SocketClosed(sabuhp.Socket) {}
+
+func (o *openRecorder) Opened() []time.Time {
+	o.Lock()
+	defer o.Unlock()
+	return append([]time.Time(nil), o.opened...)
+}
+
+func TestSSEServer_ReplayThrottle(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var interval = 5 * time.Millisecond
+	var sseServer = ManagedSSEServer(controlCtx, logger, nil, &codecs.MessageJsonCodec{})
+	sseServer.ReplayThrottle = NewReplayThrottle(interval, time.Millisecond)
+
+	var recorder = &openRecorder{}
+	sseServer.Stream(recorder)
+
+	var httpServer = httptest.NewServer(sseServer)
+	defer httpServer.Close()
+
+	var clients = 50
+	var started = time.Now()
+	var waiter sync.WaitGroup
+	waiter.Add(clients)
+	for i := 0; i < clients; i++ {
+		go func() {
+			defer waiter.Done()
+
+			var req, reqErr = http.NewRequestWithContext(controlCtx, "GET", httpServer.URL, nil)
+			require.NoError(t, reqErr)
+			req.Header.Set(ClientIdentificationHeader, nxid.New().String())
+			SetLastEventIds(req.Header, map[string]string{"orders": "1-0"}, 0)
+
+			var res, resErr = httpServer.Client().Do(req)
+			if resErr == nil {
+				_ = res.Body.Close()
+			}
+		}()
+	}
+
+	require.Eventually(t, func() bool {
+		return len(recorder.Opened()) == clients
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// replays were spread over the interval of each client rather
+	// than all starting at once.
+	var opened = recorder.Opened()
+	var last = opened[len(opened)-1]
+	require.True(t, last.Sub(started) >= time.Duration(clients-1)*interval)
+
+	controlStopFunc()
+	waiter.Wait()
+}
+
+func TestSSEServer_ReplaysMissedEvents(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var codec = &codecs.MessageJsonCodec{}
+	var sseServer = ManagedSSEServer(controlCtx, logger, nil, codec)
+	sseServer.Replay = NewReplayBuffer(2)
+
+	var service = &sendOnOpen{opened: make(chan sabuhp.Socket, 3)}
+	sseServer.Stream(service)
+
+	var httpServer = httptest.NewServer(sseServer)
+	defer httpServer.Close()
+
+	var received = make(chan sabuhp.Message, 10)
+	var clients [2]*SSEClient
+	var sockets [2]*SSESocket
+	for i := range clients {
+		var client, err = NewSSEClient2(
+			controlCtx,
+			httpServer.URL,
+			"GET",
+			func(b sabuhp.Message, socket *SSEClient) error {
+				received <- b
+				return nil
+			},
+			codec,
+			logger,
+			httpServer.Client(),
+		)
+		require.NoError(t, err)
+		clients[i] = client
+		sockets[i] = (<-service.opened).(*SSESocket)
+	}
+
+	var orderMsg = func(order string) sabuhp.Message {
+		var msg = testingutils.Msg(sabuhp.T("orders"), order, "me")
+		msg.Id = nxid.New()
+		return msg
+	}
+
+	// the same message has the same id on every connection.
+	var first = orderMsg("order-1")
+	sockets[0].Send(first)
+	sockets[1].Send(first)
+	<-received
+	<-received
+
+	var firstId = clients[0].LastEventIds()["orders"]
+	require.NotEmpty(t, firstId)
+	require.Equal(t, firstId, clients[1].LastEventIds()["orders"])
+
+	// a client reconnecting after the first message is replayed the ones
+	// written since, and only the ones the buffer still holds.
+	sockets[0].Send(orderMsg("order-2"), orderMsg("order-3"), orderMsg("order-4"))
+	for i := 0; i < 3; i++ {
+		<-received
+	}
+
+	var req, reqErr = http.NewRequestWithContext(controlCtx, "GET", httpServer.URL, nil)
+	require.NoError(t, reqErr)
+	req.Header.Set(ClientIdentificationHeader, nxid.New().String())
+	SetLastEventIds(req.Header, map[string]string{"orders": firstId}, 0)
+
+	var res, resErr = httpServer.Client().Do(req)
+	require.NoError(t, resErr)
+	defer res.Body.Close()
+
+	var reader = newEventReader(res.Body)
+	var lastId = firstId
+	for _, order := range []string{"order-3", "order-4"} {
+		var event, eventErr = reader.Next()
+		require.NoError(t, eventErr)
+
+		var msg, decodeErr = codec.Decode(event.Data)
+		require.NoError(t, decodeErr)
+		require.Equal(t, order, string(msg.Bytes))
+
+		require.True(t, event.Id > lastId)
+		lastId = event.Id
+	}
+	require.Equal(t, clients[0].LastEventIds()["orders"], lastId)
+
+	controlStopFunc()
+	for _, client := range clients {
+		client.Wait()
+	}
+}
+
+func TestReplayThrottle_Wait(t *testing.T) {
+	var throttle = NewReplayThrottle(time.Hour, 0)
+	require.NoError(t, throttle.Wait(context.Background()))
+
+	var ctx, canceler = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer canceler()
+	require.Error(t, throttle.Wait(ctx))
+}
+
+type sendOnOpen struct {
+	opened chan sabuhp.Socket
+}
+
+func (s *sendOnOpen) SocketOpened(socket sabuhp.Socket) {
+	s.opened <- socket
+}
+
+func (s *sendOnOpen) SocketClosed(sabuhp.Socket) {}
+
+func TestSSEServer_AssignsEventIds(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var codec = &codecs.MessageJsonCodec{}
+	var sseServer = ManagedSSEServer(controlCtx, logger, nil, codec)
+
+	var service = &sendOnOpen{opened: make(chan sabuhp.Socket, 2)}
+	sseServer.Stream(service)
+
+	var httpServer = httptest.NewServer(sseServer)
+	defer httpServer.Close()
+
+	var received = make(chan sabuhp.Message, 10)
+	var socket, err = NewSSEClient2(
+		controlCtx,
+		httpServer.URL,
+		"GET",
+		func(b sabuhp.Message, socket *SSEClient) error {
+			received <- b
+			return nil
+		},
+		codec,
+		logger,
+		httpServer.Client(),
+	)
+	require.NoError(t, err)
+
+	var first = (<-service.opened).(*SSESocket)
+	first.Send(
+		testingutils.Msg(sabuhp.T("orders"), "order-1", "me"),
+		testingutils.Msg(sabuhp.T("users"), "user-1", "me"),
+		testingutils.Msg(sabuhp.T("orders"), "order-2", "me"),
+	)
+	for i := 0; i < 3; i++ {
+		<-received
+	}
+
+	var sent = map[string]string{"orders": "2", "users": "1"}
+	require.Equal(t, sent, first.SentEventIds())
+	require.Equal(t, sent, socket.LastEventIds())
+
+	// the client echoes the ids on reconnect and the sequences continue.
+	first.Stop()
+
+	var second = (<-service.opened).(*SSESocket)
+	require.Equal(t, sent, second.LastEventIds())
+
+	second.Send(testingutils.Msg(sabuhp.T("orders"), "order-3", "me"))
+	<-received
+	require.Equal(t, "3", socket.LastEventIds()["orders"])
+
+	controlStopFunc()
+	socket.Wait()
+}
+
+func TestSSEServer_EvictsSlowClients(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var codec = &codecs.MessageJsonCodec{}
+	var sseServer = ManagedSSEServer(controlCtx, logger, nil, codec)
+	sseServer.WriteQueueSize = 4
+
+	var evicted = make(chan SlowClient, 1)
+	sseServer.OnSlowClient = func(client SlowClient) {
+		evicted <- client
+	}
+
+	var service = &sendOnOpen{opened: make(chan sabuhp.Socket, 2)}
+	sseServer.Stream(service)
+
+	var httpServer = httptest.NewServer(sseServer)
+	defer httpServer.Close()
+
+	// the stalled client connects but never reads its stream.
+	var slowId = nxid.New().String()
+	var conn, dialErr = net.Dial("tcp", httpServer.Listener.Addr().String())
+	require.NoError(t, dialErr)
+	defer conn.Close()
+
+	var _, writeErr = fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: localhost\r\n%s: %s\r\n\r\n", ClientIdentificationHeader, slowId)
+	require.NoError(t, writeErr)
+	var slow = (<-service.opened).(*SSESocket)
+
+	var received = make(chan sabuhp.Message, 10)
+	var client, err = NewSSEClient2(
+		controlCtx,
+		httpServer.URL,
+		"GET",
+		func(b sabuhp.Message, socket *SSEClient) error {
+			received <- b
+			return nil
+		},
+		codec,
+		logger,
+		httpServer.Client(),
+	)
+	require.NoError(t, err)
+	var fast = (<-service.opened).(*SSESocket)
+
+	var payload = strings.Repeat("a", 64*1024)
+	var send = func() {
+		var msg = testingutils.Msg(sabuhp.T("orders"), payload, "me")
+		slow.Send(msg)
+		fast.Send(msg)
+
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "fast client should keep receiving")
+		}
+	}
+
+	var slowClient SlowClient
+sending:
+	for i := 0; i < 2000; i++ {
+		send()
+		select {
+		case slowClient = <-evicted:
+			break sending
+		default:
+		}
+	}
+
+	require.Equal(t, slowId, slowClient.ClientId)
+	require.Equal(t, 4, slowClient.QueueSize)
+	require.Equal(t, int64(1), sseServer.Evictions())
+
+	// the fast client is unaffected by the eviction.
+	for i := 0; i < 10; i++ {
+		send()
+	}
+
+	controlStopFunc()
+	client.Wait()
+}
+
+func TestSSEServer_ConnectionMetrics(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var codec = &codecs.MessageJsonCodec{}
+	var sseServer = ManagedSSEServer(controlCtx, logger, nil, codec)
+	sseServer.WriteQueueSize = 16
+
+	var service = &sendOnOpen{opened: make(chan sabuhp.Socket, 1)}
+	sseServer.Stream(service)
+
+	var httpServer = httptest.NewServer(sseServer)
+	defer httpServer.Close()
+
+	// the stalled client connects but never reads its stream.
+	var slowId = nxid.New().String()
+	var conn, dialErr = net.Dial("tcp", httpServer.Listener.Addr().String())
+	require.NoError(t, dialErr)
+	defer conn.Close()
+
+	var _, writeErr = fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: localhost\r\n%s: %s\r\n\r\n", ClientIdentificationHeader, slowId)
+	require.NoError(t, writeErr)
+	var slow = (<-service.opened).(*SSESocket)
+
+	require.Eventually(t, func() bool {
+		var _, ok = sseServer.ConnectionMetrics()[slowId]
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// writes go through till the connection's buffers are full, then
+	// messages back up in the write queue.
+	var payload = strings.Repeat("a", 1024)
+	var sent int64
+	for sent = 0; sent < 100000 && slow.Metrics().QueueDepth < 16; sent++ {
+		slow.Send(testingutils.Msg(sabuhp.T("orders"), payload, "me"))
+	}
+
+	var metrics = sseServer.ConnectionMetrics()[slowId]
+	require.Equal(t, slowId, metrics.ClientId)
+	require.Equal(t, 16, metrics.QueueSize)
+	require.Equal(t, 16, metrics.QueueDepth)
+	require.Equal(t, int64(0), metrics.Dropped)
+	require.True(t, metrics.EventsSent > 0)
+	require.True(t, metrics.EventsSent < sent)
+	require.True(t, metrics.BytesWritten > metrics.EventsSent*1024)
+
+	// a message sent to the full queue is dropped, as are the queued
+	// ones once the client is evicted.
+	slow.Send(testingutils.Msg(sabuhp.T("orders"), payload, "me"))
+	require.True(t, slow.Metrics().Dropped >= 1)
+	require.Eventually(t, func() bool {
+		return sseServer.Evictions() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSSEServer_StreamHeaders(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var sseServer = ManagedSSEServer(controlCtx, logger, nil, &codecs.MessageJsonCodec{})
+
+	var httpServer = httptest.NewServer(sseServer)
+	defer httpServer.Close()
+
+	var req, reqErr = http.NewRequestWithContext(controlCtx, "GET", httpServer.URL, nil)
+	require.NoError(t, reqErr)
+	req.Header.Set(ClientIdentificationHeader, nxid.New().String())
+
+	var res, resErr = httpServer.Client().Do(req)
+	require.NoError(t, resErr)
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	require.Equal(t, "no-cache", res.Header.Get("Cache-Control"))
+	require.Equal(t, "no", res.Header.Get("X-Accel-Buffering"))
+	require.Equal(t, req.Header.Get(ClientIdentificationHeader), res.Header.Get(ClientIdentificationHeader))
+}
+
+func TestSSEServer_MetadataFields(t *testing.T) {
+	var logger = &testingutils.LoggerPub{}
+	var controlCtx, controlStopFunc = context.WithCancel(context.Background())
+	defer controlStopFunc()
+
+	var codec = &codecs.MessageJsonCodec{}
+	var sseServer = ManagedSSEServer(controlCtx, logger, nil, codec)
+	sseServer.MetadataFields = true
+
+	var service = &sendOnOpen{opened: make(chan sabuhp.Socket, 2)}
+	sseServer.Stream(service)
+
+	var httpServer = httptest.NewServer(sseServer)
+	defer httpServer.Close()
+
+	// raw mode never decodes the message, so its metadata can only come
+	// from the field lines.
+	var received = make(chan sabuhp.Message, 1)
+	var socket, err = NewSSEClient2(
+		controlCtx,
+		httpServer.URL,
+		"GET",
+		func(b sabuhp.Message, socket *SSEClient) error {
+			received <- b
+			return nil
+		},
+		codec,
+		logger,
+		httpServer.Client(),
+		WithRawMode(),
+	)
+	require.NoError(t, err)
+
+	var msg = testingutils.Msg(sabuhp.T("orders"), "order-1", "me")
+	msg.Metadata = map[string]string{
+		"tenant":    "acme",
+		"route key": "eu: west\nzone",
+	}
+
+	var server = (<-service.opened).(*SSESocket)
+	server.Send(msg)
+
+	select {
+	case got := <-received:
+		require.Equal(t, "acme", got.Metadata["tenant"])
+		require.Equal(t, "eu: west\nzone", got.Metadata["route key"])
+	case <-time.After(2 * time.Second):
+		t.Fatal("message was not received")
+	}
+
+	controlStopFunc()
+	socket.Wait()
+}
+
+func TestSSESocket_SendsRacingStopAreResolved(t *testing.T) {
+	var recorder = httptest.NewRecorder()
+	var req = httptest.NewRequest(http.MethodGet, "/events", nil)
+	var socket = NewSSESocket(
+		"client",
+		context.Background(),
+		req,
+		recorder,
+		sabuhp.Params{},
+		&codecs.MessageJsonCodec{},
+		&testingutils.LoggerPub{},
+		nil,
+	)
+	socket.flusher = recorder
+
+	socket.waiter.Add(1)
+	go socket.writeQueue()
+
+	var futures = make(chan *nthen.Future, 400)
+	var senders sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		senders.Add(1)
+		go func() {
+			defer senders.Done()
+			for j := 0; j < 100; j++ {
+				var msg = sabuhp.NewMessage(sabuhp.T("hello"), "me", []byte("world"))
+				msg.Future = nthen.NewFuture()
+				futures <- msg.Future
+				socket.Send(msg)
+			}
+		}()
+	}
+
+	socket.Stop()
+	senders.Wait()
+	socket.Wait()
+	close(futures)
+
+	// every message was either written or failed, none was left queued
+	// after the drain of the stopped socket.
+	for future := range futures {
+		var resolved = make(chan struct{})
+		go func(future *nthen.Future) {
+			future.Wait()
+			close(resolved)
+		}(future)
+		select {
+		case <-resolved:
+		case <-time.After(time.Second):
+			require.Fail(t, "message sent while stopping was never resolved")
+		}
+	}
+	require.Zero(t, len(socket.queue))
+}
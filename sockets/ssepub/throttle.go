@@ -0,0 +1,65 @@
+package ssepub
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+)
+
+// ReplayThrottle paces the start of replays requested by reconnecting
+// clients, so a crowd of clients reconnecting at once, e.g. after a
+// server restart, is spread out rather than replayed all at once.
+//
+// Replays start at least Interval apart, each delayed further by a
+// random duration up to Jitter.
+type ReplayThrottle struct {
+	Interval time.Duration
+	Jitter   time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// NewReplayThrottle returns a ReplayThrottle starting a replay every
+// interval with up to jitter of extra random delay.
+func NewReplayThrottle(interval time.Duration, jitter time.Duration) *ReplayThrottle {
+	return &ReplayThrottle{Interval: interval, Jitter: jitter}
+}
+
+// Wait blocks till the caller's turn to replay, returning an error if
+// the context is done before.
+func (t *ReplayThrottle) Wait(ctx context.Context) error {
+	var delay = t.reserve()
+	if delay <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return nerror.WrapOnly(ctx.Err())
+	case <-time.After(delay):
+		return nil
+	}
+}
+
+// reserve books the next replay slot, returning the delay till it.
+func (t *ReplayThrottle) reserve() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var now = time.Now()
+	var slot = t.next
+	if slot.Before(now) {
+		slot = now
+	}
+	t.next = slot.Add(t.Interval)
+
+	var delay = slot.Sub(now)
+	if t.Jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(t.Jitter)))
+	}
+	return delay
+}
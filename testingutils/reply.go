@@ -0,0 +1,106 @@
+package testingutils
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ewe-studios/sabuhp"
+
+	"github.com/influx6/npkg/nxid"
+)
+
+// ImmediateFuture returns a ReplyFuture already resolved with the reply.
+func ImmediateFuture(reply sabuhp.Message) *sabuhp.ReplyFuture {
+	var ft = sabuhp.NewReplyFuture()
+	ft.WithReply(reply)
+	return ft
+}
+
+// FailedFuture returns a ReplyFuture already resolved with the error.
+func FailedFuture(err error) *sabuhp.ReplyFuture {
+	var ft = sabuhp.NewReplyFuture()
+	ft.WithError(err)
+	return ft
+}
+
+var _ sabuhp.MessageBus = (*ReplyBus)(nil)
+
+type seededReply struct {
+	reply sabuhp.Message
+	err   error
+}
+
+// ReplyBus is a MessageBus for unit tests of request/reply logic, its
+// SendForReply returns the reply or error seeded for the topic of the
+// first message synchronously, without a broker. Requests to topics
+// without a seeded reply resolve with sabuhp.ErrReplyTimeout.
+//
+// All messages sent are recorded and returned by Sent.
+type ReplyBus struct {
+	mu      sync.Mutex
+	replies map[string]seededReply
+	sent    []sabuhp.Message
+}
+
+// ReplyWith seeds the reply of requests sent to the topic.
+func (r *ReplyBus) ReplyWith(topic string, reply sabuhp.Message) {
+	r.seed(topic, seededReply{reply: reply})
+}
+
+// FailWith seeds the error of requests sent to the topic.
+func (r *ReplyBus) FailWith(topic string, err error) {
+	r.seed(topic, seededReply{err: err})
+}
+
+func (r *ReplyBus) seed(topic string, reply seededReply) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.replies == nil {
+		r.replies = map[string]seededReply{}
+	}
+	r.replies[topic] = reply
+}
+
+// Sent returns all messages sent with Send or SendForReply.
+func (r *ReplyBus) Sent() []sabuhp.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sent = make([]sabuhp.Message, len(r.sent))
+	copy(sent, r.sent)
+	return sent
+}
+
+func (r *ReplyBus) Send(data ...sabuhp.Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, data...)
+}
+
+func (r *ReplyBus) SendForReply(tm time.Duration, fromTopic sabuhp.Topic, replyGroup string, data ...sabuhp.Message) *sabuhp.ReplyFuture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, data...)
+
+	if len(data) == 0 {
+		return FailedFuture(sabuhp.ErrReplyTimeout)
+	}
+
+	var seeded, ok = r.replies[data[0].Topic.String()]
+	if !ok {
+		return FailedFuture(sabuhp.ErrReplyTimeout)
+	}
+	if seeded.err != nil {
+		return FailedFuture(seeded.err)
+	}
+
+	var reply = seeded.reply
+	if reply.CorrelationId.IsNil() {
+		reply.CorrelationId = data[0].Id
+	}
+	return ImmediateFuture(reply)
+}
+
+// Listen returns a channel for the topic, handlers are never called.
+func (r *ReplyBus) Listen(topic string, grp string, handler sabuhp.TransportResponse) sabuhp.Channel {
+	return SubChannel{I: nxid.New(), T: topic, G: grp, Handler: handler}
+}
@@ -0,0 +1,47 @@
+package testingutils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nxid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ewe-studios/sabuhp"
+)
+
+func TestImmediateFuture(t *testing.T) {
+	var reply = Msg(sabuhp.T("hello"), "world", "me")
+
+	var resolved, resolvedErr = ImmediateFuture(reply).Get()
+	require.NoError(t, resolvedErr)
+	require.Equal(t, reply, resolved)
+
+	var failure = nerror.New("request failed")
+	var _, failedErr = FailedFuture(failure).Get()
+	require.Equal(t, failure, failedErr)
+}
+
+func TestReplyBus(t *testing.T) {
+	var bus ReplyBus
+	bus.ReplyWith("ask", Msg(sabuhp.T("ask-reply"), "answer", "responder"))
+	var failure = nerror.New("responder failed")
+	bus.FailWith("broken", failure)
+
+	var request = Msg(sabuhp.T("ask"), "question", "me")
+	request.Id = nxid.New()
+	var reply, replyErr = bus.SendForReply(time.Second, sabuhp.T("me"), "", request).Get()
+	require.NoError(t, replyErr)
+	require.Equal(t, "answer", string(reply.Bytes))
+	require.Equal(t, request.Id, reply.CorrelationId)
+
+	var _, brokenErr = bus.SendForReply(time.Second, sabuhp.T("me"), "", Msg(sabuhp.T("broken"), "question", "me")).Get()
+	require.Equal(t, failure, brokenErr)
+
+	var _, unseededErr = bus.SendForReply(time.Second, sabuhp.T("me"), "", Msg(sabuhp.T("unknown"), "question", "me")).Get()
+	require.Equal(t, sabuhp.ErrReplyTimeout, unseededErr)
+
+	bus.Send(Msg(sabuhp.T("notify"), "event", "me"))
+	require.Len(t, bus.Sent(), 4)
+}
@@ -7,14 +7,20 @@ import (
 	"github.com/ewe-studios/sabuhp"
 
 	"github.com/influx6/npkg/njson"
+	"github.com/influx6/npkg/nxid"
 )
 
 type SubChannel struct {
+	I       nxid.ID
 	T       string
 	G       string
 	Handler sabuhp.TransportResponse
 }
 
+func (s SubChannel) ID() nxid.ID {
+	return s.I
+}
+
 func (s SubChannel) Topic() string {
 	return s.T
 }
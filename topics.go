@@ -0,0 +1,86 @@
+package sabuhp
+
+import (
+	"strings"
+
+	"github.com/influx6/npkg/nstr"
+)
+
+const (
+	// DefaultTopicSeparator separates the segments of topics, e.g. of
+	// "env.service.topic_name" created by TRS.
+	DefaultTopicSeparator = "."
+
+	// AnySegment matches any single segment of a topic in a pattern.
+	AnySegment = "*"
+
+	// AnySegments ending a pattern matches any remaining segments of a
+	// topic, including none.
+	AnySegments = "#"
+
+	replySegment = "reply"
+)
+
+// TopicHierarchy describes how topics are split into segments, so
+// topics created, replied to and matched by a bus agree on one
+// separator.
+type TopicHierarchy struct {
+	// Separator separates the segments of topics, defaults to
+	// DefaultTopicSeparator.
+	Separator string
+}
+
+func (h TopicHierarchy) separator() string {
+	if h.Separator == "" {
+		return DefaultTopicSeparator
+	}
+	return h.Separator
+}
+
+// Topic creates a topic of the hierarchy with a 20 length random string
+// suffix, like T.
+func (h TopicHierarchy) Topic(segments ...string) Topic {
+	return h.Apply(NewTopic(h.Join(segments...), nstr.RandomAlphabets(20)))
+}
+
+// Apply returns the topic using the separator of the hierarchy, the
+// separator of topics of the default hierarchy is left empty.
+func (h TopicHierarchy) Apply(topic Topic) Topic {
+	if h.separator() == DefaultTopicSeparator {
+		return topic
+	}
+	return topic.WithSeparator(h.separator())
+}
+
+// Join returns the topic of the segments.
+func (h TopicHierarchy) Join(segments ...string) string {
+	return strings.Join(segments, h.separator())
+}
+
+// Segments returns the segments of the topic.
+func (h TopicHierarchy) Segments(topic string) []string {
+	return strings.Split(topic, h.separator())
+}
+
+// Match returns true if the topic matches the pattern, segment by
+// segment. An AnySegment segment of the pattern matches any segment and
+// an AnySegments last segment matches all remaining ones, e.g. with "/"
+// "orders/*/created" matches "orders/eu/created" and "orders/#" matches
+// "orders" and "orders/eu/created".
+func (h TopicHierarchy) Match(pattern string, topic string) bool {
+	var patternSegments = h.Segments(pattern)
+	var topicSegments = h.Segments(topic)
+
+	for index, segment := range patternSegments {
+		if segment == AnySegments && index == len(patternSegments)-1 {
+			return true
+		}
+		if index >= len(topicSegments) {
+			return false
+		}
+		if segment != AnySegment && segment != topicSegments[index] {
+			return false
+		}
+	}
+	return len(patternSegments) == len(topicSegments)
+}
@@ -0,0 +1,32 @@
+package sabuhp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopicHierarchy(t *testing.T) {
+	var slashes = TopicHierarchy{Separator: "/"}
+
+	var topic = slashes.Topic("orders", "eu", "created")
+	require.Equal(t, "orders/eu/created", topic.String())
+	require.Equal(t, []string{"orders", "eu", "created"}, topic.Segments())
+
+	var reply = topic.ReplyTopic()
+	require.Equal(t, "orders/eu/created/reply/"+topic.R, reply.String())
+	require.Equal(t, "/", reply.Separator())
+
+	require.True(t, slashes.Match("orders/*/created", "orders/eu/created"))
+	require.True(t, slashes.Match("orders/#", "orders/eu/created"))
+	require.True(t, slashes.Match("orders/#", "orders"))
+	require.True(t, slashes.Match("orders/eu/created/reply/*", reply.String()))
+	require.False(t, slashes.Match("orders/*", "orders/eu/created"))
+	require.False(t, slashes.Match("orders/*/created", "orders.eu.created"))
+
+	// topics of the default hierarchy keep their reply topics.
+	var dotted = NewTopic("orders.eu.created", "suffix")
+	require.Equal(t, []string{"orders", "eu", "created"}, dotted.Segments())
+	require.Equal(t, "orders.eu.created-reply-suffix", dotted.ReplyTopic().String())
+	require.Equal(t, dotted, TopicHierarchy{}.Apply(dotted))
+}
@@ -0,0 +1,33 @@
+package sabuhp
+
+import (
+	"github.com/influx6/npkg/nxid"
+)
+
+// TraceIdMetadata is the Metadata key holding the trace id of a message,
+// kept across every hop of its journey, so it can be followed through the
+// logs of all topics it passed.
+const TraceIdMetadata = "x-trace-id"
+
+// TraceId returns the trace id of the message, empty if it has none.
+func TraceId(msg Message) string {
+	return msg.Metadata[TraceIdMetadata]
+}
+
+// WithTraceId returns the message with a new trace id if it has none yet.
+//
+// The Metadata of the message is copied before the id is added, so the
+// map of the provided message is never modified.
+func WithTraceId(msg Message) Message {
+	if len(TraceId(msg)) != 0 {
+		return msg
+	}
+
+	var meta = make(Params, len(msg.Metadata)+1)
+	for key, val := range msg.Metadata {
+		meta[key] = val
+	}
+	meta[TraceIdMetadata] = nxid.New().String()
+	msg.Metadata = meta
+	return msg
+}
@@ -0,0 +1,27 @@
+package sabuhp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTraceId(t *testing.T) {
+	var msg = NewMessage(T("hello"), "me", []byte("world"))
+	msg.Metadata = Params{"key": "value"}
+	require.Empty(t, TraceId(msg))
+
+	var traced = WithTraceId(msg)
+	require.NotEmpty(t, TraceId(traced))
+	require.Equal(t, "value", traced.Metadata["key"])
+
+	// the original metadata is left untouched.
+	require.Empty(t, TraceId(msg))
+
+	// an existing trace id is kept.
+	require.Equal(t, TraceId(traced), TraceId(WithTraceId(traced)))
+
+	var noMeta = NewMessage(T("hello"), "me", []byte("world"))
+	noMeta.Metadata = nil
+	require.NotEmpty(t, TraceId(WithTraceId(noMeta)))
+}
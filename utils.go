@@ -4,8 +4,6 @@ import (
 	"log"
 	"time"
 
-	"github.com/influx6/npkg/nthen"
-
 	"github.com/influx6/npkg/njson"
 )
 
@@ -47,7 +45,7 @@ var _ MessageBus = (*BusBuilder)(nil)
 
 type BusBuilder struct {
 	SendFunc         func(data ...Message)
-	SendForReplyFunc func(tm time.Duration, from Topic, replyGroup string, data ...Message) *nthen.Future
+	SendForReplyFunc func(tm time.Duration, from Topic, replyGroup string, data ...Message) *ReplyFuture
 	ListenFunc       func(topic string, grp string, handler TransportResponse) Channel
 }
 
@@ -55,7 +53,7 @@ func (t BusBuilder) Listen(topic string, grp string, handler TransportResponse)
 	return t.ListenFunc(topic, grp, handler)
 }
 
-func (t BusBuilder) SendForReply(tm time.Duration, from Topic, replyGroup string, data ...Message) *nthen.Future {
+func (t BusBuilder) SendForReply(tm time.Duration, from Topic, replyGroup string, data ...Message) *ReplyFuture {
 	return t.SendForReplyFunc(tm, from, replyGroup, data...)
 }
 
@@ -1,5 +1,7 @@
 package utils
 
+import "github.com/influx6/npkg/nxid"
+
 type ErrorHandler struct {
 	Err error
 }
@@ -9,11 +11,16 @@ func (es *ErrorHandler) Run() error {
 }
 
 type CloseErrorChannel struct {
+	I     nxid.ID
 	T     string
 	G     string
 	Error error
 }
 
+func (es *CloseErrorChannel) ID() nxid.ID {
+	return es.I
+}
+
 func (es *CloseErrorChannel) Group() string {
 	return es.G
 }
@@ -1,11 +1,16 @@
 package utils
 
 import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
 	"github.com/ewe-studios/sabuhp"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/influx6/npkg"
@@ -86,5 +91,89 @@ func DoRequest(
 		}
 		return nil, nil, nerror.WrapOnly(&requestErr)
 	}
+
+	DecompressResponse(response)
 	return req, response, nil
 }
+
+// DecompressResponse replaces the body of the response with a reader
+// which decompresses it if the server set a gzip or deflate Content-Encoding.
+//
+// The http.Transport only decompresses responses when it set the
+// Accept-Encoding header itself, so this covers requests which set the
+// header explicitly. Decompression starts on the first read, hence streaming
+// responses (e.g server sent events) are never blocked by it.
+func DecompressResponse(response *http.Response) {
+	var encoding = strings.ToLower(strings.TrimSpace(response.Header.Get("Content-Encoding")))
+	if encoding != "gzip" && encoding != "deflate" {
+		return
+	}
+
+	response.Body = &decompressReader{encoding: encoding, body: response.Body}
+	response.Header.Del("Content-Encoding")
+	response.Header.Del("Content-Length")
+	response.ContentLength = -1
+	response.Uncompressed = true
+}
+
+type decompressReader struct {
+	encoding string
+	body     io.ReadCloser
+	reader   io.ReadCloser
+	err      error
+}
+
+func (d *decompressReader) Read(p []byte) (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+
+	if d.reader == nil {
+		switch d.encoding {
+		case "gzip":
+			var gzipReader, gzipErr = gzip.NewReader(d.body)
+			if gzipErr != nil {
+				d.err = nerror.WrapOnly(gzipErr)
+				return 0, d.err
+			}
+			d.reader = gzipReader
+		default:
+			var deflateReader, deflateErr = newDeflateReader(d.body)
+			if deflateErr != nil {
+				d.err = deflateErr
+				return 0, d.err
+			}
+			d.reader = deflateReader
+		}
+	}
+
+	return d.reader.Read(p)
+}
+
+// newDeflateReader returns a reader of a deflate Content-Encoding, which
+// is zlib wrapped deflate (RFC 9110, 8.4.1.2), falling back to raw
+// deflate sent by some servers when the zlib header is missing.
+func newDeflateReader(body io.Reader) (io.ReadCloser, error) {
+	var buffered = bufio.NewReader(body)
+	if header, peekErr := buffered.Peek(2); peekErr == nil && isZlibHeader(header) {
+		var zlibReader, zlibErr = zlib.NewReader(buffered)
+		if zlibErr != nil {
+			return nil, nerror.WrapOnly(zlibErr)
+		}
+		return zlibReader, nil
+	}
+	return flate.NewReader(buffered), nil
+}
+
+// isZlibHeader returns true if the bytes are a zlib header using the
+// deflate method with a valid check value.
+func isZlibHeader(header []byte) bool {
+	return header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0
+}
+
+func (d *decompressReader) Close() error {
+	if d.reader != nil {
+		_ = d.reader.Close()
+	}
+	return d.body.Close()
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoRequest_Decompression(t *testing.T) {
+	var payload = bytes.Repeat([]byte("hello world "), 100)
+
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body bytes.Buffer
+		switch r.Header.Get("Accept-Encoding") {
+		case "gzip":
+			var writer = gzip.NewWriter(&body)
+			_, _ = writer.Write(payload)
+			_ = writer.Close()
+		case "deflate":
+			var writer = zlib.NewWriter(&body)
+			_, _ = writer.Write(payload)
+			_ = writer.Close()
+		default:
+			body.Write(payload)
+		}
+
+		if encoding := r.Header.Get("Accept-Encoding"); encoding != "" {
+			w.Header().Set("Content-Encoding", encoding)
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(body.Bytes())
+	}))
+	defer server.Close()
+
+	for _, encoding := range []string{"gzip", "deflate", ""} {
+		t.Run("encoding_"+encoding, func(t *testing.T) {
+			var headers = http.Header{}
+			if encoding != "" {
+				headers.Set("Accept-Encoding", encoding)
+			}
+
+			var _, response, err = DoRequest(context.Background(), server.Client(), "GET", server.URL, nil, headers)
+			require.NoError(t, err)
+
+			var body, readErr = ioutil.ReadAll(response.Body)
+			require.NoError(t, readErr)
+			require.NoError(t, response.Body.Close())
+
+			require.Equal(t, payload, body)
+			require.Empty(t, response.Header.Get("Content-Encoding"))
+		})
+	}
+}
+
+func TestDecompressResponse_RawDeflate(t *testing.T) {
+	var payload = bytes.Repeat([]byte("hello world "), 100)
+
+	// some servers send raw deflate without the zlib wrapper.
+	var body bytes.Buffer
+	var writer, _ = flate.NewWriter(&body, flate.DefaultCompression)
+	_, _ = writer.Write(payload)
+	_ = writer.Close()
+
+	var response = &http.Response{
+		Header: http.Header{"Content-Encoding": []string{"deflate"}},
+		Body:   ioutil.NopCloser(&body),
+	}
+	DecompressResponse(response)
+
+	var decompressed, readErr = ioutil.ReadAll(response.Body)
+	require.NoError(t, readErr)
+	require.Equal(t, payload, decompressed)
+}